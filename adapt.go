@@ -0,0 +1,135 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const protoadaptPath = "google.golang.org/protobuf/protoadapt"
+
+func rewriteMessageV1(c *funcCall) bool {
+	return rewriteAdapt(c, "MessageV1Of", hasProtoReflect)
+}
+
+func rewriteMessageV2(c *funcCall) bool {
+	return rewriteAdapt(c, "MessageV2Of", hasV1Methods)
+}
+
+// rewriteAdapt rewrites a call of proto.MessageV1 or proto.MessageV2 to the
+// protoadapt function of the given name, if the message it converts
+// implements the interface the function takes, which ok tells.
+func rewriteAdapt(c *funcCall, name string, ok func(types.Type) bool) bool {
+	msg := "proto." + c.sel.Sel.Name + " should be replaced with protoadapt." + name
+	if c.call == nil || len(c.call.Args) != 1 {
+		report.Report(c.pass, c.sel, msg)
+		return false
+	}
+	if !ok(c.pass.TypesInfo.TypeOf(c.call.Args[0])) {
+		// The v1 functions take any value, but protoadapt only messages of
+		// the API it adapts.
+		report.Report(c.pass, c.call, msg+", which only takes messages of the API it adapts")
+		return false
+	}
+	c.rw.require(c.pass, protoadaptPath)
+	repl := c.rw.qualifier(c.pass, protoadaptPath) + "." + name
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Use protoadapt."+name, edit.ReplaceWithString(c.pass.Fset, c.sel, repl))))
+	return true
+}
+
+// hasV1Methods reports whether values of type t implement the v1 message
+// interface, protoiface.MessageV1.
+func hasV1Methods(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	for _, name := range []string{"Reset", "String", "ProtoMessage"} {
+		if obj, _, _ := types.LookupFieldOrMethod(t, false, nil, name); obj == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// checkAdapt reports, in the packages using both the v1 and the v2 proto
+// packages, the type assertions of messages from the interface of one API
+// to that of the other, which fail for the messages only generated for the
+// first. The assertions are rewritten to the protoadapt functions, which
+// wrap such messages instead.
+func checkAdapt(pass *analysis.Pass) (interface{}, error) {
+	v1, v2 := false, false
+	for _, imp := range pass.Pkg.Imports() {
+		switch pkgPath(imp) {
+		case protoV1Path:
+			v1 = true
+		case protoV2Path:
+			v2 = true
+		}
+	}
+	if !v1 || !v2 {
+		return nil, nil
+	}
+	forEachFile(pass, func(pass *analysis.Pass, file *ast.File) {
+		type adaptAssert struct {
+			assert *ast.TypeAssertExpr
+			name   string
+		}
+		var fixable []adaptAssert
+		ast.Inspect(file, func(node ast.Node) bool {
+			assert, ok := node.(*ast.TypeAssertExpr)
+			if !ok || assert.Type == nil {
+				return true
+			}
+			from, to := pass.TypesInfo.TypeOf(assert.X), pass.TypesInfo.TypeOf(assert.Type)
+			if !types.IsInterface(from) || !types.IsInterface(to) {
+				return true
+			}
+			var name string
+			switch {
+			case hasV1Methods(from) && !hasProtoReflect(from) && hasProtoReflect(to) && !hasV1Methods(to):
+				name = "MessageV2Of"
+			case hasProtoReflect(from) && !hasV1Methods(from) && hasV1Methods(to) && !hasProtoReflect(to):
+				name = "MessageV1Of"
+			default:
+				return true
+			}
+			path, _ := astutil.PathEnclosingInterval(file, assert.Pos(), assert.End())
+			if assign, ok := path[1].(*ast.AssignStmt); ok && len(assign.Lhs) == 2 {
+				// The comma-ok form tells the messages of the other API
+				// apart, as protoadapt does not.
+				report.Report(pass, assert, "the type assertion fails for the messages not generated for the API it asserts; protoadapt."+name+" adapts them")
+				return true
+			}
+			fixable = append(fixable, adaptAssert{assert, name})
+			return true
+		})
+		if len(fixable) == 0 {
+			return
+		}
+
+		// The fixes depend on each other like those of the other checks, so
+		// the first one makes the import edits they share.
+		rw := stdImportRewrite(pass, file, "", len(fixable), protoadaptPath)
+		for i, a := range fixable {
+			msg := "the type assertion fails for the messages not generated for the API it asserts, so it should be replaced with protoadapt." + a.name
+			if rw == nil {
+				report.Report(pass, a.assert, msg)
+				continue
+			}
+			call := rw.qualifier(pass, protoadaptPath) + "." + a.name + "(" + report.Render(pass, a.assert.X) + ")"
+			edits := []analysis.TextEdit{edit.ReplaceWithString(pass.Fset, a.assert, call)}
+			if i == 0 {
+				edits = append(edits, rw.importEdits(pass)...)
+			}
+			report.Report(pass, a.assert, msg, report.Fixes(edit.Fix("Use protoadapt."+a.name, edits...)))
+		}
+	})
+	return nil, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ssa"
+)
+
+// checkAliasing reports the mutations of the sub-messages that getters
+// return from shared messages: those received from channels, looked up in
+// maps or sync.Maps, as caches are, or passed as parameters documented as
+// shared. The getters return the sub-messages themselves, not copies, so
+// the mutations race with the other users of the shared messages; the v2
+// runtime, which caches the sizes of messages and marshals them lazily,
+// makes such races show. The check is heuristic, so it is only run when
+// asked to with -check=aliasing.
+func checkAliasing(pass *analysis.Pass) (interface{}, error) {
+	skipped := map[*ast.File]bool{}
+	for _, file := range pass.Files {
+		skipped[file] = skipFile(pass, file)
+	}
+	for _, fn := range srcFuncs(pass, skipped) {
+		checkAliasingFunc(pass, fn)
+	}
+	return nil, nil
+}
+
+// sharedValue describes where a value of a function comes from.
+type sharedValue struct {
+	// from describes the shared message the value is, or is a sub-message
+	// of, like "received from a channel".
+	from string
+
+	// getter is the name of the getter returning the value, or "" if it is
+	// the shared message itself.
+	getter string
+}
+
+func checkAliasingFunc(pass *analysis.Pass, fn *ssa.Function) {
+	shared := map[ssa.Value]sharedValue{}
+	for _, p := range sharedParams(fn) {
+		shared[p] = sharedValue{from: "passed as the shared parameter " + p.Name()}
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if v, ok := instr.(ssa.Value); ok {
+				if from := sharedSource(v); from != "" {
+					shared[v] = sharedValue{from: from}
+				}
+			}
+		}
+	}
+
+	// Propagate the shared values through the getters, and the type
+	// assertions of them, until no more are found.
+	for changed := true; changed; {
+		changed = false
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				v, ok := instr.(ssa.Value)
+				if !ok {
+					continue
+				}
+				if _, ok := shared[v]; ok {
+					continue
+				}
+				switch v := v.(type) {
+				case *ssa.TypeAssert:
+					if s, ok := shared[v.X]; ok && !v.CommaOk {
+						shared[v] = s
+						changed = true
+					}
+				case *ssa.Extract:
+					if s, ok := shared[v.Tuple]; ok && v.Index == 0 {
+						shared[v] = s
+						changed = true
+					}
+				case *ssa.Call:
+					getter := messageGetter(v.Common())
+					if getter == "" || len(v.Call.Args) == 0 {
+						continue
+					}
+					if s, ok := shared[v.Call.Args[0]]; ok {
+						shared[v] = sharedValue{from: s.from, getter: getter}
+						changed = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			var msg ssa.Value
+			pos := instr.Pos()
+			switch instr := instr.(type) {
+			case *ssa.Store:
+				if addr, ok := instr.Addr.(*ssa.FieldAddr); ok {
+					msg = addr.X
+				}
+			case *ssa.Call:
+				msg, pos = mutatedMessage(instr.Common()), instr.Common().Pos()
+			}
+			if msg == nil {
+				continue
+			}
+			s, ok := shared[msg]
+			if !ok || s.getter == "" || !pos.IsValid() {
+				continue
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:     pos,
+				Message: "the message " + s.getter + " returns is mutated, but is part of a message " + s.from + ", which its other users may read at the same time; clone it with proto.Clone first",
+			})
+		}
+	}
+}
+
+// sharedSource describes where v comes from if it is a shared message, or
+// a tuple holding one first, or returns "".
+func sharedSource(v ssa.Value) string {
+	var t types.Type
+	var from string
+	switch v := v.(type) {
+	case *ssa.UnOp:
+		if v.Op != token.ARROW {
+			return ""
+		}
+		t, from = v.X.Type().Underlying().(*types.Chan).Elem(), "received from a channel"
+	case *ssa.Lookup:
+		m, ok := v.X.Type().Underlying().(*types.Map)
+		if !ok {
+			return ""
+		}
+		t, from = m.Elem(), "looked up in a map"
+	case *ssa.Call:
+		fn := v.Call.StaticCallee()
+		if fn == nil || fn.Object() == nil || fn.Object().Pkg() == nil || fn.Object().Pkg().Path() != "sync" {
+			return ""
+		}
+		recv := fn.Signature.Recv()
+		if recv == nil || types.TypeString(recv.Type(), nil) != "*sync.Map" || (fn.Name() != "Load" && fn.Name() != "LoadOrStore") {
+			return ""
+		}
+		// The values of sync.Maps are only known to be messages once they
+		// are asserted to be.
+		return "loaded from a sync.Map"
+	default:
+		return ""
+	}
+	if !isMessage(t) {
+		return ""
+	}
+	return from
+}
+
+// sharedParams returns the parameters of fn that its doc comment documents
+// as shared: those it names in a comment saying "shared".
+func sharedParams(fn *ssa.Function) []*ssa.Parameter {
+	decl, ok := fn.Syntax().(*ast.FuncDecl)
+	if !ok || decl.Doc == nil {
+		return nil
+	}
+	doc := decl.Doc.Text()
+	if !strings.Contains(doc, "shared") {
+		return nil
+	}
+	var params []*ssa.Parameter
+	for _, p := range fn.Params {
+		if isMessage(p.Type()) && containsWord(doc, p.Name()) {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// containsWord reports whether s contains word, not as a part of a longer
+// word.
+func containsWord(s, word string) bool {
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool {
+		return !(r == '_' || r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z')
+	}) {
+		if f == word {
+			return true
+		}
+	}
+	return false
+}
+
+// messageGetter returns the name of the method call calls if it is a getter
+// of a message returning a sub-message, like GetFoo, or "".
+func messageGetter(call *ssa.CallCommon) string {
+	fn := call.StaticCallee()
+	if fn == nil || fn.Signature.Recv() == nil || !strings.HasPrefix(fn.Name(), "Get") {
+		return ""
+	}
+	sig := fn.Signature
+	if !isMessage(sig.Recv().Type()) || sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return ""
+	}
+	res := sig.Results().At(0).Type()
+	if types.IsInterface(res) || !isMessage(res) {
+		return ""
+	}
+	return fn.Name()
+}
+
+// mutatedMessage returns the message call mutates, calling its Reset method
+// or proto.Reset or proto.Merge with it, or nil.
+func mutatedMessage(call *ssa.CallCommon) ssa.Value {
+	fn := call.StaticCallee()
+	if fn == nil || len(call.Args) == 0 {
+		return nil
+	}
+	if fn.Signature.Recv() != nil {
+		if fn.Name() == "Reset" && isMessage(fn.Signature.Recv().Type()) {
+			return call.Args[0]
+		}
+		return nil
+	}
+	if fn.Pkg == nil || (fn.Name() != "Reset" && fn.Name() != "Merge") {
+		return nil
+	}
+	switch pkgPath(fn.Pkg.Pkg) {
+	case protoV1Path, protoV2Path:
+		// The conversions of the messages to the interfaces come first.
+		if mi, ok := call.Args[0].(*ssa.MakeInterface); ok {
+			return mi.X
+		}
+	}
+	return nil
+}
+
+// fileOf returns the file of pass containing pos, or nil.
+func fileOf(pass *analysis.Pass, pos token.Pos) *ast.File {
+	for _, file := range pass.Files {
+		if file.Pos() <= pos && pos <= file.End() {
+			return file
+		}
+	}
+	return nil
+}
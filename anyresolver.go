@@ -0,0 +1,105 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/report"
+)
+
+// anyResolverNote tells what replaces the AnyResolver option of jsonpb.
+const anyResolverNote = "; protojson takes a protoregistry.MessageTypeResolver and ExtensionTypeResolver, like a *protoregistry.Types, as the Resolver option instead, and defaults to protoregistry.GlobalTypes"
+
+// mapResolverNote tells that a resolver looking up a map is likely not
+// needed.
+const mapResolverNote = "; it resolves the types from a hand-maintained map, which the global registry likely makes unnecessary, since it holds every generated message linked in"
+
+// checkAnyResolvers reports the types declared in file that implement
+// jsonpb.AnyResolver, which protojson replaces with the resolvers of
+// protoregistry.
+func checkAnyResolvers(pass *analysis.Pass, file *ast.File) {
+	jsonpb := importedPackage(pass.Pkg, jsonpbPath)
+	if jsonpb == nil {
+		return
+	}
+	obj, ok := jsonpb.Scope().Lookup("AnyResolver").(*types.TypeName)
+	if !ok {
+		return
+	}
+	iface, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		tn, ok := pass.TypesInfo.Defs[spec.Name].(*types.TypeName)
+		if !ok || types.IsInterface(tn.Type()) {
+			return true
+		}
+		if !types.Implements(tn.Type(), iface) && !types.Implements(types.NewPointer(tn.Type()), iface) {
+			return true
+		}
+		msg := spec.Name.Name + " implements jsonpb.AnyResolver" + anyResolverNote + "; register the types it resolves in a protoregistry.Types to pass instead"
+		if resolvesFromMap(pass, tn) {
+			msg += mapResolverNote
+		}
+		report.Report(pass, spec.Name, msg)
+		return true
+	})
+}
+
+// anyResolverOption returns the note of the AnyResolver option of jsonpb,
+// given its value.
+func anyResolverOption(pass *analysis.Pass, value ast.Expr) string {
+	t := pass.TypesInfo.TypeOf(value)
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if named, ok := t.(*types.Named); ok && resolvesFromMap(pass, named.Obj()) {
+		return anyResolverNote + mapResolverNote
+	}
+	return anyResolverNote
+}
+
+// resolvesFromMap reports whether the Resolve method of tn, declared in
+// the package of pass, looks the types up in a map keyed by strings.
+func resolvesFromMap(pass *analysis.Pass, tn *types.TypeName) bool {
+	if tn.Pkg() != pass.Pkg {
+		return false
+	}
+	m, _, _ := types.LookupFieldOrMethod(types.NewPointer(tn.Type()), false, tn.Pkg(), "Resolve")
+	fn, ok := m.(*types.Func)
+	if !ok {
+		return false
+	}
+	for _, file := range pass.Files {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Body == nil || pass.TypesInfo.Defs[decl.Name] != fn {
+				continue
+			}
+			found := false
+			ast.Inspect(decl.Body, func(node ast.Node) bool {
+				index, ok := node.(*ast.IndexExpr)
+				if !ok {
+					return !found
+				}
+				if m, ok := pass.TypesInfo.TypeOf(index.X).Underlying().(*types.Map); ok {
+					if b, ok := m.Key().Underlying().(*types.Basic); ok && b.Info()&types.IsString != 0 {
+						found = true
+					}
+				}
+				return !found
+			})
+			return found
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const protowirePath = "google.golang.org/protobuf/encoding/protowire"
+
+// bufferNote tells what replaces proto.Buffer.
+const bufferNote = "proto.MarshalOptions and proto.UnmarshalOptions, which append to and read from plain byte slices, or protowire for the low-level encoding"
+
+// bufferMethods maps the methods of proto.Buffer to what replaces them.
+var bufferMethods = map[string]string{
+	"Bytes":             "the byte slice marshaled messages are appended to",
+	"DebugPrint":        "protowire to walk the fields, or the String method of messages",
+	"DecodeFixed32":     "protowire.ConsumeFixed32",
+	"DecodeFixed64":     "protowire.ConsumeFixed64",
+	"DecodeGroup":       "protowire.ConsumeGroup and proto.UnmarshalOptions with Merge set",
+	"DecodeMessage":     "protowire.ConsumeBytes and proto.UnmarshalOptions with Merge set",
+	"DecodeRawBytes":    "protowire.ConsumeBytes",
+	"DecodeStringBytes": "protowire.ConsumeString",
+	"DecodeVarint":      "protowire.ConsumeVarint",
+	"DecodeZigzag32":    "protowire.ConsumeVarint and protowire.DecodeZigZag",
+	"DecodeZigzag64":    "protowire.ConsumeVarint and protowire.DecodeZigZag",
+	"EncodeFixed32":     "protowire.AppendFixed32",
+	"EncodeFixed64":     "protowire.AppendFixed64",
+	"EncodeMessage":     "protowire.AppendVarint of proto.Size and proto.MarshalOptions.MarshalAppend",
+	"EncodeRawBytes":    "protowire.AppendBytes",
+	"EncodeStringBytes": "protowire.AppendString",
+	"EncodeVarint":      "protowire.AppendVarint",
+	"EncodeZigzag32":    "protowire.AppendVarint and protowire.EncodeZigZag",
+	"EncodeZigzag64":    "protowire.AppendVarint and protowire.EncodeZigZag",
+	"Marshal":           "proto.MarshalOptions.MarshalAppend",
+	"Reset":             "a byte slice truncated to length 0",
+	"SetBuf":            "the byte slice messages are read from",
+	"SetDeterministic":  "the Deterministic field of proto.MarshalOptions",
+	"Unmarshal":         "proto.UnmarshalOptions with Merge set",
+	"Unread":            "the byte slice messages are read from",
+}
+
+// rewriteBuffer reports the references to proto.Buffer and the calls of
+// proto.NewBuffer, which have no v2 counterpart.
+func rewriteBuffer(c *funcCall) bool {
+	report.Report(c.pass, c.sel, "proto."+c.sel.Sel.Name+" has no v2 counterpart; use "+bufferNote+" instead")
+	return false
+}
+
+// rewriteEncodeVarint rewrites the calls of proto.EncodeVarint to
+// protowire.AppendVarint, appending to a nil slice.
+func rewriteEncodeVarint(c *funcCall) bool {
+	const msg = "proto.EncodeVarint should be replaced with protowire.AppendVarint"
+	if c.call == nil || len(c.call.Args) != 1 {
+		report.Report(c.pass, c.sel, msg)
+		return false
+	}
+	c.rw.require(c.pass, protowirePath)
+	repl := c.rw.qualifier(c.pass, protowirePath) + ".AppendVarint(nil, " + report.Render(c.pass, c.call.Args[0]) + ")"
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Use protowire.AppendVarint", edit.ReplaceWithString(c.pass.Fset, c.call, repl))))
+	return true
+}
+
+// rewriteSizeVarint rewrites the calls of proto.SizeVarint to
+// protowire.SizeVarint.
+func rewriteSizeVarint(c *funcCall) bool {
+	const msg = "proto.SizeVarint should be replaced with protowire.SizeVarint"
+	if c.call == nil {
+		report.Report(c.pass, c.sel, msg)
+		return false
+	}
+	c.rw.require(c.pass, protowirePath)
+	repl := c.rw.qualifier(c.pass, protowirePath) + ".SizeVarint"
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Use protowire.SizeVarint", edit.ReplaceWithString(c.pass.Fset, c.sel, repl))))
+	return true
+}
+
+// rewriteDecodeVarint reports the calls of proto.DecodeVarint, which
+// protowire.ConsumeVarint replaces. It returns a negative length rather
+// than 0 on errors, so the checks of the length need changing along.
+func rewriteDecodeVarint(c *funcCall) bool {
+	report.Report(c.pass, c.sel, "proto.DecodeVarint should be replaced with protowire.ConsumeVarint, which returns a negative length rather than 0 on errors; protowire.ParseError tells which")
+	return false
+}
+
+// checkBuffer reports the calls of the methods of proto.Buffer, with what
+// replaces each of them.
+func checkBuffer(pass *analysis.Pass) (interface{}, error) {
+	forEachFile(pass, func(pass *analysis.Pass, file *ast.File) {
+		ast.Inspect(file, func(node ast.Node) bool {
+			sel, ok := node.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			s, ok := pass.TypesInfo.Selections[sel]
+			if !ok || s.Kind() != types.MethodVal || !isBuffer(s.Recv()) {
+				return true
+			}
+			repl, ok := bufferMethods[sel.Sel.Name]
+			if !ok {
+				repl = bufferNote
+			}
+			report.Report(pass, sel.Sel, "the method Buffer."+sel.Sel.Name+" of the v1 proto package has no v2 counterpart; use "+repl+" instead")
+			return true
+		})
+	})
+	return nil, nil
+}
+
+// isBuffer reports whether t is proto.Buffer of the v1 proto package, or a
+// pointer to it.
+func isBuffer(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Name() == "Buffer" && obj.Pkg() != nil && pkgPath(obj.Pkg()) == protoV1Path
+}
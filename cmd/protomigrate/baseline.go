@@ -0,0 +1,101 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// baseline records the findings of a run, so that later runs only report
+// new ones. Findings are identified by their file, rule and message, without
+// the link to the documentation of the rule, rather than their line, which
+// edits elsewhere in the file shift; the count says how many of each the
+// file holds.
+type baseline struct {
+	Findings []baselineFinding `json:"findings"`
+}
+
+type baselineFinding struct {
+	File    string `json:"file"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+type baselineKey struct {
+	file, rule, msg string
+}
+
+func keyOf(d checker.Diagnostic) baselineKey {
+	msg := d.Message
+	if r, ok := protomigrate.LookupRule(ruleOf(d)); ok {
+		msg = r.TrimDocNote(msg)
+	}
+	return baselineKey{relPath(d.Position.Filename), ruleOf(d), msg}
+}
+
+// readBaseline reads the baseline in the named file.
+func readBaseline(name string) (map[baselineKey]int, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var b baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+	counts := map[baselineKey]int{}
+	for _, f := range b.Findings {
+		counts[baselineKey{f.File, f.Rule, f.Message}] += f.Count
+	}
+	return counts, nil
+}
+
+// writeBaseline writes the baseline of diags to the named file.
+func writeBaseline(name string, diags []checker.Diagnostic) error {
+	counts := map[baselineKey]int{}
+	for _, d := range diags {
+		counts[keyOf(d)]++
+	}
+	b := baseline{Findings: []baselineFinding{}}
+	for key, n := range counts {
+		b.Findings = append(b.Findings, baselineFinding{key.file, key.rule, key.msg, n})
+	}
+	sort.Slice(b.Findings, func(i, j int) bool {
+		fi, fj := b.Findings[i], b.Findings[j]
+		if fi.File != fj.File {
+			return fi.File < fj.File
+		}
+		if fi.Rule != fj.Rule {
+			return fi.Rule < fj.Rule
+		}
+		return fi.Message < fj.Message
+	})
+	data, err := json.MarshalIndent(b, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, append(data, '\n'), 0666)
+}
+
+// suppress returns the diagnostics that are not in the baseline. When a
+// file holds more findings of a kind than the baseline counts, the last
+// ones are reported.
+func suppress(diags []checker.Diagnostic, counts map[baselineKey]int) []checker.Diagnostic {
+	var kept []checker.Diagnostic
+	for _, d := range diags {
+		key := keyOf(d)
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
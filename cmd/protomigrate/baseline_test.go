@@ -0,0 +1,45 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// TestBaseline checks that the baseline of a run is written without the
+// links to the documentation of the rules, and suppresses all the findings
+// of the run but those in excess of its counts.
+func TestBaseline(t *testing.T) {
+	_, diags := testDiagnostics()
+	rule, _ := protomigrate.LookupRule("PM2001")
+	diags[0].Message = rule.AddDocNote(diags[0].Message)
+	// The same finding on another line.
+	moved := diags[0]
+	moved.Position.Line, moved.Position.Offset = 5, 55
+
+	name := filepath.Join(t.TempDir(), "baseline.json")
+	if err := writeBaseline(name, []checker.Diagnostic{diags[0], diags[1], diags[1]}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "baseline.json", data)
+
+	counts, err := readBaseline(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := suppress([]checker.Diagnostic{moved, diags[1], diags[0], diags[1], diags[1]}, counts)
+	if want := []checker.Diagnostic{diags[0], diags[1]}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept %v, want the last of each of the findings in excess %v", kept, want)
+	}
+}
@@ -0,0 +1,515 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+const (
+	v1Path = "github.com/golang/protobuf"
+	v2Path = "google.golang.org/protobuf"
+
+	// v1Repo and v2Repo are the names rules_go and Gazelle give the
+	// repositories of the protobuf modules.
+	v1Repo = "@com_github_golang_protobuf"
+	v2Repo = "@org_golang_google_protobuf"
+)
+
+// bazelReport lists the buildozer commands that migrate the BUILD files of
+// a Bazel workspace along with the Go code, by target.
+type bazelReport struct {
+	Targets []*bazelTarget `json:"targets"`
+}
+
+type bazelTarget struct {
+	Label    string   `json:"label"`
+	Commands []string `json:"commands"`
+}
+
+// A buildRule is a rule of a BUILD file, as far as its string and list of
+// strings attributes go. The strings of attributes set to other
+// expressions, like glob or select calls, are kept as well.
+type buildRule struct {
+	Kind, Name string
+	Attrs      map[string][]string
+}
+
+// printBazel prints the buildozer commands migrating the BUILD files of the
+// Bazel workspace of the packages matching patterns, and returns the exit
+// code: 1 if the packages could not be analyzed or the BUILD files read, 0
+// otherwise.
+func printBazel(w io.Writer, patterns []string, analyzers []*analysis.Analyzer) int {
+	if *format == "sarif" || *format == "html" {
+		log.Print("cannot print the buildozer commands with -format=" + *format)
+		return 1
+	}
+	pkgs, diags, err := analyze(patterns, analyzers)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if len(pkgs) == 0 {
+		return 0
+	}
+	r, err := bazel(pkgs, diags)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if *format == "json" {
+		err = encodeJSON(w, r)
+	} else {
+		err = r.write(w)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+// bazel returns the buildozer commands migrating the BUILD files of the
+// workspace of pkgs along with the fixes of diags, the findings in them:
+//
+//   - the go_library, go_binary and go_test rules of the files with
+//     findings get dependencies on the packages of google.golang.org/protobuf
+//     the fixes import, and lose those on the packages of
+//     github.com/golang/protobuf no file of theirs imports once fixed;
+//   - the go_proto_library rules of the packages with findings, and of the
+//     packages they import, get the compilers of protoc-gen-go and
+//     protoc-gen-go-grpc of the v2 API in place of those of protoc-gen-go
+//     before v1.4 and of gogo protobuf.
+//
+// The labels of the dependencies follow those the BUILD files already
+// have, with or without the go_default_library names of older Gazelle.
+func bazel(pkgs []*packages.Package, diags []checker.Diagnostic) (*bazelReport, error) {
+	root := ""
+	for _, pkg := range pkgs {
+		if pkg.Module != nil && pkg.Module.Main && len(pkg.GoFiles) > 0 {
+			root = bazelWorkspace(filepath.Dir(pkg.GoFiles[0]))
+			break
+		}
+	}
+	if root == "" {
+		return nil, fmt.Errorf("no Bazel workspace found for the packages")
+	}
+
+	fset := pkgs[0].Fset
+	byFile := map[string][]checker.Diagnostic{}
+	for _, d := range diags {
+		if strings.HasSuffix(d.Position.Filename, ".go") {
+			byFile[d.Position.Filename] = append(byFile[d.Position.Filename], d)
+		}
+	}
+
+	// The imports of the files of the packages, as the fixes leave them,
+	// and the import paths involved in the findings: those of the packages
+	// with findings and of the packages they import.
+	type fileImports struct{ kept, added map[string]bool }
+	files := map[string]*fileImports{}
+	involved := map[string]bool{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Syntax {
+			name := fset.File(f.Pos()).Name()
+			if _, ok := files[name]; ok {
+				continue
+			}
+			kept, added := fixedImports(f, byFile[name])
+			files[name] = &fileImports{kept, added}
+			if len(byFile[name]) > 0 {
+				involved[strings.TrimSuffix(pkg.PkgPath, "_test")] = true
+				for path := range pkg.Imports {
+					involved[path] = true
+				}
+			}
+		}
+	}
+
+	r := &bazelReport{Targets: []*bazelTarget{}}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if base := info.Name(); path != root && (strings.HasPrefix(base, ".") || strings.HasPrefix(base, "bazel-")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isBuildFile(path) {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dir := filepath.Dir(path)
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return err
+		}
+		pkgLabel := "//" + strings.TrimPrefix(filepath.ToSlash(rel), ".")
+		rules := parseBuild(data)
+		suffix := labelSuffix(rules)
+		for _, rule := range rules {
+			var cmds []string
+			switch rule.Kind {
+			case "go_library", "go_binary", "go_test":
+				var srcs []*fileImports
+				fixed := false
+				for _, src := range rule.Attrs["srcs"] {
+					name := filepath.Join(dir, filepath.FromSlash(src))
+					if f, ok := files[name]; ok {
+						srcs = append(srcs, f)
+						fixed = fixed || len(byFile[name]) > 0
+					}
+				}
+				if !fixed {
+					continue
+				}
+				deps := map[string]bool{}
+				for _, dep := range rule.Attrs["deps"] {
+					deps[dep] = true
+				}
+				var remove, add []string
+				for _, dep := range rule.Attrs["deps"] {
+					path, ok := v1Import(dep)
+					if !ok {
+						continue
+					}
+					used := false
+					for _, f := range srcs {
+						used = used || f.kept[path]
+					}
+					if !used {
+						remove = append(remove, dep)
+					}
+				}
+				seen := map[string]bool{}
+				for _, f := range srcs {
+					for path := range f.added {
+						label := v2Label(path, suffix)
+						if !deps[label] && !seen[label] {
+							seen[label] = true
+							add = append(add, label)
+						}
+					}
+				}
+				sort.Strings(add)
+				if len(remove) > 0 {
+					cmds = append(cmds, "remove deps "+strings.Join(remove, " "))
+				}
+				if len(add) > 0 {
+					cmds = append(cmds, "add deps "+strings.Join(add, " "))
+				}
+			case "go_proto_library":
+				if len(rule.Attrs["importpath"]) == 0 || !involved[rule.Attrs["importpath"][0]] {
+					continue
+				}
+				cmds = compilerCommands(rule)
+			}
+			if len(cmds) > 0 {
+				r.Targets = append(r.Targets, &bazelTarget{Label: pkgLabel + ":" + rule.Name, Commands: cmds})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(r.Targets, func(i, j int) bool { return r.Targets[i].Label < r.Targets[j].Label })
+	return r, nil
+}
+
+// quotedV2Path matches the quoted import paths of the packages of
+// google.golang.org/protobuf.
+var quotedV2Path = regexp.MustCompile(`"` + regexp.QuoteMeta(v2Path) + `(/[^"]*)?"`)
+
+// fixedImports returns the import paths of the packages of
+// github.com/golang/protobuf that f still imports once the fixes of diags,
+// the findings in it, are applied, and those of the packages of
+// google.golang.org/protobuf the fixes import that f does not already.
+func fixedImports(f *ast.File, diags []checker.Diagnostic) (kept, added map[string]bool) {
+	kept, added = map[string]bool{}, map[string]bool{}
+	imported := map[string]bool{}
+	for _, spec := range f.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		imported[path] = true
+		if path != v1Path && !strings.HasPrefix(path, v1Path+"/") {
+			continue
+		}
+		kept[path] = true
+		for _, d := range diags {
+			for _, fix := range d.SuggestedFixes {
+				for _, e := range fix.TextEdits {
+					if e.Pos <= spec.Path.Pos() && spec.Path.End() <= e.End && !strings.Contains(string(e.NewText), spec.Path.Value) {
+						delete(kept, path)
+					}
+				}
+			}
+		}
+	}
+	for _, d := range diags {
+		for _, fix := range d.SuggestedFixes {
+			for _, e := range fix.TextEdits {
+				for _, quoted := range quotedV2Path.FindAllString(string(e.NewText), -1) {
+					if path, err := strconv.Unquote(quoted); err == nil && !imported[path] {
+						added[path] = true
+					}
+				}
+			}
+		}
+	}
+	return kept, added
+}
+
+// v1Import returns the import path of the package of github.com/golang/protobuf
+// of label, if it is one.
+func v1Import(label string) (string, bool) {
+	if !strings.HasPrefix(label, v1Repo+"//") {
+		return "", false
+	}
+	rel := strings.TrimPrefix(label, v1Repo+"//")
+	if i := strings.Index(rel, ":"); i >= 0 {
+		rel = rel[:i]
+	}
+	if rel == "" {
+		return v1Path, true
+	}
+	return v1Path + "/" + rel, true
+}
+
+// v2Label returns the label of the package of google.golang.org/protobuf of
+// import path path, ending with suffix.
+func v2Label(path, suffix string) string {
+	return v2Repo + "//" + strings.TrimPrefix(strings.TrimPrefix(path, v2Path), "/") + suffix
+}
+
+// labelSuffix returns the name the labels of the Go packages of rules end
+// with, :go_default_library if their dependencies on packages of other
+// repositories do, as older Gazelle names them, "" otherwise.
+func labelSuffix(rules []*buildRule) string {
+	for _, rule := range rules {
+		for _, dep := range rule.Attrs["deps"] {
+			if strings.HasPrefix(dep, "@") && strings.HasSuffix(dep, ":go_default_library") {
+				return ":go_default_library"
+			}
+		}
+	}
+	return ""
+}
+
+// compilerCommands returns the buildozer commands switching the compilers of
+// rule, a go_proto_library, from those generating code for the v1 API to
+// go_proto, and go_grpc_v2 for those generating gRPC services. The default
+// compiler, when rule sets none, depends on the version of rules_go, and
+// is left alone.
+func compilerCommands(rule *buildRule) []string {
+	attr := "compilers"
+	if len(rule.Attrs["compiler"]) > 0 {
+		attr = "compiler"
+	}
+	var remove []string
+	keep := map[string]bool{}
+	prefix := ""
+	grpc := false
+	for _, c := range rule.Attrs[attr] {
+		i := strings.LastIndex(c, ":")
+		if i < 0 || !strings.HasSuffix(c[:i], "//proto") {
+			keep[c] = true
+			continue
+		}
+		name := c[i+1:]
+		switch {
+		case name == "go_grpc":
+			grpc = true
+		case strings.HasSuffix(name, "_grpc") && gogoBufPlugins[strings.TrimSuffix(name, "_grpc")]:
+			grpc = true
+		case strings.HasSuffix(name, "_proto") && gogoBufPlugins[strings.TrimSuffix(name, "_proto")]:
+		default:
+			keep[c] = true
+			continue
+		}
+		remove = append(remove, c)
+		prefix = c[:i+1]
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+	var add []string
+	for _, name := range []string{"go_proto", "go_grpc_v2"} {
+		if name == "go_grpc_v2" && !grpc {
+			continue
+		}
+		if !keep[prefix+name] {
+			add = append(add, prefix+name)
+		}
+	}
+	if attr == "compiler" {
+		return []string{"remove compiler", "set compilers " + strings.Join(add, " ")}
+	}
+	cmds := []string{"remove compilers " + strings.Join(remove, " ")}
+	if len(add) > 0 {
+		cmds = append(cmds, "add compilers "+strings.Join(add, " "))
+	}
+	return cmds
+}
+
+// bazelWorkspace returns the root of the Bazel workspace of dir, the
+// closest of dir and its parents with a WORKSPACE, WORKSPACE.bazel or
+// MODULE.bazel file, or "" if there is none.
+func bazelWorkspace(dir string) string {
+	for {
+		for _, name := range []string{"WORKSPACE", "WORKSPACE.bazel", "MODULE.bazel"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// isBuildFile reports whether path is a BUILD file: BUILD.bazel, or BUILD
+// if its directory has no BUILD.bazel, which Bazel prefers.
+func isBuildFile(path string) bool {
+	switch filepath.Base(path) {
+	case "BUILD.bazel":
+		return true
+	case "BUILD":
+		_, err := os.Stat(filepath.Join(filepath.Dir(path), "BUILD.bazel"))
+		return os.IsNotExist(err)
+	}
+	return false
+}
+
+// parseBuild returns the rules of the BUILD file of content data: the calls
+// at the top level with a name attribute.
+func parseBuild(data []byte) []*buildRule {
+	toks := tokenizeBuild(string(data))
+	var rules []*buildRule
+	for i := 0; i+1 < len(toks); i++ {
+		if !isBuildIdent(toks[i]) || toks[i+1] != "(" {
+			continue
+		}
+		rule := &buildRule{Kind: toks[i], Attrs: map[string][]string{}}
+		depth := 0
+		attr := ""
+		for i++; i < len(toks); i++ {
+			switch t := toks[i]; {
+			case t == "(" || t == "[" || t == "{":
+				depth++
+			case t == ")" || t == "]" || t == "}":
+				depth--
+			case depth == 1 && t == ",":
+				attr = ""
+			case depth == 1 && attr == "" && isBuildIdent(t) && i+1 < len(toks) && toks[i+1] == "=":
+				attr = t
+				i++
+			case attr != "" && strings.HasPrefix(t, `"`):
+				s, err := strconv.Unquote(t)
+				if err != nil {
+					continue
+				}
+				rule.Attrs[attr] = append(rule.Attrs[attr], s)
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		if names := rule.Attrs["name"]; len(names) == 1 {
+			rule.Name = names[0]
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// tokenizeBuild splits src, the content of a BUILD file, into identifiers,
+// strings, with their quotes, and other characters, dropping the comments
+// and spaces. Single quoted and triple quoted strings are turned into
+// double quoted ones.
+func tokenizeBuild(src string) []string {
+	var toks []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			quote := string(c)
+			if strings.HasPrefix(src[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+			j := i + len(quote)
+			for j < len(src) && !strings.HasPrefix(src[j:], quote) {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j > len(src) {
+				j = len(src)
+			}
+			toks = append(toks, strconv.Quote(src[i+len(quote):j]))
+			i = j + len(quote)
+		case isBuildIdentByte(c):
+			j := i
+			for j < len(src) && isBuildIdentByte(src[j]) {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			i = j
+		default:
+			toks = append(toks, string(c))
+			i++
+		}
+	}
+	return toks
+}
+
+func isBuildIdentByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isBuildIdent(s string) bool {
+	return s != "" && isBuildIdentByte(s[0]) && !('0' <= s[0] && s[0] <= '9')
+}
+
+// write writes the report to w as buildozer command lines, one per target.
+func (r *bazelReport) write(w io.Writer) error {
+	for _, t := range r.Targets {
+		line := "buildozer"
+		for _, cmd := range t.Commands {
+			line += " '" + cmd + "'"
+		}
+		if _, err := fmt.Fprintln(w, line+" "+t.Label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
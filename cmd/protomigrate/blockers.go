@@ -0,0 +1,183 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// blockerReport lists the modules, out of the main modules, whose API the
+// packages use mentions v1 protobuf types, which the packages cannot
+// migrate off until the modules do.
+type blockerReport struct {
+	Modules []*blockingModule `json:"modules"`
+}
+
+type blockingModule struct {
+	Path    string            `json:"path"`
+	Version string            `json:"version,omitempty"`
+	Symbols []*blockingSymbol `json:"symbols"`
+}
+
+// A blockingSymbol is a symbol of a blocking module that packages use: a
+// v1-only message type, or a function, method or field whose type mentions
+// v1 types.
+type blockingSymbol struct {
+	Symbol   string   `json:"symbol"`
+	Message  bool     `json:"message,omitempty"`
+	Packages []string `json:"packages"`
+}
+
+// printBlockers prints the blocker report of the packages matching
+// patterns, and returns the exit code: 1 if the packages could not be
+// analyzed, 3 if modules block their migration, 0 otherwise.
+func printBlockers(w io.Writer, patterns []string) int {
+	if *format == "sarif" || *format == "html" {
+		log.Print("cannot print the blockers with -format=" + *format)
+		return 1
+	}
+	pkgs, err := checker.Load(patterns, *tests)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	exported, err := checker.ExportedFacts(pkgs, []*analysis.Analyzer{facts.API})
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	r := blockers(pkgs, exported)
+	if *format == "json" {
+		err = encodeJSON(w, r)
+	} else {
+		err = r.write(w)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if len(r.Modules) > 0 && *failOn != "none" {
+		return 3
+	}
+	return 0
+}
+
+// blockers returns the blocker report of pkgs, given the facts of the
+// facts.API analyzer and of those it requires. The modules of the protobuf
+// runtimes, whose v1 API the migration replaces, are left out.
+func blockers(pkgs []*packages.Package, exported *checker.Facts) *blockerReport {
+	deps := map[*types.Package]*packages.Package{}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		deps[pkg.Types] = pkg
+	})
+	external := func(tpkg *types.Package) (*packages.Module, bool) {
+		dep, ok := deps[tpkg]
+		if !ok || dep.Module == nil || dep.Module.Main || facts.IsProtoPackage(dep.PkgPath) {
+			return nil, false
+		}
+		return dep.Module, true
+	}
+
+	// The symbols of the v1 API of the external packages, by their names
+	// qualified by the paths of their packages.
+	v1API := map[types.Object]string{}
+	messages := map[types.Object]bool{}
+	for tpkg, fs := range exported.Packages {
+		if _, ok := external(tpkg); !ok {
+			continue
+		}
+		for _, f := range fs {
+			if api, ok := f.(*facts.V1API); ok {
+				for obj, name := range apiObjects(tpkg, api) {
+					v1API[obj] = tpkg.Path() + "." + name
+				}
+			}
+		}
+	}
+	for obj, fs := range exported.Objects {
+		if _, ok := external(obj.Pkg()); !ok {
+			continue
+		}
+		for _, f := range fs {
+			if m, ok := f.(*facts.IsMessage); ok && m.Kind == facts.V1Only {
+				v1API[obj] = obj.Pkg().Path() + "." + obj.Name()
+				messages[obj] = true
+			}
+		}
+	}
+
+	modules := map[string]*blockingModule{}
+	symbols := map[types.Object]*blockingSymbol{}
+	users := map[*blockingSymbol]map[string]bool{}
+	for _, pkg := range pkgs {
+		if pkg.Module != nil && !pkg.Module.Main {
+			continue
+		}
+		for _, obj := range pkg.TypesInfo.Uses {
+			name, ok := v1API[obj]
+			if !ok {
+				continue
+			}
+			s := symbols[obj]
+			if s == nil {
+				mod, _ := external(obj.Pkg())
+				m := modules[mod.Path]
+				if m == nil {
+					m = &blockingModule{Path: mod.Path, Version: moduleVersion(mod)}
+					modules[mod.Path] = m
+				}
+				s = &blockingSymbol{Symbol: name, Message: messages[obj]}
+				symbols[obj] = s
+				users[s] = map[string]bool{}
+				m.Symbols = append(m.Symbols, s)
+			}
+			users[s][strings.TrimSuffix(pkg.PkgPath, "_test")] = true
+		}
+	}
+
+	r := &blockerReport{Modules: []*blockingModule{}}
+	for _, m := range modules {
+		for _, s := range m.Symbols {
+			for path := range users[s] {
+				s.Packages = append(s.Packages, path)
+			}
+			sort.Strings(s.Packages)
+		}
+		sort.Slice(m.Symbols, func(i, j int) bool { return m.Symbols[i].Symbol < m.Symbols[j].Symbol })
+		r.Modules = append(r.Modules, m)
+	}
+	sort.Slice(r.Modules, func(i, j int) bool { return r.Modules[i].Path < r.Modules[j].Path })
+	return r
+}
+
+// write writes the report to w as text, a paragraph a module.
+func (r *blockerReport) write(w io.Writer) error {
+	var b strings.Builder
+	for i, m := range r.Modules {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s %s blocks the migration with %d symbols:\n", m.Path, m.Version, len(m.Symbols))
+		for _, s := range m.Symbols {
+			kind := "v1 API"
+			if s.Message {
+				kind = "v1-only message"
+			}
+			fmt.Fprintf(&b, "\t%s (%s), used by %s\n", s.Symbol, kind, strings.Join(s.Packages, " "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,305 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+	"gopkg.in/yaml.v2"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// bufPluginRule is the ID of the rule of the findings of checkBuf.
+const bufPluginRule = "PM7004"
+
+const (
+	bufGenName  = "buf.gen.yaml"
+	bufWorkName = "buf.work.yaml"
+)
+
+// bufGen is a buf.gen.yaml file, of version v1 or v2, as far as its plugins
+// go.
+type bufGen struct {
+	Plugins []bufPlugin `yaml:"plugins"`
+}
+
+// A bufPlugin is a plugin of a buf.gen.yaml file. Name and Plugin name a
+// local or remote plugin in v1 files, Remote and Local in v2 ones, where
+// Local may be a command line; Opt is a string or a list of them.
+type bufPlugin struct {
+	Name   string      `yaml:"name"`
+	Plugin string      `yaml:"plugin"`
+	Remote string      `yaml:"remote"`
+	Local  interface{} `yaml:"local"`
+	Out    string      `yaml:"out"`
+	Opt    interface{} `yaml:"opt"`
+}
+
+// bufWork is a buf.work.yaml file, listing the directories of the modules
+// of .proto files of a workspace.
+type bufWork struct {
+	Directories []string `yaml:"directories"`
+}
+
+// checkBuf reports, in the buf.gen.yaml files of the main modules of pkgs,
+// the plugins generating code for the v1 API: the gogo plugins, which
+// protoc-gen-go replaces, protoc-gen-go passed plugins=grpc, which it no
+// longer takes from v1.4 on, and the remote plugins of protoc-gen-go before
+// v1.4 and of grpc-gateway v1. Each finding lists the packages of pkgs the
+// plugin generates, those in the directory it writes to, so that the
+// findings in their code can be traced to the buf.gen.yaml file to update
+// first. The files are looked for in the directory of each module and its
+// parents up to the root of the repository, and in the directories their
+// buf.work.yaml files list. A file is checked once, along with the first
+// module it is found from, and so in the shard of the path of the module.
+func checkBuf(fset *token.FileSet, pkgs []*packages.Package) ([]checker.Diagnostic, error) {
+	if !protomigrate.RuleEnabled(bufPluginRule) {
+		return nil, nil
+	}
+	mods, roots := mainModules(pkgs)
+	seen := map[string]bool{}
+	var diags []checker.Diagnostic
+	for _, m := range mods {
+		names, err := findBufGen(filepath.Dir(m.GoMod))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if !shard.has(m.Path) {
+				continue
+			}
+			ds, err := checkBufGen(fset, name, m, roots[m])
+			if err != nil {
+				return nil, err
+			}
+			diags = append(diags, ds...)
+		}
+	}
+	return diags, nil
+}
+
+// findBufGen returns the paths of the buf.gen.yaml files of dir and its
+// parents up to the root of the repository, the first with a .git entry,
+// and of the directories their buf.work.yaml files list.
+func findBufGen(dir string) ([]string, error) {
+	var names []string
+	add := func(dir string) error {
+		name := filepath.Join(dir, bufGenName)
+		if _, err := os.Stat(name); err == nil {
+			names = append(names, name)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	for {
+		if err := add(dir); err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, bufWorkName))
+		if err == nil {
+			var w bufWork
+			if err := yaml.Unmarshal(data, &w); err != nil {
+				return nil, fmt.Errorf("%s: %v", filepath.Join(dir, bufWorkName), err)
+			}
+			for _, d := range w.Directories {
+				if err := add(filepath.Join(dir, filepath.FromSlash(d))); err != nil {
+					return nil, err
+				}
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return names, nil
+}
+
+// checkBufGen returns the findings of the named buf.gen.yaml file, found
+// from the main module m of pkgs. The file is added to fset, so that the
+// findings have positions.
+func checkBufGen(fset *token.FileSet, name string, m *packages.Module, pkgs []*packages.Package) ([]checker.Diagnostic, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var gen bufGen
+	if err := yaml.Unmarshal(data, &gen); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	tf := fset.AddFile(name, -1, len(data))
+	tf.SetLinesForContent(data)
+	lines := strings.Split(string(data), "\n")
+
+	var diags []checker.Diagnostic
+	next := 0
+	for i := range gen.Plugins {
+		p := &gen.Plugins[i]
+		id := p.id()
+		// yaml.v2 keeps no positions, so the plugins are found in order
+		// by the line naming them.
+		line := 1
+		for j := next; j < len(lines); j++ {
+			key := strings.TrimLeft(lines[j], " \t-")
+			named := false
+			for _, k := range []string{"name:", "plugin:", "remote:", "local:"} {
+				named = named || strings.HasPrefix(key, k)
+			}
+			if named && strings.Contains(key, id) {
+				line, next = j+1, j+1
+				break
+			}
+		}
+		msg, ok := p.outdated()
+		if !ok {
+			continue
+		}
+		out := filepath.Join(filepath.Dir(name), filepath.FromSlash(p.Out))
+		if gens := generatedPackages(pkgs, out); len(gens) > 0 {
+			msg += fmt.Sprintf("; it generates the packages %s", strings.Join(gens, ", "))
+		}
+		if r, ok := protomigrate.LookupRule(bufPluginRule); ok {
+			msg = r.AddDocNote(msg)
+		}
+		pos := tf.LineStart(line)
+		diags = append(diags, checker.Diagnostic{
+			Diagnostic: analysis.Diagnostic{Pos: pos, Category: bufPluginRule, Message: msg},
+			Analyzer:   protomigrate.Analyzer,
+			Position:   fset.Position(pos),
+			Package:    m.Path,
+		})
+	}
+	return diags, nil
+}
+
+// id returns what names p in its file.
+func (p *bufPlugin) id() string {
+	for _, s := range []string{p.Remote, p.Plugin, p.Name} {
+		if s != "" {
+			return s
+		}
+	}
+	switch local := p.Local.(type) {
+	case string:
+		return local
+	case []interface{}:
+		if len(local) > 0 {
+			if s, ok := local[0].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// nameVersion returns the name of p, without the prefix protoc-gen- of its
+// command or the path of its remote, and its version if it is a remote
+// plugin that gives one, without the revision of the plugin.
+func (p *bufPlugin) nameVersion() (string, string) {
+	name := filepath.Base(filepath.FromSlash(p.id()))
+	version := ""
+	if p.Remote != "" || strings.Contains(p.Plugin, "/") || strings.Contains(p.Name, "/") {
+		if i := strings.Index(name, ":"); i >= 0 {
+			name, version = name[:i], name[i+1:]
+			if j := strings.LastIndex(version, "-"); j >= 0 && strings.Trim(version[j+1:], "0123456789") == "" {
+				version = version[:j]
+			}
+		}
+	}
+	return strings.TrimPrefix(name, "protoc-gen-"), version
+}
+
+// opts returns the options of p.
+func (p *bufPlugin) opts() []string {
+	var opts []string
+	add := func(s string) {
+		opts = append(opts, strings.Split(s, ",")...)
+	}
+	switch opt := p.Opt.(type) {
+	case string:
+		add(opt)
+	case []interface{}:
+		for _, o := range opt {
+			if s, ok := o.(string); ok {
+				add(s)
+			}
+		}
+	}
+	return opts
+}
+
+// outdated returns the message of the finding of p, if it generates code
+// for the v1 API.
+func (p *bufPlugin) outdated() (string, bool) {
+	name, version := p.nameVersion()
+	switch {
+	case gogoBufPlugins[name]:
+		return fmt.Sprintf("plugin %s of gogo protobuf generates code for the gogo runtime; replace it with protoc-gen-go of google.golang.org/protobuf", p.id()), true
+	case name == "go" && version != "" && semver.IsValid(version) && semver.Compare(version, hybridVersion) < 0:
+		return fmt.Sprintf("plugin %s is protoc-gen-go %s, which generates code for the v1 API; upgrade it to %s or later", p.id(), version, strings.TrimSuffix(hybridVersion, ".0")), true
+	case name == "grpc-gateway" && version != "" && semver.Major(version) == "v1":
+		return fmt.Sprintf("plugin %s is grpc-gateway %s, whose code marshals with package jsonpb of the v1 API; upgrade it to v2", p.id(), version), true
+	case name == "go":
+		for _, opt := range p.opts() {
+			if opt == "plugins=grpc" {
+				return fmt.Sprintf("plugin %s is passed plugins=grpc, which protoc-gen-go no longer takes from %s on; generate the gRPC code with the plugin go-grpc", p.id(), strings.TrimSuffix(hybridVersion, ".0")), true
+			}
+		}
+	}
+	return "", false
+}
+
+// gogoBufPlugins are the plugins of gogo protobuf, by name.
+var gogoBufPlugins = map[string]bool{
+	"gogo":       true,
+	"gofast":     true,
+	"gogofast":   true,
+	"gogofaster": true,
+	"gogoslick":  true,
+	"gogotypes":  true,
+	"gostring":   true,
+}
+
+// generatedPackages returns the paths of the packages of pkgs in dir or
+// below it with files generated by protoc plugins, sorted.
+func generatedPackages(pkgs []*packages.Package, dir string) []string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, pkg := range pkgs {
+		for _, name := range pkg.GoFiles {
+			rel, err := filepath.Rel(dir, filepath.Dir(name))
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			if (strings.HasSuffix(name, ".pb.go") || strings.HasSuffix(name, ".pb.gw.go")) && !seen[pkg.PkgPath] {
+				seen[pkg.PkgPath] = true
+				paths = append(paths, pkg.PkgPath)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,398 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// configName is the name of the configuration file, which is looked up in
+// the current directory and its parents.
+const configName = ".protomigrate.yaml"
+
+// config is the content of the configuration file. The flags set on the
+// command line override it.
+type config struct {
+	// Go is the Go version the migrated code targets, like -go.
+	Go string `yaml:"go"`
+
+	// Generated migrates generated files too, like -generated.
+	Generated bool `yaml:"generated"`
+
+	// OwnProtoDeprecations reports the uses of deprecated proto fields and
+	// enum values in their own package too, like -own-proto-deprecations.
+	OwnProtoDeprecations bool `yaml:"own-proto-deprecations"`
+
+	// Enable lists the only rules to report, and Disable the rules not to
+	// report, like -enable and -disable. Rules are given by ID or name.
+	Enable  []string `yaml:"enable"`
+	Disable []string `yaml:"disable"`
+
+	// Check lists the heuristic rules to report too, like -check.
+	Check []string `yaml:"check"`
+
+	// Exclude lists the files whose findings are dropped, as patterns of
+	// filepath.Match relative to the directory of the configuration file;
+	// a pattern ending in /... matches the files below a directory.
+	Exclude []string `yaml:"exclude"`
+
+	// Deprecated maps the paths of packages to also report as deprecated
+	// to their deprecation messages, like -deprecated.
+	Deprecated map[string]string `yaml:"deprecated"`
+
+	// ImportMap maps the import paths of the forks, mirrors and
+	// re-exports of the packages of github.com/golang/protobuf to the
+	// packages they stand for, with notes on how they differ, like
+	// -import-map.
+	ImportMap map[string]struct {
+		Path string `yaml:"path"`
+		Note string `yaml:"note"`
+	} `yaml:"import-map"`
+
+	// Deprecations names a database of more deprecated packages and
+	// symbols, relative to the directory of the configuration file, like
+	// -deprecations.
+	Deprecations string `yaml:"deprecations"`
+
+	// DocURL is the template of the URLs documenting the rules, like
+	// -doc-url.
+	DocURL string `yaml:"doc-url"`
+
+	// Severity maps rules to the severity of their findings: error,
+	// warning, the default, or note, or info alike, like -severity.
+	Severity map[string]string `yaml:"severity"`
+
+	// Fix controls the fixes.
+	Fix struct {
+		// Disable lists the rules whose fixes are not applied, which
+		// leaves their findings to migrate by hand.
+		Disable []string `yaml:"disable"`
+
+		// Level is the least safe level of the fixes applied, like
+		// -fix-level.
+		Level string `yaml:"level"`
+
+		// TODO adds a TODO comment at the findings left without a fix,
+		// like -todo.
+		TODO bool `yaml:"todo"`
+	} `yaml:"fix"`
+
+	// Strict reports any use of the v1 API left, like -strict, for the
+	// modules declared fully migrated.
+	Strict bool `yaml:"strict"`
+
+	// FailOn is the exit code policy, like -fail-on.
+	FailOn string `yaml:"fail-on"`
+
+	// GroupBy tells how the findings are grouped, like -group-by.
+	GroupBy string `yaml:"group-by"`
+
+	// Regen configures how protomigrate regen regenerates files.
+	Regen struct {
+		// Tool is protoc, the default, or buf.
+		Tool string `yaml:"tool"`
+
+		// Include lists the directories protoc looks up imports in,
+		// besides that of the file regenerated, relative to the
+		// directory of the configuration file.
+		Include []string `yaml:"include"`
+
+		// Template is the buf.gen.yaml file buf generate runs with,
+		// relative to the directory of the configuration file.
+		Template string `yaml:"template"`
+	} `yaml:"regen"`
+
+	// Rewrites are declarative rules rewriting the references to symbols
+	// of other packages, like the protobuf wrappers of a company, which
+	// run along the built-in rules.
+	Rewrites []protomigrate.Rewrite `yaml:"rewrites"`
+
+	// path is the path of the configuration file, and dir its directory.
+	path, dir string
+}
+
+// findConfig returns the path of the configuration file that applies to the
+// current directory, or "" if there is none.
+func findConfig() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		name := filepath.Join(dir, configName)
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readConfig reads the named configuration file.
+func readConfig(name string) (*config, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg.path, cfg.dir = name, filepath.Dir(abs)
+
+	// The rules of the rewrites can be configured as the others.
+	if err := protomigrate.RegisterRewrites(cfg.Rewrites); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	// The rules are kept by ID.
+	for _, ids := range []*[]string{&cfg.Enable, &cfg.Disable, &cfg.Fix.Disable} {
+		for i, rule := range *ids {
+			r, ok := protomigrate.LookupRule(rule)
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown rule: %q", name, rule)
+			}
+			(*ids)[i] = r.ID
+		}
+	}
+	severities := map[string]string{}
+	for rule, severity := range cfg.Severity {
+		id, severity, err := parseSeverity(rule, severity)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		severities[id] = severity
+	}
+	cfg.Severity = severities
+	switch cfg.Regen.Tool {
+	case "", "protoc", "buf":
+	default:
+		return nil, fmt.Errorf("%s: unknown regen tool %q", name, cfg.Regen.Tool)
+	}
+	for _, pattern := range cfg.Exclude {
+		if _, err := filepath.Match(strings.TrimSuffix(pattern, "/..."), ""); err != nil {
+			return nil, fmt.Errorf("%s: invalid exclude pattern %q: %v", name, pattern, err)
+		}
+	}
+	return cfg, nil
+}
+
+// parseSeverity returns the ID of rule, given by ID or name, and severity,
+// with info translated to note, the name SARIF gives it.
+func parseSeverity(rule, severity string) (id, s string, err error) {
+	r, ok := protomigrate.LookupRule(rule)
+	if !ok {
+		return "", "", fmt.Errorf("unknown rule: %q", rule)
+	}
+	switch severity {
+	case "error", "warning", "note":
+	case "info":
+		severity = "note"
+	default:
+		return "", "", fmt.Errorf("invalid severity of %s: %q", rule, severity)
+	}
+	return r.ID, severity, nil
+}
+
+// severityFlag is the value of -severity, a comma-separated list of rules
+// and the severity of their findings, as in ptypes=error,PM2001=warning.
+type severityFlag map[string]string
+
+func (f severityFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for id, s := range f {
+		pairs = append(pairs, id+"="+s)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f severityFlag) Set(v string) error {
+	for _, pair := range strings.Split(v, ",") {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			return fmt.Errorf("missing severity of %q", pair)
+		}
+		id, s, err := parseSeverity(strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:]))
+		if err != nil {
+			return err
+		}
+		f[id] = s
+	}
+	return nil
+}
+
+// setFlags sets the analyzer flags that cfg configures, unless they are set
+// on the command line already.
+func (cfg *config) setFlags() error {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	values := map[string][]string{}
+	if cfg.Go != "" {
+		values["go"] = []string{cfg.Go}
+	}
+	if cfg.Generated {
+		values["generated"] = []string{"true"}
+	}
+	if cfg.OwnProtoDeprecations {
+		values["own-proto-deprecations"] = []string{"true"}
+	}
+	if cfg.Deprecations != "" {
+		name := cfg.Deprecations
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(cfg.dir, name)
+		}
+		values["deprecations"] = []string{name}
+	}
+	if cfg.DocURL != "" {
+		values["doc-url"] = []string{cfg.DocURL}
+	}
+	if cfg.Fix.Level != "" {
+		values["fix-level"] = []string{cfg.Fix.Level}
+	}
+	if cfg.Fix.TODO {
+		values["todo"] = []string{"true"}
+	}
+	if cfg.Strict {
+		values["strict"] = []string{"true"}
+	}
+	if cfg.FailOn != "" {
+		values["fail-on"] = []string{cfg.FailOn}
+	}
+	if cfg.GroupBy != "" {
+		values["group-by"] = []string{cfg.GroupBy}
+	}
+	if len(cfg.Enable) > 0 {
+		values["enable"] = []string{strings.Join(cfg.Enable, ",")}
+	}
+	if len(cfg.Disable) > 0 {
+		values["disable"] = []string{strings.Join(cfg.Disable, ",")}
+	}
+	if len(cfg.Check) > 0 {
+		values["check"] = []string{strings.Join(cfg.Check, ",")}
+	}
+	for path, msg := range cfg.Deprecated {
+		values["deprecated"] = append(values["deprecated"], path+": "+msg)
+	}
+	sort.Strings(values["deprecated"])
+	for from, m := range cfg.ImportMap {
+		v := from + "=" + m.Path
+		if m.Note != "" {
+			v += ": " + m.Note
+		}
+		values["import-map"] = append(values["import-map"], v)
+	}
+	sort.Strings(values["import-map"])
+	for name, vs := range values {
+		if set[name] {
+			continue
+		}
+		for _, v := range vs {
+			if err := flag.Set(name, v); err != nil {
+				return fmt.Errorf("%s: %s: %v", cfg.path, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// excluded reports whether the findings in the named file are dropped.
+func (cfg *config) excluded(name string) bool {
+	rel, err := filepath.Rel(cfg.dir, name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range cfg.Exclude {
+		if dir := strings.TrimSuffix(pattern, "/..."); dir != pattern {
+			if ok, _ := filepath.Match(dir, rel); ok {
+				return true
+			}
+			// The pattern matches the directories the file is in.
+			for d := filepath.Dir(filepath.FromSlash(rel)); d != "."; d = filepath.Dir(d) {
+				if ok, _ := filepath.Match(dir, filepath.ToSlash(d)); ok {
+					return true
+				}
+			}
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// apply drops the findings of diags in excluded files, and the fixes that
+// are disabled.
+func (cfg *config) apply(diags []checker.Diagnostic) []checker.Diagnostic {
+	noFix := map[string]bool{}
+	for _, id := range cfg.Fix.Disable {
+		noFix[id] = true
+	}
+	var kept []checker.Diagnostic
+	for _, d := range diags {
+		if cfg.excluded(d.Position.Filename) {
+			continue
+		}
+		if noFix[d.Category] {
+			d.SuggestedFixes = nil
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+// severity returns the severity of d: that set by -severity, or else by
+// cfg, or else warning.
+func (cfg *config) severity(d checker.Diagnostic) string {
+	return cfg.ruleSeverity(ruleOf(d))
+}
+
+// ruleSeverities returns the severities of the rules set by -severity or
+// by cfg, by ID.
+func (cfg *config) ruleSeverities() map[string]string {
+	m := map[string]string{}
+	for id, s := range cfg.Severity {
+		m[id] = s
+	}
+	for id, s := range severities {
+		m[id] = s
+	}
+	return m
+}
+
+// ruleSeverity returns the severity of the findings of the rule with the
+// given ID.
+func (cfg *config) ruleSeverity(id string) string {
+	if s, ok := severities[id]; ok {
+		return s
+	}
+	if s, ok := cfg.Severity[id]; ok {
+		return s
+	}
+	return "warning"
+}
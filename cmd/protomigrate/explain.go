@@ -0,0 +1,79 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/protobuf-tools/protomigrate"
+)
+
+// explain prints the explanations of the rules named by args, by ID or
+// name, or lists the rules if args is empty.
+func explain(w io.Writer, args []string) error {
+	bw := bufio.NewWriter(w)
+	if len(args) == 0 {
+		for _, r := range protomigrate.Rules() {
+			fmt.Fprintf(bw, "%s %-18s %s\n", r.ID, r.Name, r.Doc)
+		}
+		return bw.Flush()
+	}
+	for i, arg := range args {
+		r, e, ok := protomigrate.Explain(arg)
+		if !ok {
+			return fmt.Errorf("unknown rule: %q", arg)
+		}
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "%s %s: %s\n", r.ID, r.Name, r.Doc)
+		if e.Description != "" {
+			fmt.Fprintln(bw)
+			wrap(bw, e.Description, "", 76)
+		}
+		if e.Before != "" {
+			fmt.Fprintf(bw, "\nBefore:\n\n%s\n", indent(e.Before))
+		}
+		if e.After != "" {
+			fmt.Fprintf(bw, "\nAfter:\n\n%s\n", indent(e.After))
+		}
+		if len(e.Caveats) > 0 {
+			fmt.Fprintf(bw, "\nCaveats:\n\n")
+			for _, c := range e.Caveats {
+				wrap(bw, c, "  - ", 76)
+			}
+		}
+		if u := r.DocURL(); u != "" {
+			fmt.Fprintf(bw, "\nSee %s\n", u)
+		}
+	}
+	return bw.Flush()
+}
+
+// wrap writes text to w in lines of at most width characters, where words
+// allow, the first prefixed with prefix and the others indented as much.
+func wrap(w io.Writer, text, prefix string, width int) {
+	line, empty := prefix, true
+	for _, word := range strings.Fields(text) {
+		switch {
+		case empty:
+		case len(line)+1+len(word) > width:
+			fmt.Fprintln(w, line)
+			line = strings.Repeat(" ", len(prefix))
+		default:
+			line += " "
+		}
+		line += word
+		empty = false
+	}
+	fmt.Fprintln(w, line)
+}
+
+// indent indents each line of code by a tab.
+func indent(code string) string {
+	return "\t" + strings.Replace(code, "\n", "\n\t", -1)
+}
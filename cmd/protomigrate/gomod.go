@@ -0,0 +1,672 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+const (
+	protoV1Module = "github.com/golang/protobuf"
+	protoV2Module = "google.golang.org/protobuf"
+
+	// hybridVersion is the first version of protoV1Module implemented over
+	// the runtime of protoV2Module.
+	hybridVersion = "v1.4.0"
+)
+
+// moduleVersionRule, dependencyVersionRule, moduleReplaceRule and
+// codegenVersionRule are the IDs of the rules of the findings of
+// checkModules.
+const (
+	moduleVersionRule     = "PM1003"
+	dependencyVersionRule = "PM1005"
+	moduleReplaceRule     = "PM1006"
+	codegenVersionRule    = "PM1007"
+)
+
+const grpcModule = "google.golang.org/grpc"
+
+// grpcSupportVersions maps the N of the grpc.SupportPackageIsVersionN
+// constants the code generated for gRPC services asserts to the first
+// versions of grpc-go declaring them. The older ones are declared by all
+// the versions the v2 API builds with.
+var grpcSupportVersions = map[int]string{
+	6: "v1.27.0",
+	7: "v1.32.0",
+	8: "v1.62.0",
+	9: "v1.64.0",
+}
+
+// protoimplGenVersions maps the generation versions the code protoc-gen-go
+// generates asserts with protoimpl.EnforceVersion to the first versions of
+// google.golang.org/protobuf supporting them.
+var protoimplGenVersions = map[int]string{
+	20: "v1.20.0",
+}
+
+// protoV2APIVersions maps the APIs of google.golang.org/protobuf the fixes
+// use that its first versions lack to the first versions that have them:
+// packages by their path, functions by the path of their package and their
+// name, and the methods of the well-known types by their name.
+var protoV2APIVersions = map[string]string{
+	protoV2Module + "/proto.MessageName":           "v1.26.0",
+	protoV2Module + "/protoadapt":                  "v1.30.0",
+	protoV2Module + "/types/known/anypb.New":       "v1.25.0",
+	protoV2Module + "/types/known/durationpb.New":  "v1.25.0",
+	protoV2Module + "/types/known/timestamppb.New": "v1.25.0",
+	protoV2Module + "/types/known/timestamppb.Now": "v1.25.0",
+	"AsDuration":   "v1.25.0",
+	"AsTime":       "v1.25.0",
+	"CheckValid":   "v1.25.0",
+	"MessageIs":    "v1.25.0",
+	"UnmarshalNew": "v1.25.0",
+	"UnmarshalTo":  "v1.25.0",
+}
+
+// dependencyConflicts lists the versions of the modules of genproto and
+// grpc-go that conflict with google.golang.org/protobuf, from its version
+// since on, or with another module of the build, with.
+var dependencyConflicts = []struct {
+	path, fixed string
+	since, with string
+	reason      string
+}{
+	{
+		path:   "google.golang.org/genproto",
+		fixed:  "v0.0.0-20200526211855-cb27e3aa2013",
+		since:  "v1.26.0",
+		reason: "its packages of the field_mask, api, type and source_context .proto files of the well-known types register them along with the types/known packages, which panics from that version on",
+	},
+	{
+		path:   "google.golang.org/genproto",
+		fixed:  "v0.0.0-20230410155749-daa745c078e1",
+		with:   "google.golang.org/genproto/googleapis/rpc",
+		reason: "its googleapis/rpc packages moved to that module, which makes their imports ambiguous",
+	},
+	{
+		path:   "google.golang.org/genproto",
+		fixed:  "v0.0.0-20230410155749-daa745c078e1",
+		with:   "google.golang.org/genproto/googleapis/api",
+		reason: "its googleapis/api packages moved to that module, which makes their imports ambiguous",
+	},
+	{
+		path:   "google.golang.org/grpc",
+		fixed:  "v1.32.0",
+		since:  "v1.20.0",
+		reason: "its protoc-gen-go no longer generates the gRPC services, and the code protoc-gen-go-grpc generates for them instead needs grpc v1.32.0",
+	},
+}
+
+// checkModules reports, in the go.mod file of each main module of pkgs, the
+// version of github.com/golang/protobuf its build resolves: up to v1.3,
+// the v1 API has a runtime of its own, and the module is to be upgraded
+// first; from v1.4, it is implemented over the v2 runtime, and the code is
+// to be migrated; once no package imports it, it is to be dropped. It also
+// reports the versions of genproto and grpc-go that conflict with the
+// version of google.golang.org/protobuf the migration targets, -protobuf
+// or else that of the build, as dependencyConflicts lists them, and the
+// replacements of the protobuf modules with forks or directories, and the
+// versions of grpc-go and google.golang.org/protobuf older than the
+// generated files of the module need. The go.mod files are added to fset,
+// so that the findings have positions. With -shard, a module is checked in
+// the shard its path is in.
+func checkModules(fset *token.FileSet, pkgs []*packages.Package) ([]checker.Diagnostic, error) {
+	enabled := false
+	for _, id := range []string{moduleVersionRule, dependencyVersionRule, moduleReplaceRule, codegenVersionRule} {
+		enabled = enabled || protomigrate.RuleEnabled(id)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	mods, roots := mainModules(pkgs)
+	var diags []checker.Diagnostic
+	for _, m := range mods {
+		if !shard.has(m.Path) {
+			continue
+		}
+		g, err := readGoMod(fset, m)
+		if err != nil {
+			return nil, err
+		}
+		versions := moduleVersions(roots[m])
+		if protomigrate.RuleEnabled(moduleVersionRule) {
+			if d, ok := checkProtoVersion(g, versions); ok {
+				diags = append(diags, d)
+			}
+		}
+		if protomigrate.RuleEnabled(dependencyVersionRule) {
+			diags = append(diags, checkDependencyVersions(g, versions)...)
+		}
+		if protomigrate.RuleEnabled(moduleReplaceRule) {
+			diags = append(diags, checkReplaces(g)...)
+		}
+		if protomigrate.RuleEnabled(codegenVersionRule) {
+			diags = append(diags, checkCodegenVersions(g, roots[m], versions)...)
+		}
+	}
+	return diags, nil
+}
+
+// checkProtoVersion returns the finding of the version of
+// github.com/golang/protobuf of the main module of g, whose build resolves
+// the given versions of the modules, if it has one.
+func checkProtoVersion(g *goMod, versions map[string]string) (checker.Diagnostic, bool) {
+	req := g.require(protoV1Module)
+	v1, v2 := versions[protoV1Module], versions[protoV2Module]
+	var msg string
+	switch {
+	case v1 != "" && semver.Compare(v1, hybridVersion) < 0:
+		msg = fmt.Sprintf("module %s builds with %s %s, which implements the v1 API on a runtime of its own; upgrade it to %s or later, which implements it over the v2 runtime of %s, before migrating the code", g.m.Path, protoV1Module, v1, strings.TrimSuffix(hybridVersion, ".0"), protoV2Module)
+	case v1 != "":
+		msg = fmt.Sprintf("module %s builds with %s %s, which implements the v1 API over the v2 runtime of %s %s; migrate the code to the v2 API, then drop the requirement of %s", g.m.Path, protoV1Module, v1, protoV2Module, v2, protoV1Module)
+	case req != nil:
+		msg = fmt.Sprintf("module %s is on the v2 API only, but still requires %s %s, which no package imports; drop the requirement with go mod tidy", g.m.Path, protoV1Module, req.Mod.Version)
+	default:
+		return checker.Diagnostic{}, false
+	}
+	return g.diagnostic(moduleVersionRule, protoV1Module, msg), true
+}
+
+// checkDependencyVersions returns the findings of the versions of the
+// modules of dependencyConflicts the build of the main module of g
+// resolves, given with those of the other modules in versions, or else
+// that g requires, since a conflict may only break the build once a
+// package imports the modules.
+func checkDependencyVersions(g *goMod, versions map[string]string) []checker.Diagnostic {
+	version := func(path string) string {
+		if v := versions[path]; v != "" {
+			return v
+		}
+		if req := g.require(path); req != nil {
+			return req.Mod.Version
+		}
+		return ""
+	}
+	target := *protobufVersion
+	if target == "" {
+		target = version(protoV2Module)
+	}
+	var diags []checker.Diagnostic
+	for _, c := range dependencyConflicts {
+		v := version(c.path)
+		if v == "" || semver.Compare(v, c.fixed) >= 0 {
+			continue
+		}
+		var msg string
+		switch {
+		case c.with != "":
+			with := version(c.with)
+			if with == "" {
+				continue
+			}
+			msg = fmt.Sprintf("module %s builds with %s %s along with %s %s: %s; upgrade %s to %s or later", g.m.Path, c.path, v, c.with, with, c.reason, c.path, c.fixed)
+		default:
+			if target == "" || semver.Compare(target, c.since) < 0 {
+				continue
+			}
+			msg = fmt.Sprintf("module %s builds with %s %s, which conflicts with %s %s: %s; upgrade it to %s or later", g.m.Path, c.path, v, protoV2Module, target, c.reason, c.fixed)
+		}
+		diags = append(diags, g.diagnostic(dependencyVersionRule, c.path, msg))
+	}
+	return diags
+}
+
+// checkReplaces returns the findings of the replace directives of g that
+// redirect a protobuf module to another module, a fork, or to a directory.
+// Forks are commonly kept to pin the behavior of the versions before v1.4,
+// which the findings, made for the upstream module, do not account for.
+func checkReplaces(g *goMod) []checker.Diagnostic {
+	var diags []checker.Diagnostic
+	for _, r := range g.f.Replace {
+		if r.Old.Path != protoV1Module && r.Old.Path != protoV2Module {
+			continue
+		}
+		if r.New.Path == r.Old.Path {
+			continue
+		}
+		what := "the fork " + r.New.Path + " " + r.New.Version
+		if r.New.Version == "" {
+			what = "the directory " + r.New.Path
+		}
+		msg := fmt.Sprintf("module %s replaces %s with %s, which may not behave as the upstream module the migration is made for, like the versions before v1.4; migrate to the upstream module first, or make sure the fork tracks it", g.m.Path, r.Old.Path, what)
+		diags = append(diags, g.diagnosticAt(moduleReplaceRule, r.Syntax.Start.Line, msg))
+	}
+	return diags
+}
+
+// checkCodegenVersions returns the findings of the versions of grpc-go and
+// google.golang.org/protobuf the build of the main module of g resolves,
+// given in versions, or else that g requires, that are older than the
+// generated files of pkgs, its packages, need: those the
+// grpc.SupportPackageIsVersionN and protoimpl.EnforceVersion guards of the
+// files assert, which fail to compile with older versions, and, for the
+// files generated by protoc-gen-go of google.golang.org/protobuf, its
+// version, since the code it generates may use the runtime of the same
+// version.
+func checkCodegenVersions(g *goMod, pkgs []*packages.Package, versions map[string]string) []checker.Diagnostic {
+	type fileNeed struct {
+		file string
+		codegenNeed
+	}
+	needs := map[string][]fileNeed{}
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Syntax {
+			name := pkg.Fset.File(f.Pos()).Name()
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			rel, err := filepath.Rel(filepath.Dir(g.m.GoMod), name)
+			if err != nil {
+				rel = name
+			}
+			for path, ns := range codegenNeeds(f) {
+				for _, n := range ns {
+					needs[path] = append(needs[path], fileNeed{filepath.ToSlash(rel), n})
+				}
+			}
+		}
+	}
+	var diags []checker.Diagnostic
+	for _, path := range []string{grpcModule, protoV2Module} {
+		v := versions[path]
+		if v == "" {
+			if req := g.require(path); req != nil {
+				v = req.Mod.Version
+			}
+		}
+		if v == "" {
+			continue
+		}
+		// A file is listed with the newest version it needs.
+		newest := map[string]fileNeed{}
+		max := ""
+		for _, n := range needs[path] {
+			if semver.Compare(v, n.version) >= 0 {
+				continue
+			}
+			if prev, ok := newest[n.file]; !ok || semver.Compare(n.version, prev.version) > 0 {
+				newest[n.file] = n
+			}
+			if max == "" || semver.Compare(n.version, max) > 0 {
+				max = n.version
+			}
+		}
+		if len(newest) == 0 {
+			continue
+		}
+		var files []string
+		for _, n := range newest {
+			files = append(files, fmt.Sprintf("%s (%s)", n.file, n.reason))
+		}
+		sort.Strings(files)
+		msg := fmt.Sprintf("module %s builds with %s %s, older than its generated files need: %s; upgrade it to %s or later, since the files do not compile with it", g.m.Path, path, v, strings.Join(files, ", "), max)
+		diags = append(diags, g.diagnostic(codegenVersionRule, path, msg))
+	}
+	return diags
+}
+
+// A codegenNeed is a version of a module generated code needs, and the
+// reason it does, like grpc.SupportPackageIsVersion7.
+type codegenNeed struct {
+	version, reason string
+}
+
+// codegenNeeds returns the versions of the modules, by path, that the
+// generated file f needs, as its version guards, like
+// grpc.SupportPackageIsVersion7, and the version of protoc-gen-go its
+// header gives tell.
+func codegenNeeds(f *ast.File) map[string][]codegenNeed {
+	needs := map[string][]codegenNeed{}
+	for _, group := range f.Comments {
+		if group.Pos() > f.Package {
+			break
+		}
+		for _, c := range group.List {
+			line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+			if v := strings.TrimPrefix(line, "protoc-gen-go "); v != line && semver.IsValid(v) && semver.Compare(v, protoimplGenVersions[20]) >= 0 {
+				needs[protoV2Module] = append(needs[protoV2Module], codegenNeed{v, "protoc-gen-go " + v})
+			}
+		}
+	}
+	names := map[string]string{}
+	for _, spec := range f.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		names[name] = path
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			// The guards are declared at the top level.
+			return false
+		case *ast.SelectorExpr:
+			x, ok := n.X.(*ast.Ident)
+			if !ok || names[x.Name] != grpcModule {
+				break
+			}
+			if v, ok := grpcSupportVersions[guardVersion(strings.TrimPrefix(n.Sel.Name, "SupportPackageIsVersion"))]; ok && strings.HasPrefix(n.Sel.Name, "SupportPackageIsVersion") {
+				needs[grpcModule] = append(needs[grpcModule], codegenNeed{v, "grpc." + n.Sel.Name})
+			}
+		case *ast.CallExpr:
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "EnforceVersion" || len(n.Args) != 1 {
+				break
+			}
+			if x, ok := sel.X.(*ast.Ident); !ok || names[x.Name] != protoV2Module+"/runtime/protoimpl" {
+				break
+			}
+			bin, ok := n.Args[0].(*ast.BinaryExpr)
+			if !ok {
+				break
+			}
+			for _, operand := range []ast.Expr{bin.X, bin.Y} {
+				lit, ok := operand.(*ast.BasicLit)
+				if !ok || lit.Kind != token.INT {
+					continue
+				}
+				gen := guardVersion(lit.Value)
+				if v, ok := protoimplGenVersions[gen]; ok {
+					needs[protoV2Module] = append(needs[protoV2Module], codegenNeed{v, fmt.Sprintf("protoimpl.EnforceVersion(%d)", gen)})
+				}
+			}
+		}
+		return true
+	})
+	return needs
+}
+
+// guardVersion returns the version number s spells, or -1.
+func guardVersion(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// goMod is the go.mod file of a main module, added to a file set for the
+// findings in it to have positions.
+type goMod struct {
+	m    *packages.Module
+	f    *modfile.File
+	fset *token.FileSet
+	tf   *token.File
+}
+
+func readGoMod(fset *token.FileSet, m *packages.Module) (*goMod, error) {
+	data, err := ioutil.ReadFile(m.GoMod)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.ParseLax(m.GoMod, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	tf := fset.AddFile(m.GoMod, -1, len(data))
+	tf.SetLinesForContent(data)
+	return &goMod{m, f, fset, tf}, nil
+}
+
+// require returns the requirement of the module at path, or nil.
+func (g *goMod) require(path string) *modfile.Require {
+	for _, r := range g.f.Require {
+		if r.Mod.Path == path {
+			return r
+		}
+	}
+	return nil
+}
+
+// diagnostic returns the finding of the rule with the given ID and message
+// at the requirement of the module at path, or else the module statement.
+func (g *goMod) diagnostic(rule, path, msg string) checker.Diagnostic {
+	line := 1
+	if req := g.require(path); req != nil {
+		line = req.Syntax.Start.Line
+	} else if g.f.Module != nil {
+		line = g.f.Module.Syntax.Start.Line
+	}
+	return g.diagnosticAt(rule, line, msg)
+}
+
+// diagnosticAt returns the finding of the rule with the given ID and
+// message at the given line.
+func (g *goMod) diagnosticAt(rule string, line int, msg string) checker.Diagnostic {
+	if r, ok := protomigrate.LookupRule(rule); ok {
+		msg = r.AddDocNote(msg)
+	}
+	pos := g.tf.LineStart(line)
+	return checker.Diagnostic{
+		Diagnostic: analysis.Diagnostic{Pos: pos, Category: rule, Message: msg},
+		Analyzer:   protomigrate.Analyzer,
+		Position:   g.fset.Position(pos),
+		Package:    g.m.Path,
+	}
+}
+
+// fixModules adds to fixes the edits of the go.mod files of the main
+// modules of pkgs whose files the fixes make use google.golang.org/protobuf:
+// a requirement is added at the version their build resolves if they do
+// not require it directly, and raised to the first version with the APIs
+// the fixes use otherwise, so that the fixed code builds. It returns the
+// directories of the modules whose files the fixes change, for go mod tidy
+// to drop the requirements left unused.
+func fixModules(pkgs []*packages.Package, fixes *checker.Fixes) ([]string, error) {
+	mods, roots := mainModules(pkgs)
+	var dirs []string
+	for _, m := range mods {
+		changed, imports, needed, err := changedImports(roots[m], fixes)
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			continue
+		}
+		dirs = append(dirs, filepath.Dir(m.GoMod))
+		if !imports[protoV2Module] && needed == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(m.GoMod)
+		if err != nil {
+			return nil, err
+		}
+		f, err := modfile.Parse(m.GoMod, data, nil)
+		if err != nil {
+			return nil, err
+		}
+		var req *modfile.Require
+		for _, r := range f.Require {
+			if r.Mod.Path == protoV2Module {
+				req = r
+			}
+		}
+		switch {
+		case req != nil && !req.Indirect:
+			if semver.Compare(req.Mod.Version, needed) >= 0 {
+				continue
+			}
+			if err := f.AddRequire(protoV2Module, needed); err != nil {
+				return nil, err
+			}
+		default:
+			v := moduleVersions(roots[m])[protoV2Module]
+			if v == "" {
+				log.Printf("%s: the fixes import %s, which the build does not resolve; require it with go get", m.GoMod, protoV2Module)
+				continue
+			}
+			if semver.Compare(v, needed) < 0 {
+				v = needed
+			}
+			if req != nil {
+				if err := f.DropRequire(protoV2Module); err != nil {
+					return nil, err
+				}
+			}
+			f.AddNewRequire(protoV2Module, v, false)
+		}
+		f.Cleanup()
+		out, err := f.Format()
+		if err != nil {
+			return nil, err
+		}
+		fixes.Files[m.GoMod] = out
+	}
+	return dirs, nil
+}
+
+// changedImports reports whether the fixes change files of pkgs, which of
+// the protobuf modules the changed files import once fixed, and the first
+// version of google.golang.org/protobuf with the APIs of protoV2APIVersions
+// the fixes use, or "" if they use none.
+func changedImports(pkgs []*packages.Package, fixes *checker.Fixes) (bool, map[string]bool, string, error) {
+	changed := false
+	imports := map[string]bool{}
+	needed := ""
+	need := func(api string) {
+		if v, ok := protoV2APIVersions[api]; ok && semver.Compare(v, needed) > 0 {
+			needed = v
+		}
+	}
+	fset := token.NewFileSet()
+	for _, pkg := range pkgs {
+		for _, name := range pkg.CompiledGoFiles {
+			src, ok := fixes.Files[name]
+			if !ok {
+				continue
+			}
+			changed = true
+			f, err := parser.ParseFile(fset, name, src, 0)
+			if err != nil {
+				return false, nil, "", err
+			}
+			names := map[string]string{}
+			for _, spec := range f.Imports {
+				path, _ := strconv.Unquote(spec.Path.Value)
+				for _, m := range []string{protoV1Module, protoV2Module} {
+					if path == m || strings.HasPrefix(path, m+"/") {
+						imports[m] = true
+					}
+				}
+				need(path)
+				if spec.Name != nil {
+					names[spec.Name.Name] = path
+				} else {
+					names[path[strings.LastIndex(path, "/")+1:]] = path
+				}
+			}
+
+			// The methods are told from those of other types by the fixes
+			// adding calls of them.
+			orig, err := parser.ParseFile(fset, name, nil, 0)
+			if err != nil {
+				return false, nil, "", err
+			}
+			methods := selectedNames(orig)
+			for sel, n := range selectedNames(f) {
+				if n > methods[sel] {
+					need(sel)
+				}
+			}
+			ast.Inspect(f, func(node ast.Node) bool {
+				if sel, ok := node.(*ast.SelectorExpr); ok {
+					if x, ok := sel.X.(*ast.Ident); ok && names[x.Name] != "" {
+						need(names[x.Name] + "." + sel.Sel.Name)
+					}
+				}
+				return true
+			})
+		}
+	}
+	return changed, imports, needed, nil
+}
+
+// selectedNames counts the selectors of file by the name they select.
+func selectedNames(file *ast.File) map[string]int {
+	names := map[string]int{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			names[sel.Sel.Name]++
+		}
+		return true
+	})
+	return names
+}
+
+// mainModules returns the main modules of pkgs with a go.mod file, sorted
+// by it, and the packages of pkgs in each.
+func mainModules(pkgs []*packages.Package) ([]*packages.Module, map[*packages.Module][]*packages.Package) {
+	roots := map[*packages.Module][]*packages.Package{}
+	var mods []*packages.Module
+	for _, pkg := range pkgs {
+		if m := pkg.Module; m != nil && m.Main && m.GoMod != "" {
+			if _, ok := roots[m]; !ok {
+				mods = append(mods, m)
+			}
+			roots[m] = append(roots[m], pkg)
+		}
+	}
+	sort.Slice(mods, func(i, j int) bool { return mods[i].GoMod < mods[j].GoMod })
+	return mods, roots
+}
+
+// moduleVersions returns the versions of the modules the build of pkgs
+// resolves, which are those of the modules of the packages they import, as
+// minimal version selection picked them.
+func moduleVersions(pkgs []*packages.Package) map[string]string {
+	versions := map[string]string{}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if dep := pkg.Module; dep != nil && !dep.Main {
+			versions[dep.Path] = moduleVersion(dep)
+		}
+	})
+	return versions
+}
+
+// tidyModules runs go mod tidy in each of dirs, which adds the
+// requirements the fixed code calls for and drops those it no longer does,
+// like that of github.com/golang/protobuf once no package imports it.
+func tidyModules(dirs []string) error {
+	for _, dir := range dirs {
+		cmd := exec.Command("go", "mod", "tidy")
+		cmd.Dir = dir
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("go mod tidy in %s: %v", dir, err)
+		}
+	}
+	return nil
+}
+
+// moduleVersion returns the version of m, or of the module replacing it.
+func moduleVersion(m *packages.Module) string {
+	if m.Replace != nil && m.Replace.Version != "" {
+		return m.Replace.Version
+	}
+	return m.Version
+}
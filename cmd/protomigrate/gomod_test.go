@@ -0,0 +1,131 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// TestFixModules checks that the go.mod files of the main modules whose
+// fixed files import google.golang.org/protobuf require it directly, at a
+// version with the APIs they use.
+func TestFixModules(t *testing.T) {
+	const (
+		src   = "package p\n\nimport \"github.com/golang/protobuf/ptypes\"\n\nvar _ = ptypes.Timestamp\n"
+		fixed = "package p\n\nimport \"google.golang.org/protobuf/proto\"\n\nvar _ = proto.Marshal\n"
+	)
+	tests := []struct {
+		name  string
+		gomod string
+		src   string
+		fixed string
+		want  string
+	}{
+		{
+			name:  "add",
+			gomod: "module example.com/m\n\ngo 1.15\n\nrequire github.com/golang/protobuf v1.4.3\n",
+			want:  "module example.com/m\n\ngo 1.15\n\nrequire (\n\tgithub.com/golang/protobuf v1.4.3\n\tgoogle.golang.org/protobuf v1.25.0\n)\n",
+		},
+		{
+			// The build resolves a later version than the indirect
+			// requirement.
+			name:  "bump",
+			gomod: "module example.com/m\n\ngo 1.15\n\nrequire (\n\tgithub.com/golang/protobuf v1.4.3\n\tgoogle.golang.org/protobuf v1.23.0 // indirect\n)\n",
+			want:  "module example.com/m\n\ngo 1.15\n\nrequire (\n\tgithub.com/golang/protobuf v1.4.3\n\tgoogle.golang.org/protobuf v1.25.0\n)\n",
+		},
+		{
+			name:  "direct",
+			gomod: "module example.com/m\n\ngo 1.15\n\nrequire google.golang.org/protobuf v1.20.0\n",
+		},
+		{
+			// timestamppb.New is only there from v1.25.0 on.
+			name:  "function",
+			gomod: "module example.com/m\n\ngo 1.15\n\nrequire google.golang.org/protobuf v1.20.0\n",
+			fixed: "package p\n\nimport \"google.golang.org/protobuf/types/known/timestamppb\"\n\nvar _ = timestamppb.New\n",
+			want:  "module example.com/m\n\ngo 1.15\n\nrequire google.golang.org/protobuf v1.25.0\n",
+		},
+		{
+			// The build resolves an older version than protoadapt needs.
+			name:  "package",
+			gomod: "module example.com/m\n\ngo 1.15\n\nrequire github.com/golang/protobuf v1.4.3\n",
+			fixed: "package p\n\nimport \"google.golang.org/protobuf/protoadapt\"\n\nvar _ = protoadapt.MessageV2Of\n",
+			want:  "module example.com/m\n\ngo 1.15\n\nrequire (\n\tgithub.com/golang/protobuf v1.4.3\n\tgoogle.golang.org/protobuf v1.30.0\n)\n",
+		},
+		{
+			// The fix calls AsTime on a message of another package.
+			name:  "method",
+			gomod: "module example.com/m\n\ngo 1.15\n\nrequire google.golang.org/protobuf v1.20.0\n",
+			fixed: "package p\n\nimport \"example.com/m/q\"\n\nvar _ = q.Stamp.AsTime\n",
+			want:  "module example.com/m\n\ngo 1.15\n\nrequire google.golang.org/protobuf v1.25.0\n",
+		},
+		{
+			// The method was already called before the fixes.
+			name:  "called",
+			gomod: "module example.com/m\n\ngo 1.15\n\nrequire google.golang.org/protobuf v1.20.0\n",
+			src:   "package p\n\nimport \"example.com/m/q\"\n\nvar _ = q.Stamp.AsTime // TODO\n",
+			fixed: "package p\n\nimport \"example.com/m/q\"\n\nvar _ = q.Stamp.AsTime\n",
+		},
+		{
+			name: "blocks",
+			gomod: "module example.com/m\n\ngo 1.15\n\nrequire (\n\tgithub.com/golang/protobuf v1.4.3\n\tgolang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect\n)\n\n" +
+				"replace github.com/golang/protobuf => ../protobuf\n",
+			want: "module example.com/m\n\ngo 1.15\n\nrequire (\n\tgithub.com/golang/protobuf v1.4.3\n\tgolang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect\n\tgoogle.golang.org/protobuf v1.25.0\n)\n\n" +
+				"replace github.com/golang/protobuf => ../protobuf\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			gomod := filepath.Join(dir, "go.mod")
+			if err := ioutil.WriteFile(gomod, []byte(tt.gomod), 0666); err != nil {
+				t.Fatal(err)
+			}
+			name := filepath.Join(dir, "p.go")
+			orig := src
+			if tt.src != "" {
+				orig = tt.src
+			}
+			if err := ioutil.WriteFile(name, []byte(orig), 0666); err != nil {
+				t.Fatal(err)
+			}
+			fixed := fixed
+			if tt.fixed != "" {
+				fixed = tt.fixed
+			}
+			pkg := &packages.Package{
+				PkgPath:         "example.com/m",
+				CompiledGoFiles: []string{name},
+				Module:          &packages.Module{Path: "example.com/m", Main: true, GoMod: gomod},
+				Imports: map[string]*packages.Package{
+					"google.golang.org/protobuf/proto": {
+						PkgPath: "google.golang.org/protobuf/proto",
+						Module:  &packages.Module{Path: protoV2Module, Version: "v1.25.0"},
+					},
+				},
+			}
+			fixes := &checker.Fixes{Files: map[string][]byte{name: []byte(fixed)}}
+
+			dirs, err := fixModules([]*packages.Package{pkg}, fixes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dirs) != 1 || dirs[0] != dir {
+				t.Errorf("fixModules returned the directories %q, want %q", dirs, dir)
+			}
+			got, ok := fixes.Files[gomod]
+			switch {
+			case tt.want == "" && ok:
+				t.Errorf("go.mod fixed as:\n%s\nwant it unchanged", got)
+			case tt.want != "" && string(got) != tt.want:
+				t.Errorf("go.mod fixed as:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
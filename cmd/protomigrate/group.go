@@ -0,0 +1,64 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// groupSamples is the number of findings a group relates to.
+const groupSamples = 3
+
+// groupByImport returns diags with the findings of a rule in a package
+// grouped into one, at the first of them, usually the import they go
+// through. A group tells how many findings it has, and relates to the
+// first few; the findings alone in their group are left as they are.
+// diags are sorted by position, and so are the groups.
+func groupByImport(diags []checker.Diagnostic) []checker.Diagnostic {
+	type groupKey struct {
+		pkg, rule string
+	}
+	groups := map[groupKey][]checker.Diagnostic{}
+	var keys []groupKey
+	for _, d := range diags {
+		key := groupKey{d.Package, ruleOf(d)}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], d)
+	}
+
+	grouped := make([]checker.Diagnostic, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		first := group[0]
+		if len(group) == 1 {
+			grouped = append(grouped, first)
+			continue
+		}
+		msg, note := first.Message, ""
+		if r, ok := protomigrate.LookupRule(key.rule); ok {
+			msg = r.TrimDocNote(first.Message)
+			note = first.Message[len(msg):]
+			key.rule += " " + r.Name
+		}
+		d := first
+		d.Message = fmt.Sprintf("%d findings of %s in package %s, like: %s%s", len(group), key.rule, key.pkg, msg, note)
+		d.SuggestedFixes = nil
+		d.Related = nil
+		for _, s := range group[1:] {
+			if len(d.Related) == groupSamples {
+				break
+			}
+			d.Related = append(d.Related, analysis.RelatedInformation{Pos: s.Pos, End: s.End, Message: s.Message})
+		}
+		grouped = append(grouped, d)
+	}
+	return grouped
+}
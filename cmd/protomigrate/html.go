@@ -0,0 +1,216 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+	"github.com/protobuf-tools/protomigrate/internal/diff"
+	"github.com/protobuf-tools/protomigrate/migrate"
+)
+
+// excerptContext is the number of lines an excerpt shows around the lines
+// of a finding.
+const excerptContext = 2
+
+// htmlWriter writes the output of -format=html, a report in a single page
+// with the findings by package, their code and the previews of their
+// fixes, and the descriptions of their rules. Since the page starts with
+// the summary of the findings, they are kept, rendered, until the end.
+type htmlWriter struct {
+	w    io.Writer
+	fset *token.FileSet
+	r    *migrate.Reporter
+
+	pkgs  map[string]*htmlPackage
+	rules map[string]bool
+	n     int
+}
+
+type htmlPackage struct {
+	Path     string
+	Findings []htmlFinding
+	Fixable  int
+}
+
+type htmlFinding struct {
+	Position string
+	Rule     string
+	Severity string
+	Message  string
+
+	// Excerpt holds the lines of the finding, with those around them, and
+	// Fix the unified diff of its first fix, if it has one.
+	Excerpt []htmlLine
+	Fix     string
+}
+
+type htmlLine struct {
+	Number int
+	Text   string
+	Marked bool
+}
+
+type htmlRule struct {
+	protomigrate.Rule
+	protomigrate.Explanation
+}
+
+func newHTMLWriter(w io.Writer, fset *token.FileSet, r *migrate.Reporter) *htmlWriter {
+	return &htmlWriter{w: w, fset: fset, r: r, pkgs: map[string]*htmlPackage{}, rules: map[string]bool{}}
+}
+
+func (hw *htmlWriter) write(diags []checker.Diagnostic) error {
+	// The diagnostics are written a package at a time, whose files are
+	// read once for all its findings.
+	files := map[string][]string{}
+	for _, d := range diags {
+		finding := findingOf(hw.r, d)
+		start, end := finding.Position, finding.Position
+		if finding.End != nil {
+			end = *finding.End
+		}
+		lines, ok := files[start.File]
+		if !ok {
+			if data, err := ioutil.ReadFile(start.File); err == nil {
+				lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			}
+			files[start.File] = lines
+		}
+
+		posn := start
+		posn.File = relPath(posn.File)
+		f := htmlFinding{
+			Position: posn.String(),
+			Rule:     finding.Rule,
+			Severity: finding.Severity,
+			Message:  finding.Message,
+		}
+		for n := start.Line - excerptContext; n <= end.Line+excerptContext; n++ {
+			if n >= 1 && n <= len(lines) {
+				f.Excerpt = append(f.Excerpt, htmlLine{n, lines[n-1], n >= start.Line && n <= end.Line})
+			}
+		}
+		if len(d.SuggestedFixes) > 0 {
+			d := d
+			d.SuggestedFixes = d.SuggestedFixes[:1]
+			fixes, err := checker.ApplyFixes(hw.fset, []checker.Diagnostic{d})
+			if err != nil {
+				return err
+			}
+			f.Fix = fixDiff(fixes)
+		}
+
+		pkg := hw.pkgs[finding.Package]
+		if pkg == nil {
+			pkg = &htmlPackage{Path: finding.Package}
+			hw.pkgs[finding.Package] = pkg
+		}
+		pkg.Findings = append(pkg.Findings, f)
+		if len(finding.Fixes) > 0 {
+			pkg.Fixable++
+		}
+		hw.rules[f.Rule] = true
+		hw.n++
+	}
+	return nil
+}
+
+func (hw *htmlWriter) close() error {
+	data := struct {
+		Findings int
+		Packages []*htmlPackage
+		Rules    []htmlRule
+	}{Findings: hw.n}
+	for _, pkg := range hw.pkgs {
+		data.Packages = append(data.Packages, pkg)
+	}
+	sort.Slice(data.Packages, func(i, j int) bool { return data.Packages[i].Path < data.Packages[j].Path })
+	for id := range hw.rules {
+		r, e, ok := protomigrate.Explain(id)
+		if !ok {
+			r = protomigrate.Rule{ID: id}
+		}
+		data.Rules = append(data.Rules, htmlRule{r, e})
+	}
+	sort.Slice(data.Rules, func(i, j int) bool { return data.Rules[i].ID < data.Rules[j].ID })
+	return htmlReport.Execute(hw.w, data)
+}
+
+// fixDiff returns the unified diff of the files fixes change.
+func fixDiff(fixes *checker.Fixes) string {
+	names := make([]string, 0, len(fixes.Files))
+	for name := range fixes.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b bytes.Buffer
+	for _, name := range names {
+		old, err := ioutil.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		rel := relPath(name)
+		b.Write(diff.Unified("a/"+rel, "b/"+rel, old, fixes.Files[name]))
+	}
+	return b.String()
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>protomigrate report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.2em 1em 0.2em 0; }
+summary { cursor: pointer; }
+details.package { margin: 0.5em 0; }
+.finding { margin: 1em 0 1em 1.5em; }
+.rule, .severity { font-size: 0.9em; color: #555; }
+pre { background: #f6f8fa; padding: 0.5em; overflow-x: auto; }
+.marked { background: #fff5b1; }
+.number { color: #999; user-select: none; }
+</style>
+</head>
+<body>
+<h1>protomigrate report</h1>
+<p>{{.Findings}} findings in {{len .Packages}} packages.</p>
+<table>
+<tr><th>Package</th><th>Findings</th><th>Fixable</th></tr>
+{{range .Packages}}<tr><td><a href="#{{.Path}}">{{.Path}}</a></td><td>{{len .Findings}}</td><td>{{.Fixable}}</td></tr>
+{{end}}</table>
+
+<h2>Packages</h2>
+{{range .Packages}}<details class="package" id="{{.Path}}">
+<summary>{{.Path}}: {{len .Findings}} findings</summary>
+{{range .Findings}}<div class="finding">
+<div><code>{{.Position}}</code> <a class="rule" href="#{{.Rule}}">{{.Rule}}</a> <span class="severity">{{.Severity}}</span></div>
+<p>{{.Message}}</p>
+{{if .Excerpt}}<pre>{{range .Excerpt}}<span class="{{if .Marked}}marked{{end}}"><span class="number">{{printf "%5d" .Number}}</span>  {{.Text}}</span>
+{{end}}</pre>{{end}}
+{{if .Fix}}<details><summary>Fix</summary><pre>{{.Fix}}</pre></details>{{end}}
+</div>
+{{end}}</details>
+{{end}}
+<h2>Rules</h2>
+{{range .Rules}}<h3 id="{{.ID}}">{{.ID}} {{.Name}}</h3>
+{{if .Doc}}<p>{{.Doc}}</p>{{end}}
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{if .Before}}<p>Before:</p><pre>{{.Before}}</pre>{{end}}
+{{if .After}}<p>After:</p><pre>{{.After}}</pre>{{end}}
+{{if .Caveats}}<p>Caveats:</p><ul>{{range .Caveats}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{with .DocURL}}<p><a href="{{.}}">{{.}}</a></p>{{end}}
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,925 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Command protomigrate reports the uses of the Go protobuf v1 API in the
+// named packages, and how to migrate them to the v2 API.
+//
+// Usage:
+//
+//	protomigrate [-flag] [package...]
+//	protomigrate explain [rule...]
+//	protomigrate suppress [package...]
+//	protomigrate merge [file...]
+//	protomigrate plan [package...]
+//	protomigrate blockers [package...]
+//	protomigrate stats [package...]
+//	protomigrate proto [directory...]
+//	protomigrate regen [package...]
+//	protomigrate bazel [package...]
+//
+// With -fix, the suggested fixes are applied to the files in place; with
+// -dry-run, the files that -fix would change are only listed, and with
+// -diff, the changes are printed as a unified diff that git apply accepts.
+//
+// Each fix is safe, if it preserves the semantics of the code, mostly-safe,
+// if it preserves them but for details like the exact output of a
+// marshaler, or unsafe, if it calls for a review, like the rewrite of ==
+// to proto.Equal. With -fix-level=safe, or mostly-safe, only the fixes up
+// to that level are suggested, and so applied, which suits automation.
+//
+// The go.mod file of a module whose files the fixes make import packages
+// of google.golang.org/protobuf is fixed too, requiring it at the version
+// the build resolves if it does not already, or at the first version with
+// the APIs the fixes use, like timestamppb.New from v1.25.0 on, if that is
+// later; with -tidy, -fix also runs go
+// mod tidy in the modules it changes, which updates go.sum and drops the
+// requirement of github.com/golang/protobuf once no package imports it, so
+// that the fixed modules build right away.
+//
+// With -todo, the findings left without a fix get one adding a TODO comment
+// with their message above their statement or declaration instead, so that
+// -fix tracks the migration left to do by hand in the code:
+//
+//	// TODO(protomigrate PM3001): proto.NewBuffer has no v2 counterpart; ...
+//
+// With -format=json, the diagnostics, along with their suggested fixes, are
+// printed as JSON, to the standard output unless it holds the output of
+// -dry-run or -diff:
+//
+//	{"diagnostics": [{"file": ..., "line": ..., "column": ..., "offset": ...,
+//		"end": {...}, "rule": ..., "severity": ..., "message": ...,
+//		"related": [{"file": ..., ..., "message": ...}],
+//		"fixes": [{"message": ..., "edits": [{"start": {...}, "end": {...},
+//		"new_text": ...}]}], "package": ...,
+//		"generator": {"name": ..., "version": ..., "api": ...}}]}
+//
+// The diagnostics are the findings of the Report of package migrate, which
+// programs embedding the migration get alike; fields are only ever added.
+//
+// With -format=sarif, they are printed as a SARIF 2.1.0 log instead, for
+// code scanning tools; files below the current directory are located
+// relative to the %SRCROOT% base. Both relate the uses of deprecated
+// symbols to the declarations of the symbols, and of their replacements
+// where the deprecations name them.
+//
+// With -format=html, they are printed as a report in a single HTML page
+// instead, to share with those who do not run protomigrate: the findings
+// are listed by package, each with an excerpt of its code and a preview of
+// its fix as a diff, and followed by the explanations of their rules.
+//
+// With -group-by=import, the findings of a rule in a package are reported
+// as one, at the first of them, which is usually the import they go
+// through, with their count and the locations of the next few of them, to
+// make sense of packages with hundreds of them; the fixes are left out of
+// the output, though -fix applies them all.
+//
+// The diagnostics of each package are printed once it is analyzed, sorted
+// by position, so that a run over a large repository holds those of one
+// package at a time; -fix, -dry-run, -diff, -write-baseline and
+// -group-by=import, which need those of all packages, print them at the end
+// instead.
+//
+// The configuration file .protomigrate.yaml, looked up in the current
+// directory and its parents unless -config names another, configures the
+// runs in a repository. The flags set on the command line override it:
+//
+//	go: "1.16"          # the -go flag
+//	generated: true     # the -generated flag
+//	own-proto-deprecations: true # the -own-proto-deprecations flag
+//	enable: [PM2001]    # the -enable flag
+//	disable: [wkt]      # the -disable flag
+//	check: [aliasing]   # the -check flag
+//	deprecated:         # the -deprecated flag
+//	  example.com/oldpb: use example.com/newpb instead
+//	deprecations: deprecations.yaml # the -deprecations flag
+//	import-map:         # the -import-map flag
+//	  example.com/fork/protobuf/proto:
+//	    path: github.com/golang/protobuf/proto
+//	    note: the fork adds proto.MarshalCanonical
+//	exclude:            # files whose findings are dropped, relative to the file
+//	  - third_party/...
+//	  - "*_legacy.go"
+//	doc-url: https://wiki.example.com/protomigrate/{id} # the -doc-url flag
+//	severity:           # error, warning (the default), note or info, per rule
+//	  symbol-deprecated: note
+//	  ptypes: error
+//	fix:
+//	  disable: [descriptor] # rules whose findings are fixed by hand
+//	  level: mostly-safe    # the -fix-level flag
+//	  todo: true            # the -todo flag
+//	strict: true        # the -strict flag
+//	fail-on: error      # the -fail-on flag
+//	group-by: import    # the -group-by flag
+//	regen:              # how protomigrate regen regenerates files
+//	  tool: protoc      # or buf
+//	  include: [third_party/proto] # more -I directories of protoc
+//	  template: buf.gen.yaml       # the template of buf generate
+//	rewrites:           # rules rewriting the references to other packages
+//	  - id: ACME1001
+//	    name: acme-marshal
+//	    package: example.com/acme/pbutil
+//	    symbol: MarshalText
+//	    replacement: prototext.Format($1)
+//	    imports: [google.golang.org/protobuf/encoding/prototext]
+//
+// The replacement of a rewrite is the expression replacing the symbol, or
+// the calls of the symbol if it refers to their arguments, $1 to $9, or $*
+// for all of them. The import of the package goes away once the fixes leave
+// it unused. The rules of the rewrites are configured as the built-in ones,
+// in the configuration file; the flags naming rules do not know them.
+//
+// With -cache, the facts and diagnostics of the packages are kept in the
+// named directory, so that the later runs only analyze the packages whose
+// files, or whose dependencies, changed since. The entries of a package are
+// specific to the flags and the protomigrate executable of the run. They
+// are stored as the packages are analyzed, so that a run interrupted, as
+// by running out of memory or time, resumes where it left off when run
+// again; -resume does so with the cache of the protomigrate directory of
+// the user cache directory if -cache is not set.
+//
+// With -baseline, the findings recorded in the named file are neither
+// reported nor fixed, so that only new ones fail a build; -write-baseline
+// records the current findings in it. Findings are matched by their file,
+// rule and message, not their line.
+//
+// The comments of golangci-lint and staticcheck suppress findings in the
+// code: //nolint, bare or naming protomigrate among its linters, all of
+// them, and //lint:ignore and //lint:file-ignore those of the rules they
+// list by ID or name, with a reason. A comment at the end of a line applies
+// to the line, one on lines of its own to the declaration or statement
+// following it, and //lint:file-ignore to its file:
+//
+//	s := proto.CompactTextString(m) //nolint:protomigrate
+//
+//	//lint:ignore PM3001,PM1001 the text format is compared in golden files
+//	func golden(m proto.Message) string {
+//
+// The pragma //protomigrate:ignore suppresses the findings of all rules,
+// or with =rule,... those of the rules it lists, in the same scopes, or in
+// its whole file if it precedes the package clause:
+//
+//	//protomigrate:ignore=PM1001,proto
+//
+//	package legacy
+//
+// The fixes of a suppressed finding are left out along with those of its
+// file they depend on.
+//
+// protomigrate exits with status 1 if the packages cannot be analyzed, and
+// with status 3 if findings are left unfixed. -fail-on restricts the latter
+// to findings of severity error, or warning and error, or turns it off
+// with none, so that a run only reports; it is any by default. The
+// findings are warnings unless the configuration or -severity, which
+// overrides it rule by rule, sets another severity, which the json and
+// sarif formats report too:
+//
+//	protomigrate -severity=ptypes=error,jsonpb=warning -fail-on=error ./...
+//
+// With -debug, each package logs the checks run on it to the standard
+// error, in lines of key=value pairs with the number of their findings and
+// how long they took, and with -debug=trace each finding too, reported or
+// suppressed; -debug-dir writes the log of each package to a file of the
+// named directory instead.
+//
+// With -strict, for modules fully migrated to the v2 API, any use of the v1
+// API left is reported, generated files included, so that none comes back:
+// the imports of github.com/golang/protobuf and its packages, including the
+// well-known types it aliases from google.golang.org/protobuf, the uses of
+// protoadapt, and the exported API whose types mention v1 types. A module
+// declares itself migrated with strict: true in its configuration file.
+//
+// Besides, protomigrate accepts -go, the Go version the migrated code
+// targets, -generated, which migrates generated files too, -enable and
+// -disable, the rules to report or not, -check, the heuristic rules to
+// report too, which are not by default, -deprecated, packages to report as
+// deprecated, -deprecations, a database of deprecated packages and
+// symbols in the format of facts.DeprecationDatabase, and
+// -own-proto-deprecations, which reports the uses of the fields and enum
+// values deprecated in a .proto file in the package generated from it too,
+// since they are deprecated for the users of the messages rather than for
+// the Go API.
+//
+// -import-map, repeatable, maps the import path of a fork, mirror or
+// re-export of a package of github.com/golang/protobuf to that package, as
+// in -import-map='example.com/fork/proto=github.com/golang/protobuf/proto:
+// adds MarshalCanonical', so that its imports and uses are detected and
+// rewritten as those of the package; the findings about its imports give
+// the note.
+//
+// The messages of the findings end with a link to the documentation of
+// their rule, that of the v2 packages replacing the v1 API or else the
+// list below, which the sarif format gives as the help of the rules too.
+// -doc-url links them to other documentation instead, like that of a
+// company, given as a URL where {id} and {name} stand for those of the
+// rule, or leaves the links out with none.
+//
+// Each finding reports on a rule with a stable ID and a name:
+//
+//	PM1001 import-deprecated  Imports of deprecated packages
+//	PM1002 symbol-deprecated  Uses of deprecated identifiers
+//	PM1003 module-version     Versions of github.com/golang/protobuf, in go.mod
+//	PM1004 strict             Uses of the v1 API left, with -strict
+//	PM1005 dependency-version Conflicting versions of genproto and grpc-go, in go.mod
+//	PM1006 module-replace     Replacements of the protobuf modules with forks, in go.mod
+//	PM1007 codegen-version    Runtimes older than the generated code needs, in go.mod
+//	PM2001 jsonpb             Uses of package jsonpb
+//	PM2002 jsonpb-options     Options of jsonpb with no protojson translation
+//	PM2003 gateway-jsonpb     Marshalers of grpc-gateway v1 configured for jsonpb
+//	PM3001 proto              Uses of package proto moved to other packages
+//	PM3002 protoadapt         Type assertions between v1 and v2 messages
+//	PM4001 descriptor         Uses of package descriptor
+//	PM5001 ptypes             Uses of the ptypes helpers
+//	PM6001 wkt                Imports of the v1 well-known type packages
+//	PM7001 gogo               Files generated by protoc-gen-gogo
+//	PM7002 go-generate        go:generate directives with deprecated protoc options
+//	PM7003 old-codegen        Files generated by protoc-gen-go before v1.4
+//	PM7004 buf-plugins        Plugins generating v1 code, in buf.gen.yaml
+//	PM7005 stale-codegen      Generated files differing from their .proto files
+//	PM7006 legacy-grpc        Generated files with gRPC services of plugins=grpc
+//	PM8001 deep-equal         Comparisons of messages with reflect.DeepEqual
+//	PM8002 encoding-json      Messages encoded with encoding/json
+//	PM8003 message-copy       Copies of message structs
+//	PM8004 pointer-equal      Comparisons of messages with ==
+//	PM8005 map-key            Maps keyed by message structs
+//	PM8006 xxx-members        Uses of the XXX_ members of messages
+//	PM8007 aliasing           Mutations of the sub-messages of shared messages, with -check
+//	PM8008 registry-conflict  Registrations of the same .proto file or type
+//	PM8009 enum-maps          Changes of the maps generated for enums
+//	PM8010 custom-marshaler   Marshal, Unmarshal and Merge methods of messages
+//
+// protomigrate explain prints the description of the rules named by ID or
+// name, with an example of their migration, its caveats and the link to
+// their documentation, or lists the rules if none are named:
+//
+//	protomigrate explain PM3001
+//
+// protomigrate suppress inserts a pragma before the package clause of each
+// file of the named packages with findings, suppressing the rules of its
+// findings, so that the files are left as they are while others are
+// migrated. -dry-run and -diff apply to it as to -fix.
+//
+// protomigrate plan prints a plan of the migration of the named packages,
+// in phases of changes that suit a pull request each: the changes of a
+// phase are independent of one another, and only depend on those of the
+// phases before, leaves first, as a change comes after those of the
+// packages it imports. The packages with findings, those whose exported
+// API mentions v1 types and those using such API make up the changes, a
+// package using the v1 API of another moving along with it, as the uses
+// tying them tell. With -format=json, the plan is printed as JSON:
+//
+//	{"phases": [{"changes": [{"packages": [...], "findings": ...,
+//		"uses": ["example.com/a uses example.com/b.F", ...]}]}]}
+//
+// protomigrate blockers lists the modules the named packages depend on,
+// out of their main modules and the protobuf runtimes, whose API still
+// mentions v1 types, with the symbols of each the packages use and the
+// packages using them: v1-only message types, and functions, methods and
+// fields whose types mention v1 types. The packages cannot be fully
+// migrated until the modules are, and so blockers exits with status 3 if
+// it lists any, unless -fail-on is none. With -format=json, the list is
+// printed as JSON:
+//
+//	{"modules": [{"path": ..., "version": ..., "symbols": [{"symbol":
+//		"example.com/dep/api.Client.Get", "message": false,
+//		"packages": [...]}]}]}
+//
+// protomigrate stats prints the number of findings in the named packages,
+// in total and by rule, by API family, like ptypes, jsonpb, text or
+// extensions, and by package, along with how many of them have a fix and
+// how many are left to migrate by hand, to track the progress of a
+// migration over time; the findings of -baseline are left out. It prints
+// them as tables, or as JSON or CSV with -format=json or -format=csv:
+//
+//	by,key,findings,fixable,manual
+//	total,total,152,118,34
+//	rule,PM3001 proto,97,80,17
+//
+// protomigrate proto reads the .proto files of the named directories, like
+// ./... for the current directory and those below it, and reports those
+// without a go_package option, which protoc-gen-go requires since v1.20,
+// those whose go_package is out of the modules found along them, and those
+// of a package and directory generating different Go packages; it exits
+// with status 3 if it reports any, unless -fail-on is none. It then maps
+// the messages to the Go types generated for them, so that the findings in
+// Go code can be traced to the .proto files to change. With -format=json,
+// the report is printed as JSON:
+//
+//	{"files": [{"path": "api/v1/api.proto", "package": "api.v1",
+//		"go_package": "example.com/api/v1", "module": "example.com",
+//		"messages": [{"name": "api.v1.Request",
+//		"go_type": "example.com/api/v1.Request"}]}],
+//	 "problems": [{"position": "api/v1/old.proto:1", "message": ...}]}
+//
+// protomigrate regen applies the fixes to the named packages, as -fix
+// does, then regenerates their files generated by protoc-gen-go for the v1
+// API, so that a single command takes them to code building on the v2 API.
+// By default, it runs protoc with protoc-gen-go, and protoc-gen-go-grpc for
+// the files holding gRPC services, which it takes to their own
+// _grpc.pb.go files; the files are regenerated in place, in their
+// packages, whatever the go_package options of their .proto files, which
+// are looked for in the directories of the files and their parents up to
+// the module root. With tool: buf in the regen section of the
+// configuration file, it runs buf generate for their .proto files
+// instead, whose template says where the files go. With -dry-run or -diff,
+// the commands are printed rather than run.
+//
+// protomigrate bazel prints the buildozer commands migrating the BUILD
+// files of the Bazel workspace of the named packages, built with rules_go,
+// along with the fixes of the findings in them: the go_library, go_binary
+// and go_test rules of the files with findings get dependencies on the
+// packages of @org_golang_google_protobuf the fixes import, and lose those
+// on the packages of @com_github_golang_protobuf no file of theirs imports
+// once fixed, and the go_proto_library rules of the packages with findings
+// and of those they import switch from the compilers of protoc-gen-go
+// before v1.4 and of gogo protobuf to go_proto, and go_grpc_v2 for gRPC
+// services:
+//
+//	buildozer 'remove deps @com_github_golang_protobuf//ptypes/any' 'add deps @org_golang_google_protobuf//types/known/anypb' //api:api
+//	buildozer 'remove compilers @io_bazel_rules_go//proto:go_grpc' 'add compilers @io_bazel_rules_go//proto:go_proto @io_bazel_rules_go//proto:go_grpc_v2' //api:api_go_proto
+//
+// With -format=json, the commands are printed as JSON, by target.
+//
+// With -shard=N/M, only the Nth of M shards of the packages matched is
+// analyzed, the shard of a package following from its path, so that CI
+// can split a run across M jobs; protomigrate merge prints the outputs of
+// the shards in the named files, in the json or sarif format of -format, as
+// the output of one run:
+//
+//	protomigrate -format=sarif -shard=2/4 ./... > shard2.sarif
+//	protomigrate -format=sarif merge shard*.sarif > protomigrate.sarif
+//
+// -cpuprofile, -memprofile and -trace write a CPU profile, a heap profile
+// and an execution trace of the run to the named files, for go tool pprof
+// and go tool trace, to report performance problems with.
+//
+// Besides the findings in the code, protomigrate reports in the go.mod file
+// of each module of the packages the version of github.com/golang/protobuf
+// its build resolves, since the migration differs for each: up to v1.3, it
+// has a runtime of its own and is to be upgraded first, from v1.4 on it
+// runs over google.golang.org/protobuf and the code is to be migrated, and
+// once no package imports it, its requirement is to be dropped. It also
+// reports there the versions of google.golang.org/genproto and
+// google.golang.org/grpc that conflict with the version of
+// google.golang.org/protobuf the migration targets, that of -protobuf or
+// else the one the build resolves, along with the versions to upgrade them
+// to: genproto registering the well-known types again, genproto holding
+// the packages moved to its googleapis modules, and grpc-go too old for the
+// code protoc-gen-go-grpc generates. The replace directives redirecting the
+// protobuf modules to forks or directories are reported too, since forks
+// commonly pin the behavior of the versions before v1.4, which the
+// findings, made for the upstream modules, do not account for. So are the
+// versions of grpc-go and google.golang.org/protobuf older than the
+// generated files of the module need, as their
+// grpc.SupportPackageIsVersionN and protoimpl.EnforceVersion guards and
+// the versions of protoc-gen-go in their headers tell, before the guards
+// fail to compile with cryptic errors.
+//
+// The buf.gen.yaml files found from the modules, in their directories and
+// those up to the root of the repository, or listed by buf.work.yaml, are
+// checked as well: the plugins generating code for the v1 API, those of
+// gogo protobuf, protoc-gen-go before v1.4 or passed plugins=grpc, and
+// grpc-gateway v1, are reported along with the packages they generate, in
+// the directories they write to, so that the findings in the generated
+// code can be traced to the plugins to update.
+//
+// When invoked by go vet -vettool, or by the drivers of build systems that
+// run vet tools one package at a time, protomigrate runs as a vet tool
+// instead, on the facts of the dependencies the previous runs wrote:
+//
+//	go vet -vettool=$(which protomigrate) -protomigrate.fix-level=mostly-safe ./...
+//
+// Only the flags of the analysis apply then, prefixed with the name of the
+// analyzer, and the configuration file is that found from the directory
+// of each package. The analyzers of the groups of checks, like pmimports,
+// run instead of protomigrate with the flags named after them:
+//
+//	go vet -vettool=$(which protomigrate) -pmjsonpb -pmjsonpb.fix-level=unsafe ./...
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+	"github.com/protobuf-tools/protomigrate/internal/diff"
+)
+
+var (
+	fix             = flag.Bool("fix", false, "apply the suggested fixes to the files in place")
+	dryRun          = flag.Bool("dry-run", false, "list the files -fix would change, without changing them")
+	diffs           = flag.Bool("diff", false, "print the changes -fix would make as a unified diff, without making them")
+	tidy            = flag.Bool("tidy", false, "with -fix, run go mod tidy in the modules whose files are fixed")
+	tests           = flag.Bool("test", true, "also analyze the test files of the packages")
+	configFile      = flag.String("config", "", "read the configuration from the named `file` instead of "+configName)
+	baselineFile    = flag.String("baseline", "", "do not report the findings recorded in the named `file`")
+	recordBaseline  = flag.Bool("write-baseline", false, "record the findings in the -baseline file, instead of reporting them")
+	failOn          = flag.String("fail-on", "any", "exit with status 3 on findings of severity `error`, warning or above, any severity, or none")
+	format          = flag.String("format", "text", "print the diagnostics as `text`, or as json, sarif or html to the standard output")
+	groupBy         = flag.String("group-by", "site", "report each finding at its `site`, or with import, those of a rule in a package as one")
+	cacheDir        = flag.String("cache", "", "keep the facts and diagnostics of the packages in the named `directory`, to only analyze those that changed again")
+	resume          = flag.Bool("resume", false, "resume an interrupted run from the packages it analyzed, kept in the -cache directory, or else in the user cache directory")
+	cpuProfile      = flag.String("cpuprofile", "", "write a CPU profile of the run to the named `file`")
+	memProfile      = flag.String("memprofile", "", "write a heap profile at the end of the run to the named `file`")
+	traceFile       = flag.String("trace", "", "write an execution trace of the run to the named `file`")
+	severities      = severityFlag{}
+	protobufVersion = flag.String("protobuf", "", "check the versions of genproto and grpc-go against the `version` of google.golang.org/protobuf the migration targets, rather than that of the build")
+	shard           shardFlag
+)
+
+func init() {
+	flag.Var(&shard, "shard", "only analyze the packages of the `N/M`th of M shards, to split a run across M jobs")
+	flag.Var(severities, "severity", "set the severity of the findings of rules, as a comma-separated list of `rule=severity` pairs")
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("protomigrate: ")
+
+	analyzers := []*analysis.Analyzer{protomigrate.Analyzer}
+	if err := analysis.Validate(analyzers); err != nil {
+		log.Fatal(err)
+	}
+	protomigrate.Analyzer.Flags.VisitAll(func(f *flag.Flag) {
+		flag.Var(f.Value, f.Name, f.Usage)
+	})
+	if isVetTool(os.Args[1:]) {
+		runVetTool(os.Args[1:])
+	}
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", strings.Split(protomigrate.Analyzer.Doc, "\n\n")[0])
+		fmt.Fprintf(os.Stderr, "Usage: protomigrate [-flag] [package...]\n")
+		fmt.Fprintf(os.Stderr, "       protomigrate explain [rule...]\n")
+		fmt.Fprintf(os.Stderr, "       protomigrate suppress [package...]\n")
+		fmt.Fprintf(os.Stderr, "       protomigrate merge [file...]\n")
+		fmt.Fprintf(os.Stderr, "       protomigrate plan [package...]\n")
+		fmt.Fprintf(os.Stderr, "       protomigrate blockers [package...]\n")
+		fmt.Fprintf(os.Stderr, "       protomigrate stats [package...]\n")
+		fmt.Fprintf(os.Stderr, "       protomigrate proto [directory...]\n")
+		fmt.Fprintf(os.Stderr, "       protomigrate regen [package...]\n")
+		fmt.Fprintf(os.Stderr, "       protomigrate bazel [package...]\n\n")
+		fmt.Fprintln(os.Stderr, "Flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	switch *format {
+	case "text", "json", "sarif", "html":
+	case "csv":
+		if flag.Arg(0) != "stats" {
+			log.Fatal("-format=csv only applies to protomigrate stats")
+		}
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+	if err := loadConfig(); err != nil {
+		log.Fatal(err)
+	}
+	if *groupBy != "site" && *groupBy != "import" {
+		log.Fatalf("unknown -group-by mode %q", *groupBy)
+	}
+	switch *failOn {
+	case "error", "warning", "any", "none":
+	default:
+		log.Fatalf("unknown -fail-on policy %q", *failOn)
+	}
+	if *protobufVersion != "" && !semver.IsValid(*protobufVersion) {
+		log.Fatalf("invalid -protobuf version %q", *protobufVersion)
+	}
+	if *recordBaseline && *baselineFile == "" {
+		log.Fatal("-write-baseline requires -baseline")
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if args[0] == "explain" {
+		if err := explain(os.Stdout, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if args[0] == "suppress" {
+		os.Exit(insertPragmas(args[1:], analyzers))
+	}
+	if args[0] == "merge" {
+		if err := merge(os.Stdout, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if args[0] == "plan" {
+		os.Exit(printPlan(os.Stdout, args[1:], analyzers))
+	}
+	if args[0] == "blockers" {
+		os.Exit(printBlockers(os.Stdout, args[1:]))
+	}
+	if args[0] == "stats" {
+		os.Exit(printStats(os.Stdout, args[1:], analyzers))
+	}
+	if args[0] == "proto" {
+		os.Exit(printProto(os.Stdout, args[1:]))
+	}
+	if args[0] == "regen" {
+		*fix = !*dryRun && !*diffs
+		os.Exit(regen(args[1:], analyzers))
+	}
+	if args[0] == "bazel" {
+		os.Exit(printBazel(os.Stdout, args[1:], analyzers))
+	}
+	stop, err := startProfiles()
+	if err != nil {
+		log.Fatal(err)
+	}
+	code := run(args, analyzers)
+	if err := stop(); err != nil {
+		log.Print(err)
+		code = 1
+	}
+	os.Exit(code)
+}
+
+// startProfiles starts the CPU profile and the execution trace of the run
+// as -cpuprofile and -trace say, and returns the function stopping them and
+// writing the heap profile of -memprofile.
+func startProfiles() (stop func() error, err error) {
+	var stops []func() error
+	stop = func() error {
+		var err error
+		for _, s := range stops {
+			if serr := s(); err == nil {
+				err = serr
+			}
+		}
+		return err
+	}
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		stops = append(stops, func() error {
+			pprof.StopCPUProfile()
+			return f.Close()
+		})
+	}
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stop()
+			return nil, err
+		}
+		stops = append(stops, func() error {
+			trace.Stop()
+			return f.Close()
+		})
+	}
+	if *memProfile != "" {
+		stops = append(stops, func() error {
+			f, err := os.Create(*memProfile)
+			if err != nil {
+				return err
+			}
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		})
+	}
+	return stop, nil
+}
+
+// run analyzes the packages matching patterns, and returns the exit code:
+// 1 if they could not be analyzed, 3 if diagnostics were reported but
+// not fixed, as far as -fail-on counts them, 0 otherwise.
+func run(patterns []string, analyzers []*analysis.Analyzer) int {
+	if streamed() {
+		return stream(patterns, analyzers)
+	}
+	pkgs, diags, err := analyze(patterns, analyzers)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if len(pkgs) == 0 {
+		return 0
+	}
+	if *recordBaseline {
+		if err := writeBaseline(*baselineFile, diags); err != nil {
+			log.Print(err)
+			return 1
+		}
+		return 0
+	}
+	if *baselineFile != "" {
+		counts, err := readBaseline(*baselineFile)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		diags = suppress(diags, counts)
+	}
+
+	if !*fix && !*dryRun && !*diffs {
+		if err := printDiagnostics(pkgs[0].Fset, diags); err != nil {
+			log.Print(err)
+			return 1
+		}
+		return exitCode(diags, 0)
+	}
+
+	fixes, err := checker.ApplyFixes(pkgs[0].Fset, diags)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	dirs, err := fixModules(pkgs, fixes)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if err := writeFixes(fixes); err != nil {
+		log.Print(err)
+		return 1
+	}
+	if *tidy && *fix && !*dryRun && !*diffs {
+		if err := tidyModules(dirs); err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+	if fixes.Skipped > 0 {
+		log.Printf("skipped %d conflicting fixes, run again to apply them", fixes.Skipped)
+	}
+
+	// The diagnostics without a fix still call for a manual migration.
+	var unfixed []checker.Diagnostic
+	for _, d := range diags {
+		if len(d.SuggestedFixes) == 0 {
+			unfixed = append(unfixed, d)
+		}
+	}
+	if err := printDiagnostics(pkgs[0].Fset, unfixed); err != nil {
+		log.Print(err)
+		return 1
+	}
+	return exitCode(unfixed, fixes.Skipped)
+}
+
+// streamed reports whether the diagnostics are printed as the packages are
+// analyzed, rather than once all are, which applying the fixes, writing
+// the baseline and grouping the findings call for.
+func streamed() bool {
+	return !*fix && !*dryRun && !*diffs && !*recordBaseline && *groupBy == "site"
+}
+
+// stream analyzes the packages matching patterns like run, but prints the
+// diagnostics of each package once it is analyzed, so that the memory of a
+// run does not grow with its findings.
+func stream(patterns []string, analyzers []*analysis.Analyzer) int {
+	pkgs, mods, cache, err := load(patterns)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if len(pkgs) == 0 {
+		return 0
+	}
+	var counts map[baselineKey]int
+	if *baselineFile != "" {
+		if counts, err = readBaseline(*baselineFile); err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+
+	w, err := newDiagnosticWriter(diagnosticOutput(), pkgs[0].Fset, *format)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	code := 0
+	emit := func(diags []checker.Diagnostic) error {
+		diags = conf.apply(diags)
+		if counts != nil {
+			diags = suppress(diags, counts)
+		}
+		if exitCode(diags, 0) != 0 {
+			code = 3
+		}
+		return w.write(diags)
+	}
+	err = emit(mods)
+	if err == nil {
+		err = checker.Stream(pkgs, analyzers, cache, emit)
+	}
+	if err == nil {
+		err = w.close()
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	return code
+}
+
+// analyze loads the packages matching patterns and runs analyzers on them,
+// returning the packages and the diagnostics the configuration keeps.
+func analyze(patterns []string, analyzers []*analysis.Analyzer) ([]*packages.Package, []checker.Diagnostic, error) {
+	pkgs, mods, cache, err := load(patterns)
+	if err != nil || len(pkgs) == 0 {
+		return nil, nil, err
+	}
+	diags, err := checker.Run(pkgs, analyzers, cache)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkgs, conf.apply(append(mods, diags...)), nil
+}
+
+// load loads the packages matching patterns, checks the go.mod files of
+// their modules, and opens the cache of -cache, or that of -resume, if
+// either is set.
+func load(patterns []string) ([]*packages.Package, []checker.Diagnostic, *checker.Cache, error) {
+	pkgs, err := checker.Load(patterns, *tests)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// The modules are checked before the packages are sharded, as the
+	// versions of a module follow from all of its packages.
+	var mods []checker.Diagnostic
+	if len(pkgs) > 0 {
+		if mods, err = checkModules(pkgs[0].Fset, pkgs); err != nil {
+			return nil, nil, nil, err
+		}
+		bufs, err := checkBuf(pkgs[0].Fset, pkgs)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		mods = append(mods, bufs...)
+	}
+	if pkgs = shard.filter(pkgs); len(pkgs) == 0 {
+		return nil, nil, nil, nil
+	}
+	dir := *cacheDir
+	if dir == "" && *resume {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		dir = filepath.Join(base, "protomigrate")
+	}
+	var cache *checker.Cache
+	if dir != "" {
+		if cache, err = checker.OpenCache(dir); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return pkgs, mods, cache, nil
+}
+
+// writeFixes writes the files changed by fixes in place, or with -dry-run
+// lists them, and with -diff prints their changes, to the standard output.
+func writeFixes(fixes *checker.Fixes) error {
+	names := make([]string, 0, len(fixes.Files))
+	for name := range fixes.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if *dryRun {
+			if _, err := fmt.Println(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if *diffs {
+			old, err := ioutil.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			rel := relPath(name)
+			if _, err := os.Stdout.Write(diff.Unified("a/"+rel, "b/"+rel, old, fixes.Files[name])); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ioutil.WriteFile(name, fixes.Files[name], 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exitCode returns the exit code of a run that leaves diags unfixed and
+// skips the given number of conflicting fixes, following -fail-on: 3 if
+// the run fails, 0 otherwise. Skipped fixes fail the run unless -fail-on
+// is none.
+func exitCode(diags []checker.Diagnostic, skipped int) int {
+	if *failOn == "none" {
+		return 0
+	}
+	if skipped > 0 {
+		return 3
+	}
+	for _, d := range diags {
+		switch conf.severity(d) {
+		case "error":
+			return 3
+		case "warning":
+			if *failOn != "error" {
+				return 3
+			}
+		default:
+			if *failOn == "any" {
+				return 3
+			}
+		}
+	}
+	return 0
+}
+
+// conf is the configuration, empty if there is no configuration file.
+var conf = &config{}
+
+// loadConfig loads the configuration file named by -config, or else found
+// from the current directory, and sets the flags it configures.
+func loadConfig() error {
+	name := *configFile
+	if name == "" {
+		var err error
+		if name, err = findConfig(); err != nil || name == "" {
+			return err
+		}
+	}
+	cfg, err := readConfig(name)
+	if err != nil {
+		return err
+	}
+	if err := cfg.setFlags(); err != nil {
+		return err
+	}
+	conf = cfg
+	return nil
+}
+
+// printDiagnostics prints diags in the format of -format, grouped as
+// -group-by says: text goes to the standard error, like the diagnostics of
+// go vet, and the other formats to the standard output if the files -fix
+// changes are not printed there.
+func printDiagnostics(fset *token.FileSet, diags []checker.Diagnostic) error {
+	if *groupBy == "import" {
+		diags = groupByImport(diags)
+	}
+	return writeDiagnostics(diagnosticOutput(), fset, *format, diags)
+}
+
+// diagnosticOutput returns where the diagnostics are printed, as
+// printDiagnostics says.
+func diagnosticOutput() io.Writer {
+	if *format != "text" && !*dryRun && !*diffs {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+// relPath returns name relative to the current directory if it is below it,
+// so that the diff applies from there, and name itself otherwise.
+func relPath(name string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return name
+	}
+	rel, err := filepath.Rel(wd, name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return name
+	}
+	return filepath.ToSlash(rel)
+}
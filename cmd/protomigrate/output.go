@@ -0,0 +1,126 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+	"github.com/protobuf-tools/protomigrate/migrate"
+)
+
+// ruleOf returns the ID of the rule d reports on, or the name of the
+// analyzer if d has no category.
+func ruleOf(d checker.Diagnostic) string {
+	if d.Category != "" {
+		return d.Category
+	}
+	return d.Analyzer.Name
+}
+
+// writeDiagnostics writes diags to w in the named format.
+func writeDiagnostics(w io.Writer, fset *token.FileSet, format string, diags []checker.Diagnostic) error {
+	dw, err := newDiagnosticWriter(w, fset, format)
+	if err != nil {
+		return err
+	}
+	if err := dw.write(diags); err != nil {
+		return err
+	}
+	return dw.close()
+}
+
+// A diagnosticWriter writes diagnostics in a format as they are reported, so
+// that they need not all be held until the end of a run.
+type diagnosticWriter interface {
+	// write writes diags after those written before.
+	write(diags []checker.Diagnostic) error
+
+	// close completes the output.
+	close() error
+}
+
+// newDiagnosticWriter returns a diagnosticWriter writing to w in the named
+// format. The writers write the findings the diagnostics are turned into,
+// as library users of package migrate get them.
+func newDiagnosticWriter(w io.Writer, fset *token.FileSet, format string) (diagnosticWriter, error) {
+	r := migrate.NewReporter(fset, conf.ruleSeverities())
+	switch format {
+	case "text":
+		return textWriter{w, r}, nil
+	case "json":
+		return &jsonWriter{w: w, r: r}, nil
+	case "sarif":
+		return newSARIFWriter(w, r), nil
+	case "html":
+		return newHTMLWriter(w, fset, r), nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// textWriter writes diagnostics a line each, like go vet.
+type textWriter struct {
+	w io.Writer
+	r *migrate.Reporter
+}
+
+func (tw textWriter) write(diags []checker.Diagnostic) error {
+	for _, d := range diags {
+		f := findingOf(tw.r, d)
+		if _, err := fmt.Fprintf(tw.w, "%s: %s\n", f.Position, f.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tw textWriter) close() error {
+	return nil
+}
+
+// jsonWriter writes the output of -format=json a diagnostic at a time,
+// indented as encoding it whole would.
+type jsonWriter struct {
+	w io.Writer
+	r *migrate.Reporter
+	n int
+}
+
+func (jw *jsonWriter) write(diags []checker.Diagnostic) error {
+	for _, d := range diags {
+		data, err := json.MarshalIndent(findingOf(jw.r, d), "\t\t", "\t")
+		if err != nil {
+			return err
+		}
+		sep := ",\n\t\t"
+		if jw.n == 0 {
+			sep = "{\n\t\"diagnostics\": [\n\t\t"
+		}
+		jw.n++
+		if _, err := io.WriteString(jw.w, sep); err != nil {
+			return err
+		}
+		if _, err := jw.w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (jw *jsonWriter) close() error {
+	end := "\n\t]\n}\n"
+	if jw.n == 0 {
+		end = "{\n\t\"diagnostics\": []\n}\n"
+	}
+	_, err := io.WriteString(jw.w, end)
+	return err
+}
+
+// findingOf returns the finding of d.
+func findingOf(r *migrate.Reporter, d checker.Diagnostic) migrate.Finding {
+	return r.Finding(d.Diagnostic, d.Analyzer, d.Package)
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+	"github.com/protobuf-tools/protomigrate/migrate"
+)
+
+// testSource is the file the diagnostics of testDiagnostics are reported in.
+const testSource = "package p\n\nimport \"github.com/golang/protobuf/jsonpb\"\n\nvar m jsonpb.Marshaler\n"
+
+// testDiagnostics returns diagnostics of testSource, named p.go, of a rule
+// with an end, a related location and a fix, and of an analyzer with no
+// rules.
+func testDiagnostics() (*token.FileSet, []checker.Diagnostic) {
+	fset := token.NewFileSet()
+	tf := fset.AddFile("p.go", -1, len(testSource))
+	tf.SetLinesForContent([]byte(testSource))
+	imp := tf.Pos(len("package p\n\nimport "))
+	decl := tf.Pos(len("package p\n\nimport \"github.com/golang/protobuf/jsonpb\"\n\n"))
+	a := &analysis.Analyzer{Name: "protomigrate", Doc: "protomigrate reports the uses of the v1 API.\n\nIt suggests fixes."}
+	vet := &analysis.Analyzer{Name: "vet", Doc: "vet reports suspicious constructs."}
+	diags := []checker.Diagnostic{
+		{
+			Diagnostic: analysis.Diagnostic{
+				Pos:      imp,
+				End:      imp + token.Pos(len(`"github.com/golang/protobuf/jsonpb"`)),
+				Category: "PM2001",
+				Message:  "package jsonpb is deprecated: use protojson",
+				Related:  []analysis.RelatedInformation{{Pos: decl, Message: "used here"}},
+				SuggestedFixes: []analysis.SuggestedFix{{
+					Message: "Import protojson",
+					TextEdits: []analysis.TextEdit{{
+						Pos:     imp,
+						End:     imp + token.Pos(len(`"github.com/golang/protobuf/jsonpb"`)),
+						NewText: []byte(`"google.golang.org/protobuf/encoding/protojson"`),
+					}},
+				}},
+			},
+			Analyzer: a,
+			Package:  "example.com/p",
+		},
+		{
+			Diagnostic: analysis.Diagnostic{Pos: decl, Message: "m is unused"},
+			Analyzer:   vet,
+			Package:    "example.com/p",
+		},
+	}
+	for i := range diags {
+		diags[i].Position = fset.Position(diags[i].Pos)
+	}
+	return fset, diags
+}
+
+// checkGolden checks that got is the content of the named file of
+// testdata.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	want, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output:\n%s\nwant testdata/%s:\n%s", got, name, want)
+	}
+}
+
+func TestWriteDiagnosticsJSON(t *testing.T) {
+	fset, diags := testDiagnostics()
+	var buf bytes.Buffer
+	if err := writeDiagnostics(&buf, fset, "json", diags); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "diagnostics.json", buf.Bytes())
+
+	// The output is the report encoded whole.
+	var report migrate.Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	var whole bytes.Buffer
+	if err := encodeJSON(&whole, report); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(whole.Bytes(), buf.Bytes()) {
+		t.Errorf("output:\n%s\nwant the report encoded whole:\n%s", buf.Bytes(), whole.Bytes())
+	}
+
+	buf.Reset()
+	if err := writeDiagnostics(&buf, fset, "json", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "{\n\t\"diagnostics\": []\n}\n"; got != want {
+		t.Errorf("output of no diagnostics %q, want %q", got, want)
+	}
+}
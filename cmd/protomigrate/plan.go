@@ -0,0 +1,363 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// A migrationPlan splits the migration of packages into phases, each of
+// changes independent of one another, which only depend on the changes of
+// the phases before them.
+type migrationPlan struct {
+	Phases []planPhase `json:"phases"`
+}
+
+type planPhase struct {
+	Changes []*planChange `json:"changes"`
+}
+
+// A planChange is a set of packages to migrate together, since some use
+// the v1 API that others export, which the migration of the latter
+// changes.
+type planChange struct {
+	Packages []string `json:"packages"`
+	Findings int      `json:"findings"`
+
+	// Uses lists the uses that tie the packages, like "a uses b.F".
+	Uses []string `json:"uses,omitempty"`
+
+	deps  map[*planChange]bool
+	phase int
+}
+
+// printPlan prints the migration plan of the packages matching patterns,
+// and returns the exit code: 1 if the packages could not be analyzed, 0
+// otherwise.
+func printPlan(w io.Writer, patterns []string, analyzers []*analysis.Analyzer) int {
+	if *format == "sarif" || *format == "html" {
+		log.Print("cannot print a plan with -format=" + *format)
+		return 1
+	}
+	pkgs, diags, err := analyze(patterns, analyzers)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if len(pkgs) == 0 {
+		return 0
+	}
+	exported, err := checker.ExportedFacts(pkgs, []*analysis.Analyzer{facts.API})
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	p := plan(pkgs, diags, exported.Packages)
+	if *format == "json" {
+		err = encodeJSON(w, p)
+	} else {
+		err = p.write(w)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+// plan returns the migration plan of pkgs, given the findings in them and
+// the package facts of the facts.API analyzer. The packages to migrate are
+// those with findings, those exporting v1 API and those using it; a
+// package using the v1 API of another migrates along with it. The changes
+// are ordered leaves first: a change comes after those of the packages its
+// packages import, directly or not.
+func plan(pkgs []*packages.Package, diags []checker.Diagnostic, apis map[*types.Package][]analysis.Fact) *migrationPlan {
+	// The test variants of a package, and its external test package, are
+	// migrated along with it.
+	pathOf := map[*types.Package]string{}
+	var roots []*packages.Package
+	for _, pkg := range pkgs {
+		if pkg.Name == "main" && strings.HasSuffix(pkg.ID, ".test") {
+			continue
+		}
+		pathOf[pkg.Types] = strings.TrimSuffix(pkg.PkgPath, "_test")
+		roots = append(roots, pkg)
+	}
+
+	findings := map[string]int{}
+	migrated := map[string]bool{}
+	for _, d := range diags {
+		// The findings in go.mod and buf.gen.yaml files are those of
+		// modules, not of packages.
+		if strings.HasSuffix(d.Position.Filename, ".go") {
+			path := strings.TrimSuffix(d.Package, "_test")
+			findings[path]++
+			migrated[path] = true
+		}
+	}
+
+	// The objects of the v1 API of each package, by their names in the
+	// facts.
+	v1API := map[*types.Package]map[types.Object]string{}
+	for tpkg, fs := range apis {
+		path, ok := pathOf[tpkg]
+		if !ok {
+			continue
+		}
+		for _, f := range fs {
+			if api, ok := f.(*facts.V1API); ok {
+				v1API[tpkg] = apiObjects(tpkg, api)
+				migrated[path] = true
+			}
+		}
+	}
+
+	parent := map[string]string{}
+	var find func(path string) string
+	find = func(path string) string {
+		if p, ok := parent[path]; ok && p != path {
+			parent[path] = find(p)
+			return parent[path]
+		}
+		return path
+	}
+	uses := map[string]map[string]bool{}
+	for _, pkg := range roots {
+		path := pathOf[pkg.Types]
+		for _, obj := range pkg.TypesInfo.Uses {
+			if obj.Pkg() == nil || obj.Pkg() == pkg.Types {
+				continue
+			}
+			name, ok := v1API[obj.Pkg()][obj]
+			if !ok || pathOf[obj.Pkg()] == path {
+				continue
+			}
+			dep := pathOf[obj.Pkg()]
+			migrated[path] = true
+			if a, b := find(path), find(dep); a != b {
+				parent[a] = b
+			}
+			if uses[path] == nil {
+				uses[path] = map[string]bool{}
+			}
+			uses[path][fmt.Sprintf("%s uses %s.%s", path, dep, name)] = true
+		}
+	}
+
+	changes := map[string]*planChange{}
+	for path := range migrated {
+		root := find(path)
+		c := changes[root]
+		if c == nil {
+			c = &planChange{deps: map[*planChange]bool{}}
+			changes[root] = c
+		}
+		c.Packages = append(c.Packages, path)
+		c.Findings += findings[path]
+		for use := range uses[path] {
+			c.Uses = append(c.Uses, use)
+		}
+	}
+
+	// A change depends on the changes of the packages its packages
+	// import, directly or not, through packages migrated or not.
+	reach := map[*packages.Package]map[*planChange]bool{}
+	var visit func(pkg *packages.Package) map[*planChange]bool
+	visit = func(pkg *packages.Package) map[*planChange]bool {
+		if r, ok := reach[pkg]; ok {
+			return r
+		}
+		r := map[*planChange]bool{}
+		reach[pkg] = r
+		for _, imp := range pkg.Imports {
+			path, ok := pathOf[imp.Types]
+			if !ok {
+				continue
+			}
+			if c := changes[find(path)]; c != nil && migrated[path] {
+				r[c] = true
+			}
+			for c := range visit(imp) {
+				r[c] = true
+			}
+		}
+		return r
+	}
+	for _, pkg := range roots {
+		path := pathOf[pkg.Types]
+		if !migrated[path] {
+			continue
+		}
+		c := changes[find(path)]
+		for dep := range visit(pkg) {
+			if dep != c {
+				c.deps[dep] = true
+			}
+		}
+	}
+
+	list := make([]*planChange, 0, len(changes))
+	for _, c := range changes {
+		sort.Strings(c.Packages)
+		sort.Strings(c.Uses)
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Packages[0] < list[j].Packages[0] })
+	list = mergeCycles(list)
+
+	p := &migrationPlan{}
+	var phaseOf func(c *planChange) int
+	phaseOf = func(c *planChange) int {
+		if c.phase == 0 {
+			c.phase = 1
+			for dep := range c.deps {
+				if n := phaseOf(dep) + 1; n > c.phase {
+					c.phase = n
+				}
+			}
+		}
+		return c.phase
+	}
+	for _, c := range list {
+		n := phaseOf(c)
+		for len(p.Phases) < n {
+			p.Phases = append(p.Phases, planPhase{})
+		}
+		p.Phases[n-1].Changes = append(p.Phases[n-1].Changes, c)
+	}
+	return p
+}
+
+// mergeCycles merges the changes of list that depend on one another, which
+// the imports of the packages they tie together make possible, into one,
+// so that the changes left form a graph without cycles. list is sorted by
+// the first package of the changes, and so is the result.
+func mergeCycles(list []*planChange) []*planChange {
+	// Tarjan's algorithm finds the strongly connected components.
+	index := map[*planChange]int{}
+	low := map[*planChange]int{}
+	onStack := map[*planChange]bool{}
+	var stack []*planChange
+	merged := map[*planChange]*planChange{}
+	var connect func(c *planChange)
+	connect = func(c *planChange) {
+		index[c] = len(index)
+		low[c] = index[c]
+		stack = append(stack, c)
+		onStack[c] = true
+		deps := make([]*planChange, 0, len(c.deps))
+		for dep := range c.deps {
+			deps = append(deps, dep)
+		}
+		sort.Slice(deps, func(i, j int) bool { return deps[i].Packages[0] < deps[j].Packages[0] })
+		for _, dep := range deps {
+			if _, ok := index[dep]; !ok {
+				connect(dep)
+				if low[dep] < low[c] {
+					low[c] = low[dep]
+				}
+			} else if onStack[dep] && index[dep] < low[c] {
+				low[c] = index[dep]
+			}
+		}
+		if low[c] != index[c] {
+			return
+		}
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			merged[top] = c
+			if top == c {
+				break
+			}
+		}
+	}
+	for _, c := range list {
+		if _, ok := index[c]; !ok {
+			connect(c)
+		}
+	}
+
+	var out []*planChange
+	for _, c := range list {
+		m := merged[c]
+		if m == c {
+			out = append(out, c)
+			continue
+		}
+		m.Packages = append(m.Packages, c.Packages...)
+		m.Findings += c.Findings
+		m.Uses = append(m.Uses, c.Uses...)
+		for dep := range c.deps {
+			m.deps[dep] = true
+		}
+	}
+	for _, c := range out {
+		deps := map[*planChange]bool{}
+		for dep := range c.deps {
+			if merged[dep] != c {
+				deps[merged[dep]] = true
+			}
+		}
+		c.deps = deps
+		sort.Strings(c.Packages)
+		sort.Strings(c.Uses)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Packages[0] < out[j].Packages[0] })
+	return out
+}
+
+// apiObjects returns the objects of pkg named in api, by name.
+func apiObjects(pkg *types.Package, api *facts.V1API) map[types.Object]string {
+	objs := map[types.Object]string{}
+	for _, name := range api.Objects {
+		typeName, member := name, ""
+		if i := strings.Index(name, "."); i >= 0 {
+			typeName, member = name[:i], name[i+1:]
+		}
+		obj := pkg.Scope().Lookup(typeName)
+		if obj == nil {
+			continue
+		}
+		if member != "" {
+			obj, _, _ = types.LookupFieldOrMethod(obj.Type(), true, pkg, member)
+			if obj == nil {
+				continue
+			}
+		}
+		objs[obj] = name
+	}
+	return objs
+}
+
+// write writes the plan to w as text, a paragraph a phase.
+func (p *migrationPlan) write(w io.Writer) error {
+	var b strings.Builder
+	for i, phase := range p.Phases {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "Phase %d: %d changes\n", i+1, len(phase.Changes))
+		for _, c := range phase.Changes {
+			fmt.Fprintf(&b, "\t%s: %d findings\n", strings.Join(c.Packages, " "), c.Findings)
+			for _, use := range c.Uses {
+				fmt.Fprintf(&b, "\t\t%s\n", use)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
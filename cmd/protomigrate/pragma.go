@@ -0,0 +1,97 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"go/ast"
+	"log"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// insertPragmas inserts in each file of the packages matching patterns
+// with findings a pragma suppressing the rules of its findings in the whole
+// file, and returns the exit code: 1 if the packages could not be analyzed
+// or the files written, 0 otherwise.
+func insertPragmas(patterns []string, analyzers []*analysis.Analyzer) int {
+	pkgs, diags, err := analyze(patterns, analyzers)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if len(pkgs) == 0 {
+		return 0
+	}
+	fixes, err := checker.ApplyFixes(pkgs[0].Fset, pragmas(pkgs, diags))
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if err := writeFixes(fixes); err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+// pragmas returns a diagnostic for each file of pkgs with findings in
+// diags, whose fix inserts the pragma before its package clause. The pragma
+// joins the package comment if there is one, and is set apart from the
+// package clause otherwise.
+func pragmas(pkgs []*packages.Package, diags []checker.Diagnostic) []checker.Diagnostic {
+	rules := map[string]map[string]bool{}
+	for _, d := range diags {
+		name := d.Position.Filename
+		if rules[name] == nil {
+			rules[name] = map[string]bool{}
+		}
+		rules[name][ruleOf(d)] = true
+	}
+
+	files := map[string]*ast.File{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Syntax {
+			files[pkg.Fset.File(f.Pos()).Name()] = f
+		}
+	}
+
+	fset := pkgs[0].Fset
+	var fixes []checker.Diagnostic
+	for name, ids := range rules {
+		f, ok := files[name]
+		if !ok {
+			continue
+		}
+		list := make([]string, 0, len(ids))
+		for id := range ids {
+			list = append(list, id)
+		}
+		sort.Strings(list)
+		text := "//protomigrate:ignore=" + strings.Join(list, ",") + "\n"
+		if f.Doc == nil {
+			text += "\n"
+		}
+		tf := fset.File(f.Package)
+		pos := tf.LineStart(tf.Line(f.Package))
+		fixes = append(fixes, checker.Diagnostic{
+			Diagnostic: analysis.Diagnostic{
+				Pos:     pos,
+				Message: "findings suppressed",
+				SuggestedFixes: []analysis.SuggestedFix{{
+					Message:   "Insert " + strings.TrimSpace(text),
+					TextEdits: []analysis.TextEdit{{Pos: pos, End: pos, NewText: []byte(text)}},
+				}},
+			},
+			Analyzer: protomigrate.Analyzer,
+			Position: fset.Position(pos),
+		})
+	}
+	return fixes
+}
@@ -0,0 +1,290 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/mod/modfile"
+
+	"github.com/protobuf-tools/protomigrate/internal/protosrc"
+)
+
+// protoReport is the report of protomigrate proto: the .proto files found,
+// with the problems of their go_package options and the Go types their
+// messages are generated as.
+type protoReport struct {
+	Files    []*protoFile   `json:"files"`
+	Problems []protoProblem `json:"problems"`
+}
+
+// A protoFile is a .proto file, as far as the Go code generated from it
+// goes.
+type protoFile struct {
+	Path      string `json:"path"`
+	Package   string `json:"package,omitempty"`
+	GoPackage string `json:"go_package,omitempty"`
+
+	// Module is the path of the module of the directory of the file, if it
+	// is in one.
+	Module   string         `json:"module,omitempty"`
+	Messages []protoMessage `json:"messages,omitempty"`
+
+	goPackageLine int
+}
+
+// A protoMessage is a message of a .proto file, by its full name, and the
+// Go type generated for it, qualified by the path of its package.
+type protoMessage struct {
+	Name   string `json:"name"`
+	GoType string `json:"go_type,omitempty"`
+}
+
+type protoProblem struct {
+	Position string `json:"position"`
+	Message  string `json:"message"`
+}
+
+// printProto prints the report of the .proto files in the directories
+// matching patterns, and returns the exit code: 1 if the files could not be
+// read, 3 if their go_package options have problems, unless -fail-on is
+// none, 0 otherwise.
+func printProto(w io.Writer, patterns []string) int {
+	if *format == "sarif" || *format == "html" {
+		log.Print("cannot print the .proto files with -format=" + *format)
+		return 1
+	}
+	names, modules, err := findProtoFiles(patterns)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	r := &protoReport{Files: []*protoFile{}, Problems: []protoProblem{}}
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		f := parseProto(data)
+		f.Path = relPath(name)
+		if f.Module, err = moduleOf(filepath.Dir(name), modules); err != nil {
+			log.Print(err)
+			return 1
+		}
+		r.Files = append(r.Files, f)
+	}
+	r.check(modules)
+	if *format == "json" {
+		err = encodeJSON(w, r)
+	} else {
+		err = r.write(w)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if len(r.Problems) > 0 && *failOn != "none" {
+		return 3
+	}
+	return 0
+}
+
+// findProtoFiles returns the .proto files of the directories matching
+// patterns, sorted, and the paths of the modules of the go.mod files found
+// along them, by directory. As for packages, dir/... matches dir and the
+// directories below it but vendor and testdata directories and those
+// starting with . or _.
+func findProtoFiles(patterns []string) ([]string, map[string]string, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	seen := map[string]bool{}
+	var names []string
+	modules := map[string]string{}
+	visit := func(dir string) error {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			name := filepath.Join(dir, e.Name())
+			switch {
+			case e.IsDir():
+			case e.Name() == "go.mod":
+				data, err := ioutil.ReadFile(name)
+				if err != nil {
+					return err
+				}
+				modules[dir] = modfile.ModulePath(data)
+			case strings.HasSuffix(e.Name(), ".proto") && !seen[name]:
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		return nil
+	}
+	for _, pattern := range patterns {
+		root, recursive := pattern, false
+		if pattern == "..." || strings.HasSuffix(pattern, "/...") {
+			root, recursive = strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/"), true
+			if root == "" {
+				root = "."
+			}
+		}
+		root, err := filepath.Abs(root)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !recursive {
+			if err := visit(root); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			if base := info.Name(); path != root && (base == "vendor" || base == "testdata" || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+				return filepath.SkipDir
+			}
+			return visit(path)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	sort.Strings(names)
+	return names, modules, nil
+}
+
+// moduleOf returns the path of the module of dir, that of the go.mod file
+// of dir or of its closest parent with one, or "" if there is none.
+// modules caches the paths by directory.
+func moduleOf(dir string, modules map[string]string) (string, error) {
+	for d := dir; ; {
+		if path, ok := modules[d]; ok {
+			return path, nil
+		}
+		data, err := ioutil.ReadFile(filepath.Join(d, "go.mod"))
+		if err == nil {
+			modules[d] = modfile.ModulePath(data)
+			return modules[d], nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return "", nil
+		}
+		d = parent
+	}
+}
+
+// check records the problems of the go_package options of the files of r:
+// files without one, which protoc-gen-go requires since v1.20, files whose
+// go_package is out of the modules known, those of the files and the
+// go.mod files found along them, and files of the same package and
+// directory generated into different Go packages.
+func (r *protoReport) check(modules map[string]string) {
+	problem := func(f *protoFile, line int, format string, args ...interface{}) {
+		r.Problems = append(r.Problems, protoProblem{
+			Position: fmt.Sprintf("%s:%d", f.Path, line),
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+	known := map[string]bool{}
+	for _, path := range modules {
+		if path != "" {
+			known[path] = true
+		}
+	}
+	inModule := func(path string) bool {
+		for mod := range known {
+			if path == mod || strings.HasPrefix(path, mod+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	type key struct{ dir, pkg string }
+	firsts := map[key]*protoFile{}
+	for _, f := range r.Files {
+		if f.GoPackage == "" {
+			problem(f, 1, "file has no go_package option, which protoc-gen-go requires since v1.20 to tell the import path of the Go package it generates")
+			continue
+		}
+		if len(known) > 0 && !inModule(f.GoPackage) {
+			problem(f, f.goPackageLine, "go_package %s is out of the modules found, %s", f.GoPackage, strings.Join(sortedKeys(known), ", "))
+		}
+		k := key{filepath.Dir(f.Path), f.Package}
+		if first, ok := firsts[k]; !ok {
+			firsts[k] = f
+		} else if first.GoPackage != f.GoPackage {
+			problem(f, f.goPackageLine, "go_package %s differs from %s of %s, of the same package %s in the same directory", f.GoPackage, first.GoPackage, first.Path, f.Package)
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// write writes the report to w as text: the problems, as go vet prints its
+// findings, then a table of the messages.
+func (r *protoReport) write(w io.Writer) error {
+	for _, p := range r.Problems {
+		fmt.Fprintf(w, "%s: %s\n", p.Position, p.Message)
+	}
+	if len(r.Problems) > 0 {
+		fmt.Fprintln(w)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "MESSAGE\tGO TYPE\tFILE\n")
+	for _, f := range r.Files {
+		for _, m := range f.Messages {
+			goType := m.GoType
+			if goType == "" {
+				goType = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", m.Name, goType, f.Path)
+		}
+	}
+	return tw.Flush()
+}
+
+// parseProto returns the package, go_package option and messages of the
+// .proto file of content data.
+func parseProto(data []byte) *protoFile {
+	src := protosrc.Parse(data)
+	f := &protoFile{Package: src.Package, GoPackage: src.GoPackage, goPackageLine: src.GoPackageLine}
+	for _, msg := range src.Messages {
+		m := protoMessage{Name: msg.Name}
+		if f.Package != "" {
+			m.Name = f.Package + "." + msg.Name
+		}
+		if f.GoPackage != "" {
+			m.GoType = f.GoPackage + "." + msg.GoName()
+		}
+		f.Messages = append(f.Messages, m)
+	}
+	return f
+}
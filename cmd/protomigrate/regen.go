@@ -0,0 +1,248 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// grpcPath is the path of the package of grpc-go, which the files
+// protoc-gen-go generated with plugins=grpc import.
+const grpcPath = "google.golang.org/grpc"
+
+// A regenFile is a file generated by protoc-gen-go for the v1 API, to be
+// regenerated for the v2 API.
+type regenFile struct {
+	// path is the path of the file, and source that of its .proto file
+	// relative to the directory include of the include path.
+	path, source, include string
+
+	// pkg is the import path and name of its package, which the file is
+	// regenerated into whatever the go_package of its .proto file says.
+	pkg string
+
+	// grpc reports whether the file holds the code of gRPC services, to be
+	// generated by protoc-gen-go-grpc into a file of its own.
+	grpc bool
+}
+
+// regen applies the fixes to the packages matching patterns, as -fix does,
+// then regenerates their files generated by protoc-gen-go for the v1 API
+// with protoc-gen-go and protoc-gen-go-grpc, or buf, as the configuration
+// says, so that the packages build on the v2 API. It returns the exit code
+// of the fixes, or 1 if the files could not be regenerated.
+func regen(patterns []string, analyzers []*analysis.Analyzer) int {
+	code := run(patterns, analyzers)
+	if code == 1 {
+		return code
+	}
+	pkgs, err := checker.Load(patterns, *tests)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	files, err := regenFiles(pkgs)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if len(files) == 0 {
+		return code
+	}
+	if conf.Regen.Tool == "buf" {
+		err = regenBuf(files)
+	} else {
+		err = regenProtoc(files)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	return code
+}
+
+// regenFiles returns the files of the packages of pkgs, out of their main
+// modules, generated by protoc-gen-go for the v1 API whose .proto files
+// are found, in the directories of the files and their parents up to the
+// module root. Those whose .proto files are not found are logged.
+func regenFiles(pkgs []*packages.Package) ([]regenFile, error) {
+	exported, err := checker.ExportedFacts(pkgs, []*analysis.Analyzer{facts.Protoc})
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var files []regenFile
+	for _, pkg := range pkgs {
+		if pkg.Module == nil || !pkg.Module.Main || len(pkg.GoFiles) == 0 {
+			continue
+		}
+		dir := filepath.Dir(pkg.GoFiles[0])
+		for _, fact := range exported.Packages[pkg.Types] {
+			pf, ok := fact.(*facts.ProtoFiles)
+			if !ok {
+				continue
+			}
+			for _, f := range pf.Files {
+				path := filepath.Join(dir, f.Name)
+				if f.V2 || seen[path] {
+					continue
+				}
+				seen[path] = true
+				include, ok := protoInclude(dir, f.Source, filepath.Dir(pkg.Module.GoMod))
+				if !ok {
+					log.Printf("%s: cannot regenerate it, since its .proto file %q is not found", relPath(path), f.Source)
+					continue
+				}
+				files = append(files, regenFile{
+					path:    path,
+					source:  f.Source,
+					include: include,
+					pkg:     strings.TrimSuffix(pkg.PkgPath, "_test") + ";" + pkg.Name,
+					grpc:    importsGRPC(pkg, path),
+				})
+			}
+		}
+	}
+	return files, nil
+}
+
+// protoInclude returns the directory, out of dir and its parents up to
+// root, holding the .proto file of path source, relative to it.
+func protoInclude(dir, source, root string) (string, bool) {
+	if source == "" {
+		return "", false
+	}
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, filepath.FromSlash(source))); err == nil {
+			return d, true
+		}
+		parent := filepath.Dir(d)
+		if d == root || parent == d {
+			return "", false
+		}
+		d = parent
+	}
+}
+
+// importsGRPC reports whether the named file of pkg imports grpc-go.
+func importsGRPC(pkg *packages.Package, name string) bool {
+	for i, f := range pkg.Syntax {
+		if i >= len(pkg.CompiledGoFiles) || pkg.CompiledGoFiles[i] != name {
+			continue
+		}
+		for _, spec := range f.Imports {
+			if path, _ := strconv.Unquote(spec.Path.Value); path == grpcPath {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// regenProtoc regenerates files with protoc, a file at a time: the outputs
+// of protoc-gen-go and protoc-gen-go-grpc are written to a temporary
+// directory, then moved to the directory of the file, so that they replace
+// it whatever the go_package of the .proto file, which the M option maps
+// to the package of the file. The include directories of the
+// configuration are passed to protoc after that of the file. With -dry-run
+// or -diff, the commands are printed instead.
+func regenProtoc(files []regenFile) error {
+	tmp, err := ioutil.TempDir("", "protomigrate")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	for _, f := range files {
+		args := []string{"-I" + f.include}
+		for _, dir := range conf.Regen.Include {
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(conf.dir, dir)
+			}
+			args = append(args, "-I"+dir)
+		}
+		mapping := "M" + f.source + "=" + f.pkg
+		args = append(args, "--go_out="+tmp, "--go_opt=paths=source_relative", "--go_opt="+mapping)
+		if f.grpc {
+			args = append(args, "--go-grpc_out="+tmp, "--go-grpc_opt=paths=source_relative", "--go-grpc_opt="+mapping)
+		}
+		args = append(args, f.source)
+		if *dryRun || *diffs {
+			fmt.Println("protoc " + strings.Join(args, " "))
+			continue
+		}
+		cmd := exec.Command("protoc", args...)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("protoc %s: %v", f.source, err)
+		}
+		base := strings.TrimSuffix(filepath.FromSlash(f.source), ".proto")
+		outputs := []string{".pb.go"}
+		if f.grpc {
+			outputs = append(outputs, "_grpc.pb.go")
+		}
+		dir := filepath.Dir(f.path)
+		for _, suffix := range outputs {
+			data, err := ioutil.ReadFile(filepath.Join(tmp, base+suffix))
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, strings.TrimSuffix(filepath.Base(f.path), ".pb.go")+suffix), data, 0666); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// regenBuf regenerates files with buf generate, run in the directory of the
+// configuration file with its buf.gen.yaml template, limited to the .proto
+// files of files; the template says where the outputs go, so it has to be
+// updated for the v2 API first. With -dry-run or -diff, the command is
+// printed instead.
+func regenBuf(files []regenFile) error {
+	dir := conf.dir
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+	args := []string{"generate"}
+	if conf.Regen.Template != "" {
+		args = append(args, "--template", conf.Regen.Template)
+	}
+	for _, f := range files {
+		path, err := filepath.Rel(dir, filepath.Join(f.include, filepath.FromSlash(f.source)))
+		if err != nil {
+			return err
+		}
+		args = append(args, "--path", filepath.ToSlash(path))
+	}
+	if *dryRun || *diffs {
+		fmt.Println("buf " + strings.Join(args, " "))
+		return nil
+	}
+	cmd := exec.Command("buf", args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("buf generate: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+	"github.com/protobuf-tools/protomigrate/migrate"
+)
+
+// The subset of SARIF 2.1.0 that -format=sarif outputs, as specified by
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html: a log
+// of one run, whose results come before the tool, so that they are written
+// as they are reported, and the rules they report on once all are.
+
+// sarifHeader begins the log, up to the results of its run.
+const sarifHeader = "{\n\t\"$schema\": \"https://json.schemastore.org/sarif-2.1.0.json\",\n\t\"version\": \"2.1.0\",\n\t\"runs\": [\n\t\t{\n\t\t\t\"results\": ["
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name,omitempty"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+	HelpURI          string       `json:"helpUri,omitempty"`
+
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// sarifRegion is a region given by its lines and columns, or by its byte
+// offset and length.
+type sarifRegion struct {
+	StartLine   int  `json:"startLine,omitempty"`
+	StartColumn int  `json:"startColumn,omitempty"`
+	EndLine     int  `json:"endLine,omitempty"`
+	EndColumn   int  `json:"endColumn,omitempty"`
+	ByteOffset  *int `json:"byteOffset,omitempty"`
+	ByteLength  *int `json:"byteLength,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+// sarifWriter writes a SARIF log a result at a time, then the tool with the
+// rules of the results, indented as encoding the log whole would.
+type sarifWriter struct {
+	w    io.Writer
+	r    *migrate.Reporter
+	tool sarifTool
+	seen map[string]bool
+	n    int
+}
+
+func newSARIFWriter(w io.Writer, r *migrate.Reporter) *sarifWriter {
+	return &sarifWriter{
+		w: w,
+		r: r,
+		tool: sarifTool{Driver: sarifDriver{
+			Name:           "protomigrate",
+			InformationURI: "https://github.com/protobuf-tools/protomigrate",
+			Rules:          []sarifRule{},
+		}},
+		seen: map[string]bool{},
+	}
+}
+
+func (sw *sarifWriter) write(diags []checker.Diagnostic) error {
+	for _, d := range diags {
+		f := findingOf(sw.r, d)
+		if id := f.Rule; !sw.seen[id] {
+			sw.seen[id] = true
+			rule := sarifRule{
+				ID:               id,
+				ShortDescription: sarifMessage{strings.Split(d.Analyzer.Doc, "\n\n")[0]},
+				FullDescription:  sarifMessage{d.Analyzer.Doc},
+
+				DefaultConfiguration: sarifConfiguration{sw.r.Severity(id)},
+			}
+			if r, ok := protomigrate.LookupRule(id); ok {
+				rule.Name = r.Name
+				rule.ShortDescription = sarifMessage{r.Doc}
+				rule.HelpURI = r.DocURL()
+			}
+			sw.tool.Driver.Rules = append(sw.tool.Driver.Rules, rule)
+		}
+
+		data, err := json.MarshalIndent(sarifResultOf(f), "\t\t\t\t", "\t")
+		if err != nil {
+			return err
+		}
+		sep := ",\n\t\t\t\t"
+		if sw.n == 0 {
+			sep = sarifHeader + "\n\t\t\t\t"
+		}
+		sw.n++
+		if _, err := io.WriteString(sw.w, sep); err != nil {
+			return err
+		}
+		if _, err := sw.w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sw *sarifWriter) close() error {
+	results := "\n\t\t\t]"
+	if sw.n == 0 {
+		results = sarifHeader + "]"
+	}
+	tool, err := json.MarshalIndent(sw.tool, "\t\t\t", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(sw.w, "%s,\n\t\t\t\"tool\": %s\n\t\t}\n\t]\n}\n", results, tool)
+	return err
+}
+
+// sarifResultOf returns the SARIF result of f.
+func sarifResultOf(f migrate.Finding) sarifResult {
+	// The columns of SARIF count UTF-16 code units by default, those of
+	// go/token bytes, which only differ past non-ASCII text.
+	region := sarifRegion{StartLine: f.Line, StartColumn: f.Column}
+	if f.End != nil {
+		region.EndLine, region.EndColumn = f.End.Line, f.End.Column
+	}
+	r := sarifResult{
+		RuleID:  f.Rule,
+		Level:   f.Severity,
+		Message: sarifMessage{f.Message},
+		Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: artifactLocation(f.File),
+			Region:           region,
+		}}},
+	}
+	for i, rel := range f.Related {
+		r.RelatedLocations = append(r.RelatedLocations, sarifLocation{
+			ID: i + 1,
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: artifactLocation(rel.File),
+				Region:           sarifRegion{StartLine: rel.Line, StartColumn: rel.Column},
+			},
+			Message: &sarifMessage{rel.Message},
+		})
+	}
+	for _, fix := range f.Fixes {
+		r.Fixes = append(r.Fixes, sarifFix{
+			Description:     sarifMessage{fix.Message},
+			ArtifactChanges: artifactChanges(fix.Edits),
+		})
+	}
+	return r
+}
+
+// artifactChanges groups edits by file, in the order the files are first
+// edited.
+func artifactChanges(edits []migrate.TextEdit) []sarifArtifactChange {
+	var changes []sarifArtifactChange
+	index := map[string]int{}
+	for _, e := range edits {
+		i, ok := index[e.Start.File]
+		if !ok {
+			i = len(changes)
+			index[e.Start.File] = i
+			changes = append(changes, sarifArtifactChange{ArtifactLocation: artifactLocation(e.Start.File)})
+		}
+		offset, length := e.Start.Offset, e.End.Offset-e.Start.Offset
+		changes[i].Replacements = append(changes[i].Replacements, sarifReplacement{
+			DeletedRegion:   sarifRegion{ByteOffset: &offset, ByteLength: &length},
+			InsertedContent: sarifMessage{e.NewText},
+		})
+	}
+	return changes
+}
+
+// artifactLocation locates the named file relative to the source root,
+// the current directory, if it is below it.
+func artifactLocation(name string) sarifArtifactLocation {
+	rel := relPath(name)
+	if filepath.IsAbs(rel) {
+		return sarifArtifactLocation{URI: "file://" + filepath.ToSlash(rel)}
+	}
+	return sarifArtifactLocation{URI: rel, URIBaseID: "%SRCROOT%"}
+}
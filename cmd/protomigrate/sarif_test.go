@@ -0,0 +1,43 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+func TestWriteDiagnosticsSARIF(t *testing.T) {
+	fset, diags := testDiagnostics()
+	var buf bytes.Buffer
+	if err := writeDiagnostics(&buf, fset, "sarif", diags); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "diagnostics.sarif", buf.Bytes())
+
+	// The output is the log encoded whole, as are the logs of no results.
+	for _, diags := range [][]checker.Diagnostic{diags, nil} {
+		buf.Reset()
+		if err := writeDiagnostics(&buf, fset, "sarif", diags); err != nil {
+			t.Fatal(err)
+		}
+		var log sarifLog
+		if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+			t.Fatalf("output of %d diagnostics:\n%s\n%v", len(diags), buf.Bytes(), err)
+		}
+		if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != len(diags) {
+			t.Errorf("output of %d diagnostics:\n%s\nwant a SARIF 2.1.0 log of a run with a result each", len(diags), buf.Bytes())
+		}
+		var whole bytes.Buffer
+		if err := encodeJSON(&whole, log); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(whole.Bytes(), buf.Bytes()) {
+			t.Errorf("output:\n%s\nwant the log encoded whole:\n%s", buf.Bytes(), whole.Bytes())
+		}
+	}
+}
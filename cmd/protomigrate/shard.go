@@ -0,0 +1,167 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/protobuf-tools/protomigrate/migrate"
+)
+
+// shardFlag is a flag.Value holding the shard of the packages a run
+// analyzes, N/M for the Nth of M shards, or none if M is 0.
+type shardFlag struct {
+	n, m int
+}
+
+func (s *shardFlag) String() string {
+	if s.m == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", s.n, s.m)
+}
+
+func (s *shardFlag) Set(v string) error {
+	i := strings.Index(v, "/")
+	if i < 0 {
+		return fmt.Errorf("invalid shard %q: want N/M", v)
+	}
+	n, err1 := strconv.Atoi(v[:i])
+	m, err2 := strconv.Atoi(v[i+1:])
+	if err1 != nil || err2 != nil || m < 1 || n < 1 || n > m {
+		return fmt.Errorf("invalid shard %q: want N/M with 1 <= N <= M", v)
+	}
+	s.n, s.m = n, m
+	return nil
+}
+
+// filter returns the packages of pkgs in the shard. A package is in the
+// shard given by the hash of its path, so that the shards do not change as
+// packages are added and removed elsewhere, and the test variants of a
+// package are in the same shard as it.
+func (s *shardFlag) filter(pkgs []*packages.Package) []*packages.Package {
+	if s.m == 0 {
+		return pkgs
+	}
+	var kept []*packages.Package
+	for _, pkg := range pkgs {
+		if s.has(pkg.PkgPath) {
+			kept = append(kept, pkg)
+		}
+	}
+	return kept
+}
+
+// has reports whether the package or module at path is in the shard.
+func (s *shardFlag) has(path string) bool {
+	if s.m == 0 {
+		return true
+	}
+	h := fnv.New32a()
+	io.WriteString(h, path)
+	return int(h.Sum32()%uint32(s.m)) == s.n-1
+}
+
+// merge reads the outputs of the shards of a run in the named files, in
+// the format of -format, json or sarif, and writes them to w as the output
+// of a single run.
+func merge(w io.Writer, names []string) error {
+	switch *format {
+	case "json":
+		return mergeJSON(w, names)
+	case "sarif":
+		return mergeSARIF(w, names)
+	}
+	return fmt.Errorf("cannot merge the outputs of -format=%s", *format)
+}
+
+func mergeJSON(w io.Writer, names []string) error {
+	out := migrate.Report{Findings: []migrate.Finding{}}
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		var in migrate.Report
+		if err := json.Unmarshal(data, &in); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		out.Findings = append(out.Findings, in.Findings...)
+	}
+	sort.SliceStable(out.Findings, func(i, j int) bool {
+		pi, pj := out.Findings[i].Position, out.Findings[j].Position
+		if pi.File != pj.File {
+			return pi.File < pj.File
+		}
+		return pi.Offset < pj.Offset
+	})
+	return encodeJSON(w, out)
+}
+
+// sarifLog is a SARIF log as -format=sarif outputs it, for reading the
+// outputs of shards.
+type sarifLog struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []struct {
+		Results []sarifResult `json:"results"`
+		Tool    sarifTool     `json:"tool"`
+	} `json:"runs"`
+}
+
+func mergeSARIF(w io.Writer, names []string) error {
+	var out sarifLog
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		var in sarifLog
+		if err := json.Unmarshal(data, &in); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if len(in.Runs) != 1 {
+			return fmt.Errorf("%s: not the output of protomigrate", name)
+		}
+		if out.Runs == nil {
+			out = in
+			continue
+		}
+		run, inRun := &out.Runs[0], in.Runs[0]
+		run.Results = append(run.Results, inRun.Results...)
+		seen := map[string]bool{}
+		for _, r := range run.Tool.Driver.Rules {
+			seen[r.ID] = true
+		}
+		for _, r := range inRun.Tool.Driver.Rules {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, r)
+			}
+		}
+	}
+	if out.Runs == nil {
+		return fmt.Errorf("no output to merge")
+	}
+	sort.Slice(out.Runs[0].Tool.Driver.Rules, func(i, j int) bool {
+		return out.Runs[0].Tool.Driver.Rules[i].ID < out.Runs[0].Tool.Driver.Rules[j].ID
+	})
+	return encodeJSON(w, out)
+}
+
+// encodeJSON writes v to w as indented JSON, like the outputs it merges.
+func encodeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// TestMerge checks that the merged outputs of the shards of a run are the
+// output of the run unsharded. The findings of JSON outputs are sorted by
+// position, while the results of SARIF logs are merged in order.
+func TestMerge(t *testing.T) {
+	fset, diags := testDiagnostics()
+	tests := []struct {
+		format string
+		shards [][]int // indices of the diagnostics of each shard
+	}{
+		{"json", [][]int{{1}, {}, {0}}},
+		{"sarif", [][]int{{0}, {}, {1}}},
+	}
+	for _, tt := range tests {
+		f := tt.format
+		t.Run(f, func(t *testing.T) {
+			old := *format
+			*format = f
+			t.Cleanup(func() { *format = old })
+
+			var want bytes.Buffer
+			if err := writeDiagnostics(&want, fset, f, diags); err != nil {
+				t.Fatal(err)
+			}
+
+			dir := t.TempDir()
+			var names []string
+			for i, shard := range tt.shards {
+				var buf bytes.Buffer
+				dw, err := newDiagnosticWriter(&buf, fset, f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, j := range shard {
+					if err := dw.write(diags[j : j+1]); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := dw.close(); err != nil {
+					t.Fatal(err)
+				}
+				name := filepath.Join(dir, fmt.Sprintf("shard%d.%s", i+1, f))
+				if err := ioutil.WriteFile(name, buf.Bytes(), 0666); err != nil {
+					t.Fatal(err)
+				}
+				names = append(names, name)
+			}
+
+			var got bytes.Buffer
+			if err := merge(&got, names); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Errorf("merged output:\n%s\nwant:\n%s", got.Bytes(), want.Bytes())
+			}
+		})
+	}
+}
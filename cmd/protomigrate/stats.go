@@ -0,0 +1,203 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// migrationStats counts the findings of a run, in total and by rule, API
+// family and package.
+type migrationStats struct {
+	Total    statsRow   `json:"total"`
+	Rules    []statsRow `json:"rules"`
+	Families []statsRow `json:"families"`
+	Packages []statsRow `json:"packages"`
+}
+
+// statsRow counts the findings of a key, and of those the ones with a fix
+// and the ones left to migrate by hand.
+type statsRow struct {
+	Key      string `json:"key"`
+	Findings int    `json:"findings"`
+	Fixable  int    `json:"fixable"`
+	Manual   int    `json:"manual"`
+}
+
+func (r *statsRow) add(d checker.Diagnostic) {
+	r.Findings++
+	if len(d.SuggestedFixes) > 0 {
+		r.Fixable++
+	} else {
+		r.Manual++
+	}
+}
+
+// textFuncs and extensionFuncs are the functions of the v1 proto package
+// of the text and extensions families.
+var (
+	textFuncs      = []string{"CompactText", "CompactTextString", "MarshalText", "MarshalTextString", "UnmarshalText"}
+	extensionFuncs = []string{"ClearExtension", "GetExtension", "HasExtension", "RegisterExtension", "SetExtension"}
+)
+
+// familyOf returns the API family of the v1 API d reports a use of: that
+// of the package of its rule, jsonpb for the rules of package jsonpb and
+// of its uses by grpc-gateway, and text and extensions for the functions of
+// package proto of those, which the messages of the rule start with.
+func familyOf(d checker.Diagnostic) string {
+	rule := ruleOf(d)
+	switch rule {
+	case "PM2001", "PM2002", "PM2003":
+		return "jsonpb"
+	case "PM3001":
+		for _, fn := range textFuncs {
+			if strings.HasPrefix(d.Message, "proto."+fn+" ") {
+				return "text"
+			}
+		}
+		for _, fn := range extensionFuncs {
+			if strings.HasPrefix(d.Message, "proto."+fn+" ") {
+				return "extensions"
+			}
+		}
+	}
+	if r, ok := protomigrate.LookupRule(rule); ok {
+		return r.Name
+	}
+	return rule
+}
+
+// printStats prints the statistics of the findings in the packages
+// matching patterns, and returns the exit code: 1 if the packages could
+// not be analyzed, 0 otherwise.
+func printStats(w io.Writer, patterns []string, analyzers []*analysis.Analyzer) int {
+	if *format == "sarif" || *format == "html" {
+		log.Print("cannot print the statistics with -format=" + *format)
+		return 1
+	}
+	_, diags, err := analyze(patterns, analyzers)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if *baselineFile != "" {
+		counts, err := readBaseline(*baselineFile)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		diags = suppress(diags, counts)
+	}
+	s := stats(diags)
+	switch *format {
+	case "json":
+		err = encodeJSON(w, s)
+	case "csv":
+		err = s.writeCSV(w)
+	default:
+		err = s.write(w)
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+// stats counts diags. The rows of the rules and families are sorted by
+// their keys, and those of the packages by their findings, most first.
+func stats(diags []checker.Diagnostic) *migrationStats {
+	s := &migrationStats{Total: statsRow{Key: "total"}}
+	rules := map[string]*statsRow{}
+	families := map[string]*statsRow{}
+	pkgs := map[string]*statsRow{}
+	row := func(rows map[string]*statsRow, key string) *statsRow {
+		r := rows[key]
+		if r == nil {
+			r = &statsRow{Key: key}
+			rows[key] = r
+		}
+		return r
+	}
+	for _, d := range diags {
+		rule := ruleOf(d)
+		if r, ok := protomigrate.LookupRule(rule); ok {
+			rule += " " + r.Name
+		}
+		s.Total.add(d)
+		row(rules, rule).add(d)
+		row(families, familyOf(d)).add(d)
+		row(pkgs, d.Package).add(d)
+	}
+	list := func(rows map[string]*statsRow) []statsRow {
+		out := []statsRow{}
+		for _, r := range rows {
+			out = append(out, *r)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+		return out
+	}
+	s.Rules = list(rules)
+	s.Families = list(families)
+	s.Packages = list(pkgs)
+	sort.SliceStable(s.Packages, func(i, j int) bool { return s.Packages[i].Findings > s.Packages[j].Findings })
+	return s
+}
+
+// write writes the statistics to w as tables, one for each of the rules,
+// the families and the packages.
+func (s *migrationStats) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for i, t := range []struct {
+		title string
+		rows  []statsRow
+	}{
+		{"RULE", s.Rules},
+		{"FAMILY", s.Families},
+		{"PACKAGE", s.Packages},
+	} {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s\tFINDINGS\tFIXABLE\tMANUAL\n", t.title)
+		for _, r := range append(t.rows, s.Total) {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", r.Key, r.Findings, r.Fixable, r.Manual)
+		}
+	}
+	return tw.Flush()
+}
+
+// writeCSV writes the statistics to w as CSV, a row for each count, keyed
+// by what it counts by, rule, family, package or total, and its key.
+func (s *migrationStats) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"by", "key", "findings", "fixable", "manual"})
+	for _, t := range []struct {
+		by   string
+		rows []statsRow
+	}{
+		{"total", []statsRow{s.Total}},
+		{"rule", s.Rules},
+		{"family", s.Families},
+		{"package", s.Packages},
+	} {
+		for _, r := range t.rows {
+			cw.Write([]string{t.by, r.Key, strconv.Itoa(r.Findings), strconv.Itoa(r.Fixable), strconv.Itoa(r.Manual)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"github.com/protobuf-tools/protomigrate"
+)
+
+// isVetTool reports whether args are those of a run of protomigrate as a
+// vet tool: go vet -vettool, and the drivers of build systems following
+// its protocol, run the tool with -V=full to key the results they cache,
+// with -flags to list the flags it accepts, and then once per package,
+// with the .cfg file describing it.
+func isVetTool(args []string) bool {
+	for _, arg := range args {
+		switch strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-") {
+		case "V=full", "flags":
+			return true
+		}
+	}
+	return len(args) > 0 && strings.HasSuffix(args[len(args)-1], ".cfg")
+}
+
+// runVetTool runs protomigrate as a vet tool on the package of the .cfg
+// file of args, with the facts of its dependencies the previous runs
+// wrote, and exits. The flags of each analyzer are prefixed with its name,
+// like -protomigrate.fix-level, and the analyzers of the groups run
+// instead of protomigrate if they are enabled, like with -pmimports; the
+// configuration file is that found from the directory of the package.
+func runVetTool(args []string) {
+	// The configuration sets the flags of the analyzers shared with those
+	// of the command line, which the flags of the vet tool override.
+	if err := loadConfig(); err != nil {
+		log.Fatal(err)
+	}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flag.Var(versionFlag{}, "V", "print version and exit")
+
+	analyzers := append([]*analysis.Analyzer{protomigrate.Analyzer}, protomigrate.GroupAnalyzers()...)
+	if !enablesGroup(args) {
+		// Each group would report the findings of protomigrate again.
+		args = append([]string{"-" + protomigrate.Analyzer.Name}, args...)
+	}
+	os.Args = append(os.Args[:1], args...)
+	unitchecker.Main(analyzers...)
+	panic("unreachable")
+}
+
+// enablesGroup reports whether args enable the analyzer of a group.
+func enablesGroup(args []string) bool {
+	for _, arg := range args {
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		v := "true"
+		if i := strings.Index(name, "="); i >= 0 {
+			name, v = name[:i], name[i+1:]
+		}
+		for _, a := range protomigrate.GroupAnalyzers() {
+			if enabled, err := strconv.ParseBool(v); name == a.Name && err == nil && enabled {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// versionFlag is the -V flag of the vet tool, which prints its version,
+// made of the hashes of its executable and of the configuration file of
+// the current directory, so that go vet runs it again once either changes.
+type versionFlag struct{}
+
+func (versionFlag) IsBoolFlag() bool { return true }
+func (versionFlag) Get() interface{} { return nil }
+func (versionFlag) String() string   { return "" }
+
+func (versionFlag) Set(v string) error {
+	if v != "full" {
+		return fmt.Errorf("unsupported flag value: -V=%s", v)
+	}
+	files := []string{os.Args[0]}
+	name, err := findConfig()
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		files = append(files, name)
+	}
+	h := sha256.New()
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%s version devel comments-go-here buildID=%02x\n", os.Args[0], string(h.Sum(nil)))
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+)
+
+// checkCodegen reports the files of a directory generated by protoc-gen-go
+// before v1.4, for the v1 API, in a single finding on the first of them:
+// their messages only implement the v2 API once regenerated, which is done
+// a directory at a time. The finding lists the .proto files they were
+// generated from that are found, looking for the paths their headers give
+// from the directory and its parents up to the module root.
+func checkCodegen(pass *analysis.Pass) (interface{}, error) {
+	files, ok := pass.ResultOf[facts.Protoc].(map[*types.Package]*facts.ProtoFiles)[pass.Pkg]
+	if !ok {
+		return nil, nil
+	}
+	byName := map[string]*ast.File{}
+	for _, file := range pass.Files {
+		byName[filepath.Base(pass.Fset.PositionFor(file.Pos(), false).Filename)] = file
+	}
+	var first *ast.File
+	var names, protos []string
+	for _, f := range files.Files {
+		file, ok := byName[f.Name]
+		if f.V2 || !ok {
+			continue
+		}
+		if first == nil {
+			first = file
+		}
+		names = append(names, f.Name)
+		dir := filepath.Dir(pass.Fset.PositionFor(file.Pos(), false).Filename)
+		if source := protoSource(file); source != "" {
+			if path, ok := locateProto(dir, source); ok {
+				protos = append(protos, path)
+			}
+		}
+	}
+	if first == nil {
+		return nil, nil
+	}
+	msg := fmt.Sprintf("files generated by protoc-gen-go before v1.4, for the v1 API, have to be regenerated with protoc-gen-go of google.golang.org/protobuf: %s", strings.Join(names, ", "))
+	if len(protos) > 0 {
+		msg += fmt.Sprintf("; their .proto files are %s", strings.Join(protos, ", "))
+	}
+	pass.Report(analysis.Diagnostic{Pos: first.Package, End: first.Name.End(), Message: msg})
+	return nil, nil
+}
+
+// protoSource returns the path of the .proto file the header of file, as
+// generated by protoc-gen-go, says it was generated from, or "" if it does
+// not.
+func protoSource(file *ast.File) string {
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			break
+		}
+		for _, c := range group.List {
+			if source := strings.TrimPrefix(c.Text, "// source: "); source != c.Text {
+				return strings.TrimSpace(source)
+			}
+		}
+	}
+	return ""
+}
+
+// locateProto looks for the .proto file of path source, relative to one of
+// the directories of the include path of protoc, in dir and its parents up
+// to the root of the module, and returns its path relative to dir.
+func locateProto(dir, source string) (string, bool) {
+	for d := dir; ; {
+		path := filepath.Join(d, filepath.FromSlash(source))
+		if _, err := os.Stat(path); err == nil {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return path, true
+			}
+			return filepath.ToSlash(rel), true
+		}
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return "", false
+		}
+		d = parent
+	}
+}
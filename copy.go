@@ -0,0 +1,197 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"honnef.co/go/tools/analysis/report"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+)
+
+// copyNote completes the diagnostics of checkCopy.
+const copyNote = "; the v2 runtime keeps internal state in messages, which must not be copied"
+
+// checkCopy reports the copies of message structs, like the copylocks
+// vet check does for locks: by assignments, declarations, range
+// variables, composite literals, calls, returns, and parameters and
+// receivers. The messages generated for the v1 API can be copied, but not
+// once regenerated for the v2 API, whose runtime keeps the state of a
+// message in it.
+func checkCopy(pass *analysis.Pass) (interface{}, error) {
+	skipped := map[*ast.File]bool{}
+	for _, file := range pass.Files {
+		skipped[file] = skipFile(pass, file)
+	}
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	nodeFilter := []ast.Node{
+		(*ast.File)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.CallExpr)(nil),
+		(*ast.CompositeLit)(nil),
+		(*ast.FuncDecl)(nil),
+		(*ast.FuncLit)(nil),
+		(*ast.GenDecl)(nil),
+		(*ast.RangeStmt)(nil),
+		(*ast.ReturnStmt)(nil),
+	}
+	ins.Nodes(nodeFilter, func(node ast.Node, push bool) bool {
+		if !push {
+			return false
+		}
+		switch node := node.(type) {
+		case *ast.File:
+			return !skipped[node]
+		case *ast.AssignStmt:
+			for i, x := range node.Rhs {
+				if i >= len(node.Lhs) || isBlank(node.Lhs[i]) {
+					continue
+				}
+				if path := copiedMessageRhs(pass, x); path != "" {
+					report.Report(pass, x, fmt.Sprintf("assignment copies message value to %s: %s", report.Render(pass, node.Lhs[i]), path)+copyNote)
+				}
+			}
+		case *ast.GenDecl:
+			if node.Tok != token.VAR {
+				return true
+			}
+			for _, spec := range node.Specs {
+				spec := spec.(*ast.ValueSpec)
+				for i, x := range spec.Values {
+					if path := copiedMessageRhs(pass, x); path != "" && i < len(spec.Names) {
+						report.Report(pass, x, fmt.Sprintf("variable declaration copies message value to %s: %s", spec.Names[i].Name, path)+copyNote)
+					}
+				}
+			}
+		case *ast.CompositeLit:
+			for _, x := range node.Elts {
+				if kv, ok := x.(*ast.KeyValueExpr); ok {
+					x = kv.Value
+				}
+				if path := copiedMessageRhs(pass, x); path != "" {
+					report.Report(pass, x, fmt.Sprintf("literal copies message value from %s: %s", report.Render(pass, x), path)+copyNote)
+				}
+			}
+		case *ast.CallExpr:
+			if tv, ok := pass.TypesInfo.Types[node.Fun]; ok && tv.IsType() {
+				// Conversions are not calls.
+				return true
+			}
+			for _, x := range node.Args {
+				if path := copiedMessageRhs(pass, x); path != "" {
+					report.Report(pass, x, fmt.Sprintf("call of %s copies message value: %s", report.Render(pass, node.Fun), path)+copyNote)
+				}
+			}
+		case *ast.ReturnStmt:
+			for _, x := range node.Results {
+				if path := copiedMessageRhs(pass, x); path != "" {
+					report.Report(pass, x, "return copies message value: "+path+copyNote)
+				}
+			}
+		case *ast.RangeStmt:
+			if node.Value == nil {
+				return true
+			}
+			if isBlank(node.Value) {
+				return true
+			}
+			if t := pass.TypesInfo.TypeOf(node.Value); t != nil {
+				if path := copiedMessage(pass, t, nil); path != "" {
+					report.Report(pass, node.Value, fmt.Sprintf("range var %s copies message: %s", report.Render(pass, node.Value), path)+copyNote)
+				}
+			}
+		case *ast.FuncDecl:
+			if node.Recv != nil {
+				checkCopyFields(pass, node.Name.Name, node.Recv)
+			}
+			checkCopyFields(pass, node.Name.Name, node.Type.Params)
+		case *ast.FuncLit:
+			checkCopyFields(pass, "func", node.Type.Params)
+		}
+		return true
+	})
+	return nil, nil
+}
+
+// checkCopyFields reports the receivers or parameters of the function of
+// the given name that are messages passed by value.
+func checkCopyFields(pass *analysis.Pass, name string, fields *ast.FieldList) {
+	for _, field := range fields.List {
+		t := pass.TypesInfo.TypeOf(field.Type)
+		if t == nil {
+			continue
+		}
+		if path := copiedMessage(pass, t, nil); path != "" {
+			report.Report(pass, field.Type, fmt.Sprintf("%s passes message by value: %s", name, path)+copyNote)
+		}
+	}
+}
+
+// qualifier qualifies the names of the other packages than that of pass by
+// their names, like the diagnostics of vet.
+func qualifier(pass *analysis.Pass) types.Qualifier {
+	return func(pkg *types.Package) string {
+		if pkg == pass.Pkg {
+			return ""
+		}
+		return pkg.Name()
+	}
+}
+
+// copiedMessageRhs returns the path to the message that evaluating x
+// copies, or "" if it copies none. Composite literals and calls make new
+// values rather than copy them.
+func copiedMessageRhs(pass *analysis.Pass, x ast.Expr) string {
+	x = astutil.Unparen(x)
+	switch x := x.(type) {
+	case *ast.CompositeLit, *ast.CallExpr:
+		return ""
+	case *ast.StarExpr:
+		// A call may return a pointer to a new value.
+		if _, ok := astutil.Unparen(x.X).(*ast.CallExpr); ok {
+			return ""
+		}
+	}
+	tv, ok := pass.TypesInfo.Types[x]
+	if !ok || tv.IsType() || tv.Type == nil {
+		return ""
+	}
+	return copiedMessage(pass, tv.Type, nil)
+}
+
+// copiedMessage returns the path to the message struct that values of type
+// t hold, like "T contains durationpb.Duration", or "" if they hold none. Pointers are not
+// followed, since copying them does not copy what they point to.
+func copiedMessage(pass *analysis.Pass, t types.Type, path []string) string {
+	if _, ok := facts.ClassifyMessage(t); ok && !types.IsInterface(t) {
+		path = append(path, types.TypeString(t, qualifier(pass)))
+		s := path[0]
+		for _, p := range path[1:] {
+			s += " contains " + p
+		}
+		return s
+	}
+	if _, ok := t.Underlying().(*types.Pointer); ok {
+		return ""
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Array:
+		return copiedMessage(pass, u.Elem(), append(path, types.TypeString(t, qualifier(pass))))
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if s := copiedMessage(pass, u.Field(i).Type(), append(path, types.TypeString(t, qualifier(pass)))); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// logLevel is the verbosity of the debug log, and a flag.Getter holding
+// that of -debug.
+type logLevel int
+
+const (
+	logOff logLevel = iota
+
+	// logDebug logs the checks run on each package, with the number of
+	// their findings and how long they took.
+	logDebug
+
+	// logTrace logs each finding too, reported or suppressed.
+	logTrace
+)
+
+var logLevelNames = []string{"off", "debug", "trace"}
+
+func (l *logLevel) String() string {
+	return logLevelNames[*l]
+}
+
+func (l *logLevel) Set(s string) error {
+	// -debug alone sets the debug level.
+	if s == "true" {
+		s = "debug"
+	} else if s == "false" {
+		s = "off"
+	}
+	for i, name := range logLevelNames {
+		if s == name {
+			*l = logLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid debug level: %q", s)
+}
+
+func (l *logLevel) Get() interface{} {
+	return l.String()
+}
+
+// IsBoolFlag lets -debug be set without a level.
+func (l *logLevel) IsBoolFlag() bool {
+	return true
+}
+
+var (
+	// debugLevel is the verbosity of the debug log.
+	debugLevel logLevel
+
+	// debugDir is the directory of the debug logs of the packages, one
+	// file per package; the log goes to the standard error if it is empty.
+	debugDir string
+
+	// stderrMu serializes the lines logged to the standard error by the
+	// passes run in parallel.
+	stderrMu sync.Mutex
+)
+
+// A logger writes the debug log of a pass, in lines of key=value pairs:
+//
+//	level=debug pkg=example.com/foo msg="check done" check=checkProto findings=3 elapsed=1.2ms
+//
+// The methods of a nil logger do nothing, so that a pass logs nothing
+// unless -debug is set.
+type logger struct {
+	pkg  string
+	w    io.Writer
+	file *os.File
+}
+
+// newLogger returns the logger of pass, or nil if -debug is off.
+func newLogger(pass *analysis.Pass) (*logger, error) {
+	if debugLevel == logOff {
+		return nil, nil
+	}
+	l := &logger{pkg: pass.Pkg.Path(), w: os.Stderr}
+	if debugDir != "" {
+		if err := os.MkdirAll(debugDir, 0777); err != nil {
+			return nil, err
+		}
+		// The analyzers of the groups run on the same packages, so their
+		// logs are named after them too.
+		base := strings.Replace(l.pkg, "/", "_", -1)
+		if pass.Analyzer.Name != "protomigrate" {
+			base += "." + pass.Analyzer.Name
+		}
+		name := filepath.Join(debugDir, base+".log")
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		l.w, l.file = f, f
+	}
+	return l, nil
+}
+
+// enabled reports whether l logs at level.
+func (l *logger) enabled(level logLevel) bool {
+	return l != nil && level <= debugLevel
+}
+
+// log logs msg at level, along with the pairs of keys and values kv.
+func (l *logger) log(level logLevel, msg string, kv ...interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "level=%s pkg=%s msg=%s", logLevelNames[level], l.pkg, logValue(msg))
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, " %v=%s", kv[i], logValue(fmt.Sprint(kv[i+1])))
+	}
+	b.WriteByte('\n')
+	if l.file == nil {
+		stderrMu.Lock()
+		defer stderrMu.Unlock()
+	}
+	io.WriteString(l.w, b.String())
+}
+
+// close closes the log file of l, if any.
+func (l *logger) close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// logValue quotes v unless it is a single word.
+func logValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+		return strconv.Quote(v)
+	}
+	return v
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const (
+	descriptorPath = "github.com/golang/protobuf/descriptor"
+	protodescPath  = "google.golang.org/protobuf/reflect/protodesc"
+)
+
+// descriptorFuncs maps the descriptor functions that can be rewritten to
+// the functions rewriting calls of them.
+var descriptorFuncs = map[string]func(*funcCall) bool{
+	"ForMessage":             rewriteForMessage,
+	"MessageDescriptorProto": rewriteMessageDescriptorProto,
+}
+
+// checkDescriptor rewrites calls of the descriptor package's functions to
+// conversions of the message's protoreflect descriptor.
+func checkDescriptor(pass *analysis.Pass) (interface{}, error) {
+	rewriteCalls(pass, descriptorPath, descriptorFuncs)
+	return nil, nil
+}
+
+func rewriteForMessage(c *funcCall) bool {
+	return c.rewriteDescriptorProto("descriptor.ForMessage should be replaced with protodesc.ToFileDescriptorProto and protodesc.ToDescriptorProto of msg.ProtoReflect().Descriptor()")
+}
+
+func rewriteMessageDescriptorProto(c *funcCall) bool {
+	return c.rewriteDescriptorProto("descriptor.MessageDescriptorProto should be replaced with protodesc.ToFileDescriptorProto and protodesc.ToDescriptorProto of msg.ProtoReflect().Descriptor()")
+}
+
+// rewriteDescriptorProto rewrites the call, which must return the file and
+// message descriptor protos of its only argument, to conversions of the
+// argument's protoreflect descriptor. The call must be the value of an
+// assignment to both results.
+func (c *funcCall) rewriteDescriptorProto(msg string) bool {
+	assign, ok := c.path[1].(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 2 || len(assign.Rhs) != 1 || len(c.call.Args) != 1 {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	m := c.call.Args[0]
+	if t := c.pass.TypesInfo.TypeOf(m); !hasProtoReflect(t) {
+		report.Report(c.pass, c.call, msg+v1MessageNote(c.pass, t))
+		return false
+	}
+
+	fd, md := assign.Lhs[0], assign.Lhs[1]
+	protodesc := c.rw.qualifier(c.pass, protodescPath)
+	desc := ".ProtoReflect().Descriptor()"
+	if !isOperand(m) {
+		desc = ")" + desc
+	}
+
+	var edits []analysis.TextEdit
+	switch {
+	case isBlank(fd) && isBlank(md):
+		report.Report(c.pass, c.call, msg)
+		return false
+	case isBlank(fd):
+		// _, md := descriptor.ForMessage(m)
+		edits = []analysis.TextEdit{
+			edit.Delete(edit.Range{fd.Pos(), md.Pos()}),
+			edit.ReplaceWithString(c.pass.Fset, c.sel, protodesc+".ToDescriptorProto"),
+			insert(m.End(), desc),
+		}
+	case isBlank(md):
+		// fd, _ := descriptor.ForMessage(m)
+		edits = []analysis.TextEdit{
+			edit.Delete(edit.Range{fd.End(), md.End()}),
+			edit.ReplaceWithString(c.pass.Fset, c.sel, protodesc+".ToFileDescriptorProto"),
+			insert(m.End(), desc+".ParentFile()"),
+		}
+	default:
+		// Both results are used, so the descriptor is kept in a variable
+		// declared before the assignment.
+		if _, _, ok := stmtList(c.path[2], assign); !ok || !isSimpleExpr(m) {
+			report.Report(c.pass, c.call, msg)
+			return false
+		}
+		name := freshName(c.pass, assign.Pos(), "desc", assign)
+		decl := name + " := " + report.Render(c.pass, m) + desc + "\n" + indentation(c.pass, assign.Pos())
+		edits = []analysis.TextEdit{
+			insert(assign.Pos(), decl),
+			edit.ReplaceWithString(c.pass.Fset, c.call, protodesc+".ToFileDescriptorProto("+name+".ParentFile()), "+protodesc+".ToDescriptorProto("+name+")"),
+		}
+	}
+	if !isOperand(m) {
+		edits = append(edits, insert(m.Pos(), "("))
+	}
+	c.rw.require(c.pass, protodescPath)
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Convert the message descriptor with protodesc", edits...)))
+	return true
+}
+
+// isBlank reports whether expr is the blank identifier.
+func isBlank(expr ast.Expr) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == "_"
+}
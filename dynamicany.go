@@ -0,0 +1,173 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+// The v2 API has no counterpart of ptypes.DynamicAny: the UnmarshalNew
+// method of the Any resolves the message type the same way, but only
+// messages linked into the binary are found.
+const dynamicAnyMsg = "ptypes.DynamicAny should be replaced with the UnmarshalNew method of the Any, which looks the message type up in protoregistry.GlobalTypes; messages whose types are not linked in need a dynamicpb.NewMessage of their descriptor instead"
+
+// dynamicAny describes a variable of type ptypes.DynamicAny that only
+// serves to unmarshal an Any into a message of the type it names.
+type dynamicAny struct {
+	// spec declares the variable.
+	spec *ast.ValueSpec
+
+	// unmarshal is the ptypes.UnmarshalAny call decoding into the
+	// variable, which makes up stmt.
+	unmarshal *ast.CallExpr
+	stmt      ast.Stmt
+
+	// messages holds the references to the decoded message.
+	messages []*ast.SelectorExpr
+}
+
+func rewriteDynamicAny(c *funcCall) bool {
+	spec, ok := c.path[0].(*ast.ValueSpec)
+	if !ok || spec.Type != c.sel || len(spec.Names) != 1 {
+		report.Report(c.pass, c.sel, dynamicAnyMsg)
+		return false
+	}
+	obj, ok := c.pass.TypesInfo.Defs[spec.Names[0]].(*types.Var)
+	if !ok {
+		report.Report(c.pass, c.sel, dynamicAnyMsg)
+		return false
+	}
+	dyn, ok := findDynamicAny(c.pass, c.rw.file, obj)
+	if !ok {
+		report.Report(c.pass, c.sel, dynamicAnyMsg)
+		return false
+	}
+
+	c.rw.require(c.pass, protoV2Path)
+	edits := []analysis.TextEdit{
+		edit.ReplaceWithString(c.pass.Fset, c.sel, c.rw.qualifier(c.pass, protoV2Path)+".Message"),
+	}
+	for _, sel := range dyn.messages {
+		edits = append(edits, edit.Delete(edit.Range{sel.X.End(), sel.End()}))
+	}
+
+	// The message is assigned along with the error.
+	name := obj.Name()
+	a := dyn.unmarshal.Args[0]
+	open, sel := "", ".UnmarshalNew("
+	if !isOperand(a) {
+		open, sel = "(", ")"+sel
+	}
+	switch stmt := dyn.stmt.(type) {
+	case *ast.ExprStmt:
+		open = name + ", _ = " + open
+	case *ast.AssignStmt:
+		edits = append(edits, insert(stmt.Lhs[0].Pos(), name+", "))
+	}
+	edits = append(edits,
+		analysis.TextEdit{Pos: dyn.unmarshal.Pos(), End: a.Pos(), NewText: []byte(open)},
+		analysis.TextEdit{Pos: a.End(), End: dyn.unmarshal.Rparen, NewText: []byte(sel)})
+	report.Report(c.pass, c.sel, dynamicAnyMsg, report.Fixes(edit.Fix("Use the UnmarshalNew method", edits...)))
+	return true
+}
+
+// findDynamicAny returns the description of obj, a variable of type
+// ptypes.DynamicAny, if it is declared on its own by a statement, decoded
+// into by a single ptypes.UnmarshalAny call that is a statement of the same
+// list, and otherwise only used through its Message field.
+func findDynamicAny(pass *analysis.Pass, file *ast.File, obj *types.Var) (*dynamicAny, bool) {
+	dyn := &dynamicAny{}
+	var list []ast.Stmt
+	decl := -1
+	ok := true
+	ast.Inspect(file, func(node ast.Node) bool {
+		if !ok {
+			return false
+		}
+		id, isIdent := node.(*ast.Ident)
+		if !isIdent || pass.TypesInfo.ObjectOf(id) != obj {
+			return true
+		}
+		path, _ := astutil.PathEnclosingInterval(file, id.Pos(), id.End())
+		switch parent := path[1].(type) {
+		case *ast.ValueSpec:
+			// var dyn ptypes.DynamicAny
+			gen, isGen := path[2].(*ast.GenDecl)
+			if !isGen || len(gen.Specs) != 1 || len(parent.Values) != 0 {
+				ok = false
+				return false
+			}
+			stmt, isDecl := path[3].(*ast.DeclStmt)
+			if !isDecl {
+				ok = false
+				return false
+			}
+			list, decl, ok = stmtList(path[4], stmt)
+			dyn.spec = parent
+		case *ast.SelectorExpr:
+			// dyn.Message
+			if parent.X != id || parent.Sel.Name != "Message" {
+				ok = false
+				return false
+			}
+			dyn.messages = append(dyn.messages, parent)
+		case *ast.UnaryExpr:
+			// ptypes.UnmarshalAny(a, &dyn)
+			call, isCall := path[2].(*ast.CallExpr)
+			if parent.Op != token.AND || !isCall || dyn.unmarshal != nil ||
+				!isCallTo(pass, call, ptypesPath, "UnmarshalAny") || len(call.Args) != 2 || call.Args[1] != parent {
+				ok = false
+				return false
+			}
+			switch stmt := path[3].(type) {
+			case *ast.ExprStmt:
+				dyn.stmt = stmt
+			case *ast.AssignStmt:
+				if len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 {
+					ok = false
+					return false
+				}
+				dyn.stmt = stmt
+			default:
+				ok = false
+				return false
+			}
+			dyn.unmarshal = call
+		default:
+			ok = false
+		}
+		return false
+	})
+	if !ok || dyn.spec == nil || dyn.unmarshal == nil {
+		return nil, false
+	}
+	// The message is assigned by the same statement as the error, which
+	// must be in the scope of the variable.
+	for _, stmt := range list[decl:] {
+		if stmt == dyn.stmt {
+			return dyn, true
+		}
+	}
+	return nil, false
+}
+
+// isDynamicAny reports whether t is ptypes.DynamicAny or a pointer to it.
+func isDynamicAny(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Name() == "DynamicAny" && obj.Pkg() != nil && pkgPath(obj.Pkg()) == ptypesPath
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/types/typeutil"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const encodingJSONPath = "encoding/json"
+
+// encodingJSONFuncs maps the functions and methods of package
+// encoding/json to the index of the argument they encode or decode.
+var encodingJSONFuncs = map[string]int{
+	"json.Marshal":           0,
+	"json.MarshalIndent":     0,
+	"json.Unmarshal":         1,
+	"(*json.Encoder).Encode": 0,
+	"(*json.Decoder).Decode": 0,
+}
+
+// checkEncodingJSON reports the messages encoded or decoded with package
+// encoding/json, which does not follow the JSON mapping of protobuf: it
+// uses the names of the Go fields, and breaks on oneofs and on the
+// well-known types with a JSON form of their own, like Any. The calls of
+// json.Marshal, json.MarshalIndent with no prefix and json.Unmarshal on a
+// message are rewritten to use protojson.
+func checkEncodingJSON(pass *analysis.Pass) (interface{}, error) {
+	forEachFile(pass, func(pass *analysis.Pass, file *ast.File) {
+		type jsonCall struct {
+			call *ast.CallExpr
+			name string
+		}
+		var fixable []jsonCall
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			name, ok := encodingJSONCall(pass, call)
+			if !ok {
+				return true
+			}
+			arg := call.Args[encodingJSONFuncs[name]]
+			t := pass.TypesInfo.TypeOf(arg)
+			switch {
+			case isMessage(t) && name != "(*json.Encoder).Encode" && name != "(*json.Decoder).Decode":
+				if !hasProtoReflect(t) {
+					report.Report(pass, call, name+" should be replaced with "+protojsonName(name)+" for messages, which encoding/json does not encode in the JSON mapping of protobuf"+v1MessageNote(pass, t))
+					return true
+				}
+				if name == "json.MarshalIndent" && !isEmptyString(pass, call.Args[1]) {
+					report.Report(pass, call, name+" should be replaced with "+protojsonName(name)+" for messages, which encoding/json does not encode in the JSON mapping of protobuf; protojson has no prefix option")
+					return true
+				}
+				fixable = append(fixable, jsonCall{call, name})
+			case isMessage(t):
+				report.Report(pass, call, name+" does not encode messages in the JSON mapping of protobuf, so they should be encoded with protojson")
+			case mentionsMessage(t, map[types.Type]bool{}):
+				report.Report(pass, call, name+" does not encode the messages its argument holds in the JSON mapping of protobuf, so they should be encoded with protojson")
+			}
+			return true
+		})
+		if len(fixable) == 0 {
+			return
+		}
+
+		// The fixes depend on each other like those of the other checks, so
+		// the first one makes the import edits they share.
+		rw := stdImportRewrite(pass, file, encodingJSONPath, len(fixable), protojsonPath)
+		for i, c := range fixable {
+			msg := c.name + " should be replaced with " + protojsonName(c.name) + " for messages, which encoding/json does not encode in the JSON mapping of protobuf"
+			if rw == nil {
+				report.Report(pass, c.call, msg)
+				continue
+			}
+			q := rw.qualifier(pass, protojsonPath)
+			var edits []analysis.TextEdit
+			if c.name == "json.MarshalIndent" {
+				indent := report.Render(pass, c.call.Args[2])
+				edits = append(edits,
+					edit.ReplaceWithString(pass.Fset, edit.Range{c.call.Fun.Pos(), c.call.Args[0].Pos()}, q+".MarshalOptions{Multiline: true, Indent: "+indent+"}.Marshal("),
+					edit.Delete(edit.Range{c.call.Args[0].End(), c.call.Rparen}),
+				)
+			} else {
+				edits = append(edits, edit.ReplaceWithString(pass.Fset, c.call.Fun, q+"."+c.name[len("json."):]))
+			}
+			if i == 0 {
+				edits = append(edits, rw.importEdits(pass)...)
+			}
+			report.Report(pass, c.call, msg, report.Fixes(edit.Fix("Use "+protojsonName(c.name), edits...)))
+		}
+	})
+	return nil, nil
+}
+
+// encodingJSONCall returns the name of the function or method of package
+// encoding/json call calls, if it is one of encodingJSONFuncs.
+func encodingJSONCall(pass *analysis.Pass, call *ast.CallExpr) (string, bool) {
+	if _, ok := astutil.Unparen(call.Fun).(*ast.SelectorExpr); !ok {
+		return "", false
+	}
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || pkgPath(fn.Pkg()) != encodingJSONPath {
+		return "", false
+	}
+	name := "json." + fn.Name()
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		ptr, ok := recv.Type().(*types.Pointer)
+		if !ok {
+			return "", false
+		}
+		named, ok := ptr.Elem().(*types.Named)
+		if !ok {
+			return "", false
+		}
+		name = "(*json." + named.Obj().Name() + ")." + fn.Name()
+	}
+	i, ok := encodingJSONFuncs[name]
+	if !ok || i >= len(call.Args) || call.Ellipsis.IsValid() {
+		return "", false
+	}
+	return name, true
+}
+
+// protojsonName returns the name of the replacement of name, a function of
+// encodingJSONFuncs.
+func protojsonName(name string) string {
+	switch name {
+	case "json.Marshal":
+		return "protojson.Marshal"
+	case "json.MarshalIndent":
+		return "protojson.MarshalOptions.Marshal"
+	case "json.Unmarshal":
+		return "protojson.Unmarshal"
+	}
+	return "protojson"
+}
+
+// isEmptyString reports whether expr is the constant "".
+func isEmptyString(pass *analysis.Pass, expr ast.Expr) bool {
+	tv, ok := pass.TypesInfo.Types[expr]
+	return ok && tv.Value != nil && tv.Value.Kind() == constant.String && constant.StringVal(tv.Value) == ""
+}
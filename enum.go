@@ -0,0 +1,150 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+// rewriteEnumName rewrites the calls of proto.EnumName taking the map
+// generated for an enum to the String method of the enum, which names the
+// values, and the unknown ones by number, as proto.EnumName does.
+func rewriteEnumName(c *funcCall) bool {
+	const msg = "proto.EnumName has no v2 counterpart"
+	if c.call == nil || len(c.call.Args) != 2 {
+		report.Report(c.pass, c.sel, msg+"; the String method of generated enums names their values")
+		return false
+	}
+	enum, ok := enumOfMap(c.pass, c.call.Args[0], "_name")
+	if !ok || !hasMethod(enum.Type(), "String") {
+		report.Report(c.pass, c.call, msg+"; the values of generated enums are named by their String method, the others are looked up in the map, or in the descriptor of the enum")
+		return false
+	}
+	x := c.call.Args[1]
+	recv := ""
+	if conv, ok := astutil.Unparen(x).(*ast.CallExpr); ok && len(conv.Args) == 1 && isConversion(c.pass, conv) && types.Identical(c.pass.TypesInfo.TypeOf(conv.Args[0]), enum.Type()) {
+		// proto.EnumName(Foo_name, int32(x)), as in the String methods of
+		// the v1 generated code.
+		recv = report.Render(c.pass, conv.Args[0])
+		if !isOperand(conv.Args[0]) {
+			recv = "(" + recv + ")"
+		}
+	} else {
+		name := report.Render(c.pass, astutil.Unparen(c.call.Args[0]))
+		recv = strings.TrimSuffix(name, "_name") + "(" + report.Render(c.pass, x) + ")"
+	}
+	report.Report(c.pass, c.call, "proto.EnumName should be replaced with the String method of "+enum.Name()+", which names its values alike", report.Fixes(edit.Fix("Use the String method of the enum", edit.ReplaceWithString(c.pass.Fset, c.call, recv+".String()"))))
+	return true
+}
+
+// rewriteUnmarshalJSONEnum reports the calls of proto.UnmarshalJSONEnum,
+// which the v1 generated code of proto2 enums calls in their UnmarshalJSON
+// methods. protojson unmarshals the enums of messages itself, and the names
+// of other values are looked up in the descriptor of the enum.
+func rewriteUnmarshalJSONEnum(c *funcCall) bool {
+	report.Report(c.pass, c.sel, "proto.UnmarshalJSONEnum has no v2 counterpart; protojson unmarshals the enums of messages, and other names are looked up with the Descriptor().Values().ByName method of the enum")
+	return false
+}
+
+// checkEnumMaps reports the changes of the maps generated for enums, which
+// named their values in the v1 API: its String and UnmarshalJSON methods
+// looked them up. The v2 runtime names the values after the descriptor of
+// the enum, and generates the maps only to keep the code using them
+// building.
+func checkEnumMaps(pass *analysis.Pass) (interface{}, error) {
+	nodeFilter := []ast.Node{
+		(*ast.AssignStmt)(nil),
+		(*ast.IncDecStmt)(nil),
+		(*ast.CallExpr)(nil),
+	}
+	preorderMigrated(pass, nodeFilter, func(node ast.Node) {
+		var targets []ast.Expr
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			if node.Tok != token.DEFINE {
+				targets = node.Lhs
+			}
+		case *ast.IncDecStmt:
+			targets = []ast.Expr{node.X}
+		case *ast.CallExpr:
+			if id, ok := astutil.Unparen(node.Fun).(*ast.Ident); ok && len(node.Args) == 2 {
+				if b, ok := pass.TypesInfo.Uses[id].(*types.Builtin); ok && b.Name() == "delete" {
+					reportEnumMap(pass, node.Args[0])
+				}
+			}
+		}
+		for _, lhs := range targets {
+			switch lhs := astutil.Unparen(lhs).(type) {
+			case *ast.IndexExpr:
+				reportEnumMap(pass, lhs.X)
+			case *ast.Ident, *ast.SelectorExpr:
+				reportEnumMap(pass, lhs)
+			}
+		}
+	})
+	return nil, nil
+}
+
+// reportEnumMap reports m if it is one of the maps generated for an enum.
+func reportEnumMap(pass *analysis.Pass, m ast.Expr) {
+	for _, suffix := range []string{"_name", "_value"} {
+		if enum, ok := enumOfMap(pass, m, suffix); ok {
+			report.Report(pass, m, "changes of "+report.Render(pass, m)+" no longer change the names of the values of "+enum.Name()+", which the v2 runtime takes from the descriptor of the enum")
+			return
+		}
+	}
+}
+
+// enumOfMap returns the enum whose generated map, named after it with the
+// given suffix, m refers to: Foo_name, which maps the numbers of the
+// values of Foo to their names, or Foo_value, which maps them back.
+func enumOfMap(pass *analysis.Pass, m ast.Expr, suffix string) (*types.TypeName, bool) {
+	var id *ast.Ident
+	switch m := astutil.Unparen(m).(type) {
+	case *ast.Ident:
+		id = m
+	case *ast.SelectorExpr:
+		id = m.Sel
+	default:
+		return nil, false
+	}
+	v, ok := pass.TypesInfo.Uses[id].(*types.Var)
+	if !ok || v.Pkg() == nil || v.Parent() != v.Pkg().Scope() || !strings.HasSuffix(v.Name(), suffix) {
+		return nil, false
+	}
+	enum, ok := v.Pkg().Scope().Lookup(strings.TrimSuffix(v.Name(), suffix)).(*types.TypeName)
+	if !ok {
+		return nil, false
+	}
+	if b, ok := enum.Type().Underlying().(*types.Basic); !ok || b.Kind() != types.Int32 {
+		return nil, false
+	}
+	want := "map[int32]string"
+	if suffix == "_value" {
+		want = "map[string]int32"
+	}
+	return enum, v.Type().String() == want
+}
+
+// hasMethod reports whether values of type t have the method of the given
+// name.
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, false, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// isConversion reports whether call converts its argument to a type.
+func isConversion(pass *analysis.Pass, call *ast.CallExpr) bool {
+	tv, ok := pass.TypesInfo.Types[call.Fun]
+	return ok && tv.IsType()
+}
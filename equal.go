@@ -0,0 +1,205 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/types"
+	"path"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/types/typeutil"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+)
+
+// testifyEqual maps the comparison functions and methods of the testify
+// packages that compare with reflect.DeepEqual to the assertions of the
+// result of proto.Equal replacing them.
+var testifyEqual = map[string]string{
+	"Equal":    "True",
+	"NotEqual": "False",
+}
+
+var testifyPaths = map[string]bool{
+	"github.com/stretchr/testify/assert":  true,
+	"github.com/stretchr/testify/require": true,
+}
+
+// equalCall is a call comparing x and y with reflect.DeepEqual.
+type equalCall struct {
+	call *ast.CallExpr
+	sel  *ast.SelectorExpr
+	x, y ast.Expr
+
+	// name is the qualified name of the function called, like
+	// reflect.DeepEqual, and assertion the name of the testify assertion
+	// replacing it, or "" if it is reflect.DeepEqual.
+	name      string
+	assertion string
+}
+
+// checkDeepEqual reports the comparisons of messages with reflect.DeepEqual,
+// directly or through testify's Equal, which compare the internal state
+// the v2 runtime keeps in messages along with their content. The
+// comparisons of two messages are rewritten to use proto.Equal.
+func checkDeepEqual(pass *analysis.Pass) (interface{}, error) {
+	forEachFile(pass, func(pass *analysis.Pass, file *ast.File) {
+		var fixable []*equalCall
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			c := deepEqualCall(pass, call)
+			if c == nil {
+				return true
+			}
+			tx, ty := pass.TypesInfo.TypeOf(c.x), pass.TypesInfo.TypeOf(c.y)
+			switch {
+			case isMessage(tx) && isMessage(ty):
+				if !hasProtoReflect(tx) || !hasProtoReflect(ty) {
+					t := tx
+					if hasProtoReflect(t) {
+						t = ty
+					}
+					report.Report(pass, call, c.name+" compares the internal state of v2 messages, proto.Equal their content"+v1MessageNote(pass, t))
+					return true
+				}
+				fixable = append(fixable, c)
+			case mentionsMessage(tx, map[types.Type]bool{}) || mentionsMessage(ty, map[types.Type]bool{}):
+				report.Report(pass, call, c.name+" compares the internal state of v2 messages, cmp.Diff with protocmp.Transform() their content")
+			}
+			return true
+		})
+		if len(fixable) == 0 {
+			return
+		}
+
+		// The fixes depend on each other like those of the other checks, so
+		// the first one makes the import edits they share.
+		fixed := 0
+		for _, c := range fixable {
+			if c.assertion == "" {
+				fixed++
+			}
+		}
+		rw := stdImportRewrite(pass, file, "reflect", fixed, protoV2Path)
+		for i, c := range fixable {
+			msg := c.name + " compares the internal state of v2 messages, proto.Equal their content"
+			if rw == nil {
+				report.Report(pass, c.call, msg)
+				continue
+			}
+			q := rw.qualifier(pass, protoV2Path)
+			var edits []analysis.TextEdit
+			fix := "Use proto.Equal"
+			if c.assertion == "" {
+				edits = append(edits, edit.ReplaceWithString(pass.Fset, c.sel, q+".Equal"))
+			} else {
+				fix = "Assert the result of proto.Equal"
+				edits = append(edits,
+					edit.ReplaceWithString(pass.Fset, c.sel.Sel, c.assertion),
+					insert(c.x.Pos(), q+".Equal("),
+					insert(c.y.End(), ")"),
+				)
+			}
+			if i == 0 {
+				edits = append(edits, rw.importEdits(pass)...)
+			}
+			report.Report(pass, c.call, msg, report.Fixes(edit.Fix(fix, edits...)))
+		}
+	})
+	return nil, nil
+}
+
+// deepEqualCall returns the comparison call makes with reflect.DeepEqual,
+// if it makes one.
+func deepEqualCall(pass *analysis.Pass, call *ast.CallExpr) *equalCall {
+	sel, ok := astutil.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return nil
+	}
+	p := pkgPath(fn.Pkg())
+	c := &equalCall{call: call, sel: sel, name: path.Base(p) + "." + fn.Name()}
+	switch {
+	case p == "reflect" && fn.Name() == "DeepEqual":
+		c.x, c.y = call.Args[0], call.Args[1]
+	case testifyPaths[p] && testifyEqual[fn.Name()] != "":
+		// The functions take a testing.T first, which the methods of
+		// Assertions hold.
+		args := call.Args
+		if fn.Type().(*types.Signature).Recv() == nil {
+			args = args[1:]
+		}
+		if len(args) < 2 || call.Ellipsis.IsValid() {
+			return nil
+		}
+		c.x, c.y, c.assertion = args[0], args[1], testifyEqual[fn.Name()]
+	default:
+		return nil
+	}
+	return c
+}
+
+// isMessage reports whether values of type t are messages: pointers to
+// message structs, or values of a message interface.
+func isMessage(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	if types.IsInterface(t) {
+		for _, name := range []string{"ProtoMessage", "ProtoReflect"} {
+			if obj, _, _ := types.LookupFieldOrMethod(t, false, nil, name); obj != nil {
+				return true
+			}
+		}
+		return false
+	}
+	p, ok := t.Underlying().(*types.Pointer)
+	if !ok {
+		return false
+	}
+	_, ok = facts.ClassifyMessage(p.Elem())
+	return ok
+}
+
+// mentionsMessage reports whether values of type t hold messages: if t is
+// a message, or a message struct, or is made of them.
+func mentionsMessage(t types.Type, seen map[types.Type]bool) bool {
+	if t == nil || seen[t] {
+		return false
+	}
+	seen[t] = true
+	if isMessage(t) {
+		return true
+	}
+	if _, ok := facts.ClassifyMessage(t); ok && !types.IsInterface(t) {
+		return true
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return mentionsMessage(u.Elem(), seen)
+	case *types.Slice:
+		return mentionsMessage(u.Elem(), seen)
+	case *types.Array:
+		return mentionsMessage(u.Elem(), seen)
+	case *types.Map:
+		return mentionsMessage(u.Key(), seen) || mentionsMessage(u.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if mentionsMessage(u.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+// An Explanation describes a rule at length, for the developers hitting
+// one of its findings.
+type Explanation struct {
+	// Description tells what the rule finds and why it matters to the
+	// migration.
+	Description string
+
+	// Before shows code with a finding of the rule, and After the code it
+	// migrates to.
+	Before, After string
+
+	// Caveats lists what to watch for when migrating the findings.
+	Caveats []string
+}
+
+// Explain returns the rule with the given ID or name, and its explanation.
+func Explain(idOrName string) (Rule, Explanation, bool) {
+	r, ok := LookupRule(idOrName)
+	if !ok {
+		return Rule{}, Explanation{}, false
+	}
+	return r, explanations[r.ID], true
+}
+
+// explanations maps the IDs of the rules to their explanations.
+var explanations = map[string]Explanation{
+	"PM1001": {
+		Description: "The packages of the v1 API, github.com/golang/protobuf and the packages below it, are deprecated since its v1.4 in favor of those of google.golang.org/protobuf, which the v1 packages are implemented with. The import is reported once its references can be migrated, with the packages replacing it.",
+		Before:      `import "github.com/golang/protobuf/ptypes/duration"`,
+		After:       `import "google.golang.org/protobuf/types/known/durationpb"`,
+		Caveats: []string{
+			"The import is only removed once every reference through it is rewritten; the fixes of a file depend on each other, so apply them together.",
+			"Packages configured with -deprecated or -deprecations are reported too.",
+		},
+	},
+	"PM1002": {
+		Description: "The functions, types and fields deprecated in the v1 API, or in .proto files, are reported where they are used, with their deprecation message.",
+		Before:      `proto.RegisterType((*pb.Foo)(nil), "pkg.Foo")`,
+		After:       `// protoc-gen-go registers pkg.Foo in the generated code.`,
+		Caveats: []string{
+			"The fields and enum values deprecated in a .proto file are not reported in the package generated from it, unless -own-proto-deprecations is set.",
+		},
+	},
+	"PM1003": {
+		Description: "github.com/golang/protobuf up to v1.3 implements the v1 API on a runtime of its own, and from v1.4 on the runtime of google.golang.org/protobuf, so the migration of a module depends on the version its build resolves. On v1.3 or before, it is first upgraded to v1.4 or later, which keeps the v1 API working over the v2 runtime; on v1.4 or later, the code is migrated to the v2 API; once no package imports github.com/golang/protobuf, its requirement is dropped. The finding is reported in the go.mod file of the module.",
+		Before:      "require github.com/golang/protobuf v1.3.5",
+		After:       "require (\n\tgithub.com/golang/protobuf v1.5.2\n\tgoogle.golang.org/protobuf v1.26.0\n)",
+		Caveats: []string{
+			"Upgrading to v1.4 changes the output of the text and JSON marshalers, which the v2 runtime randomizes, and makes the registration conflicts of generated code panic.",
+			"The findings of go.mod are only reported by the protomigrate command, not by go vet -vettool.",
+		},
+	},
+	"PM1004": {
+		Description: "With -strict, any use of the v1 API is reported, for the modules fully migrated to the v2 API to keep it from coming back: the imports of the packages of github.com/golang/protobuf, the uses of protoadapt, and the exported functions, methods and fields whose types mention v1 types. Generated files are checked too.",
+		Before:      "import \"github.com/golang/protobuf/ptypes/timestamp\"\n\nfunc Legacy() proto.Message",
+		After:       "import \"google.golang.org/protobuf/types/known/timestamppb\"\n\nfunc Legacy() proto.Message // of google.golang.org/protobuf/proto",
+		Caveats: []string{
+			"The packages of the well-known types of github.com/golang/protobuf alias those of google.golang.org/protobuf, but are reported too, since importing them keeps the v1 module in the build.",
+			"Set strict: true in the .protomigrate.yaml of a migrated module rather than passing -strict, so that every run of the module enforces it.",
+		},
+	},
+	"PM1005": {
+		Description: "Some versions of the modules generated code depends on conflict with google.golang.org/protobuf. Before v0.0.0-20200526211855-cb27e3aa2013, google.golang.org/genproto registers the .proto files of well-known types that the types/known packages register too, which panics from google.golang.org/protobuf v1.26.0 on. Before v0.0.0-20230410155749-daa745c078e1, it still holds the packages moved to its googleapis/api and googleapis/rpc modules, whose imports are ambiguous if the build has both. And since protoc-gen-go no longer generates gRPC services, the code protoc-gen-go-grpc generates instead needs google.golang.org/grpc v1.32.0 or later. The finding is reported in the go.mod file of the module, at the requirement to upgrade.",
+		Before:      "require (\n\tgoogle.golang.org/genproto v0.0.0-20200423170343-7949de9c1215\n\tgoogle.golang.org/protobuf v1.28.0\n)",
+		After:       "require (\n\tgoogle.golang.org/genproto v0.0.0-20230410155749-daa745c078e1\n\tgoogle.golang.org/protobuf v1.28.0\n)",
+		Caveats: []string{
+			"With -protobuf, the versions are checked against the version of google.golang.org/protobuf the migration is to upgrade to, rather than the one the build resolves.",
+			"The findings of go.mod are only reported by the protomigrate command, not by go vet -vettool.",
+		},
+	},
+	"PM1006": {
+		Description: "A replace directive redirecting github.com/golang/protobuf or google.golang.org/protobuf to another module or to a directory builds the module with a fork, commonly kept to pin the behavior of the versions before v1.4. The findings assume the upstream module, so the fork silently invalidates them: migrate to the upstream module first, or make sure the fork tracks it. The finding is reported in the go.mod file of the module, at the directive.",
+		Before:      "replace github.com/golang/protobuf => github.com/example/protobuf v1.3.5-patched",
+		After:       "require github.com/golang/protobuf v1.5.2",
+		Caveats: []string{
+			"Replacements with other versions of the same module are not reported, since they do not change the code the module builds with.",
+			"The findings of go.mod are only reported by the protomigrate command, not by go vet -vettool.",
+		},
+	},
+	"PM1007": {
+		Description: "Generated code asserts the versions of the runtimes it needs: the gRPC code, with a grpc.SupportPackageIsVersionN constant, which the versions of grpc-go before it do not declare, and the code of protoc-gen-go, with protoimpl.EnforceVersion, which fails to compile with the versions of google.golang.org/protobuf out of its range. Since the errors of the guards hardly tell what is wrong, the versions of grpc-go and google.golang.org/protobuf the build resolves are checked against those the generated files of the module need, including the version of protoc-gen-go that generated them, whose code may use the runtime of the same version. The finding is reported in go.mod, at the requirement to upgrade, and lists the files with what they need.",
+		Before:      "require google.golang.org/grpc v1.27.0\n\n// api_grpc.pb.go\nconst _ = grpc.SupportPackageIsVersion7",
+		After:       "require google.golang.org/grpc v1.32.0",
+		Caveats: []string{
+			"The versions of grpc-go declaring the guards are those of grpc.SupportPackageIsVersion6 to 9; the older guards are declared by all the versions the v2 API builds with.",
+			"The findings of go.mod are only reported by the protomigrate command, not by go vet -vettool.",
+		},
+	},
+	"PM2001": {
+		Description: "Package jsonpb is replaced by protojson, whose MarshalOptions and UnmarshalOptions are configured like jsonpb.Marshaler and jsonpb.Unmarshaler, but marshal to and from byte slices rather than io.Writer and io.Reader: the calls of Marshal and Unmarshal making up a statement are fixed to write the encoded message, or to read the whole reader with io.ReadAll first, ioutil.ReadAll in modules before Go 1.16, once the error of the previous step is nil, and those of MarshalToString to convert the result of Marshal to a string. A type is only replaced if every call of its methods in the package is fixed, and no other field than Indent of its values is used: calls of v1 messages, or in other expressions, keep it, and the calls that could be fixed along with it.",
+		Before:      "m := &jsonpb.Marshaler{}\nerr := m.Marshal(w, msg)",
+		After:       "b, err := protojson.MarshalOptions{}.Marshal(msg)\nif err == nil {\n\t_, err = w.Write(b)\n}",
+		Caveats: []string{
+			"protojson randomizes the whitespace of its output, so code comparing the JSON text byte for byte breaks.",
+			"protojson only encodes messages of the v2 API; convert v1 messages with protoadapt.MessageV2Of first.",
+			"jsonpb.Unmarshaler.Unmarshal decodes the first JSON value of the reader, while the fix reads all of it and protojson rejects what follows the value, so code decoding several messages from one reader has to split them first.",
+		},
+	},
+	"PM2002": {
+		Description: "Some options of jsonpb.Marshaler and jsonpb.Unmarshaler have protojson options of other names or meanings, and some have none, so literals setting them are left for a manual migration. OrigName, EnumsAsInts, EmitDefaults and AllowUnknownFields are renamed UseProtoNames, UseEnumNumbers, EmitUnpopulated and DiscardUnknown by the fix of the literal, and Indent is kept, unless it sets such options too, or an Indent of other characters than spaces and tabs, which protojson rejects.",
+		Before:      "jsonpb.Marshaler{OrigName: true, EnumsAsInts: true}",
+		After:       "protojson.MarshalOptions{UseProtoNames: true, UseEnumNumbers: true}",
+		Caveats: []string{
+			"Unset, OrigName and UseProtoNames both name the fields of the JSON by their lowerCamelCase JSON names, so dropping the option changes the field names.",
+			"AnyResolver becomes Resolver, which takes a protoregistry.MessageTypeResolver and ExtensionTypeResolver rather than a jsonpb.AnyResolver.",
+			"AllowUnknownFields explicitly false is reported, since the errors protojson rejects unknown fields with are worded otherwise than those of jsonpb.",
+			"Indent, whether set in a literal or assigned, indents the JSON of protojson too, but protojson adds random spaces to its output, so golden files of the jsonpb output compared byte for byte have to be normalized, like with json.Indent, or compared as the messages they unmarshal to; prototext output is randomized likewise.",
+		},
+	},
+	"PM2003": {
+		Description: "runtime.JSONPb of grpc-gateway v1 is jsonpb.Marshaler under another name; grpc-gateway v2 replaces it with a runtime.JSONPb holding protojson options, and with no global switch for unknown fields.",
+		Before:      "runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{OrigName: true})",
+		After:       "runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{\n\tMarshalOptions:   protojson.MarshalOptions{UseProtoNames: true},\n\tUnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},\n})",
+		Caveats: []string{
+			"The v1 marshaler ignores unknown fields unless runtime.DisallowUnknownFields is called; the v2 one rejects them unless DiscardUnknown is set.",
+			"The gateway v2 marshaler emits the field names in lowerCamelCase unless UseProtoNames is set, as the v1 one did unless OrigName was.",
+		},
+	},
+	"PM3001": {
+		Description: "The functions of package proto that are not in the v2 proto package moved to other packages, like protowire, protoregistry and prototext, or became methods of the protoreflect API. The calls with a mechanical rewrite are fixed, the others are reported with what replaces them.",
+		Before:      "name := proto.MessageName(m)\ns := proto.MarshalTextString(m)",
+		After:       "name := string(m.ProtoReflect().Descriptor().FullName())\ns := prototext.Format(m)",
+		Caveats: []string{
+			"proto.Buffer, proto.SetDefaults and the registry lookups have no direct replacement; the findings tell what to use instead.",
+			"prototext randomizes the whitespace of its output, like protojson.",
+		},
+	},
+	"PM3002": {
+		Description: "Type assertions between proto.Message of the v1 API and proto.Message of the v2 API fail for the messages only generated for one of them. protoadapt.MessageV1Of and protoadapt.MessageV2Of wrap such messages instead.",
+		Before:      "m2 := m1.(protov2.Message)",
+		After:       "m2 := protoadapt.MessageV2Of(m1)",
+		Caveats: []string{
+			"The comma-ok assertions tell the messages of the other API apart, which the protoadapt functions do not, so they are only reported.",
+		},
+	},
+	"PM4001": {
+		Description: "Package descriptor returned the descriptors of messages as descriptorpb protos, which protodesc converts the protoreflect descriptors of the v2 API to.",
+		Before:      "fd, md := descriptor.ForMessage(m)",
+		After:       "md := protodesc.ToDescriptorProto(m.ProtoReflect().Descriptor())\nfd := protodesc.ToFileDescriptorProto(m.ProtoReflect().Descriptor().ParentFile())",
+		Caveats: []string{
+			"Most uses of the descriptor protos are better served by the protoreflect descriptors themselves, which need no conversion.",
+		},
+	},
+	"PM5001": {
+		Description: "The helpers of package ptypes are replaced by the constructors and methods of the well-known type packages of the v2 API, like durationpb.New and (*timestamppb.Timestamp).AsTime.",
+		Before:      "ts, err := ptypes.TimestampProto(t)",
+		After:       "ts := timestamppb.New(t)",
+		Caveats: []string{
+			"The v2 constructors return no errors; CheckValid reports the values out of range, and the fixes call it where the error was checked.",
+			"ptypes.MarshalAny and UnmarshalAny become anypb.New and (*anypb.Any).UnmarshalTo, which resolve types in the global registry.",
+		},
+	},
+	"PM6001": {
+		Description: "The well-known type packages of the v1 API, like ptypes/duration, alias the types of those of the v2 API, like types/known/durationpb, so imports are replaced and references requalified.",
+		Before:      `import "github.com/golang/protobuf/ptypes/duration"` + "\n\nvar d *duration.Duration",
+		After:       `import "google.golang.org/protobuf/types/known/durationpb"` + "\n\nvar d *durationpb.Duration",
+	},
+	"PM7001": {
+		Description: "Files generated by protoc-gen-gogo use the gogo runtime and its extensions, which protoc-gen-go has no equivalent of, so they cannot be migrated by rewriting and have to be regenerated.",
+		Before:      "// Code generated by protoc-gen-gogo. DO NOT EDIT.",
+		After:       "// Code generated by protoc-gen-go. DO NOT EDIT.",
+		Caveats: []string{
+			"gogoproto options like nullable=false and customtype change the generated Go types, so the code using them changes when regenerating.",
+		},
+	},
+	"PM7002": {
+		Description: "protoc-gen-go of google.golang.org/protobuf no longer generates gRPC code with plugins=grpc, which protoc-gen-go-grpc does, and replaces the gogo plugins, so the protoc invocations of go:generate directives are rewritten for them.",
+		Before:      "//go:generate protoc --go_out=plugins=grpc:. api.proto",
+		After:       "//go:generate protoc --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. api.proto",
+		Caveats: []string{
+			"paths=source_relative writes the files next to their .proto files rather than under their import paths, so the directives writing elsewhere need another output directory.",
+			"protoc-gen-go-grpc has to be installed, and generates services whose servers embed Unimplemented*Server unless require_unimplemented_servers=false is passed.",
+		},
+	},
+	"PM7003": {
+		Description: "protoc-gen-go generated code for the v1 API before v1.4, whose messages do not implement the v2 API, so the files it generated have to be regenerated with protoc-gen-go of google.golang.org/protobuf. The finding lists the files of a directory, and the .proto files they were generated from when found.",
+		Before:      "// Code generated by protoc-gen-go. DO NOT EDIT.\n// source: api.proto",
+		After:       "// Code generated by protoc-gen-go. DO NOT EDIT.\n// versions:\n// \tprotoc-gen-go v1.25.0\n// \tprotoc        v3.14.0\n// source: api.proto",
+		Caveats: []string{
+			"The .proto files are looked for in the directory and its parents up to the module root; those elsewhere on the include path of protoc are not listed.",
+			"Regenerated files declare the full package path of their .proto files with go_package, which protoc-gen-go requires since v1.20.",
+		},
+	},
+	"PM7004": {
+		Description: "buf generate runs the plugins of buf.gen.yaml, so the files generated for the v1 API are regenerated for the v2 API once their plugins are: protoc-gen-go v1.4 or later, without plugins=grpc, which protoc-gen-go-grpc replaces, rather than the plugins of gogo protobuf, and grpc-gateway v2. The finding lists the packages in the directory the plugin writes to.",
+		Before:      "plugins:\n  - plugin: buf.build/protocolbuffers/plugins/go:v1.3.2-1\n    out: gen\n    opt: plugins=grpc",
+		After:       "plugins:\n  - plugin: buf.build/protocolbuffers/go:v1.28.1\n    out: gen\n  - plugin: buf.build/grpc/go:v1.2.0\n    out: gen",
+		Caveats: []string{
+			"The version of a local plugin is that of the protoc-gen-go installed, which buf.gen.yaml does not tell, so only remote plugins are checked for it.",
+		},
+	},
+	"PM7005": {
+		Description: "A file generated by protoc-gen-go that has no code for messages or fields its .proto file declares, or has code for those it no longer declares, was not regenerated since the .proto file changed. Migrating its imports, or the code using it, then breaks for reasons that have nothing to do with the migration, so it is regenerated first.",
+		Before:      "message Point {\n  int32 x = 1;\n  int32 y = 2;\n  int32 z = 3;\n}\n\ntype Point struct {\n\tX int32 `protobuf:\"varint,1,opt,name=x,proto3\"`\n\tY int32 `protobuf:\"varint,2,opt,name=y,proto3\"`\n}",
+		After:       "type Point struct {\n\tX int32 `protobuf:\"varint,1,opt,name=x,proto3\"`\n\tY int32 `protobuf:\"varint,2,opt,name=y,proto3\"`\n\tZ int32 `protobuf:\"varint,3,opt,name=z,proto3\"`\n}",
+		Caveats: []string{
+			"The .proto file is the one the header of the generated file names, looked for in its directory and its parents up to the module root; files whose .proto file is not found are not checked.",
+			"Only the names of the messages and fields are compared, not their types or numbers.",
+		},
+	},
+	"PM7006": {
+		Description: "protoc-gen-go of github.com/golang/protobuf, passed plugins=grpc, generates the client and server stubs of the gRPC services of a .proto file in its .pb.go file, along with the code of its messages. protoc-gen-go of google.golang.org/protobuf no longer does, so regenerating the file for the v2 API drops the services; protoc-gen-go-grpc generates them instead, into a _grpc.pb.go file of their own. The finding lists the services of the file, by their full names.",
+		Before:      "protoc --go_out=plugins=grpc:. api.proto\n\n// api.pb.go\nvar _Greeter_serviceDesc = grpc.ServiceDesc{\n\tServiceName: \"api.Greeter\",",
+		After:       "protoc --go_out=. --go-grpc_out=. api.proto\n\n// api_grpc.pb.go\nvar Greeter_ServiceDesc = grpc.ServiceDesc{\n\tServiceName: \"api.Greeter\",",
+		Caveats: []string{
+			"The server interfaces protoc-gen-go-grpc generates require their implementations to embed UnimplementedGreeterServer, unless it is passed require_unimplemented_servers=false.",
+		},
+	},
+	"PM8001": {
+		Description: "reflect.DeepEqual compares the internal state the v2 runtime keeps in messages, like the cached size, so equal messages compare unequal. proto.Equal compares their content.",
+		Before:      "if reflect.DeepEqual(got, want) {",
+		After:       "if proto.Equal(got, want) {",
+		Caveats: []string{
+			"In tests, cmp.Diff with protocmp.Transform compares messages and prints their differences.",
+		},
+	},
+	"PM8002": {
+		Description: "encoding/json encodes the Go structs of messages rather than following the JSON mapping of protobuf, and the v2 structs hold unexported state, so messages are encoded with protojson.",
+		Before:      "b, err := json.Marshal(m)",
+		After:       "b, err := protojson.Marshal(m)",
+		Caveats: []string{
+			"The JSON of protojson names the fields in lowerCamelCase and encodes 64-bit integers as strings, so its readers may need updating.",
+		},
+	},
+	"PM8003": {
+		Description: "The v2 runtime keeps internal state in messages, including a mutex guard, which must not be copied, so messages are handled by pointer, and copied with proto.Clone or proto.Merge.",
+		Before:      "c := *m",
+		After:       "c := proto.Clone(m).(*pb.Foo)",
+	},
+	"PM8004": {
+		Description: "Comparing messages with == compares the pointers, which is rarely what tests mean; proto.Equal compares the content.",
+		Before:      "if got != want {",
+		After:       "if !proto.Equal(got, want) {",
+		Caveats: []string{
+			"Only test files are checked, where comparing the content is almost always meant.",
+		},
+	},
+	"PM8005": {
+		Description: "The structs generated for the v2 API hold incomparable internal state, so they cannot be map keys or compared with == once regenerated.",
+		Before:      "seen := map[pb.Key]bool{}",
+		After:       "seen := map[string]bool{} // keyed by a field identifying the message",
+	},
+	"PM8006": {
+		Description: "The XXX_ fields and methods of v1 messages are not generated for the v2 API; their functionality is reached through the proto functions and the protoreflect API.",
+		Before:      "b, err := m.XXX_Marshal(nil, false)",
+		After:       "b, err := proto.Marshal(m)",
+	},
+	"PM8007": {
+		Description: "Sub-messages of a message shared with other goroutines, like one received from a channel or looked up in a map, are shared too; mutating them races with the other users. The check is heuristic, so it only runs with -check=aliasing.",
+		Before:      "m := <-ch\nm.Header.Id = id",
+		After:       "m := proto.Clone(<-ch).(*pb.Request)\nm.Header.Id = id",
+		Caveats: []string{
+			"Parameters are only considered shared when their doc comment says so.",
+		},
+	},
+	"PM8008": {
+		Description: "The v2 runtime panics at init when two packages register the same .proto file, message or enum, as a copy generated by protoc-gen-gogo and one generated by protoc-gen-go do. The v1 runtime only logged a warning.",
+		Before:      "import (\n\t_ \"example.com/gogo/durationpb\"\n\t_ \"google.golang.org/protobuf/types/known/durationpb\"\n)",
+		After:       "import _ \"google.golang.org/protobuf/types/known/durationpb\"",
+		Caveats: []string{
+			"GOLANG_PROTOBUF_REGISTRATION_CONFLICT=warn turns the panic back into a warning while the duplicates are removed.",
+		},
+	},
+	"PM8009": {
+		Description: "The Foo_name and Foo_value maps are still generated for enums, but the v2 runtime names their values after the enum descriptor, so changing the maps no longer changes what String returns or what protojson accepts.",
+		Before:      `Color_name[3] = "BLUE"`,
+		After:       "// Add BLUE to the enum in the .proto file.",
+	},
+	"PM8010": {
+		Description: "The v2 runtime ignores the Marshal, Unmarshal and Merge methods of the messages generated for it, which protoc-gen-gogo generated and hand-rolled fast paths added to v1 messages; the generated code has fast paths of its own.",
+		Before:      "func (m *Point) Marshal() ([]byte, error) { ... }",
+		After:       "// Removed: proto.Marshal uses the generated fast path.",
+		Caveats: []string{
+			"Messages not generated for the v2 API still have these methods called, until they are regenerated.",
+		},
+	},
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+// rewriteGetExtension rewrites the calls of proto.GetExtension assigned to
+// a value and an error. The v2 proto.GetExtension returns no error, and
+// the default value of an extension the message does not have rather than
+// ErrMissingExtension, so the check of the error, if it only tells that,
+// becomes one of proto.HasExtension.
+func rewriteGetExtension(c *funcCall) bool {
+	const msg = "proto.GetExtension should be replaced with the v2 proto.GetExtension, which returns no error, but the default value of a missing extension"
+	if !c.extensionArgs(msg) {
+		return false
+	}
+	assign, ok := c.path[1].(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	q := c.rw.qualifier(c.pass, protoV2Path)
+	edits := []analysis.TextEdit{
+		edit.ReplaceWithString(c.pass.Fset, c.sel, q+".GetExtension"),
+		edit.Delete(edit.Range{assign.Lhs[0].End(), assign.Lhs[1].End()}),
+	}
+	if !isBlank(assign.Lhs[1]) {
+		check, ok := c.errCheck(assign)
+		if !ok || !isSimpleExpr(c.call.Args[0]) || !isSimpleExpr(c.call.Args[1]) {
+			report.Report(c.pass, c.call, msg)
+			return false
+		}
+		has := "!" + q + ".HasExtension(" + report.Render(c.pass, c.call.Args[0]) + ", " + report.Render(c.pass, c.call.Args[1]) + ")"
+		edits = append(edits, edit.ReplaceWithString(c.pass.Fset, check.Cond, has))
+	}
+	c.rw.require(c.pass, protoV2Path)
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Use the v2 proto.GetExtension", edits...)))
+	return true
+}
+
+// rewriteSetExtension rewrites the calls of proto.SetExtension whose error
+// is ignored or only checked. The v2 proto.SetExtension returns no error,
+// and panics on values of the wrong type instead, so the checks go away.
+func rewriteSetExtension(c *funcCall) bool {
+	const msg = "proto.SetExtension should be replaced with the v2 proto.SetExtension, which returns no error, but panics on values of the wrong type"
+	if !c.extensionArgs(msg) {
+		return false
+	}
+	q := c.rw.qualifier(c.pass, protoV2Path)
+	edits := []analysis.TextEdit{edit.ReplaceWithString(c.pass.Fset, c.sel, q+".SetExtension")}
+	switch parent := c.path[1].(type) {
+	case *ast.ExprStmt:
+	case *ast.AssignStmt:
+		if len(parent.Lhs) != 1 {
+			report.Report(c.pass, c.call, msg)
+			return false
+		}
+		if ifStmt, ok := c.path[2].(*ast.IfStmt); ok && ifStmt.Init == parent {
+			// if err := proto.SetExtension(m, xt, v); err != nil { ... }
+			if ifStmt.Else != nil || !c.errChecked(parent, ifStmt) {
+				report.Report(c.pass, c.call, msg)
+				return false
+			}
+			edits = append(edits,
+				edit.Delete(edit.Range{ifStmt.Pos(), c.call.Pos()}),
+				edit.Delete(edit.Range{c.call.End(), ifStmt.End()}),
+			)
+			break
+		}
+		edits = append(edits, edit.Delete(edit.Range{parent.Pos(), c.call.Pos()}))
+		if !isBlank(parent.Lhs[0]) {
+			check, ok := c.errCheck(parent)
+			if !ok {
+				report.Report(c.pass, c.call, msg)
+				return false
+			}
+			edits = append(edits, edit.Delete(edit.Range{parent.End(), check.End()}))
+		}
+	default:
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	c.rw.require(c.pass, protoV2Path)
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Use the v2 proto.SetExtension", edits...)))
+	return true
+}
+
+// rewriteExtension rewrites the calls of the functions of the v1 proto
+// package whose v2 counterparts take and return the same, like
+// proto.HasExtension.
+func rewriteExtension(c *funcCall) bool {
+	name := c.sel.Sel.Name
+	msg := "proto." + name + " should be replaced with the v2 proto." + name
+	if !c.extensionArgs(msg) {
+		return false
+	}
+	c.rw.require(c.pass, protoV2Path)
+	repl := c.rw.qualifier(c.pass, protoV2Path) + "." + name
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Use the v2 proto."+name, edit.ReplaceWithString(c.pass.Fset, c.sel, repl))))
+	return true
+}
+
+// extensionArgs reports whether the message and the extension the call
+// takes first can be passed to the v2 functions, reporting msg if not. The
+// *ExtensionDesc of the v1 package is an alias of protoimpl.ExtensionInfo
+// since v1.4, which implements protoreflect.ExtensionType.
+func (c *funcCall) extensionArgs(msg string) bool {
+	if c.call == nil || len(c.call.Args) < 2 || c.call.Ellipsis.IsValid() {
+		report.Report(c.pass, c.sel, msg)
+		return false
+	}
+	if t := c.pass.TypesInfo.TypeOf(c.call.Args[0]); !hasProtoReflect(t) {
+		report.Report(c.pass, c.call, msg+v1MessageNote(c.pass, t))
+		return false
+	}
+	if t := c.pass.TypesInfo.TypeOf(c.call.Args[1]); !isExtensionType(t) {
+		report.Report(c.pass, c.call, msg+"; the extension needs converting to a protoreflect.ExtensionType first")
+		return false
+	}
+	return true
+}
+
+// isExtensionType reports whether values of type t implement
+// protoreflect.ExtensionType.
+func isExtensionType(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, false, nil, "TypeDescriptor")
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// errCheck returns the statement following assign, which defines an error
+// as its last variable, if it is an 'if err != nil' checking it with no
+// else branch and if the error is used nowhere else.
+func (c *funcCall) errCheck(assign *ast.AssignStmt) (*ast.IfStmt, bool) {
+	if assign.Tok != token.DEFINE {
+		return nil, false
+	}
+	list, next, ok := stmtList(c.path[2], assign)
+	if !ok || next == len(list) {
+		return nil, false
+	}
+	ifStmt, ok := list[next].(*ast.IfStmt)
+	if !ok || ifStmt.Init != nil || ifStmt.Else != nil || !c.errChecked(assign, ifStmt) {
+		return nil, false
+	}
+	return ifStmt, true
+}
+
+// errChecked reports whether the condition of ifStmt is 'err != nil' for
+// the error assign defines last, and whether it is the only use of it.
+func (c *funcCall) errChecked(assign *ast.AssignStmt, ifStmt *ast.IfStmt) bool {
+	id, ok := assign.Lhs[len(assign.Lhs)-1].(*ast.Ident)
+	if !ok || assign.Tok != token.DEFINE {
+		return false
+	}
+	obj := c.pass.TypesInfo.Defs[id]
+	if obj == nil {
+		return false
+	}
+	cond, ok := ifStmt.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ {
+		return false
+	}
+	x, ok := cond.X.(*ast.Ident)
+	if !ok || c.pass.TypesInfo.Uses[x] != obj {
+		return false
+	}
+	if tv, ok := c.pass.TypesInfo.Types[cond.Y]; !ok || !tv.IsNil() {
+		return false
+	}
+	for use, o := range c.pass.TypesInfo.Uses {
+		if o == obj && use != x {
+			return false
+		}
+	}
+	return true
+}
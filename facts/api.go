@@ -0,0 +1,165 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package facts
+
+import (
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// V1API records the exported functions, methods and struct fields of a
+// package whose types mention v1 protobuf types, so that the packages
+// using them have to migrate along with it.
+type V1API struct {
+	// Objects holds the names of the functions, and of the methods and
+	// fields qualified by their type, like T.M, in order.
+	Objects []string
+}
+
+func (*V1API) AFact()           {}
+func (a *V1API) String() string { return "V1API: " + strings.Join(a.Objects, ", ") }
+
+var API = &analysis.Analyzer{
+	Name:       "fact_v1api",
+	Doc:        "Record the exported API mentioning v1 protobuf types",
+	Run:        api,
+	Requires:   []*analysis.Analyzer{Messages},
+	FactTypes:  []analysis.Fact{(*V1API)(nil)},
+	ResultType: reflect.TypeOf(map[*types.Package]*V1API{}),
+}
+
+func api(pass *analysis.Pass) (interface{}, error) {
+	msgs := pass.ResultOf[Messages].(MessagesResult)
+	m := &v1Mentions{msgs: msgs, seen: map[types.Type]bool{}}
+
+	var objs []string
+	scope := pass.Pkg.Scope()
+	names := scope.Names()
+	if ProtoFree(pass.Pkg) {
+		// The API of the package can mention no messages.
+		names = nil
+	}
+	for _, name := range names {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Func:
+			if m.mentions(obj.Type()) {
+				objs = append(objs, obj.Name())
+			}
+		case *types.TypeName:
+			if obj.IsAlias() {
+				continue
+			}
+			named, ok := obj.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			if st, ok := named.Underlying().(*types.Struct); ok {
+				for i := 0; i < st.NumFields(); i++ {
+					if f := st.Field(i); f.Exported() && m.mentions(f.Type()) {
+						objs = append(objs, obj.Name()+"."+f.Name())
+					}
+				}
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				if meth := named.Method(i); meth.Exported() && m.mentions(meth.Type()) {
+					objs = append(objs, obj.Name()+"."+meth.Name())
+				}
+			}
+		}
+	}
+	if len(objs) > 0 {
+		sort.Strings(objs)
+		pass.ExportPackageFact(&V1API{objs})
+	}
+
+	out := map[*types.Package]*V1API{}
+	for _, fact := range pass.AllPackageFacts() {
+		out[fact.Package] = fact.Fact.(*V1API)
+	}
+	return out, nil
+}
+
+// v1Mentions finds the mentions of v1 protobuf types in types.
+type v1Mentions struct {
+	msgs MessagesResult
+	seen map[types.Type]bool
+}
+
+// mentions reports whether t mentions a v1 protobuf type. The named types
+// t mentions are not looked into, since their own API is theirs.
+func (m *v1Mentions) mentions(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.Named:
+		return m.isV1(t)
+	case *types.Pointer:
+		return m.mentions(t.Elem())
+	case *types.Slice:
+		return m.mentions(t.Elem())
+	case *types.Array:
+		return m.mentions(t.Elem())
+	case *types.Chan:
+		return m.mentions(t.Elem())
+	case *types.Map:
+		return m.mentions(t.Key()) || m.mentions(t.Elem())
+	case *types.Signature:
+		return m.mentions(t.Params()) || m.mentions(t.Results())
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if m.mentions(t.At(i).Type()) {
+				return true
+			}
+		}
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if m.mentions(t.Field(i).Type()) {
+				return true
+			}
+		}
+	case *types.Interface:
+		// Interfaces may refer to themselves through their methods.
+		if m.seen[t] {
+			return false
+		}
+		m.seen[t] = true
+		for i := 0; i < t.NumMethods(); i++ {
+			if m.mentions(t.Method(i).Type()) {
+				return true
+			}
+		}
+	case interface{ Rhs() types.Type }:
+		// Newer Go releases represent aliases, like proto.Message, as
+		// types of their own; the aliased type is the one mentioned.
+		return m.mentions(t.Rhs())
+	}
+	return false
+}
+
+// isV1 reports whether t is a v1 protobuf type: a type of the v1 packages,
+// the v1 message interface, which they alias, or a message type that only
+// implements the v1 API.
+func (m *v1Mentions) isV1(t *types.Named) bool {
+	obj := t.Obj()
+	if obj.Pkg() == nil {
+		return false
+	}
+	path := CanonicalPath(obj.Pkg().Path())
+	if strings.HasPrefix(path, "github.com/golang/protobuf/") {
+		return true
+	}
+	if path == "google.golang.org/protobuf/runtime/protoiface" && obj.Name() == "MessageV1" {
+		return true
+	}
+	if fact, ok := m.msgs.Types[obj]; ok {
+		return fact.Kind == V1Only
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package facts
+
+import (
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"gopkg.in/yaml.v2"
+)
+
+// A DeprecationDatabase lists deprecated packages and symbols that are not
+// documented as such, like the wrappers of a company around protobuf. It is
+// read from a YAML or JSON file:
+//
+//	deprecated:
+//	- package: example.com/pbutil
+//	  message: Use google.golang.org/protobuf/proto instead.
+//	- package: example.com/pbutil/codec
+//	  symbol: Codec.Encode
+//	  replacement: proto.Marshal
+//
+// An entry without a symbol deprecates the package; methods and fields are
+// qualified by their type.
+type DeprecationDatabase struct {
+	Deprecated []Deprecation `yaml:"deprecated"`
+}
+
+// A Deprecation is an entry of a DeprecationDatabase.
+type Deprecation struct {
+	Package     string `yaml:"package"`
+	Symbol      string `yaml:"symbol"`
+	Message     string `yaml:"message"`
+	Replacement string `yaml:"replacement"`
+}
+
+// msg returns the deprecation message of d.
+func (d Deprecation) msg() string {
+	msg := d.Message
+	if d.Replacement != "" {
+		if msg != "" {
+			msg += " "
+		}
+		msg += "Use " + d.Replacement + " instead."
+	}
+	return msg
+}
+
+// deprecations is the database of the -deprecations flag of Deprecated.
+var deprecations = &databaseFlag{}
+
+func init() {
+	Deprecated.Flags.Var(deprecations, "deprecations", "read more deprecated packages and symbols from the named YAML or JSON `file`")
+}
+
+// databaseFlag is a flag.Value holding the database in the named file.
+type databaseFlag struct {
+	name string
+	db   DeprecationDatabase
+}
+
+func (f *databaseFlag) String() string { return f.name }
+
+// Get returns the database, whose content the name does not tell.
+func (f *databaseFlag) Get() interface{} { return f.db }
+
+func (f *databaseFlag) Set(name string) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	var db DeprecationDatabase
+	if err := yaml.UnmarshalStrict(data, &db); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	for _, d := range db.Deprecated {
+		if d.Package == "" {
+			return fmt.Errorf("%s: deprecation without a package", name)
+		}
+	}
+	f.name, f.db = name, db
+	return nil
+}
+
+// exportDatabase exports the facts of the deprecations of the database that
+// are in the package, unless it documents them already.
+func exportDatabase(pass *analysis.Pass) error {
+	path := CanonicalPath(pass.Pkg.Path())
+	for _, d := range deprecations.db.Deprecated {
+		if d.Package != path {
+			continue
+		}
+		if d.Symbol == "" {
+			if !pass.ImportPackageFact(pass.Pkg, new(IsDeprecated)) {
+				pass.ExportPackageFact(&IsDeprecated{Msg: d.msg()})
+			}
+			continue
+		}
+		obj := lookupSymbol(pass.Pkg, d.Symbol)
+		if obj == nil {
+			return fmt.Errorf("%s: deprecated symbol %s not found", deprecations.name, d.Package+"."+d.Symbol)
+		}
+		if !pass.ImportObjectFact(obj, new(IsDeprecated)) {
+			pass.ExportObjectFact(obj, &IsDeprecated{Msg: d.msg()})
+		}
+	}
+	return nil
+}
+
+// lookupSymbol returns the object of pkg named by symbol, a package-level
+// name or a method or field qualified by its type.
+func lookupSymbol(pkg *types.Package, symbol string) types.Object {
+	name, member := symbol, ""
+	if i := strings.Index(symbol, "."); i >= 0 {
+		name, member = symbol[:i], symbol[i+1:]
+	}
+	obj := pkg.Scope().Lookup(name)
+	if obj == nil || member == "" {
+		return obj
+	}
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	obj, _, _ = types.LookupFieldOrMethod(types.NewPointer(tn.Type()), false, pkg, member)
+	if obj == nil || obj.Pkg() != pkg {
+		// Members promoted from the types of other packages are theirs.
+		return nil
+	}
+	return obj
+}
@@ -13,7 +13,14 @@ import (
 	"golang.org/x/tools/go/analysis"
 )
 
-type IsDeprecated struct{ Msg string }
+type IsDeprecated struct {
+	Msg string
+
+	// Proto reports whether the deprecation is that of a proto field,
+	// enum value or message, as the code protoc-gen-go generates states
+	// it.
+	Proto bool
+}
 
 func (*IsDeprecated) AFact()           {}
 func (d *IsDeprecated) String() string { return "Deprecated: " + d.Msg }
@@ -24,7 +31,7 @@ type DeprecatedResult struct {
 }
 
 var Deprecated = &analysis.Analyzer{
-	Name:       "fact.deprecated",
+	Name:       "fact_deprecated",
 	Doc:        "Mark deprecated objects",
 	Run:        deprecated,
 	FactTypes:  []analysis.Fact{(*IsDeprecated)(nil)},
@@ -51,6 +58,10 @@ func deprecated(pass *analysis.Pass) (interface{}, error) {
 		}
 		return ""
 	}
+	// The deprecations of a .proto file are carried over to the code
+	// generated from it, where the fields and enum values have them as
+	// line comments.
+	proto := false
 	doDocs := func(names []*ast.Ident, docs []*ast.CommentGroup) {
 		alt := extractDeprecatedMessage(docs)
 		if alt == "" {
@@ -59,7 +70,7 @@ func deprecated(pass *analysis.Pass) (interface{}, error) {
 
 		for _, name := range names {
 			obj := pass.TypesInfo.ObjectOf(name)
-			pass.ExportObjectFact(obj, &IsDeprecated{alt})
+			pass.ExportObjectFact(obj, &IsDeprecated{Msg: alt, Proto: proto})
 		}
 	}
 
@@ -68,11 +79,18 @@ func deprecated(pass *analysis.Pass) (interface{}, error) {
 		docs = append(docs, f.Doc)
 	}
 	if alt := extractDeprecatedMessage(docs); alt != "" {
-		pass.ExportPackageFact(&IsDeprecated{alt})
+		pass.ExportPackageFact(&IsDeprecated{Msg: alt})
 	}
 
 	docs = docs[:0]
 	for _, f := range pass.Files {
+		gen, _ := isGenerated(pass.Fset.PositionFor(f.Pos(), false).Filename)
+		switch gen.Generator {
+		case ProtocGenGo, ProtocGenGoV2, ProtocGenGogo:
+			proto = true
+		default:
+			proto = false
+		}
 		fn := func(node ast.Node) bool {
 			if node == nil {
 				return true
@@ -97,13 +115,20 @@ func deprecated(pass *analysis.Pass) (interface{}, error) {
 				ret = true
 			case *ast.ValueSpec:
 				docs = append(docs, node.Doc)
+				if proto {
+					docs = append(docs, node.Comment)
+				}
 				names = node.Names
 				ret = false
 			case *ast.File:
 				return true
 			case *ast.StructType:
 				for _, field := range node.Fields.List {
-					doDocs(field.Names, []*ast.CommentGroup{field.Doc})
+					fieldDocs := []*ast.CommentGroup{field.Doc}
+					if proto {
+						fieldDocs = append(fieldDocs, field.Comment)
+					}
+					doDocs(field.Names, fieldDocs)
 				}
 				return false
 			case *ast.InterfaceType:
@@ -126,6 +151,10 @@ func deprecated(pass *analysis.Pass) (interface{}, error) {
 		ast.Inspect(f, fn)
 	}
 
+	if err := exportDatabase(pass); err != nil {
+		return nil, err
+	}
+
 	out := DeprecatedResult{
 		Objects:  map[types.Object]*IsDeprecated{},
 		Packages: map[*types.Package]*IsDeprecated{},
@@ -22,9 +22,69 @@ const (
 	Goyacc
 	Cgo
 	Stringer
+	// ProtocGenGo is protoc-gen-go generating code for the v1 API, before
+	// it was reimplemented as part of google.golang.org/protobuf.
 	ProtocGenGo
+	// ProtocGenGoV2 is protoc-gen-go generating code for the v2 API.
+	ProtocGenGoV2
+	// ProtocGenGogo is protoc-gen-gogo, or one of its variants like
+	// protoc-gen-gofast.
+	ProtocGenGogo
+	ProtocGenGRPCGateway
+	Mockgen
 )
 
+// generatorNames holds the names of the generators, by Generator.
+var generatorNames = [...]string{
+	Unknown:              "unknown",
+	Goyacc:               "goyacc",
+	Cgo:                  "cgo",
+	Stringer:             "stringer",
+	ProtocGenGo:          "protoc-gen-go",
+	ProtocGenGoV2:        "protoc-gen-go",
+	ProtocGenGogo:        "protoc-gen-gogo",
+	ProtocGenGRPCGateway: "protoc-gen-grpc-gateway",
+	Mockgen:              "mockgen",
+}
+
+// String returns the name of g, like protoc-gen-go, that of both
+// ProtocGenGo and ProtocGenGoV2.
+func (g Generator) String() string {
+	if g < 0 || int(g) >= len(generatorNames) {
+		return "unknown"
+	}
+	return generatorNames[g]
+}
+
+// GeneratedFile describes a generated file.
+type GeneratedFile struct {
+	Generator Generator
+
+	// Version is the version of the generator, as the header of the file
+	// gives it, like v1.25.0, or "" if it does not.
+	Version string
+
+	// ProtocVersion is the version of protoc that ran protoc-gen-go, as
+	// the header of the file gives it, like v3.14.0, or "" if it does not.
+	ProtocVersion string
+
+	// Source is the path of the .proto file protoc-gen-go generated the
+	// file from, relative to a directory of the include path of protoc, as
+	// the header of the file gives it, or "" if it does not.
+	Source string
+}
+
+// gogoGenerators lists the names of the variants of protoc-gen-gogo.
+var gogoGenerators = map[string]bool{
+	"protoc-gen-gogo":       true,
+	"protoc-gen-gofast":     true,
+	"protoc-gen-gogofast":   true,
+	"protoc-gen-gogofaster": true,
+	"protoc-gen-gogoslick":  true,
+	"protoc-gen-gogotypes":  true,
+	"protoc-gen-gostring":   true,
+}
+
 var (
 	// used by cgo before Go 1.11
 	oldCgo = []byte("// Created by cgo - DO NOT EDIT")
@@ -32,60 +92,123 @@ var (
 	suffix = []byte(" DO NOT EDIT.")
 	nl     = []byte("\n")
 	crnl   = []byte("\r\n")
+
+	// The protoc-gen-go of the v2 API lists its version in the header,
+	// and the code it generates uses the protoimpl runtime.
+	protocGenGoVersion = "protoc-gen-go "
+	protocVersion      = "protoc "
+	protoSource        = "source: "
+	protoimpl          = []byte(`"google.golang.org/protobuf/runtime/protoimpl"`)
 )
 
-func isGenerated(path string) (Generator, bool) {
+// maxHeaderSize is the number of bytes of a file isGenerated reads at most.
+// The comment marking generated code precedes the package clause, and the
+// header and imports of the files of protoc-gen-go come right after it, so
+// reading no further bounds the cost of the large files not generated, as
+// gopls analyzes them on every edit.
+const maxHeaderSize = 64 << 10
+
+// ReadGenerated reads the header of the file of path, and returns how it was
+// generated, if it was, as the result of Generated does.
+func ReadGenerated(path string) (GeneratedFile, bool) {
+	return isGenerated(path)
+}
+
+func isGenerated(path string) (GeneratedFile, bool) {
 	f, err := os.Open(path)
 	if err != nil {
-		return 0, false
+		return GeneratedFile{}, false
 	}
 	defer f.Close()
-	br := bufio.NewReader(f)
+	br := bufio.NewReader(io.LimitReader(f, maxHeaderSize))
+	var gen GeneratedFile
+	found := false
+	header := false
 	for {
 		s, err := br.ReadBytes('\n')
 		if err != nil && err != io.EOF {
-			return 0, false
+			return GeneratedFile{}, false
 		}
 		s = bytes.TrimSuffix(s, crnl)
 		s = bytes.TrimSuffix(s, nl)
-		if bytes.HasPrefix(s, prefix) && bytes.HasSuffix(s, suffix) {
-			if len(s)-len(suffix) < len(prefix) {
-				return Unknown, true
+		switch {
+		case found:
+			// The rest of the file tells the protoc-gen-go outputs apart.
+			if header && bytes.HasPrefix(s, []byte("//")) {
+				line := strings.TrimLeft(string(s[len("//"):]), " \t")
+				if strings.HasPrefix(line, protocGenGoVersion) {
+					gen.Version = strings.TrimSpace(line[len(protocGenGoVersion):])
+				}
+				if strings.HasPrefix(line, protoSource) {
+					gen.Source = strings.TrimSpace(line[len(protoSource):])
+				}
+				if strings.HasPrefix(line, protocVersion) {
+					// protoc may not tell its version, as in "(unknown)".
+					if v := strings.TrimSpace(line[len(protocVersion):]); strings.HasPrefix(v, "v") {
+						gen.ProtocVersion = v
+					}
+				}
+			} else {
+				header = false
 			}
-
-			text := string(s[len(prefix) : len(s)-len(suffix)])
-			switch text {
-			case "by goyacc.":
-				return Goyacc, true
-			case "by cmd/cgo;":
-				return Cgo, true
-			case "by protoc-gen-go.":
-				return ProtocGenGo, true
+			if gen.Generator == ProtocGenGo && (gen.Version != "" || bytes.Contains(s, protoimpl)) {
+				gen.Generator = ProtocGenGoV2
 			}
-			if strings.HasPrefix(text, `by "stringer `) {
-				return Stringer, true
+			if gen.Generator == ProtocGenGoV2 && !header {
+				return gen, true
 			}
-			if strings.HasPrefix(text, `by goyacc `) {
-				return Goyacc, true
+		case bytes.HasPrefix(s, prefix) && bytes.HasSuffix(s, suffix):
+			found = true
+			if len(s)-len(suffix) < len(prefix) {
+				return GeneratedFile{Generator: Unknown}, true
 			}
-
-			return Unknown, true
-		}
-		if bytes.Equal(s, oldCgo) {
-			return Cgo, true
+			gen.Generator = generator(string(s[len(prefix) : len(s)-len(suffix)]))
+			if gen.Generator != ProtocGenGo {
+				return gen, true
+			}
+			header = true
+		case bytes.Equal(s, oldCgo):
+			return GeneratedFile{Generator: Cgo}, true
 		}
 		if err == io.EOF {
 			break
 		}
 	}
-	return 0, false
+	return gen, found
+}
+
+// generator returns the generator that text, the part of a "Code generated"
+// comment between its prefix and suffix, names.
+func generator(text string) Generator {
+	switch text {
+	case "by goyacc.":
+		return Goyacc
+	case "by cmd/cgo;":
+		return Cgo
+	case "by protoc-gen-go.":
+		return ProtocGenGo
+	case "by protoc-gen-grpc-gateway.":
+		return ProtocGenGRPCGateway
+	case "by MockGen.":
+		return Mockgen
+	}
+	if strings.HasPrefix(text, `by "stringer `) {
+		return Stringer
+	}
+	if strings.HasPrefix(text, `by goyacc `) {
+		return Goyacc
+	}
+	if name := strings.TrimSuffix(strings.TrimPrefix(text, "by "), "."); gogoGenerators[name] {
+		return ProtocGenGogo
+	}
+	return Unknown
 }
 
 var Generated = &analysis.Analyzer{
 	Name: "isgenerated",
 	Doc:  "annotate file names that have been code generated",
 	Run: func(pass *analysis.Pass) (interface{}, error) {
-		m := map[string]Generator{}
+		m := map[string]GeneratedFile{}
 		for _, f := range pass.Files {
 			path := pass.Fset.PositionFor(f.Pos(), false).Filename
 			g, ok := isGenerated(path)
@@ -96,5 +219,5 @@ var Generated = &analysis.Analyzer{
 		return m, nil
 	},
 	RunDespiteErrors: true,
-	ResultType:       reflect.TypeOf(map[string]Generator{}),
+	ResultType:       reflect.TypeOf(map[string]GeneratedFile{}),
 }
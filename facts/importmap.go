@@ -0,0 +1,90 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package facts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A MappedPath is the package an import path stands for.
+type MappedPath struct {
+	// Path is the import path of the package.
+	Path string
+
+	// Note tells how the package of the import path differs from it, as
+	// the findings about its imports say.
+	Note string
+}
+
+// importMap maps the import paths of the forks, mirrors and re-exports of
+// the packages of the protobuf runtimes, like those of
+// github.com/golang/protobuf, to the packages they stand for, which the
+// analyzers detect and rewrite their imports as. It is the value of the
+// -import-map flag of API.
+var importMap = importMapFlag{}
+
+func init() {
+	API.Flags.Var(importMap, "import-map", "detect and rewrite the imports of a package as those of another, like a fork of a package of github.com/golang/protobuf, in the format 'path=path' or 'path=path: note'; may be repeated")
+}
+
+// MapImportPath records that the package of import path from stands for
+// that of path to, as -import-map does, with note telling how they differ.
+// It has to be called before the analyzers run.
+func MapImportPath(from, to, note string) error {
+	if from == "" || to == "" || from == to {
+		return fmt.Errorf("invalid import mapping %q to %q", from, to)
+	}
+	if _, ok := importMap[to]; ok {
+		return fmt.Errorf("import path %s is mapped to %s, which is mapped too", from, to)
+	}
+	importMap[from] = MappedPath{to, note}
+	return nil
+}
+
+// LookupImportPath returns the package the import path path stands for, if
+// it is mapped to another.
+func LookupImportPath(path string) (MappedPath, bool) {
+	m, ok := importMap[path]
+	return m, ok
+}
+
+// CanonicalPath returns the import path of the package path stands for,
+// out of any vendor directory: the one it is mapped to, if any, or itself.
+func CanonicalPath(path string) string {
+	path = trimVendor(path)
+	if m, ok := importMap[path]; ok {
+		return m.Path
+	}
+	return path
+}
+
+// importMapFlag is a flag.Value holding the import paths mapped to others.
+type importMapFlag map[string]MappedPath
+
+func (f importMapFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for from, m := range f {
+		pair := from + "=" + m.Path
+		if m.Note != "" {
+			pair += ": " + m.Note
+		}
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "; ")
+}
+
+func (f importMapFlag) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i < 0 {
+		return fmt.Errorf("invalid import mapping, want 'path=path' or 'path=path: note': %q", v)
+	}
+	to, note := v[i+1:], ""
+	if j := strings.Index(to, ":"); j >= 0 {
+		to, note = to[:j], strings.TrimSpace(to[j+1:])
+	}
+	return MapImportPath(strings.TrimSpace(v[:i]), strings.TrimSpace(to), note)
+}
@@ -0,0 +1,23 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package facts
+
+import "testing"
+
+func TestImportMap(t *testing.T) {
+	if err := API.Flags.Set("import-map", "example.com/fork/proto=github.com/golang/protobuf/proto: adds MarshalCanonical"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { delete(importMap, "example.com/fork/proto") })
+	want := MappedPath{Path: "github.com/golang/protobuf/proto", Note: "adds MarshalCanonical"}
+	if m, ok := LookupImportPath("example.com/fork/proto"); !ok || m != want {
+		t.Errorf("LookupImportPath(example.com/fork/proto) = %v, %v, want %v, true", m, ok, want)
+	}
+	if got := CanonicalPath("example.com/app/vendor/example.com/fork/proto"); got != want.Path {
+		t.Errorf("CanonicalPath of the vendored fork = %s, want %s", got, want.Path)
+	}
+	if err := API.Flags.Set("import-map", "example.com/other=example.com/fork/proto"); err == nil {
+		t.Error("mapping a path to a mapped one succeeded, want an error")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package facts
+
+import (
+	"go/types"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// MessageKind classifies message types by the protobuf APIs they
+// implement.
+type MessageKind int
+
+const (
+	// V1Only messages only implement the v1 proto.Message interface,
+	// like the output of protoc-gen-go before v1.4.
+	V1Only MessageKind = iota
+	// V2Native messages only implement the v2 API.
+	V2Native
+	// Hybrid messages implement both APIs, like the output of
+	// protoc-gen-go since v1.4.
+	Hybrid
+)
+
+func (k MessageKind) String() string {
+	switch k {
+	case V1Only:
+		return "v1-only"
+	case V2Native:
+		return "v2-native"
+	case Hybrid:
+		return "hybrid"
+	}
+	return "unknown"
+}
+
+type IsMessage struct{ Kind MessageKind }
+
+func (*IsMessage) AFact()           {}
+func (m *IsMessage) String() string { return "Message: " + m.Kind.String() }
+
+// MessagesResult maps the message types of the package and of its
+// dependencies to their facts.
+type MessagesResult struct {
+	Types map[*types.TypeName]*IsMessage
+}
+
+var Messages = &analysis.Analyzer{
+	Name:       "fact_messages",
+	Doc:        "Classify protobuf message types by the APIs they implement",
+	Run:        messages,
+	FactTypes:  []analysis.Fact{(*IsMessage)(nil)},
+	ResultType: reflect.TypeOf(MessagesResult{}),
+}
+
+const protoreflectPath = "google.golang.org/protobuf/reflect/protoreflect"
+
+func messages(pass *analysis.Pass) (interface{}, error) {
+	scope := pass.Pkg.Scope()
+	names := scope.Names()
+	if ProtoFree(pass.Pkg) {
+		// The package can declare no messages.
+		names = nil
+	}
+	for _, name := range names {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		if kind, ok := ClassifyMessage(tn.Type()); ok {
+			pass.ExportObjectFact(tn, &IsMessage{kind})
+		}
+	}
+
+	out := MessagesResult{Types: map[*types.TypeName]*IsMessage{}}
+	for _, fact := range pass.AllObjectFacts() {
+		if tn, ok := fact.Object.(*types.TypeName); ok {
+			out.Types[tn] = fact.Fact.(*IsMessage)
+		}
+	}
+	return out, nil
+}
+
+// ClassifyMessage returns the kind of the message type t, whose pointers
+// implement the message APIs, reporting false if t is not a message type.
+// Interfaces are not message types.
+func ClassifyMessage(t types.Type) (MessageKind, bool) {
+	if _, ok := t.Underlying().(*types.Interface); ok {
+		return 0, false
+	}
+	mset := types.NewMethodSet(types.NewPointer(t))
+	v1 := hasMethod(mset, "Reset", 0, 0) && hasMethod(mset, "String", 0, 1) && hasMethod(mset, "ProtoMessage", 0, 0)
+	v2 := false
+	if sel := mset.Lookup(nil, "ProtoReflect"); sel != nil {
+		sig := sel.Type().(*types.Signature)
+		if sig.Params().Len() == 0 && sig.Results().Len() == 1 {
+			named, ok := sig.Results().At(0).Type().(*types.Named)
+			v2 = ok && named.Obj().Name() == "Message" && named.Obj().Pkg() != nil &&
+				CanonicalPath(named.Obj().Pkg().Path()) == protoreflectPath
+		}
+	}
+	switch {
+	case v1 && v2:
+		return Hybrid, true
+	case v1:
+		return V1Only, true
+	case v2:
+		return V2Native, true
+	}
+	return 0, false
+}
+
+// hasMethod reports whether mset has an exported method of the given name
+// with the given numbers of parameters and results.
+func hasMethod(mset *types.MethodSet, name string, params, results int) bool {
+	sel := mset.Lookup(nil, name)
+	if sel == nil {
+		return false
+	}
+	sig := sel.Type().(*types.Signature)
+	return sig.Params().Len() == params && sig.Results().Len() == results
+}
+
+// trimVendor returns path without the vendor directory it may be in.
+func trimVendor(path string) string {
+	if i := strings.LastIndex(path, "/vendor/"); i >= 0 {
+		return path[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(path, "vendor/")
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package facts
+
+import (
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// ProtoFiles records the files of a package that protoc-gen-go generated,
+// so that the packages using their messages can tell how old they are.
+type ProtoFiles struct {
+	// Files holds the files, in order of name.
+	Files []ProtoFile
+}
+
+// A ProtoFile is a file generated by protoc-gen-go.
+type ProtoFile struct {
+	// Name is the base name of the file.
+	Name string
+
+	// V2 reports whether the file is generated for the v2 API, by
+	// protoc-gen-go since v1.4.
+	V2 bool
+
+	// PluginVersion and ProtocVersion are the versions of protoc-gen-go
+	// and protoc the header of the file lists, like v1.25.0 and v3.14.0,
+	// or "" if it does not. protoc-gen-go lists none before v1.4.
+	PluginVersion string
+	ProtocVersion string
+
+	// Source is the path of the .proto file the file was generated from,
+	// relative to a directory of the include path of protoc, or "" if the
+	// header of the file does not give it.
+	Source string
+}
+
+// Generator describes the protoc-gen-go and protoc that generated f, like
+// "protoc-gen-go v1.25.0 and protoc v3.14.0".
+func (f ProtoFile) Generator() string {
+	var s string
+	switch {
+	case f.PluginVersion != "":
+		s = "protoc-gen-go " + f.PluginVersion
+	case f.V2:
+		s = "protoc-gen-go"
+	default:
+		s = "protoc-gen-go before v1.4"
+	}
+	if f.ProtocVersion != "" {
+		s += " and protoc " + f.ProtocVersion
+	}
+	return s
+}
+
+func (*ProtoFiles) AFact() {}
+func (p *ProtoFiles) String() string {
+	files := make([]string, len(p.Files))
+	for i, f := range p.Files {
+		files[i] = f.Name + " (" + f.Generator() + ")"
+	}
+	return "ProtoFiles: " + strings.Join(files, ", ")
+}
+
+// File returns the file of the given base name.
+func (p *ProtoFiles) File(name string) (ProtoFile, bool) {
+	i := sort.Search(len(p.Files), func(i int) bool { return p.Files[i].Name >= name })
+	if i < len(p.Files) && p.Files[i].Name == name {
+		return p.Files[i], true
+	}
+	return ProtoFile{}, false
+}
+
+var Protoc = &analysis.Analyzer{
+	Name:       "fact_protoc",
+	Doc:        "Record the versions of protoc-gen-go and protoc that generated the files of packages",
+	Run:        protoc,
+	Requires:   []*analysis.Analyzer{Generated},
+	FactTypes:  []analysis.Fact{(*ProtoFiles)(nil)},
+	ResultType: reflect.TypeOf(map[*types.Package]*ProtoFiles{}),
+}
+
+func protoc(pass *analysis.Pass) (interface{}, error) {
+	gen := pass.ResultOf[Generated].(map[string]GeneratedFile)
+	var files []ProtoFile
+	for path, g := range gen {
+		if g.Generator != ProtocGenGo && g.Generator != ProtocGenGoV2 {
+			continue
+		}
+		files = append(files, ProtoFile{
+			Name:          filepath.Base(path),
+			V2:            g.Generator == ProtocGenGoV2,
+			PluginVersion: g.Version,
+			ProtocVersion: g.ProtocVersion,
+			Source:        g.Source,
+		})
+	}
+	if len(files) > 0 {
+		sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+		pass.ExportPackageFact(&ProtoFiles{files})
+	}
+
+	out := map[*types.Package]*ProtoFiles{}
+	for _, fact := range pass.AllPackageFacts() {
+		out[fact.Package] = fact.Fact.(*ProtoFiles)
+	}
+	return out, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package facts
+
+import (
+	"go/types"
+	"strings"
+)
+
+// protoModules are the paths of the modules of the protobuf runtimes, which
+// the code generated for messages imports.
+var protoModules = []string{
+	"github.com/golang/protobuf",
+	"github.com/gogo/protobuf",
+	"google.golang.org/protobuf",
+}
+
+// ProtoFree reports whether pkg imports no package of the protobuf
+// runtimes, directly or not. Such a package declares no messages and
+// mentions none in its API, so the analyzers skip it; most packages of a
+// large repository are.
+func ProtoFree(pkg *types.Package) bool {
+	return !Imports(pkg, IsProtoPackage)
+}
+
+// Imports reports whether pkg is, or imports directly or not, a package
+// whose path, out of any vendor directory, matches.
+func Imports(pkg *types.Package, match func(path string) bool) bool {
+	seen := map[*types.Package]bool{}
+	var imports func(pkg *types.Package) bool
+	imports = func(pkg *types.Package) bool {
+		if seen[pkg] {
+			return false
+		}
+		seen[pkg] = true
+		if match(CanonicalPath(pkg.Path())) {
+			return true
+		}
+		for _, imp := range pkg.Imports() {
+			if imports(imp) {
+				return true
+			}
+		}
+		return false
+	}
+	return imports(pkg)
+}
+
+// IsProtoPackage reports whether the package at path is one of those of the
+// protobuf runtimes.
+func IsProtoPackage(path string) bool {
+	for _, m := range protoModules {
+		if path == m || strings.HasPrefix(path, m+"/") {
+			return true
+		}
+	}
+	return false
+}
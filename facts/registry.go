@@ -0,0 +1,293 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package facts
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// Registrations records the .proto files and the messages and enums a
+// package registers in the global registry, which the v1 proto package
+// shares with the v2 runtime since v1.4. Two packages registering the same
+// name conflict, as when both a v1 and a v2 copy of a package are linked
+// in after a partial migration.
+type Registrations struct {
+	// Files holds the paths of the .proto files, and Names the full names
+	// of the messages and enums, in order.
+	Files []string
+	Names []string
+}
+
+func (*Registrations) AFact() {}
+func (r *Registrations) String() string {
+	return "Registrations: " + strings.Join(append(append([]string(nil), r.Files...), r.Names...), ", ")
+}
+
+var Registry = &analysis.Analyzer{
+	Name:       "fact_registry",
+	Doc:        "Record the protobuf types packages register in the global registry",
+	Run:        registry,
+	FactTypes:  []analysis.Fact{(*Registrations)(nil)},
+	ResultType: reflect.TypeOf(map[*types.Package]*Registrations{}),
+}
+
+// protoV1Path is the path of the v1 proto package, whose Register functions
+// register the code of protoc-gen-go before v1.4.
+const protoV1Path = "github.com/golang/protobuf/proto"
+
+func registry(pass *analysis.Pass) (interface{}, error) {
+	files := map[string]bool{}
+	names := map[string]bool{}
+	pkgFiles := pass.Files
+	if ProtoFree(pass.Pkg) {
+		// The package can register nothing.
+		pkgFiles = nil
+	}
+	for _, f := range pkgFiles {
+		ast.Inspect(f, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.CallExpr:
+				registerCall(pass, node, files, names)
+			case *ast.ValueSpec:
+				// The code of protoc-gen-go since v1.4 registers the
+				// descriptors of its raw descriptor variables.
+				for i, name := range node.Names {
+					if !strings.HasPrefix(name.Name, "file_") || !strings.HasSuffix(name.Name, "_rawDesc") || i >= len(node.Values) {
+						continue
+					}
+					raw, ok := constantBytes(pass, node.Values[i])
+					if !ok {
+						continue
+					}
+					if file, decls, ok := descriptorNames(raw); ok {
+						files[file] = true
+						for _, name := range decls {
+							names[name] = true
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	if len(files) > 0 || len(names) > 0 {
+		pass.ExportPackageFact(&Registrations{Files: sortedKeys(files), Names: sortedKeys(names)})
+	}
+
+	out := map[*types.Package]*Registrations{}
+	for _, fact := range pass.AllPackageFacts() {
+		out[fact.Package] = fact.Fact.(*Registrations)
+	}
+	return out, nil
+}
+
+// registerCall records what call registers if it calls a Register function
+// of the v1 proto package with a constant name.
+func registerCall(pass *analysis.Pass, call *ast.CallExpr, files, names map[string]bool) {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || CanonicalPath(fn.Pkg().Path()) != protoV1Path {
+		return
+	}
+	arg := -1
+	set := names
+	switch fn.Name() {
+	case "RegisterType", "RegisterMapType":
+		arg = 1
+	case "RegisterEnum":
+		arg = 0
+	case "RegisterFile":
+		arg, set = 0, files
+	}
+	if arg < 0 || arg >= len(call.Args) {
+		return
+	}
+	if tv, ok := pass.TypesInfo.Types[call.Args[arg]]; ok && tv.Value != nil && tv.Value.Kind() == constant.String {
+		set[constant.StringVal(tv.Value)] = true
+	}
+}
+
+// constantBytes returns the value of expr, if it is a constant string or a
+// literal of a byte slice of constants.
+func constantBytes(pass *analysis.Pass, expr ast.Expr) ([]byte, bool) {
+	if tv, ok := pass.TypesInfo.Types[expr]; ok && tv.Value != nil {
+		if tv.Value.Kind() != constant.String {
+			return nil, false
+		}
+		return []byte(constant.StringVal(tv.Value)), true
+	}
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, false
+	}
+	b := make([]byte, 0, len(lit.Elts))
+	for _, elt := range lit.Elts {
+		tv, ok := pass.TypesInfo.Types[elt]
+		if !ok || tv.Value == nil || tv.Value.Kind() != constant.Int {
+			return nil, false
+		}
+		v, ok := constant.Int64Val(tv.Value)
+		if !ok || v < 0 || v > 0xff {
+			return nil, false
+		}
+		b = append(b, byte(v))
+	}
+	return b, true
+}
+
+// descriptorNames returns the path of the file raw, a serialized
+// google.protobuf.FileDescriptorProto, describes, and the full names of
+// the messages and enums it declares, nested ones included.
+func descriptorNames(raw []byte) (file string, names []string, ok bool) {
+	var pkg string
+	var msgs, enums [][]byte
+	ok = wireFields(raw, func(num int, v []byte) {
+		switch num {
+		case 1: // name
+			file = string(v)
+		case 2: // package
+			pkg = string(v)
+		case 4: // message_type
+			msgs = append(msgs, v)
+		case 5: // enum_type
+			enums = append(enums, v)
+		}
+	})
+	if !ok || file == "" {
+		return "", nil, false
+	}
+	var message func(prefix string, raw []byte) bool
+	message = func(prefix string, raw []byte) bool {
+		var name string
+		var nested, enums [][]byte
+		if !wireFields(raw, func(num int, v []byte) {
+			switch num {
+			case 1: // name
+				name = string(v)
+			case 3: // nested_type
+				nested = append(nested, v)
+			case 4: // enum_type
+				enums = append(enums, v)
+			}
+		}) {
+			return false
+		}
+		full := qualify(prefix, name)
+		names = append(names, full)
+		for _, e := range enums {
+			if !enum(full, e, &names) {
+				return false
+			}
+		}
+		for _, m := range nested {
+			if !message(full, m) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, m := range msgs {
+		if !message(pkg, m) {
+			return "", nil, false
+		}
+	}
+	for _, e := range enums {
+		if !enum(pkg, e, &names) {
+			return "", nil, false
+		}
+	}
+	return file, names, true
+}
+
+// enum appends the full name of the enum raw, a serialized
+// google.protobuf.EnumDescriptorProto, to names.
+func enum(prefix string, raw []byte, names *[]string) bool {
+	var name string
+	if !wireFields(raw, func(num int, v []byte) {
+		if num == 1 { // name
+			name = string(v)
+		}
+	}) {
+		return false
+	}
+	*names = append(*names, qualify(prefix, name))
+	return true
+}
+
+func qualify(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// wireFields calls f with the numbers and values of the length-delimited
+// fields of the message b is the wire encoding of, skipping the others,
+// and reports whether b is well-formed.
+func wireFields(b []byte, f func(num int, v []byte)) bool {
+	for len(b) > 0 {
+		tag, n := varint(b)
+		if n == 0 {
+			return false
+		}
+		b = b[n:]
+		num, typ := int(tag>>3), tag&7
+		switch typ {
+		case 0: // varint
+			if _, n = varint(b); n == 0 {
+				return false
+			}
+			b = b[n:]
+		case 1: // fixed64
+			if len(b) < 8 {
+				return false
+			}
+			b = b[8:]
+		case 2: // bytes
+			l, n := varint(b)
+			if n == 0 || uint64(len(b)-n) < l {
+				return false
+			}
+			f(num, b[n:n+int(l)])
+			b = b[n+int(l):]
+		case 5: // fixed32
+			if len(b) < 4 {
+				return false
+			}
+			b = b[4:]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// varint decodes the varint at the start of b, and returns it and the
+// number of its bytes, or 0 if there is none.
+func varint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
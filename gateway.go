@@ -0,0 +1,89 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const gatewayRuntimePath = "github.com/grpc-ecosystem/grpc-gateway/runtime"
+
+// gatewayOptions maps the fields of runtime.JSONPb of grpc-gateway v1,
+// which are those of jsonpb.Marshaler, to the protojson.MarshalOptions
+// fields of runtime.JSONPb of grpc-gateway v2.
+var gatewayOptions = map[string]string{
+	"AnyResolver":  "Resolver",
+	"EmitDefaults": "EmitUnpopulated",
+	"EnumsAsInts":  "UseEnumNumbers",
+	"Indent":       "Indent",
+	"OrigName":     "UseProtoNames",
+}
+
+// checkGatewayJSONPb reports the runtime.JSONPb marshalers of grpc-gateway
+// v1 configured in file, which wrap jsonpb, with the configuration of the
+// grpc-gateway v2 marshaler, which wraps protojson, translated from theirs.
+// The v1 marshaler allows unknown fields unless DisallowUnknownFields is
+// called, so the translation discards them too.
+func checkGatewayJSONPb(pass *analysis.Pass, file *ast.File) {
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CompositeLit:
+			if !isGatewayJSONPb(pass.TypesInfo.TypeOf(node)) {
+				return true
+			}
+			var opts []string
+			resolver := false
+			for _, elt := range node.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				key := kv.Key.(*ast.Ident)
+				name, ok := gatewayOptions[key.Name]
+				if !ok {
+					name = key.Name
+				}
+				resolver = resolver || key.Name == "AnyResolver"
+				opts = append(opts, name+": "+report.Render(pass, kv.Value))
+			}
+			v2 := "runtime.JSONPb{"
+			if len(opts) > 0 {
+				v2 += "MarshalOptions: protojson.MarshalOptions{" + strings.Join(opts, ", ") + "}, "
+			}
+			v2 += "UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true}}"
+			msg := "runtime.JSONPb of grpc-gateway v1 is configured with the options of jsonpb; the runtime.JSONPb of grpc-gateway v2 takes those of protojson instead, as in " + v2
+			if resolver {
+				msg += ", where the Resolver is a protoregistry resolver rather than a jsonpb.AnyResolver"
+			}
+			reportRule(pass, node, "PM2003", msg)
+		case *ast.CallExpr:
+			sel, ok := astutil.Unparen(node.Fun).(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			fn, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func)
+			if !ok || fn.Name() != "DisallowUnknownFields" || fn.Pkg() == nil || pkgPath(fn.Pkg()) != gatewayRuntimePath {
+				return true
+			}
+			reportRule(pass, node, "PM2003", "runtime.DisallowUnknownFields of grpc-gateway v1 has no v2 counterpart; the runtime.JSONPb of grpc-gateway v2 disallows unknown fields unless its UnmarshalOptions set DiscardUnknown")
+		}
+		return true
+	})
+}
+
+// isGatewayJSONPb reports whether t is runtime.JSONPb of grpc-gateway v1.
+func isGatewayJSONPb(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Name() == "JSONPb" && obj.Pkg() != nil && pkgPath(obj.Pkg()) == gatewayRuntimePath
+}
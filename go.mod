@@ -4,6 +4,8 @@ go 1.15
 
 require (
 	github.com/davecgh/go-spew v1.1.1
+	golang.org/x/mod v0.3.0
 	golang.org/x/tools v0.0.0-20201229013931-929a8494cf60
+	gopkg.in/yaml.v2 v2.4.0
 	honnef.co/go/tools v0.2.0-0.dev.0.20201230041409-6027df352cfc
 )
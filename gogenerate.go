@@ -0,0 +1,157 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// gogoPlugins are the protoc plugins of gogo protobuf that protoc-gen-go
+// replaces.
+var gogoPlugins = map[string]bool{
+	"gogo":       true,
+	"gofast":     true,
+	"gogofast":   true,
+	"gogofaster": true,
+	"gogoslick":  true,
+}
+
+// checkGoGenerate rewrites the protoc invocations of go:generate directives
+// that protoc-gen-go of google.golang.org/protobuf no longer runs as they
+// are: plugins=grpc, which protoc-gen-go-grpc replaces, and the gogo
+// plugins, which protoc-gen-go replaces along with their mappings to the
+// gogo well-known types. The rewritten invocations pass
+// paths=source_relative, unless they choose where the files go, which
+// moves the files generated under their import paths next to their .proto
+// files, hence the fixes are unsafe.
+func checkGoGenerate(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		if skipFile(pass, file) {
+			continue
+		}
+		for _, group := range file.Comments {
+			for _, c := range group.List {
+				const prefix = "//go:generate protoc "
+				if !strings.HasPrefix(c.Text, prefix) {
+					continue
+				}
+				args, reasons := rewriteProtoc(strings.Fields(c.Text[len(prefix):]))
+				if len(reasons) == 0 {
+					continue
+				}
+				text := prefix + strings.Join(args, " ")
+				pass.Report(analysis.Diagnostic{
+					Pos:     c.Pos(),
+					End:     c.End(),
+					Message: fmt.Sprintf("protoc invocation of go:generate directive is deprecated: %s", strings.Join(reasons, "; ")),
+					SuggestedFixes: []analysis.SuggestedFix{{
+						Message:   "Rewrite the protoc invocation",
+						TextEdits: []analysis.TextEdit{{Pos: c.Pos(), End: c.End(), NewText: []byte(text)}},
+					}},
+				})
+			}
+		}
+	}
+	return nil, nil
+}
+
+// rewriteProtoc returns the arguments of a protoc invocation rewritten for
+// protoc-gen-go of google.golang.org/protobuf, and the reasons for the
+// rewrite, none if args need none.
+func rewriteProtoc(args []string) ([]string, []string) {
+	var reasons []string
+	seen := map[string]bool{}
+	reason := func(r string) {
+		if !seen[r] {
+			seen[r] = true
+			reasons = append(reasons, r)
+		}
+	}
+	hasPaths, hasGRPC := false, false
+	for _, arg := range args {
+		hasGRPC = hasGRPC || strings.HasPrefix(arg, "--go-grpc_out=")
+		if strings.HasPrefix(arg, "--go_opt=") || strings.HasPrefix(arg, "--go-grpc_opt=") {
+			for _, p := range strings.Split(arg[strings.Index(arg, "=")+1:], ",") {
+				hasPaths = hasPaths || strings.HasPrefix(p, "paths=") || strings.HasPrefix(p, "module=")
+			}
+		}
+	}
+
+	var out []string
+	var rewritten []int
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--plugin=protoc-gen-") {
+			name := strings.TrimPrefix(arg, "--plugin=protoc-gen-")
+			if i := strings.Index(name, "="); i >= 0 && gogoPlugins[name[:i]] {
+				reason(fmt.Sprintf("protoc-gen-%s is replaced by protoc-gen-go", name[:i]))
+				continue
+			}
+		}
+		i := strings.Index(arg, "_out=")
+		if !strings.HasPrefix(arg, "--") || i < 0 {
+			out = append(out, arg)
+			continue
+		}
+		plugin, value := arg[2:i], arg[i+len("_out="):]
+		if plugin != "go" && !gogoPlugins[plugin] {
+			out = append(out, arg)
+			continue
+		}
+		if plugin != "go" {
+			reason(fmt.Sprintf("protoc-gen-%s is replaced by protoc-gen-go", plugin))
+		}
+		var params []string
+		dir := value
+		if j := strings.Index(value, ":"); j >= 0 {
+			params, dir = strings.Split(value[:j], ","), value[j+1:]
+		}
+		grpc := false
+		var kept []string
+		for _, p := range params {
+			switch {
+			case p == "plugins=grpc":
+				grpc = true
+				reason("plugins=grpc is no longer supported, protoc-gen-go-grpc generates the gRPC code")
+			case strings.HasPrefix(p, "M") && strings.Contains(p, "=github.com/gogo/protobuf/"):
+				reason("the mappings to the gogo well-known types are replaced by those of google.golang.org/protobuf")
+			default:
+				hasPaths = hasPaths || strings.HasPrefix(p, "paths=") || strings.HasPrefix(p, "module=")
+				kept = append(kept, p)
+			}
+		}
+		rewritten = append(rewritten, len(out))
+		out = append(out, "--go_out="+outValue(kept, dir))
+		if grpc && !hasGRPC {
+			hasGRPC = true
+			rewritten = append(rewritten, len(out))
+			out = append(out, "--go-grpc_out="+outValue(kept, dir))
+		}
+	}
+	if len(reasons) == 0 {
+		return args, nil
+	}
+	if !hasPaths {
+		for _, i := range rewritten {
+			j := strings.Index(out[i], "_out=") + len("_out=")
+			if k := strings.Index(out[i][j:], ":"); k >= 0 {
+				out[i] = out[i][:j+k] + ",paths=source_relative" + out[i][j+k:]
+			} else {
+				out[i] = out[i][:j] + "paths=source_relative:" + out[i][j:]
+			}
+		}
+	}
+	return out, reasons
+}
+
+// outValue returns the value of a --*_out flag of protoc passing params to
+// the plugin and writing to dir.
+func outValue(params []string, dir string) string {
+	if len(params) == 0 {
+		return dir
+	}
+	return strings.Join(params, ",") + ":" + dir
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+)
+
+// gogoMethods maps the methods that gogoproto extensions add to the
+// messages protoc-gen-gogo generates to the extensions.
+var gogoMethods = []struct {
+	method, extension string
+}{
+	{"Marshal", "marshaler"},
+	{"Unmarshal", "unmarshaler"},
+	{"Size", "sizer"},
+	{"Equal", "equal"},
+	{"VerboseEqual", "verbose_equal"},
+	{"GoString", "gostring"},
+	{"Compare", "compare"},
+	{"Description", "description"},
+}
+
+// checkGogo reports the files generated by protoc-gen-gogo, which have to be
+// regenerated with protoc-gen-go: their code uses the gogo runtime, and
+// the gogoproto extensions have no protoc-gen-go equivalent, so rewriting
+// their imports cannot migrate them. The other checks leave them alone.
+func checkGogo(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		if !isGogoFile(pass, file) {
+			continue
+		}
+		msg := "file generated by protoc-gen-gogo has to be regenerated with protoc-gen-go of google.golang.org/protobuf, since rewriting its imports cannot migrate it"
+		if exts := gogoExtensions(pass, file); len(exts) > 0 {
+			msg += fmt.Sprintf("; it uses the gogoproto extensions %s, which protoc-gen-go has no equivalent of", strings.Join(exts, ", "))
+		}
+		pass.Report(analysis.Diagnostic{Pos: file.Package, End: file.Name.End(), Message: msg})
+	}
+	return nil, nil
+}
+
+// isGogoFile reports whether file is generated by protoc-gen-gogo or one of
+// its variants.
+func isGogoFile(pass *analysis.Pass, file *ast.File) bool {
+	gen, ok := Generator(pass, file.Pos())
+	return ok && gen == facts.ProtocGenGogo
+}
+
+// gogoExtensions returns the names of the gogoproto extensions the code of
+// file shows the use of, in the order of gogoMethods and then of the
+// fields.
+func gogoExtensions(pass *analysis.Pass, file *ast.File) []string {
+	seen := map[string]bool{}
+	var fieldExts []string
+	use := func(ext string) {
+		if !seen[ext] {
+			seen[ext] = true
+			fieldExts = append(fieldExts, ext)
+		}
+	}
+	methods := map[string]bool{}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil && strings.HasPrefix(decl.Name.Name, "NewPopulated") {
+				use("populate")
+			}
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				tn, ok := pass.TypesInfo.Defs[spec.(*ast.TypeSpec).Name].(*types.TypeName)
+				if !ok || tn.IsAlias() {
+					continue
+				}
+				if _, ok := facts.ClassifyMessage(tn.Type()); !ok {
+					continue
+				}
+				mset := types.NewMethodSet(types.NewPointer(tn.Type()))
+				for _, m := range gogoMethods {
+					if mset.Lookup(nil, m.method) != nil {
+						methods[m.extension] = true
+					}
+				}
+				st, ok := tn.Type().Underlying().(*types.Struct)
+				if !ok {
+					continue
+				}
+				for i := 0; i < st.NumFields(); i++ {
+					if ext := gogoFieldExtension(st.Field(i).Type()); ext != "" {
+						use(ext)
+					}
+				}
+			}
+		}
+	}
+	var exts []string
+	for _, m := range gogoMethods {
+		if methods[m.extension] {
+			exts = append(exts, m.extension)
+		}
+	}
+	return append(exts, fieldExts...)
+}
+
+// gogoFieldExtension returns the gogoproto extension the type of a message
+// field shows the use of, if any: protoc-gen-go only generates pointers to
+// messages, and the well-known types rather than those of package time.
+func gogoFieldExtension(t types.Type) string {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	} else if _, ok := facts.ClassifyMessage(t); ok {
+		return "nullable"
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "time" {
+		return ""
+	}
+	switch named.Obj().Name() {
+	case "Time":
+		return "stdtime"
+	case "Duration":
+		return "stdduration"
+	}
+	return ""
+}
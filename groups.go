@@ -0,0 +1,90 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"golang.org/x/tools/go/analysis"
+)
+
+// The analyzers of the groups of checks, for drivers and users that only
+// want some of the migration, like golangci-lint configurations enabling
+// them one at a time. Each runs the checks of its group as Analyzer does,
+// with the same flags, rules and suppressions; running them all reports
+// what Analyzer does, but for the fixes of the calls of the v1 proto
+// package, which they split by family and so no longer share the import
+// edits of their files.
+var (
+	ImportsAnalyzer = newGroupAnalyzer("pmimports", "migrate the imports of the v1 packages replaced by v2 ones: adapters, proto.Buffer, descriptor, the well-known types and the functions of the v1 proto package of no other group")
+
+	JSONPBAnalyzer = newGroupAnalyzer("pmjsonpb", "migrate the uses of the v1 jsonpb package to protojson")
+
+	PtypesAnalyzer = newGroupAnalyzer("pmptypes", "migrate the uses of the v1 ptypes package to the methods of the well-known types")
+
+	TextAnalyzer = newGroupAnalyzer("pmtext", "migrate the text format functions of the v1 proto package to prototext")
+
+	ExtensionsAnalyzer = newGroupAnalyzer("pmextensions", "migrate the extension functions of the v1 proto package to those of the v2 one")
+
+	RegistryAnalyzer = newGroupAnalyzer("pmregistry", "migrate the registration and lookup functions of the v1 proto package to protoregistry")
+
+	DeprecatedAnalyzer = newGroupAnalyzer("pmdeprecated", "report the uses of deprecated protobuf APIs, and of the v1 API in packages declared fully migrated")
+
+	CodegenAnalyzer = newGroupAnalyzer("pmcodegen", "report the generated code to regenerate: gogo, stale and legacy gRPC files and go:generate directives")
+
+	MessagesAnalyzer = newGroupAnalyzer("pmmessages", "migrate the uses of messages the v2 API breaks: copies, comparisons, map keys, encoding/json and XXX_ fields")
+)
+
+// groupAnalyzers lists the analyzers of the groups, in order.
+var groupAnalyzers = []*analysis.Analyzer{
+	ImportsAnalyzer,
+	JSONPBAnalyzer,
+	PtypesAnalyzer,
+	TextAnalyzer,
+	ExtensionsAnalyzer,
+	RegistryAnalyzer,
+	DeprecatedAnalyzer,
+	CodegenAnalyzer,
+	MessagesAnalyzer,
+}
+
+// GroupAnalyzers returns the analyzers of the groups of the checks of
+// Analyzer. They require the same analyzers as Analyzer.
+func GroupAnalyzers() []*analysis.Analyzer {
+	return append([]*analysis.Analyzer(nil), groupAnalyzers...)
+}
+
+// newGroupAnalyzer returns the analyzer of name running the checks of its
+// group, those of the plugins registered included.
+func newGroupAnalyzer(name, doc string) *analysis.Analyzer {
+	a := &analysis.Analyzer{
+		Name: name,
+		Doc:  doc,
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			return runChecks(pass, groupChecks(name))
+		},
+		Requires: Analyzer.Requires,
+	}
+	registerFlags(a, name == "pmdeprecated")
+	return a
+}
+
+// groupChecks returns the checks of the group of name.
+func groupChecks(name string) []check {
+	var cs []check
+	for _, c := range append(append([]check(nil), checks...), protoFamilyChecks...) {
+		if c.group == name {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+// isGroup reports whether name is that of the analyzer of a group.
+func isGroup(name string) bool {
+	for _, a := range groupAnalyzers {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,473 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package checker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/objectpath"
+)
+
+// A Cache stores the facts and diagnostics of the packages analyzed, so
+// that the later runs of the same analyzers only analyze the packages that
+// changed, or whose dependencies did.
+//
+// An entry is keyed by the content of the files of its package, the keys of
+// its dependencies, the analyzers and the values of their flags, and the
+// executable running them. Flags whose value depends on more than their
+// string, like the content of a file, implement flag.Getter to have the
+// value they get keyed.
+type Cache struct {
+	dir  string
+	tool []byte
+}
+
+// cacheVersion is the version of the format of the entries.
+const cacheVersion = "protomigrate-cache-1"
+
+// OpenCache opens the cache in dir, creating the directory if need be.
+func OpenCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, tool: h.Sum(nil)}, nil
+}
+
+// cacheEntry is what the cache stores for a package.
+type cacheEntry struct {
+	// Diagnostics maps the names of the analyzers that ran on the package
+	// as requested, rather than for their facts, to their diagnostics.
+	Diagnostics map[string][]cachedDiagnostic
+
+	ObjectFacts  []cachedObjectFact
+	PackageFacts []analysis.Fact
+}
+
+type cachedObjectFact struct {
+	Path objectpath.Path
+	Fact analysis.Fact
+}
+
+// cachedPos is a token.Pos as a file name and an offset in it, which stay
+// the same across runs.
+type cachedPos struct {
+	File   string
+	Offset int
+}
+
+type cachedDiagnostic struct {
+	Pos, End       cachedPos
+	Category       string
+	Message        string
+	SuggestedFixes []cachedFix
+	Related        []cachedRelated
+}
+
+type cachedFix struct {
+	Message   string
+	TextEdits []cachedEdit
+}
+
+type cachedEdit struct {
+	Pos, End cachedPos
+	NewText  []byte
+}
+
+type cachedRelated struct {
+	Pos, End cachedPos
+	Message  string
+}
+
+// cacheKeys computes the keys of the cache entries of pkgs and of their
+// dependencies, given the analyzers run on them.
+func (c *Cache) cacheKeys(pkgs []*packages.Package, analyzers []*analysis.Analyzer) (map[*packages.Package]string, error) {
+	// The analyzers run for their facts or results are keyed too, along
+	// with the flags they share with those requested.
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%x\n%s %s/%s\n", cacheVersion, c.tool, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	seen := map[*analysis.Analyzer]bool{}
+	var visit func(a *analysis.Analyzer)
+	visit = func(a *analysis.Analyzer) {
+		if seen[a] {
+			return
+		}
+		seen[a] = true
+		fmt.Fprintf(h, "analyzer %s\n", a.Name)
+		a.Flags.VisitAll(func(f *flag.Flag) {
+			if g, ok := f.Value.(flag.Getter); ok {
+				fmt.Fprintf(h, "flag %s=%#v\n", f.Name, g.Get())
+			} else {
+				fmt.Fprintf(h, "flag %s=%s\n", f.Name, f.Value)
+			}
+		})
+		for _, req := range a.Requires {
+			visit(req)
+		}
+	}
+	for _, a := range analyzers {
+		visit(a)
+	}
+	base := h.Sum(nil)
+
+	keys := map[*packages.Package]string{}
+	var key func(pkg *packages.Package) (string, error)
+	key = func(pkg *packages.Package) (string, error) {
+		if k, ok := keys[pkg]; ok {
+			return k, nil
+		}
+		h := sha256.New()
+		fmt.Fprintf(h, "%x\npackage %s %s\n", base, pkg.ID, pkg.PkgPath)
+		files := append(append([]string(nil), pkg.CompiledGoFiles...), pkg.OtherFiles...)
+		for _, name := range files {
+			data, err := ioutil.ReadFile(name)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "file %s %x\n", name, sha256.Sum256(data))
+		}
+		paths := make([]string, 0, len(pkg.Imports))
+		for path := range pkg.Imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			k, err := key(pkg.Imports[path])
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "import %s %s\n", path, k)
+		}
+		k := hex.EncodeToString(h.Sum(nil))
+		keys[pkg] = k
+		return k, nil
+	}
+	var err error
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if err == nil {
+			_, err = key(pkg)
+		}
+	})
+	return keys, err
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// get returns the entry of the given key, or nil if there is none or it
+// cannot be read.
+func (c *Cache) get(key string) *cacheEntry {
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil
+	}
+	var e cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		return nil
+	}
+	return &e
+}
+
+// put stores the entry of the given key. It is written to a temporary
+// file first, so that concurrent runs never read a partial entry.
+func (c *Cache) put(key string, e *cacheEntry) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return err
+	}
+	name := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), key+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// posCoder converts the positions of the files of a package and its
+// dependencies to the positions of a cache entry and back.
+type posCoder struct {
+	fset  *token.FileSet
+	files map[string]*token.File
+}
+
+// newPosCoder returns the posCoder of the files of pkg and its
+// dependencies, which the related information of diagnostics points into.
+func newPosCoder(pkg *packages.Package) *posCoder {
+	pc := &posCoder{fset: pkg.Fset, files: map[string]*token.File{}}
+	packages.Visit([]*packages.Package{pkg}, nil, func(p *packages.Package) {
+		for _, f := range p.Syntax {
+			if tf := p.Fset.File(f.Pos()); tf != nil {
+				pc.files[tf.Name()] = tf
+			}
+		}
+	})
+	return pc
+}
+
+func (pc *posCoder) encode(pos token.Pos) cachedPos {
+	if !pos.IsValid() {
+		return cachedPos{}
+	}
+	tf := pc.fset.File(pos)
+	pc.files[tf.Name()] = tf
+	return cachedPos{File: tf.Name(), Offset: tf.Offset(pos)}
+}
+
+func (pc *posCoder) decode(p cachedPos) (token.Pos, error) {
+	if p.File == "" {
+		return token.NoPos, nil
+	}
+	tf, ok := pc.files[p.File]
+	if !ok || p.Offset > tf.Size() {
+		return token.NoPos, fmt.Errorf("position in unknown file %s", p.File)
+	}
+	return tf.Pos(p.Offset), nil
+}
+
+func (pc *posCoder) encodeDiagnostic(d analysis.Diagnostic) cachedDiagnostic {
+	cd := cachedDiagnostic{
+		Pos:      pc.encode(d.Pos),
+		End:      pc.encode(d.End),
+		Category: d.Category,
+		Message:  d.Message,
+	}
+	for _, fix := range d.SuggestedFixes {
+		cf := cachedFix{Message: fix.Message}
+		for _, edit := range fix.TextEdits {
+			cf.TextEdits = append(cf.TextEdits, cachedEdit{pc.encode(edit.Pos), pc.encode(edit.End), edit.NewText})
+		}
+		cd.SuggestedFixes = append(cd.SuggestedFixes, cf)
+	}
+	for _, rel := range d.Related {
+		cd.Related = append(cd.Related, cachedRelated{pc.encode(rel.Pos), pc.encode(rel.End), rel.Message})
+	}
+	return cd
+}
+
+func (pc *posCoder) decodeDiagnostic(cd cachedDiagnostic) (analysis.Diagnostic, error) {
+	var errs []error
+	decode := func(p cachedPos) token.Pos {
+		pos, err := pc.decode(p)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return pos
+	}
+	d := analysis.Diagnostic{
+		Pos:      decode(cd.Pos),
+		End:      decode(cd.End),
+		Category: cd.Category,
+		Message:  cd.Message,
+	}
+	for _, cf := range cd.SuggestedFixes {
+		fix := analysis.SuggestedFix{Message: cf.Message}
+		for _, ce := range cf.TextEdits {
+			fix.TextEdits = append(fix.TextEdits, analysis.TextEdit{Pos: decode(ce.Pos), End: decode(ce.End), NewText: ce.NewText})
+		}
+		d.SuggestedFixes = append(d.SuggestedFixes, fix)
+	}
+	for _, cr := range cd.Related {
+		d.Related = append(d.Related, analysis.RelatedInformation{Pos: decode(cr.Pos), End: decode(cr.End), Message: cr.Message})
+	}
+	if len(errs) > 0 {
+		return analysis.Diagnostic{}, errs[0]
+	}
+	return d, nil
+}
+
+// registerFacts registers the fact types of analyzers and of the analyzers
+// they require for gob, which encodes the facts of the cache entries as
+// interfaces.
+func registerFacts(analyzers []*analysis.Analyzer) {
+	seen := map[*analysis.Analyzer]bool{}
+	var visit func(a *analysis.Analyzer)
+	visit = func(a *analysis.Analyzer) {
+		if seen[a] {
+			return
+		}
+		seen[a] = true
+		for _, f := range a.FactTypes {
+			gob.Register(f)
+		}
+		for _, req := range a.Requires {
+			visit(req)
+		}
+	}
+	for _, a := range analyzers {
+		visit(a)
+	}
+}
+
+// restore restores the facts and diagnostics of the packages whose entries
+// the cache has, given their keys. The entries of the packages requested
+// have to hold the diagnostics of all analyzers.
+func (r *runner) restore(c *Cache, keys map[*packages.Package]string, pkgs []*packages.Package, analyzers []*analysis.Analyzer) {
+	registerFacts(analyzers)
+	requested := map[*packages.Package]bool{}
+	for _, pkg := range pkgs {
+		requested[pkg] = true
+	}
+	for pkg, key := range keys {
+		e := c.get(key)
+		if e == nil {
+			continue
+		}
+		if requested[pkg] {
+			complete := true
+			for _, a := range analyzers {
+				_, ok := e.Diagnostics[a.Name]
+				complete = complete && ok
+			}
+			if !complete {
+				continue
+			}
+		}
+		if diags, err := r.restoreEntry(pkg, e, analyzers); err == nil {
+			r.cached[pkg] = diags
+		}
+	}
+}
+
+// restoreEntry adds the facts of the entry of pkg to the store, and returns
+// its diagnostics. Nothing is added if it returns an error.
+func (r *runner) restoreEntry(pkg *packages.Package, e *cacheEntry, analyzers []*analysis.Analyzer) (map[*analysis.Analyzer][]analysis.Diagnostic, error) {
+	objectFacts := map[objectFactKey]analysis.Fact{}
+	for _, f := range e.ObjectFacts {
+		obj, err := objectpath.Object(pkg.Types, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		objectFacts[objectFactKey{obj, reflect.TypeOf(f.Fact)}] = f.Fact
+	}
+	pc := newPosCoder(pkg)
+	diags := map[*analysis.Analyzer][]analysis.Diagnostic{}
+	for _, a := range analyzers {
+		for _, cd := range e.Diagnostics[a.Name] {
+			d, err := pc.decodeDiagnostic(cd)
+			if err != nil {
+				return nil, err
+			}
+			diags[a] = append(diags[a], d)
+		}
+	}
+	for key, fact := range objectFacts {
+		r.objectFacts[key] = fact
+	}
+	for _, fact := range e.PackageFacts {
+		r.pkgFacts[packageFactKey{pkg.Types, reflect.TypeOf(fact)}] = fact
+	}
+	return diags, nil
+}
+
+// store stores the entries of the packages analyzed since it was last
+// called, given their keys, so that the entries of a run interrupted
+// before its end are kept. The facts of objects that cannot be found from
+// the scope of their package, like those of local variables, are left out,
+// since no other package can see them.
+func (r *runner) store(c *Cache, keys map[*packages.Package]string, analyzers []*analysis.Analyzer) error {
+	factTypes := factTypesOf(analyzers)
+	for pkg := range r.pending {
+		key, ok := keys[pkg]
+		if !ok {
+			continue
+		}
+		if _, ok := r.cached[pkg]; ok {
+			continue
+		}
+		e := &cacheEntry{Diagnostics: map[string][]cachedDiagnostic{}}
+		pc := newPosCoder(pkg)
+		for _, a := range analyzers {
+			act, ok := r.actions[actionKey{a, pkg}]
+			if !ok {
+				continue
+			}
+			cds := []cachedDiagnostic{}
+			for _, d := range act.diags {
+				cds = append(cds, pc.encodeDiagnostic(d))
+			}
+			e.Diagnostics[a.Name] = cds
+		}
+		for _, fk := range r.objectFactKeys[pkg.Types] {
+			path, err := objectpath.For(fk.obj)
+			if err != nil {
+				continue
+			}
+			e.ObjectFacts = append(e.ObjectFacts, cachedObjectFact{path, r.objectFacts[fk]})
+		}
+		for _, typ := range factTypes {
+			if fact, ok := r.pkgFacts[packageFactKey{pkg.Types, typ}]; ok {
+				e.PackageFacts = append(e.PackageFacts, fact)
+			}
+		}
+		if err := c.put(key, e); err != nil {
+			return err
+		}
+	}
+	r.pending = map[*packages.Package]bool{}
+	return nil
+}
+
+// factTypesOf returns the fact types of analyzers and of the analyzers they
+// require.
+func factTypesOf(analyzers []*analysis.Analyzer) []reflect.Type {
+	var typs []reflect.Type
+	seen := map[*analysis.Analyzer]bool{}
+	var visit func(a *analysis.Analyzer)
+	visit = func(a *analysis.Analyzer) {
+		if seen[a] {
+			return
+		}
+		seen[a] = true
+		for _, f := range a.FactTypes {
+			typs = append(typs, reflect.TypeOf(f))
+		}
+		for _, req := range a.Requires {
+			visit(req)
+		}
+	}
+	for _, a := range analyzers {
+		visit(a)
+	}
+	return typs
+}
@@ -0,0 +1,300 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// nameFact is the fact the analyzers of newNamesAnalyzer export for the
+// functions of a package.
+type nameFact struct{ Name string }
+
+func (*nameFact) AFact() {}
+
+// newNamesAnalyzer returns an analyzer exporting the names of the functions
+// of a package, followed by the value of its -suffix flag, as facts, and
+// reporting the calls of the functions of its dependencies with a fix
+// renaming them to their fact. It counts its runs by package path in runs.
+func newNamesAnalyzer(runs map[string]int) *analysis.Analyzer {
+	a := &analysis.Analyzer{
+		Name:      "names",
+		Doc:       "export the names of the functions and report their calls",
+		FactTypes: []analysis.Fact{new(nameFact)},
+	}
+	suffix := a.Flags.String("suffix", "", "suffix of the names")
+	a.Run = func(pass *analysis.Pass) (interface{}, error) {
+		runs[pass.Pkg.Path()]++
+		for _, f := range pass.Files {
+			ast.Inspect(f, func(node ast.Node) bool {
+				switch node := node.(type) {
+				case *ast.FuncDecl:
+					pass.ExportObjectFact(pass.TypesInfo.Defs[node.Name], &nameFact{node.Name.Name + *suffix})
+				case *ast.CallExpr:
+					sel, ok := node.Fun.(*ast.SelectorExpr)
+					if !ok {
+						return true
+					}
+					var fact nameFact
+					if !pass.ImportObjectFact(pass.TypesInfo.Uses[sel.Sel], &fact) {
+						return true
+					}
+					pass.Report(analysis.Diagnostic{
+						Pos:     node.Pos(),
+						End:     node.End(),
+						Message: "calls " + fact.Name,
+						SuggestedFixes: []analysis.SuggestedFix{{
+							Message:   "Rename the function",
+							TextEdits: []analysis.TextEdit{{Pos: sel.Sel.Pos(), End: sel.Sel.End(), NewText: []byte(fact.Name)}},
+						}},
+					})
+				}
+				return true
+			})
+		}
+		return nil, nil
+	}
+	return a
+}
+
+// testSources are the packages of the tests of the cache, in dependency
+// order.
+var testSources = []struct{ path, src string }{
+	{"example.com/a", "package a\n\nfunc F() {}\n"},
+	{"example.com/b", "package b\n\nimport \"example.com/a\"\n\nfunc G() { a.F() }\n"},
+}
+
+// writeSources writes the files of testSources to dir.
+func writeSources(t *testing.T, dir string) {
+	t.Helper()
+	for _, s := range testSources {
+		writeSource(t, dir, s.path, s.src)
+	}
+}
+
+func writeSource(t *testing.T, dir, path, src string) {
+	t.Helper()
+	name := filepath.Join(dir, filepath.FromSlash(path), "p.go")
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loadSources loads the packages of testSources from dir, as Load would,
+// and returns them in dependency order.
+func loadSources(t *testing.T, dir string) []*packages.Package {
+	t.Helper()
+	fset := token.NewFileSet()
+	sizes := types.SizesFor("gc", "amd64")
+	byPath := map[string]*packages.Package{}
+	var pkgs []*packages.Package
+	for _, s := range testSources {
+		name := filepath.Join(dir, filepath.FromSlash(s.path), "p.go")
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg := &packages.Package{
+			ID:              s.path,
+			Name:            f.Name.Name,
+			PkgPath:         s.path,
+			Fset:            fset,
+			GoFiles:         []string{name},
+			CompiledGoFiles: []string{name},
+			Syntax:          []*ast.File{f},
+			TypesSizes:      sizes,
+			Imports:         map[string]*packages.Package{},
+			TypesInfo: &types.Info{
+				Types:      map[ast.Expr]types.TypeAndValue{},
+				Defs:       map[*ast.Ident]types.Object{},
+				Uses:       map[*ast.Ident]types.Object{},
+				Implicits:  map[ast.Node]types.Object{},
+				Selections: map[*ast.SelectorExpr]*types.Selection{},
+				Scopes:     map[ast.Node]*types.Scope{},
+			},
+		}
+		for _, imp := range f.Imports {
+			path := imp.Path.Value[1 : len(imp.Path.Value)-1]
+			pkg.Imports[path] = byPath[path]
+		}
+		conf := &types.Config{
+			Importer: importerFunc(func(path string) (*types.Package, error) {
+				if dep, ok := byPath[path]; ok {
+					return dep.Types, nil
+				}
+				return nil, fmt.Errorf("no package %s", path)
+			}),
+			Sizes: sizes,
+		}
+		pkg.Types, err = conf.Check(s.path, fset, pkg.Syntax, pkg.TypesInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byPath[s.path] = pkg
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// summary describes the diagnostics diags, with the positions of their fix.
+func summary(fset *token.FileSet, diags []Diagnostic) []string {
+	var s []string
+	for _, d := range diags {
+		line := fmt.Sprintf("%s: %s", d.Position, d.Message)
+		for _, fix := range d.SuggestedFixes {
+			for _, e := range fix.TextEdits {
+				line += fmt.Sprintf(" [%s %s-%d %q]", fix.Message, fset.Position(e.Pos), fset.Position(e.End).Offset, e.NewText)
+			}
+		}
+		s = append(s, line)
+	}
+	return s
+}
+
+// TestCache checks that the packages whose entries the cache has are not
+// analyzed again, and that their facts and diagnostics are restored.
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := OpenCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSources(t, dir)
+	runs := map[string]int{}
+	analyzers := []*analysis.Analyzer{newNamesAnalyzer(runs)}
+
+	pkgs := loadSources(t, dir)
+	diags, err := Run(pkgs[1:], analyzers, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := summary(pkgs[0].Fset, diags)
+	if len(want) != 1 {
+		t.Fatalf("diagnostics %q, want one", want)
+	}
+
+	pkgs = loadSources(t, dir)
+	diags, err = Run(pkgs[1:], analyzers, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary(pkgs[0].Fset, diags); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored diagnostics %q, want %q", got, want)
+	}
+	if wantRuns := map[string]int{"example.com/a": 1, "example.com/b": 1}; !reflect.DeepEqual(runs, wantRuns) {
+		t.Errorf("runs %v, want %v", runs, wantRuns)
+	}
+
+	// Only the package that changed is analyzed again, with the facts of
+	// its dependency restored.
+	writeSource(t, dir, "example.com/b", "package b\n\nimport \"example.com/a\"\n\n// G calls F.\nfunc G() { a.F() }\n")
+	pkgs = loadSources(t, dir)
+	diags, err = Run(pkgs[1:], analyzers, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].Message != "calls F" || diags[0].Position.Line != 6 {
+		t.Errorf("diagnostics of the changed package %q, want calls F on line 6", summary(pkgs[0].Fset, diags))
+	}
+	if wantRuns := map[string]int{"example.com/a": 1, "example.com/b": 2}; !reflect.DeepEqual(runs, wantRuns) {
+		t.Errorf("runs %v, want %v", runs, wantRuns)
+	}
+}
+
+// TestCacheKeys checks that the keys of the entries change with the files
+// of the packages and of their dependencies, and with the flags of the
+// analyzers.
+func TestCacheKeys(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := OpenCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSources(t, dir)
+	a := newNamesAnalyzer(map[string]int{})
+	analyzers := []*analysis.Analyzer{a}
+	keys := func() []string {
+		t.Helper()
+		pkgs := loadSources(t, dir)
+		byPkg, err := cache.cacheKeys(pkgs[1:], analyzers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []string{byPkg[pkgs[0]], byPkg[pkgs[1]]}
+	}
+
+	orig := keys()
+	if again := keys(); !reflect.DeepEqual(again, orig) {
+		t.Errorf("keys %q of the same packages, want %q", again, orig)
+	}
+
+	writeSource(t, dir, "example.com/a", "package a\n\nfunc F() { F() }\n")
+	changed := keys()
+	if changed[0] == orig[0] || changed[1] == orig[1] {
+		t.Errorf("keys %q once a file of the dependency changed, want other keys than %q", changed, orig)
+	}
+
+	if err := a.Flags.Set("suffix", "V2"); err != nil {
+		t.Fatal(err)
+	}
+	if flagged := keys(); flagged[0] == changed[0] || flagged[1] == changed[1] {
+		t.Errorf("keys %q once a flag changed, want other keys than %q", flagged, changed)
+	}
+}
+
+// TestCacheResume checks that a run interrupted after some packages were
+// analyzed resumes from the entries of those packages.
+func TestCacheResume(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := OpenCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSources(t, dir)
+	runs := map[string]int{}
+	analyzers := []*analysis.Analyzer{newNamesAnalyzer(runs)}
+
+	errInterrupted := errors.New("interrupted")
+	err = Stream(loadSources(t, dir), analyzers, cache, func([]Diagnostic) error {
+		return errInterrupted
+	})
+	if err != errInterrupted {
+		t.Fatalf("Stream returned %v, want %v", err, errInterrupted)
+	}
+	if wantRuns := map[string]int{"example.com/a": 1}; !reflect.DeepEqual(runs, wantRuns) {
+		t.Fatalf("runs of the interrupted run %v, want %v", runs, wantRuns)
+	}
+
+	pkgs := loadSources(t, dir)
+	diags, err := Run(pkgs, analyzers, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].Message != "calls F" {
+		t.Errorf("diagnostics of the resumed run %q, want calls F", summary(pkgs[0].Fset, diags))
+	}
+	if wantRuns := map[string]int{"example.com/a": 1, "example.com/b": 1}; !reflect.DeepEqual(runs, wantRuns) {
+		t.Errorf("runs %v, want %v", runs, wantRuns)
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package checker runs analyzers on packages loaded from source, and
+// applies the fixes they suggest.
+package checker
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// Diagnostic is a diagnostic reported by an analyzer.
+type Diagnostic struct {
+	analysis.Diagnostic
+
+	// Analyzer is the analyzer that reported the diagnostic.
+	Analyzer *analysis.Analyzer
+
+	// Position is the position of the diagnostic's start.
+	Position token.Position
+
+	// Package is the path of the package the diagnostic is reported in.
+	Package string
+}
+
+// Load loads the packages matching patterns, along with the syntax of all
+// their dependencies, which analyzers with facts need, and the modules of
+// all. The test variants of the packages are included if tests is set.
+func Load(patterns []string, tests bool) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode:  packages.LoadAllSyntax | packages.NeedModule,
+		Tests: tests,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	var errs []packages.Error
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		errs = append(errs, pkg.Errors...)
+	})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%v", errs[0])
+	}
+	return pkgs, nil
+}
+
+// Run runs analyzers on pkgs, and returns the diagnostics they report
+// sorted by position. If cache is not nil, the packages whose entries it
+// has are not analyzed again, and the entries of the others are stored.
+func Run(pkgs []*packages.Package, analyzers []*analysis.Analyzer, cache *Cache) ([]Diagnostic, error) {
+	var diags []Diagnostic
+	err := Stream(pkgs, analyzers, cache, func(pkgDiags []Diagnostic) error {
+		diags = append(diags, pkgDiags...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		return less(diags[i].Position, diags[j].Position)
+	})
+	return diags, nil
+}
+
+// Stream runs analyzers on pkgs like Run, but rather than returning the
+// diagnostics of all packages, it passes those of each package, sorted by
+// position, to emit once the package is analyzed, and stops at the first
+// error emit returns. The diagnostics and results of a package are
+// released once emitted, so that the memory of a run does not grow with
+// its findings. If cache is not nil, the entries of the packages are
+// stored as they are analyzed, so that the packages a run analyzed are
+// not analyzed again if it is interrupted and run again.
+func Stream(pkgs []*packages.Package, analyzers []*analysis.Analyzer, cache *Cache, emit func([]Diagnostic) error) error {
+	r := newRunner()
+	var keys map[*packages.Package]string
+	if cache != nil {
+		var err error
+		keys, err = cache.cacheKeys(pkgs, analyzers)
+		if err != nil {
+			return err
+		}
+		r.restore(cache, keys, pkgs, analyzers)
+	}
+
+	// The test variant of a package repeats the diagnostics of its
+	// non-test files, so those of a package path are remembered until
+	// its last variant is emitted.
+	type diagKey struct {
+		analyzer *analysis.Analyzer
+		posn     token.Position
+		msg      string
+	}
+	variants := map[string]int{}
+	for _, pkg := range pkgs {
+		variants[pkg.PkgPath]++
+	}
+	seen := map[string]map[diagKey]bool{}
+	for _, pkg := range pkgs {
+		if seen[pkg.PkgPath] == nil {
+			seen[pkg.PkgPath] = map[diagKey]bool{}
+		}
+		var diags []Diagnostic
+		for _, a := range analyzers {
+			act := r.run(a, pkg)
+			if act.err != nil {
+				return fmt.Errorf("%s: %s: %v", pkg.PkgPath, a.Name, act.err)
+			}
+			for _, d := range act.diags {
+				d := Diagnostic{
+					Diagnostic: d,
+					Analyzer:   a,
+					Position:   pkg.Fset.Position(d.Pos),
+					Package:    pkg.PkgPath,
+				}
+				key := diagKey{d.Analyzer, d.Position, d.Message}
+				if !seen[pkg.PkgPath][key] {
+					seen[pkg.PkgPath][key] = true
+					diags = append(diags, d)
+				}
+			}
+		}
+		if cache != nil {
+			if err := r.store(cache, keys, analyzers); err != nil {
+				return err
+			}
+		}
+		r.release(pkg, analyzers)
+		if _, ok := r.cached[pkg]; ok {
+			r.cached[pkg] = map[*analysis.Analyzer][]analysis.Diagnostic{}
+		}
+		if variants[pkg.PkgPath]--; variants[pkg.PkgPath] == 0 {
+			delete(seen, pkg.PkgPath)
+		}
+
+		sort.SliceStable(diags, func(i, j int) bool {
+			return less(diags[i].Position, diags[j].Position)
+		})
+		if err := emit(diags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Facts holds the facts analyzers export, by package and by object.
+type Facts struct {
+	Packages map[*types.Package][]analysis.Fact
+	Objects  map[types.Object][]analysis.Fact
+}
+
+// ExportedFacts runs analyzers on pkgs, and returns the facts they, and the
+// analyzers they require, export for the packages of pkgs and their
+// dependencies.
+func ExportedFacts(pkgs []*packages.Package, analyzers []*analysis.Analyzer) (*Facts, error) {
+	r := newRunner()
+	for _, pkg := range pkgs {
+		for _, a := range analyzers {
+			if act := r.run(a, pkg); act.err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", pkg.PkgPath, a.Name, act.err)
+			}
+		}
+	}
+	facts := &Facts{
+		Packages: map[*types.Package][]analysis.Fact{},
+		Objects:  map[types.Object][]analysis.Fact{},
+	}
+	for key, fact := range r.pkgFacts {
+		facts.Packages[key.pkg] = append(facts.Packages[key.pkg], fact)
+	}
+	for key, fact := range r.objectFacts {
+		facts.Objects[key.obj] = append(facts.Objects[key.obj], fact)
+	}
+	return facts, nil
+}
+
+func less(a, b token.Position) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+type actionKey struct {
+	analyzer *analysis.Analyzer
+	pkg      *packages.Package
+}
+
+type objectFactKey struct {
+	obj types.Object
+	typ reflect.Type
+}
+
+type packageFactKey struct {
+	pkg *types.Package
+	typ reflect.Type
+}
+
+// action is the run of an analyzer on a package.
+type action struct {
+	result interface{}
+	diags  []analysis.Diagnostic
+	err    error
+
+	// released reports whether result was dropped, once the analyzers of
+	// the package no longer need it.
+	released bool
+}
+
+// runner runs analyzers on packages, each at most once. Since the packages
+// are type-checked together, the facts of all packages share one store.
+type runner struct {
+	actions     map[actionKey]*action
+	objectFacts map[objectFactKey]analysis.Fact
+	pkgFacts    map[packageFactKey]analysis.Fact
+
+	// cached maps the packages restored from the cache to the
+	// diagnostics of the analyzers requested, instead of running them.
+	cached map[*packages.Package]map[*analysis.Analyzer][]analysis.Diagnostic
+
+	// objectFactKeys lists the keys of the object facts exported by each
+	// package, and pending holds the packages analyzed since their entries
+	// were last stored, for storing the entries as they are analyzed.
+	objectFactKeys map[*types.Package][]objectFactKey
+	pending        map[*packages.Package]bool
+}
+
+func newRunner() *runner {
+	return &runner{
+		actions:     map[actionKey]*action{},
+		objectFacts: map[objectFactKey]analysis.Fact{},
+		pkgFacts:    map[packageFactKey]analysis.Fact{},
+		cached:      map[*packages.Package]map[*analysis.Analyzer][]analysis.Diagnostic{},
+
+		objectFactKeys: map[*types.Package][]objectFactKey{},
+		pending:        map[*packages.Package]bool{},
+	}
+}
+
+func (r *runner) run(a *analysis.Analyzer, pkg *packages.Package) *action {
+	key := actionKey{a, pkg}
+	if act, ok := r.actions[key]; ok {
+		return act
+	}
+	if diags, ok := r.cached[pkg]; ok {
+		act := &action{diags: diags[a]}
+		r.actions[key] = act
+		return act
+	}
+	act := &action{}
+	r.actions[key] = act
+	r.pending[pkg] = true
+
+	results := map[*analysis.Analyzer]interface{}{}
+	for _, req := range a.Requires {
+		dep := r.run(req, pkg)
+		if dep.err != nil {
+			act.err = fmt.Errorf("failed prerequisite %s: %v", req.Name, dep.err)
+			return act
+		}
+		results[req] = dep.result
+	}
+	if len(a.FactTypes) > 0 {
+		// The facts of the dependencies are imported, so compute them
+		// first.
+		for _, imp := range pkg.Imports {
+			if dep := r.run(a, imp); dep.err != nil {
+				act.err = fmt.Errorf("failed on dependency %s: %v", imp.PkgPath, dep.err)
+				return act
+			}
+		}
+	}
+
+	// The store holds the facts of all analyzers, of which a pass only
+	// sees its own.
+	factTypes := map[reflect.Type]bool{}
+	for _, f := range a.FactTypes {
+		factTypes[reflect.TypeOf(f)] = true
+	}
+	pass := &analysis.Pass{
+		Analyzer:   a,
+		Fset:       pkg.Fset,
+		Files:      pkg.Syntax,
+		OtherFiles: pkg.OtherFiles,
+		Pkg:        pkg.Types,
+		TypesInfo:  pkg.TypesInfo,
+		TypesSizes: pkg.TypesSizes,
+		ResultOf:   results,
+		Report:     func(d analysis.Diagnostic) { act.diags = append(act.diags, d) },
+		ImportObjectFact: func(obj types.Object, fact analysis.Fact) bool {
+			return r.importFact(r.objectFacts[objectFactKey{obj, reflect.TypeOf(fact)}], fact)
+		},
+		ImportPackageFact: func(p *types.Package, fact analysis.Fact) bool {
+			return r.importFact(r.pkgFacts[packageFactKey{p, reflect.TypeOf(fact)}], fact)
+		},
+		ExportObjectFact: func(obj types.Object, fact analysis.Fact) {
+			key := objectFactKey{obj, reflect.TypeOf(fact)}
+			if _, ok := r.objectFacts[key]; !ok {
+				r.objectFactKeys[obj.Pkg()] = append(r.objectFactKeys[obj.Pkg()], key)
+			}
+			r.objectFacts[key] = fact
+		},
+		ExportPackageFact: func(fact analysis.Fact) {
+			r.pkgFacts[packageFactKey{pkg.Types, reflect.TypeOf(fact)}] = fact
+		},
+		AllObjectFacts: func() []analysis.ObjectFact {
+			var facts []analysis.ObjectFact
+			for key, fact := range r.objectFacts {
+				if !factTypes[key.typ] {
+					continue
+				}
+				facts = append(facts, analysis.ObjectFact{Object: key.obj, Fact: fact})
+			}
+			return facts
+		},
+		AllPackageFacts: func() []analysis.PackageFact {
+			var facts []analysis.PackageFact
+			for key, fact := range r.pkgFacts {
+				if !factTypes[key.typ] {
+					continue
+				}
+				facts = append(facts, analysis.PackageFact{Package: key.pkg, Fact: fact})
+			}
+			return facts
+		},
+	}
+	act.result, act.err = a.Run(pass)
+	return act
+}
+
+// release drops the diagnostics of analyzers on pkg, once emitted, and their
+// results and those of the analyzers they require, which only the
+// analyzers run on pkg use. The actions stay, since the
+// analyzers with facts run on the packages importing pkg look them up.
+func (r *runner) release(pkg *packages.Package, analyzers []*analysis.Analyzer) {
+	for _, a := range analyzers {
+		act, ok := r.actions[actionKey{a, pkg}]
+		if !ok || act.released {
+			continue
+		}
+		act.result, act.diags, act.released = nil, nil, true
+		r.release(pkg, a.Requires)
+	}
+}
+
+// importFact copies stored, if any, into fact, which points to a value of
+// the same type.
+func (r *runner) importFact(stored, fact analysis.Fact) bool {
+	if stored == nil {
+		return false
+	}
+	reflect.ValueOf(fact).Elem().Set(reflect.ValueOf(stored).Elem())
+	return true
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package checker
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"sort"
+)
+
+// An Edit is a text edit in terms of the byte offsets of a file.
+type Edit struct {
+	Start, End int
+	Text       string
+}
+
+// Fixes holds the result of applying the suggested fixes of diagnostics.
+type Fixes struct {
+	// Files maps the names of the changed files to their new contents.
+	Files map[string][]byte
+
+	// Edits maps the names of the changed files to the edits applied to
+	// them, sorted by offset, in terms of their contents before the fixes
+	// and the formatting of Files.
+	Edits map[string][]Edit
+
+	// Applied and Skipped count the fixes that were applied, and the
+	// fixes that were not because they conflict with others.
+	Applied, Skipped int
+}
+
+// ApplyFixes applies the first suggested fix of each diagnostic, in order,
+// to the contents of the files, without writing them. A fix whose edits
+// overlap with those of a fix already applied is skipped; identical edits
+// are only applied once. The changed files are formatted.
+func ApplyFixes(fset *token.FileSet, diags []Diagnostic) (*Fixes, error) {
+	fixes := &Fixes{Files: map[string][]byte{}, Edits: map[string][]Edit{}}
+	edits := map[string][]Edit{}
+	for _, d := range diags {
+		if len(d.SuggestedFixes) == 0 {
+			continue
+		}
+		fix := map[string][]Edit{}
+		for _, e := range d.SuggestedFixes[0].TextEdits {
+			start := fset.Position(e.Pos)
+			end := start
+			if e.End.IsValid() {
+				end = fset.Position(e.End)
+			}
+			fix[start.Filename] = append(fix[start.Filename], Edit{start.Offset, end.Offset, string(e.NewText)})
+		}
+		if conflicts(edits, fix) {
+			fixes.Skipped++
+			continue
+		}
+		for name, es := range fix {
+		next:
+			for _, e := range es {
+				for _, prev := range edits[name] {
+					if prev == e {
+						continue next
+					}
+				}
+				edits[name] = append(edits[name], e)
+			}
+		}
+		fixes.Applied++
+	}
+
+	for name, es := range edits {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		// The edits at the same offset are applied in the order they were
+		// made, as analysistest applies them.
+		sort.SliceStable(es, func(i, j int) bool {
+			if es[i].Start != es[j].Start {
+				return es[i].Start < es[j].Start
+			}
+			return es[i].End < es[j].End
+		})
+		var out []byte
+		last := 0
+		for _, e := range es {
+			if e.End > len(src) {
+				return nil, fmt.Errorf("%s: edit beyond the end of the file", name)
+			}
+			if e.Start < last {
+				return nil, fmt.Errorf("%s: overlapping edits", name)
+			}
+			out = append(append(out, src[last:e.Start]...), e.Text...)
+			last = e.End
+		}
+		out = append(out, src[last:]...)
+		formatted, err := format.Source(out)
+		if err != nil {
+			return nil, fmt.Errorf("%s: fixed file does not parse: %v", name, err)
+		}
+		fixes.Files[name] = formatted
+		fixes.Edits[name] = es
+	}
+	return fixes, nil
+}
+
+// conflicts reports whether an edit of fix overlaps with one of edits
+// without being identical to it, or with another edit of fix.
+func conflicts(edits, fix map[string][]Edit) bool {
+	for name, es := range fix {
+		for i, e := range es {
+			for _, other := range es[:i] {
+				if e.Start < other.End && other.Start < e.End {
+					return true
+				}
+			}
+			for _, prev := range edits[name] {
+				if e == prev {
+					continue
+				}
+				if e.Start < prev.End && prev.Start < e.End {
+					return true
+				}
+				// Distinct insertions at the same offset have no
+				// well-defined order.
+				if e.Start == prev.Start && (e.Start == e.End || prev.Start == prev.End) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
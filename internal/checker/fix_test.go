@@ -0,0 +1,68 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package checker
+
+import (
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// TestApplyFixes is a test for ApplyFixes, with edits inserting at the
+// same offset.
+func TestApplyFixes(t *testing.T) {
+	const src = "package p\n\nfunc f() {\n\tg()\n}\n"
+	name := filepath.Join(t.TempDir(), "p.go")
+	if err := ioutil.WriteFile(name, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	tf := fset.AddFile(name, -1, len(src))
+	tf.SetLinesForContent([]byte(src))
+	pos := func(offset int) token.Pos { return tf.Pos(offset) }
+
+	call := len("package p\n\nfunc f() {\n\t")
+	end := call + len("g()")
+	diag := func(edits ...analysis.TextEdit) Diagnostic {
+		return Diagnostic{Diagnostic: analysis.Diagnostic{
+			Pos:            pos(call),
+			SuggestedFixes: []analysis.SuggestedFix{{TextEdits: edits}},
+		}}
+	}
+	insert := func(offset int, text string) analysis.TextEdit {
+		return analysis.TextEdit{Pos: pos(offset), End: pos(offset), NewText: []byte(text)}
+	}
+
+	fixes, err := ApplyFixes(fset, []Diagnostic{
+		diag(
+			insert(call, "if x := "),
+			insert(end, "; x == nil {"),
+			insert(end, "\n\ty()\n}"),
+		),
+		// The insertions of another fix at the same offset conflict.
+		diag(insert(end, "\n\tz()")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "package p\n\nfunc f() {\n\tif x := g(); x == nil {\n\t\ty()\n\t}\n}\n"
+	if got := string(fixes.Files[name]); got != want {
+		t.Errorf("fixed file:\n%s\nwant:\n%s", got, want)
+	}
+	if fixes.Applied != 1 || fixes.Skipped != 1 {
+		t.Errorf("applied %d and skipped %d fixes, want 1 and 1", fixes.Applied, fixes.Skipped)
+	}
+	wantEdits := []Edit{
+		{call, call, "if x := "},
+		{end, end, "; x == nil {"},
+		{end, end, "\n\ty()\n}"},
+	}
+	if !reflect.DeepEqual(fixes.Edits[name], wantEdits) {
+		t.Errorf("edits %v, want %v", fixes.Edits[name], wantEdits)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package diff computes line-based unified diffs.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// context is the number of unchanged lines shown around the changes.
+const context = 3
+
+// op is a line of the edit script turning a into b.
+type op struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Unified returns the unified diff turning a, the content of the file
+// oldName, into b, the content of newName. It returns nil if a and b are
+// equal.
+func Unified(oldName, newName string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	ops := edits(lines(a), lines(b))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// The hunk starts with the context before the change at i, and
+		// ends once more than twice the context separates two changes.
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end += context
+		if end > len(ops) {
+			end = len(ops)
+		}
+		writeHunk(&buf, ops, start, end)
+		i = end
+	}
+	return buf.Bytes()
+}
+
+// writeHunk writes the hunk made of ops[start:end].
+func writeHunk(buf *bytes.Buffer, ops []op, start, end int) {
+	// The hunk header counts the lines from 1.
+	oldLine, newLine := 1, 1
+	for _, o := range ops[:start] {
+		if o.kind != '+' {
+			oldLine++
+		}
+		if o.kind != '-' {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, o := range ops[start:end] {
+		if o.kind != '+' {
+			oldCount++
+		}
+		if o.kind != '-' {
+			newCount++
+		}
+	}
+	// An empty range is numbered by the line before it.
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+	for _, o := range ops[start:end] {
+		buf.WriteByte(o.kind)
+		buf.WriteString(o.line)
+		if len(o.line) == 0 || o.line[len(o.line)-1] != '\n' {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(line, count int) string {
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// lines splits b into lines, each ending with its newline but maybe the
+// last one.
+func lines(b []byte) []string {
+	var ls []string
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			i = len(b)
+		}
+		ls = append(ls, string(b[:i]))
+		b = b[i:]
+	}
+	return ls
+}
+
+// edits returns the shortest edit script turning a into b, computed by the
+// linear-space refinement of Myers' algorithm, so that the memory it takes
+// grows with the length of the files rather than with its square.
+func edits(a, b []string) []op {
+	var ops []op
+	compare(&ops, a, b)
+	return ops
+}
+
+// compare appends to ops the shortest edit script turning a into b. The
+// lines they start and end with in common are set aside first, and the rest
+// is split at the middle snake of an edit path, whose two halves are
+// compared likewise.
+func compare(ops *[]op, a, b []string) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		*ops = append(*ops, op{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, l := range b {
+			*ops = append(*ops, op{'+', l})
+		}
+	case len(b) == 0:
+		for _, l := range a {
+			*ops = append(*ops, op{'-', l})
+		}
+	default:
+		// Both differ at their ends, so the edit path takes at least two
+		// edits, and each half fewer than it.
+		x, y, u, v := middleSnake(a, b)
+		compare(ops, a[:x], b[:y])
+		for _, l := range a[x:u] {
+			*ops = append(*ops, op{' ', l})
+		}
+		compare(ops, a[u:], b[v:])
+	}
+	for _, l := range common {
+		*ops = append(*ops, op{' ', l})
+	}
+}
+
+// middleSnake returns the snake, from (x, y) to (u, v), in the middle of a
+// shortest edit path turning a into b, found by extending paths from both
+// ends of the edit graph until they overlap.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	max := (n + m + 1) / 2
+	delta := n - m
+	// forward[off+k] is the furthest x reached on the diagonal x-y = k from
+	// (0, 0), and backward[off+k] the furthest distance from the end
+	// reached on the diagonal (n-x)-(m-y) = k from (n, m).
+	off := max + 1
+	forward := make([]int, 2*max+3)
+	backward := make([]int, 2*max+3)
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			x := forward[off+k-1] + 1
+			if k == -d || k != d && forward[off+k-1] < forward[off+k+1] {
+				x = forward[off+k+1]
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[off+k] = x
+			if kb := delta - k; delta%2 != 0 && kb >= -(d-1) && kb <= d-1 && x+backward[off+kb] >= n {
+				return x0, y0, x, y
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			x := backward[off+k-1] + 1
+			if k == -d || k != d && backward[off+k-1] < backward[off+k+1] {
+				x = backward[off+k+1]
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[off+k] = x
+			if kf := delta - k; delta%2 == 0 && kf >= -d && kf <= d && x+forward[off+kf] >= n {
+				return n - x, m - y, n - x0, m - y0
+			}
+		}
+	}
+	panic("diff: no middle snake")
+}
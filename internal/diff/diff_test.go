@@ -0,0 +1,141 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package diff
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines 1 to n but those of skip, each holding its
+// number.
+func numbered(n int, skip ...int) string {
+	var b strings.Builder
+next:
+	for i := 1; i <= n; i++ {
+		for _, s := range skip {
+			if i == s {
+				continue next
+			}
+		}
+		b.WriteString(strconv.Itoa(i) + "\n")
+	}
+	return b.String()
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "insert",
+			a:    "a\nb\nc\n",
+			b:    "a\nb\nx\nc\n",
+			want: "--- a/f.go\n+++ b/f.go\n@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n",
+		},
+		{
+			name: "delete",
+			a:    "a\nb\nc\n",
+			b:    "a\nc\n",
+			want: "--- a/f.go\n+++ b/f.go\n@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+		},
+		{
+			name: "insert into empty",
+			a:    "",
+			b:    "a\n",
+			want: "--- a/f.go\n+++ b/f.go\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			// The changes of lines 2 and 9 are twice the context apart,
+			// and share a hunk, unlike that of line 20.
+			name: "hunks",
+			a:    numbered(20),
+			b:    numbered(20, 2, 9, 20),
+			want: "--- a/f.go\n+++ b/f.go\n" +
+				"@@ -1,12 +1,10 @@\n 1\n-2\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n 10\n 11\n 12\n" +
+				"@@ -17,4 +15,3 @@\n 17\n 18\n 19\n-20\n",
+		},
+		{
+			name: "no newline at end of file",
+			a:    "a\nb",
+			b:    "a\nc",
+			want: "--- a/f.go\n+++ b/f.go\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Unified("a/f.go", "b/f.go", []byte(tt.a), []byte(tt.b)))
+			if got != tt.want {
+				t.Errorf("Unified(%q, %q) =\n%s\nwant:\n%s", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence of a and
+// b, in quadratic time.
+func lcsLength(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return lcs[0][0]
+}
+
+// TestEdits checks that the edit scripts of random files of few distinct
+// lines turn one into the other with the fewest edits.
+func TestEdits(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() []string {
+		ls := make([]string, r.Intn(30))
+		for i := range ls {
+			ls[i] = string(rune('a'+r.Intn(4))) + "\n"
+		}
+		return ls
+	}
+	for i := 0; i < 1000; i++ {
+		a, b := random(), random()
+		var oldLines, newLines []string
+		n := 0
+		for _, o := range edits(a, b) {
+			if o.kind != '+' {
+				oldLines = append(oldLines, o.line)
+			}
+			if o.kind != '-' {
+				newLines = append(newLines, o.line)
+			}
+			if o.kind != ' ' {
+				n++
+			}
+		}
+		if strings.Join(oldLines, "") != strings.Join(a, "") || strings.Join(newLines, "") != strings.Join(b, "") {
+			t.Fatalf("edits(%q, %q) turns %q into %q", a, b, oldLines, newLines)
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); n != want {
+			t.Errorf("edits(%q, %q) makes %d edits, want %d", a, b, n, want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package protosrc reads the declarations of .proto files that the Go code
+// generated from them follows: their package, go_package option, messages
+// and fields. It only tokenizes the files, which is enough to follow their
+// declarations, and does not report their syntax errors.
+package protosrc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A File is a .proto file.
+type File struct {
+	Package string
+
+	// GoPackage is the import path of the go_package option, without the
+	// package name it may end with, and GoPackageLine the line of its
+	// value, or 0 if the file has none.
+	GoPackage     string
+	GoPackageLine int
+
+	// Messages holds the messages, groups included, in order of
+	// declaration, outer messages first.
+	Messages []*Message
+}
+
+// A Message is a message of a File.
+type Message struct {
+	// Name is the name of the message relative to the package of its file,
+	// like Outer.Inner.
+	Name string
+
+	// Fields holds the names of the fields, those of its oneofs included,
+	// in order of declaration.
+	Fields []string
+}
+
+// GoName returns the name of the Go type protoc-gen-go generates for m.
+func (m *Message) GoName() string {
+	return GoCamelCase(m.Name)
+}
+
+// Parse returns the declarations of the .proto file of content data.
+func Parse(data []byte) *File {
+	f := &File{}
+	toks := tokenize(string(data))
+	text := func(i int) string {
+		if i < 0 || i >= len(toks) {
+			return ""
+		}
+		return toks[i].text
+	}
+	// scopes holds the blocks the declarations are in, as the messages
+	// they declare the fields of, those of messages and oneofs, or nil.
+	var scopes []*Message
+	enclosing := func() *Message {
+		if len(scopes) == 0 {
+			return nil
+		}
+		return scopes[len(scopes)-1]
+	}
+	for i := 0; i < len(toks); i++ {
+		switch t := toks[i].text; {
+		case t == "{":
+			// Groups, like "repeated group Result = 1 {", declare a
+			// message and a field.
+			var name string
+			switch {
+			case text(i-2) == "message":
+				name = text(i - 1)
+			case text(i-4) == "group":
+				name = text(i - 3)
+				if m := enclosing(); m != nil {
+					m.Fields = append(m.Fields, strings.ToLower(name))
+				}
+			case text(i-2) == "oneof":
+				scopes = append(scopes, enclosing())
+				continue
+			default:
+				scopes = append(scopes, nil)
+				continue
+			}
+			outer := enclosing()
+			if len(scopes) > 0 && outer == nil {
+				// Messages declared in other blocks, like those of
+				// extensions, are left out.
+				scopes = append(scopes, nil)
+				continue
+			}
+			if outer != nil {
+				name = outer.Name + "." + name
+			}
+			m := &Message{Name: name}
+			f.Messages = append(f.Messages, m)
+			scopes = append(scopes, m)
+		case t == "}":
+			if len(scopes) > 0 {
+				scopes = scopes[:len(scopes)-1]
+			}
+		case t == "=" && enclosing() != nil:
+			// A field is declared by its type, or map<K, V>, its name and
+			// its number; options have other tokens before their names.
+			typ := text(i - 2)
+			if isNumber(text(i+1)) && isIdent(text(i-1)) && (isIdent(typ) || typ == ">") && typ != "option" && typ != "group" {
+				m := enclosing()
+				m.Fields = append(m.Fields, text(i-1))
+			}
+		case len(scopes) > 0:
+		case t == "package":
+			f.Package = text(i + 1)
+		case t == "option" && text(i+1) == "go_package" && text(i+2) == "=":
+			if v, err := strconv.Unquote(text(i + 3)); err == nil {
+				if j := strings.Index(v, ";"); j >= 0 {
+					v = v[:j]
+				}
+				f.GoPackage, f.GoPackageLine = v, toks[i+3].line
+			}
+		}
+	}
+	return f
+}
+
+type token struct {
+	text string
+	line int
+}
+
+// tokenize splits src into the tokens of the protobuf language:
+// identifiers, full identifiers like a.b.c included, numbers, strings,
+// with their quotes, and punctuation, dropping the comments.
+func tokenize(src string) []token {
+	var toks []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += 2 + end + 2
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c && src[j] != '\n' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(src) {
+				j++
+			}
+			if j > len(src) {
+				j = len(src)
+			}
+			text := src[i:j]
+			if c == '\'' && len(text) >= 2 {
+				text = `"` + strings.ReplaceAll(text[1:len(text)-1], `"`, `\"`) + `"`
+			}
+			toks = append(toks, token{text, line})
+			i = j
+		case isIdentByte(c):
+			j := i
+			for j < len(src) && (isIdentByte(src[j]) || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{src[i:j], line})
+			i = j
+		default:
+			toks = append(toks, token{string(c), line})
+			i++
+		}
+	}
+	return toks
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdent(s string) bool {
+	return s != "" && !isDigit(s[0]) && isIdentByte(s[0])
+}
+
+func isNumber(s string) bool {
+	return s != "" && isDigit(s[0])
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isLower(c byte) bool { return 'a' <= c && c <= 'z' }
+
+// GoCamelCase returns the name protoc-gen-go gives the Go type of the
+// message of relative name s, like Outer_Inner for Outer.Inner, as
+// GoCamelCase of google.golang.org/protobuf/internal/strs does.
+func GoCamelCase(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isLower(s[i+1]):
+			// The dot of ".x" is dropped.
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isLower(s[i+1]):
+			// The underscore of "_x" is dropped.
+		case isDigit(c):
+			b = append(b, c)
+		default:
+			if isLower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isLower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,605 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const (
+	jsonpbPath    = "github.com/golang/protobuf/jsonpb"
+	protojsonPath = "google.golang.org/protobuf/encoding/protojson"
+)
+
+// jsonpbTypes maps the jsonpb option types to their protojson counterparts.
+var jsonpbTypes = map[string]string{
+	"Marshaler":   "MarshalOptions",
+	"Unmarshaler": "UnmarshalOptions",
+}
+
+// A jsonpbOption is the protojson counterpart of an option of a jsonpb type.
+type jsonpbOption struct {
+	// name is the name of the protojson option.
+	name string
+
+	// note, if any, tells what dropping the option would change, or how
+	// the protojson option differs if it has the same name.
+	note string
+
+	// falseNote, if any, tells what changes where the option is false,
+	// which is reported when it is explicitly.
+	falseNote string
+}
+
+// noteSuffix returns the note of o, to end a message with, if it has one.
+func (o jsonpbOption) noteSuffix() string {
+	if o.note == "" {
+		return ""
+	}
+	return ": " + o.note
+}
+
+// jsonpbOptions maps the options of the jsonpb types that protojson has,
+// as Type.Option, to their counterparts, of other names but for Indent.
+var jsonpbOptions = map[string]jsonpbOption{
+	"Marshaler.OrigName": {
+		name: "UseProtoNames",
+		note: "unset, both name the fields of the JSON by their lowerCamelCase JSON names rather than their names in the .proto file, so dropping it changes the field names",
+	},
+	"Marshaler.EnumsAsInts": {
+		name: "UseEnumNumbers",
+		note: "unset, both emit the enum values by their names rather than their numbers, so dropping it changes the values",
+	},
+	"Marshaler.Indent": {
+		name: "Indent",
+		note: "protojson indents the JSON with it too, but adds random spaces to its output, which is then not byte-stable across releases: golden files of the jsonpb output compared byte for byte have to be normalized, like with json.Indent, or compared as the messages they unmarshal to, prototext output being randomized likewise",
+	},
+	"Marshaler.EmitDefaults": {
+		name: "EmitUnpopulated",
+	},
+	"Unmarshaler.AllowUnknownFields": {
+		name:      "DiscardUnknown",
+		note:      "unset, both reject the JSON with unknown fields, so dropping it makes unmarshaling fail on them",
+		falseNote: "both reject the JSON with unknown fields, but the errors of protojson are worded otherwise, and not stably, so code matching their text breaks",
+	},
+}
+
+// checkJSONPB rewrites uses of the jsonpb package to protojson, and reports
+// those of the grpc-gateway marshaler wrapping it.
+func checkJSONPB(pass *analysis.Pass) (interface{}, error) {
+	// The calls of the methods of a jsonpb type left alone would not
+	// compile on its protojson counterpart, so the type is only rewritten
+	// if every use of its values is, in any file of the package.
+	calls := map[*ast.File][]jsonpbMethodCall{}
+	kept := map[string]bool{}
+	for _, file := range pass.Files {
+		if skipFile(pass, file) {
+			continue
+		}
+		calls[file] = jsonpbMethodCalls(pass, file)
+		keepJSONPBTypes(pass, file, calls[file], kept)
+	}
+	forEachFile(pass, func(pass *analysis.Pass, file *ast.File) {
+		checkJSONPBImport(pass, file, kept)
+		checkJSONPBIndent(pass, file)
+		reportJSONPBMethodCalls(pass, calls[file], kept)
+		checkAnyResolvers(pass, file)
+		checkGatewayJSONPb(pass, file)
+	})
+	return nil, nil
+}
+
+// indentMsg reports a jsonpb.Marshaler option Indent that protojson does
+// not take, formatted with the names of the jsonpb and protojson types.
+const indentMsg = "jsonpb.%s option Indent has no automatic protojson translation: protojson.%s only indents with spaces and tabs, and fails to marshal with others"
+
+// jsonpbIndent reports whether value, that of the jsonpb.Marshaler option
+// Indent, may indent the output, and whether protojson takes it. Only
+// constants are known not to, the empty string, or to be invalid, those
+// with other characters than spaces and tabs.
+func jsonpbIndent(pass *analysis.Pass, value ast.Expr) (set, valid bool) {
+	tv := pass.TypesInfo.Types[value]
+	if tv.Value == nil {
+		return true, true
+	}
+	indent := constant.StringVal(tv.Value)
+	return indent != "", strings.Trim(indent, " \t") == ""
+}
+
+// translatesOption reports whether the fix of a composite literal of the
+// jsonpb type name translates the option kv sets.
+func translatesOption(pass *analysis.Pass, name string, kv *ast.KeyValueExpr) bool {
+	key, ok := kv.Key.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	if key.Name == "Indent" {
+		_, valid := jsonpbIndent(pass, kv.Value)
+		return valid
+	}
+	_, ok = jsonpbOptions[name+"."+key.Name]
+	return ok
+}
+
+// checkJSONPBIndent reports the assignments of the jsonpb.Marshaler option
+// Indent, which the protojson.MarshalOptions the type is rewritten to keep,
+// as the composite literals setting it are.
+func checkJSONPBIndent(pass *analysis.Pass, file *ast.File) {
+	ast.Inspect(file, func(node ast.Node) bool {
+		assign, ok := node.(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != len(assign.Rhs) {
+			return true
+		}
+		for i, lhs := range assign.Lhs {
+			sel, ok := lhs.(*ast.SelectorExpr)
+			if !ok || !isJSONPBField(pass, sel, "Marshaler", "Indent") {
+				continue
+			}
+			set, valid := jsonpbIndent(pass, assign.Rhs[i])
+			switch {
+			case !valid:
+				reportRule(pass, sel, "PM2002", fmt.Sprintf(indentMsg, "Marshaler", "MarshalOptions"))
+			case set:
+				reportRule(pass, sel, "PM2002", "jsonpb.Marshaler option Indent is one of protojson.MarshalOptions too: "+jsonpbOptions["Marshaler.Indent"].note)
+			}
+		}
+		return true
+	})
+}
+
+// checkJSONPBImport rewrites the references through the file's jsonpb
+// import, and the import itself, but those of the types kept holds.
+func checkJSONPBImport(pass *analysis.Pass, file *ast.File, kept map[string]bool) {
+	spec := findImport(file, jsonpbPath)
+	if spec == nil {
+		return
+	}
+	pkg := importedPkgName(pass, spec)
+	if pkg == nil {
+		return
+	}
+
+	rw := newImportRewrite(file, spec)
+	protojson := rw.qualifier(pass, protojsonPath)
+
+	// Composite literals whose options cannot be translated keep their
+	// type, so collect them before looking at the type references, along
+	// with the options renamed by the fixes of the others.
+	untranslated := map[*ast.SelectorExpr]bool{}
+	renamed := map[*ast.SelectorExpr][]*ast.Ident{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		lit, ok := node.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		sel, ok := lit.Type.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); !ok || pass.TypesInfo.Uses[x] != pkg {
+			return true
+		}
+		if _, ok := jsonpbTypes[sel.Sel.Name]; !ok {
+			return true
+		}
+		for _, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			key := kv.Key.(*ast.Ident)
+			if !translatesOption(pass, sel.Sel.Name, kv) {
+				msg := fmt.Sprintf("jsonpb.%s option %s has no automatic protojson translation", sel.Sel.Name, key.Name)
+				switch key.Name {
+				case "Indent":
+					msg = fmt.Sprintf(indentMsg, sel.Sel.Name, jsonpbTypes[sel.Sel.Name])
+				case "AnyResolver":
+					msg += anyResolverOption(pass, kv.Value)
+				}
+				reportRule(pass, kv, "PM2002", msg)
+				untranslated[sel] = true
+				continue
+			}
+			if key.Name == "Indent" {
+				if set, _ := jsonpbIndent(pass, kv.Value); !set {
+					continue
+				}
+			}
+			opt := jsonpbOptions[sel.Sel.Name+"."+key.Name]
+			renamed[sel] = append(renamed[sel], key)
+			if tv := pass.TypesInfo.Types[kv.Value]; opt.falseNote != "" && tv.Value != nil && !constant.BoolVal(tv.Value) {
+				reportRule(pass, kv, "PM2002", fmt.Sprintf("jsonpb.%s option %s is explicitly false, as protojson.%s option %s is by default: %s", sel.Sel.Name, key.Name, jsonpbTypes[sel.Sel.Name], opt.name, opt.falseNote))
+			}
+		}
+		return true
+	})
+
+	for _, sel := range qualifiedRefs(pass, file, pkg) {
+		name := sel.Sel.Name
+		if newName, ok := jsonpbTypes[name]; ok {
+			if untranslated[sel] || kept[name] {
+				// The options of other names are left too, and have to be
+				// renamed along with the others.
+				for _, key := range renamed[sel] {
+					opt := jsonpbOptions[name+"."+key.Name]
+					if opt.name == key.Name {
+						reportRule(pass, key, "PM2002", fmt.Sprintf("jsonpb.%s option %s is one of protojson.%s too%s", name, key.Name, newName, opt.noteSuffix()))
+						continue
+					}
+					reportRule(pass, key, "PM2002", fmt.Sprintf("jsonpb.%s option %s is protojson.%s option %s%s", name, key.Name, newName, opt.name, opt.noteSuffix()))
+				}
+				rw.unfixed++
+				continue
+			}
+			rw.fixed++
+			rw.require(pass, protojsonPath)
+			msg := fmt.Sprintf("jsonpb.%s should be replaced with protojson.%s", name, newName)
+			edits := []analysis.TextEdit{edit.ReplaceWithString(pass.Fset, sel, protojson+"."+newName)}
+			for _, key := range renamed[sel] {
+				opt := jsonpbOptions[name+"."+key.Name]
+				if opt.name == key.Name {
+					msg += fmt.Sprintf(", with option %s%s", key.Name, opt.noteSuffix())
+					continue
+				}
+				msg += fmt.Sprintf(", with option %s as %s%s", key.Name, opt.name, opt.noteSuffix())
+				edits = append(edits, edit.ReplaceWithString(pass.Fset, key, opt.name))
+			}
+			report.Report(pass, sel, msg, report.Fixes(edit.Fix("Use protojson."+newName, edits...)))
+			continue
+		}
+
+		switch name {
+		case "UnmarshalString":
+			call, ok := callOf(file, sel)
+			if !ok || len(call.Args) != 2 {
+				rw.unfixed++
+				continue
+			}
+			if t := pass.TypesInfo.TypeOf(call.Args[1]); !hasProtoReflect(t) {
+				report.Report(pass, sel, "jsonpb.UnmarshalString should be replaced with protojson.Unmarshal"+v1MessageNote(pass, t))
+				rw.unfixed++
+				continue
+			}
+			rw.fixed++
+			rw.require(pass, protojsonPath)
+			// protojson.Unmarshal takes the encoded message as bytes
+			// rather than a string.
+			edits := []analysis.TextEdit{
+				edit.ReplaceWithString(pass.Fset, sel, protojson+".Unmarshal"),
+				insert(call.Args[0].Pos(), "[]byte("),
+				insert(call.Args[0].End(), ")"),
+			}
+			report.Report(pass, sel, "jsonpb.UnmarshalString should be replaced with protojson.Unmarshal",
+				report.Fixes(edit.Fix("Use protojson.Unmarshal", edits...)))
+		default:
+			rw.unfixed++
+		}
+	}
+
+	rw.report(pass)
+}
+
+// callOf returns the call expression in file whose function is fun.
+func callOf(file *ast.File, fun ast.Expr) (*ast.CallExpr, bool) {
+	var call *ast.CallExpr
+	ast.Inspect(file, func(node ast.Node) bool {
+		if call != nil {
+			return false
+		}
+		if c, ok := node.(*ast.CallExpr); ok && c.Fun == fun {
+			call = c
+			return false
+		}
+		return true
+	})
+	return call, call != nil
+}
+
+// jsonpbRecv returns the name of the jsonpb type, one of jsonpbTypes, whose
+// field or method s selects.
+func jsonpbRecv(s *types.Selection) (string, bool) {
+	if obj := s.Obj(); obj.Pkg() == nil || pkgPath(obj.Pkg()) != jsonpbPath {
+		return "", false
+	}
+	t := s.Recv()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || pkgPath(named.Obj().Pkg()) != jsonpbPath {
+		return "", false
+	}
+	name := named.Obj().Name()
+	_, ok = jsonpbTypes[name]
+	return name, ok
+}
+
+// isJSONPBField reports whether sel selects the named field of the jsonpb
+// type recv.
+func isJSONPBField(pass *analysis.Pass, sel *ast.SelectorExpr, recv, field string) bool {
+	s, ok := pass.TypesInfo.Selections[sel]
+	if !ok || s.Kind() != types.FieldVal || s.Obj().Name() != field {
+		return false
+	}
+	name, ok := jsonpbRecv(s)
+	return ok && name == recv
+}
+
+// isJSONPBMethod reports whether sel selects the named method of the
+// jsonpb type recv.
+func isJSONPBMethod(pass *analysis.Pass, sel *ast.SelectorExpr, recv, method string) bool {
+	s, ok := pass.TypesInfo.Selections[sel]
+	if !ok || s.Kind() != types.MethodVal || s.Obj().Name() != method {
+		return false
+	}
+	name, ok := jsonpbRecv(s)
+	return ok && name == recv
+}
+
+// A jsonpbMethodCall is a call of a method of jsonpb.Marshaler or
+// jsonpb.Unmarshaler, and the diagnostic reporting it.
+type jsonpbMethodCall struct {
+	call *ast.CallExpr
+
+	// recv is the name of the jsonpb type of the method.
+	recv string
+
+	msg string
+
+	// fix names the fix made of edits, which are nil if the call cannot be
+	// fixed.
+	fix   string
+	edits []analysis.TextEdit
+}
+
+// jsonpbMethodCalls returns the calls in file of the methods of the jsonpb
+// types, whose protojson.MarshalOptions and protojson.UnmarshalOptions
+// counterparts return the encoded message rather than writing it to an
+// io.Writer, and take it rather than reading it from an io.Reader.
+func jsonpbMethodCalls(pass *analysis.Pass, file *ast.File) []jsonpbMethodCall {
+	// Only calls that make up a whole statement in a statement list can be
+	// expanded into several statements, or are the whole result of a return
+	// statement in one.
+	stmts := map[*ast.CallExpr]ast.Stmt{}
+	returns := map[*ast.CallExpr]*ast.ReturnStmt{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		var list []ast.Stmt
+		switch node := node.(type) {
+		case *ast.BlockStmt:
+			list = node.List
+		case *ast.CaseClause:
+			list = node.Body
+		case *ast.CommClause:
+			list = node.Body
+		default:
+			return true
+		}
+		for _, stmt := range list {
+			switch stmt := stmt.(type) {
+			case *ast.ExprStmt:
+				if call, ok := stmt.X.(*ast.CallExpr); ok {
+					stmts[call] = stmt
+				}
+			case *ast.AssignStmt:
+				if len(stmt.Rhs) != 1 {
+					continue
+				}
+				if call, ok := stmt.Rhs[0].(*ast.CallExpr); ok {
+					stmts[call] = stmt
+				}
+			case *ast.ReturnStmt:
+				if len(stmt.Results) != 1 {
+					continue
+				}
+				if call, ok := stmt.Results[0].(*ast.CallExpr); ok {
+					returns[call] = stmt
+				}
+			}
+		}
+		return true
+	})
+
+	const readMsg = "(*jsonpb.Unmarshaler).Unmarshal reads from an io.Reader, protojson.UnmarshalOptions.Unmarshal takes the encoded message instead"
+	var (
+		calls []jsonpbMethodCall
+		reads []*ast.CallExpr
+	)
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if isJSONPBMethod(pass, sel, "Marshaler", "MarshalToString") {
+			c := jsonpbMethodCall{call: call, recv: "Marshaler", msg: "(*jsonpb.Marshaler).MarshalToString should be replaced with protojson.MarshalOptions.Marshal, whose result is converted to a string"}
+			stmt, ok := stmts[call]
+			if ret, isReturn := returns[call]; isReturn {
+				stmt, ok = ret, true
+			}
+			if t := pass.TypesInfo.TypeOf(call.Args[0]); !hasProtoReflect(t) {
+				c.msg += v1MessageNote(pass, t)
+			} else if ok {
+				if edits, ok := marshalToString(pass, stmt, call, sel); ok {
+					c.fix, c.edits = "Marshal the message and convert it to a string", edits
+				}
+			}
+			calls = append(calls, c)
+			return true
+		}
+		if isJSONPBMethod(pass, sel, "Unmarshaler", "Unmarshal") {
+			c := jsonpbMethodCall{call: call, recv: "Unmarshaler", msg: readMsg}
+			if _, ok := stmts[call]; !ok || len(call.Args) != 2 || !isSimpleExpr(call.Args[0]) {
+				calls = append(calls, c)
+			} else if t := pass.TypesInfo.TypeOf(call.Args[1]); !hasProtoReflect(t) {
+				c.msg += v1MessageNote(pass, t)
+				calls = append(calls, c)
+			} else {
+				reads = append(reads, call)
+			}
+			return true
+		}
+		if !isJSONPBMethod(pass, sel, "Marshaler", "Marshal") {
+			return true
+		}
+
+		c := jsonpbMethodCall{call: call, recv: "Marshaler", msg: "(*jsonpb.Marshaler).Marshal writes to an io.Writer, protojson.MarshalOptions.Marshal returns the encoded message instead"}
+		stmt, ok := stmts[call]
+		if !ok || len(call.Args) != 2 || !isSimpleExpr(call.Args[0]) {
+			calls = append(calls, c)
+			return true
+		}
+		if t := pass.TypesInfo.TypeOf(call.Args[1]); !hasProtoReflect(t) {
+			c.msg += v1MessageNote(pass, t)
+			calls = append(calls, c)
+			return true
+		}
+		c.fix, c.edits = "Marshal the message and write it", marshalAndWrite(pass, stmt, call)
+		calls = append(calls, c)
+		return true
+	})
+	if len(reads) == 0 {
+		return calls
+	}
+
+	// The fixes of the reads share the import of the package of ReadAll,
+	// which the first one adds: io, or io/ioutil before Go 1.16. They are
+	// all fixed or left alone along with the jsonpb.Unmarshaler type.
+	readPath := "io/ioutil"
+	if isModuleGoVersion(pass, file, 16) {
+		readPath = "io"
+	}
+	rw := stdImport(pass, file, readPath)
+	for i, call := range reads {
+		c := jsonpbMethodCall{call: call, recv: "Unmarshaler", msg: readMsg}
+		if rw != nil {
+			c.fix, c.edits = "Read the message and unmarshal it", readAndUnmarshal(pass, stmts[call], call, rw.qualifier(pass, readPath))
+			if i == 0 {
+				c.edits = append(c.edits, rw.importEdits(pass)...)
+			}
+		}
+		calls = append(calls, c)
+	}
+	return calls
+}
+
+// keepJSONPBTypes adds to kept the names of the jsonpb types whose values
+// file uses in a way the fixes do not rewrite: calls of their methods
+// that calls does not fix, method values, fields other than Indent, which
+// protojson.MarshalOptions has too, and composite literals with options
+// that cannot be translated.
+func keepJSONPBTypes(pass *analysis.Pass, file *ast.File, calls []jsonpbMethodCall, kept map[string]bool) {
+	fixed := map[ast.Expr]bool{}
+	for _, c := range calls {
+		if c.edits != nil {
+			fixed[c.call.Fun] = true
+		}
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			s, ok := pass.TypesInfo.Selections[node]
+			if !ok {
+				return true
+			}
+			name, ok := jsonpbRecv(s)
+			if !ok {
+				return true
+			}
+			if s.Kind() == types.FieldVal && s.Obj().Name() != "Indent" || s.Kind() != types.FieldVal && !fixed[node] {
+				kept[name] = true
+			}
+		case *ast.CompositeLit:
+			t := pass.TypesInfo.TypeOf(node)
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			named, ok := t.(*types.Named)
+			if !ok || named.Obj().Pkg() == nil || pkgPath(named.Obj().Pkg()) != jsonpbPath {
+				return true
+			}
+			name := named.Obj().Name()
+			if _, ok := jsonpbTypes[name]; !ok {
+				return true
+			}
+			for _, elt := range node.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok && !translatesOption(pass, name, kv) {
+					kept[name] = true
+				}
+			}
+		}
+		return true
+	})
+}
+
+// reportJSONPBMethodCalls reports calls, with their fixes but for those of
+// the methods of the types kept holds.
+func reportJSONPBMethodCalls(pass *analysis.Pass, calls []jsonpbMethodCall, kept map[string]bool) {
+	for _, c := range calls {
+		switch {
+		case c.edits == nil:
+			report.Report(pass, c.call, c.msg)
+		case kept[c.recv]:
+			report.Report(pass, c.call, fmt.Sprintf("%s; jsonpb.%s is kept, since other uses of its values cannot be rewritten", c.msg, c.recv))
+		default:
+			report.Report(pass, c.call, c.msg, report.Fixes(edit.Fix(c.fix, c.edits...)))
+		}
+	}
+}
+
+// marshalToString returns the edits replacing call, a call of the
+// MarshalToString method selected by sel, with one of Marshal converting
+// its result to a string, as the statement stmt made up of the call uses
+// it, if it is a statement the edits can expand.
+func marshalToString(pass *analysis.Pass, stmt ast.Stmt, call *ast.CallExpr, sel *ast.SelectorExpr) ([]analysis.TextEdit, bool) {
+	rename := edit.ReplaceWithString(pass.Fset, sel.Sel, "Marshal")
+	indent := indentation(pass, stmt.Pos())
+	end := lineEnd(pass, stmt.End())
+	switch stmt := stmt.(type) {
+	case *ast.ExprStmt:
+		return []analysis.TextEdit{rename}, true
+	case *ast.ReturnStmt:
+		b := freshName(pass, stmt.Pos(), "b", call)
+		err := freshName(pass, stmt.Pos(), "err", call)
+		return []analysis.TextEdit{
+			edit.ReplaceWithString(pass.Fset, edit.Range{stmt.Pos(), call.Pos()}, fmt.Sprintf("%s, %s := ", b, err)),
+			rename,
+			insert(end, fmt.Sprintf("\n%sreturn string(%s), %s", indent, b, err)),
+		}, true
+	case *ast.AssignStmt:
+		if len(stmt.Lhs) != 2 || !isSimpleExpr(stmt.Lhs[0]) {
+			return nil, false
+		}
+		lhs := stmt.Lhs[0]
+		if id, ok := lhs.(*ast.Ident); ok && id.Name == "_" {
+			return []analysis.TextEdit{rename}, true
+		}
+		b := freshName(pass, stmt.Pos(), "b", call)
+		s := report.Render(pass, lhs)
+		edits := []analysis.TextEdit{rename}
+		tok := "="
+		if stmt.Tok == token.DEFINE {
+			edits = append(edits, edit.ReplaceWithString(pass.Fset, lhs, b))
+			if id, ok := lhs.(*ast.Ident); ok && pass.TypesInfo.Defs[id] != nil {
+				tok = ":="
+			}
+		} else {
+			edits = append(edits, edit.ReplaceWithString(pass.Fset, lhs, fmt.Sprintf("var %s []byte\n%s%s", b, indent, b)))
+		}
+		return append(edits, insert(end, fmt.Sprintf("\n%s%s %s string(%s)", indent, s, tok, b))), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+)
+
+const grpcPath = "google.golang.org/grpc"
+
+// checkLegacyGRPC reports the files generated by protoc-gen-go with
+// plugins=grpc, which hold the stubs of the gRPC services of their .proto
+// files along with the code of their messages. protoc-gen-go of
+// google.golang.org/protobuf no longer generates them, so regenerating the
+// files drops the services, which protoc-gen-go-grpc has to generate
+// separately, into _grpc.pb.go files. The services are those of the
+// grpc.ServiceDesc variables of the files.
+func checkLegacyGRPC(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		gen, ok := Generator(pass, file.Pos())
+		if !ok || gen != facts.ProtocGenGo && gen != facts.ProtocGenGoV2 {
+			continue
+		}
+		services := grpcServices(pass, file)
+		if len(services) == 0 {
+			continue
+		}
+		name := filepath.Base(pass.Fset.PositionFor(file.Pos(), false).Filename)
+		pass.Report(analysis.Diagnostic{
+			Pos:     file.Package,
+			End:     file.Name.End(),
+			Message: fmt.Sprintf("file %s was generated by protoc-gen-go with plugins=grpc, which protoc-gen-go of google.golang.org/protobuf no longer takes: the gRPC services %s have to be generated separately with protoc-gen-go-grpc, into %s", name, strings.Join(services, ", "), strings.TrimSuffix(name, ".pb.go")+"_grpc.pb.go"),
+		})
+	}
+	return nil, nil
+}
+
+// grpcServices returns the full names of the gRPC services of the
+// grpc.ServiceDesc variables declared in file, in order of declaration.
+func grpcServices(pass *analysis.Pass, file *ast.File) []string {
+	var services []string
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			for _, value := range spec.(*ast.ValueSpec).Values {
+				lit, ok := value.(*ast.CompositeLit)
+				if !ok || !isGRPCServiceDesc(pass.TypesInfo.TypeOf(lit)) {
+					continue
+				}
+				for _, elt := range lit.Elts {
+					kv, ok := elt.(*ast.KeyValueExpr)
+					if !ok {
+						continue
+					}
+					if key, ok := kv.Key.(*ast.Ident); !ok || key.Name != "ServiceName" {
+						continue
+					}
+					if tv := pass.TypesInfo.Types[kv.Value]; tv.Value != nil && tv.Value.Kind() == constant.String {
+						services = append(services, constant.StringVal(tv.Value))
+					}
+				}
+			}
+		}
+	}
+	return services
+}
+
+// isGRPCServiceDesc reports whether t is grpc.ServiceDesc.
+func isGRPCServiceDesc(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == grpcPath && obj.Name() == "ServiceDesc"
+}
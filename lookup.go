@@ -0,0 +1,186 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const protoregistryPath = "google.golang.org/protobuf/reflect/protoregistry"
+
+// rewriteFileDescriptor reports the calls of proto.FileDescriptor, whose
+// gzipped FileDescriptorProto has no mechanical replacement: the users of
+// the bytes unzip and unmarshal them to get at what the v2 registry returns
+// already.
+func rewriteFileDescriptor(c *funcCall) bool {
+	report.Report(c.pass, c.sel, "proto.FileDescriptor should be replaced with protoregistry.GlobalFiles.FindFileByPath, which returns a protoreflect.FileDescriptor rather than the gzipped bytes of a FileDescriptorProto; protodesc.ToFileDescriptorProto converts it back where the proto is needed")
+	return false
+}
+
+// rewriteEnumValueMap reports the calls of proto.EnumValueMap, whose maps
+// of the names of the values to their numbers the v2 registry does not
+// keep: the values are looked up in the descriptor of the enum instead.
+func rewriteEnumValueMap(c *funcCall) bool {
+	report.Report(c.pass, c.sel, "proto.EnumValueMap should be replaced with protoregistry.GlobalTypes.FindEnumByName, which returns a protoreflect.EnumType rather than a map; the values are looked up with Descriptor().Values().ByName")
+	return false
+}
+
+// rewriteMessageType rewrites the calls of proto.MessageType assigned to a
+// variable that is only compared to nil and used to make new messages, as
+// in
+//
+//	t := proto.MessageType(name)
+//	if t == nil { ... }
+//	m := reflect.New(t.Elem()).Interface().(proto.Message)
+//
+// The lookup becomes one of protoregistry.GlobalTypes, which returns a
+// protoreflect.MessageType rather than a reflect.Type, and the new messages
+// are made by its New method. The error it returns is dropped, since it
+// only tells that the message was not found, as the nil value does.
+func rewriteMessageType(c *funcCall) bool {
+	const msg = "proto.MessageType should be replaced with protoregistry.GlobalTypes.FindMessageByName, which returns a protoreflect.MessageType rather than a reflect.Type; its New method makes new messages"
+	if c.call == nil || len(c.call.Args) != 1 {
+		report.Report(c.pass, c.sel, msg)
+		return false
+	}
+	assign, ok := c.path[1].(*ast.AssignStmt)
+	if !ok || assign.Tok != token.DEFINE || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	id, ok := assign.Lhs[0].(*ast.Ident)
+	obj := c.pass.TypesInfo.Defs[id]
+	if !ok || obj == nil {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+
+	var (
+		edits []analysis.TextEdit
+		news  int
+	)
+	for use, o := range c.pass.TypesInfo.Uses {
+		if o != obj {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(c.rw.file, use.Pos(), use.End())
+		if comparedToNil(c.pass, path[1]) {
+			continue
+		}
+		newCall, ok := reflectNewElem(c.pass, path)
+		if !ok {
+			report.Report(c.pass, c.call, msg)
+			return false
+		}
+		edits = append(edits, edit.ReplaceWithString(c.pass.Fset, newCall, id.Name+".New()"))
+		news++
+	}
+
+	// The reflect import goes away with the last of its uses.
+	if news > 0 {
+		var refs []*ast.SelectorExpr
+		spec := findImport(c.rw.file, "reflect")
+		if spec != nil {
+			if pkg := importedPkgName(c.pass, spec); pkg != nil {
+				refs = qualifiedRefs(c.pass, c.rw.file, pkg)
+			}
+		}
+		switch {
+		case len(refs) == news:
+			edits = append(edits, deleteLines(c.pass, spec))
+		case !keepsReflect(c.pass, refs):
+			// The other uses are made by other lookups, and whichever
+			// fix goes last could not tell that it is.
+			report.Report(c.pass, c.call, msg)
+			return false
+		}
+	}
+
+	c.rw.require(c.pass, protoregistryPath)
+	name := report.Render(c.pass, c.call.Args[0])
+	if !isUntypedConst(c.pass, c.call.Args[0]) {
+		c.rw.require(c.pass, protoreflectPath)
+		name = c.rw.qualifier(c.pass, protoreflectPath) + ".FullName(" + name + ")"
+	}
+	repl := c.rw.qualifier(c.pass, protoregistryPath) + ".GlobalTypes.FindMessageByName(" + name + ")"
+	edits = append(edits,
+		insert(id.End(), ", _"),
+		edit.ReplaceWithString(c.pass.Fset, c.call, repl),
+	)
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Use protoregistry.GlobalTypes.FindMessageByName", edits...)))
+	return true
+}
+
+// comparedToNil reports whether node compares an expression to nil.
+func comparedToNil(pass *analysis.Pass, node ast.Node) bool {
+	bin, ok := node.(*ast.BinaryExpr)
+	if !ok || (bin.Op != token.EQL && bin.Op != token.NEQ) {
+		return false
+	}
+	for _, x := range []ast.Expr{bin.X, bin.Y} {
+		if tv, ok := pass.TypesInfo.Types[x]; ok && tv.IsNil() {
+			return true
+		}
+	}
+	return false
+}
+
+// reflectNewElem returns the call of reflect.New making a new value of the
+// type a reflect.Type of a message pointer points to, given the path
+// enclosing the use of the type that the call makes, as in
+// reflect.New(t.Elem()).Interface(). ok is false if the use is not such a
+// call.
+func reflectNewElem(pass *analysis.Pass, path []ast.Node) (call *ast.CallExpr, ok bool) {
+	if len(path) < 6 {
+		return nil, false
+	}
+	elem, ok := path[1].(*ast.SelectorExpr)
+	if !ok || elem.Sel.Name != "Elem" {
+		return nil, false
+	}
+	if elemCall, ok := path[2].(*ast.CallExpr); !ok || elemCall.Fun != elem || len(elemCall.Args) != 0 {
+		return nil, false
+	}
+	call, ok = path[3].(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || !isReflectNew(pass, call.Fun) {
+		return nil, false
+	}
+	iface, ok := path[4].(*ast.SelectorExpr)
+	if !ok || iface.Sel.Name != "Interface" {
+		return nil, false
+	}
+	if ifaceCall, ok := path[5].(*ast.CallExpr); !ok || ifaceCall.Fun != iface {
+		return nil, false
+	}
+	return call, true
+}
+
+// isReflectNew reports whether fun is the function reflect.New.
+func isReflectNew(pass *analysis.Pass, fun ast.Expr) bool {
+	sel, ok := astutil.Unparen(fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	obj, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func)
+	return ok && obj.Pkg() != nil && obj.Pkg().Path() == "reflect" && obj.Name() == "New"
+}
+
+// keepsReflect reports whether some of refs, the references through the
+// reflect import, are not calls of reflect.New that a lookup rewrite could
+// remove, so that the import stays used.
+func keepsReflect(pass *analysis.Pass, refs []*ast.SelectorExpr) bool {
+	for _, sel := range refs {
+		if !isReflectNew(pass, sel) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/report"
+)
+
+// mapKeyNote completes the diagnostics of checkMapKey.
+const mapKeyNote = ", which are not comparable once generated for the v2 API; key on the serialized message or on a field identifying it instead"
+
+// checkMapKey reports the map types keyed by message structs, or by values
+// holding them, and the comparisons of such values with == and !=, as in
+// sets of messages. They only compile with the messages generated for the
+// v1 API: those of the v2 API hold an uncomparable field, to keep their
+// internal state from being compared.
+func checkMapKey(pass *analysis.Pass) (interface{}, error) {
+	nodeFilter := []ast.Node{
+		(*ast.MapType)(nil),
+		(*ast.BinaryExpr)(nil),
+	}
+	preorderMigrated(pass, nodeFilter, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.MapType:
+			t := pass.TypesInfo.TypeOf(node.Key)
+			if t == nil {
+				return
+			}
+			if path := copiedMessage(pass, t, nil); path != "" {
+				report.Report(pass, node.Key, "the map is keyed by message structs: "+path+mapKeyNote)
+			}
+		case *ast.BinaryExpr:
+			if node.Op != token.EQL && node.Op != token.NEQ {
+				return
+			}
+			t := pass.TypesInfo.TypeOf(node.X)
+			if t == nil {
+				return
+			}
+			if path := copiedMessage(pass, t, nil); path != "" {
+				report.Report(pass, node, node.Op.String()+" compares message structs: "+path+mapKeyNote)
+			}
+		}
+	})
+	return nil, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/report"
+)
+
+// checkMarshalers reports the methods of messages implementing the
+// proto.Marshaler, proto.Unmarshaler and proto.Merger interfaces of the v1
+// API, as protoc-gen-gogo generates and hand-rolled fast paths add. The v2
+// runtime only calls them for the messages not generated for the v2 API,
+// and ignores them once the messages are.
+func checkMarshalers(pass *analysis.Pass) (interface{}, error) {
+	forEachFile(pass, func(pass *analysis.Pass, file *ast.File) {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Recv == nil {
+				continue
+			}
+			fn, ok := pass.TypesInfo.Defs[decl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			sig := fn.Type().(*types.Signature)
+			if !hasV1Methods(sig.Recv().Type()) {
+				continue
+			}
+			iface := v1CodecInterface(fn.Name(), sig)
+			if iface == "" {
+				continue
+			}
+			recv := sig.Recv().Type()
+			if p, ok := recv.(*types.Pointer); ok {
+				recv = p.Elem()
+			}
+			name := recv.String()
+			if named, ok := recv.(*types.Named); ok {
+				name = named.Obj().Name()
+			}
+			report.Report(pass, decl.Name, name+" implements "+iface+" of the v1 API, which the v2 runtime ignores for the messages generated for the v2 API; drop the customization once "+name+" is regenerated, since the generated code has fast paths of its own through protoimpl")
+		}
+	})
+	return nil, nil
+}
+
+// v1CodecInterface returns the v1 interface a method of the given name and
+// signature implements, or "".
+func v1CodecInterface(name string, sig *types.Signature) string {
+	params, results := sig.Params(), sig.Results()
+	switch name {
+	case "Marshal":
+		if params.Len() == 0 && results.Len() == 2 && isByteSlice(results.At(0).Type()) && isError(results.At(1).Type()) {
+			return "proto.Marshaler"
+		}
+	case "Unmarshal":
+		if params.Len() == 1 && isByteSlice(params.At(0).Type()) && results.Len() == 1 && isError(results.At(0).Type()) {
+			return "proto.Unmarshaler"
+		}
+	case "Merge":
+		if params.Len() == 1 && types.IsInterface(params.At(0).Type()) && hasV1Methods(params.At(0).Type()) && results.Len() == 0 {
+			return "proto.Merger"
+		}
+	}
+	return ""
+}
+
+// isByteSlice reports whether t is []byte.
+func isByteSlice(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	b, ok := s.Elem().Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Byte
+}
+
+// isError reports whether t is the error interface.
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
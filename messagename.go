@@ -0,0 +1,147 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+// rewriteMessageName rewrites the calls of proto.MessageName to the v2
+// proto.MessageName, or, before v1.26 of the v2 module, which added it, to
+// the full name of the message descriptor. Both are a protoreflect.FullName
+// rather than a string, so the result is converted to a string where one
+// is required: everywhere but in comparisons with untyped constants and in
+// arguments of interface types, as of fmt.Printf.
+func rewriteMessageName(c *funcCall) bool {
+	const msg = "proto.MessageName should be replaced with the v2 proto.MessageName, which returns a protoreflect.FullName"
+	if c.call == nil || len(c.call.Args) != 1 {
+		report.Report(c.pass, c.sel, msg)
+		return false
+	}
+	m := c.call.Args[0]
+	if t := c.pass.TypesInfo.TypeOf(m); !hasProtoReflect(t) {
+		report.Report(c.pass, c.call, msg+v1MessageNote(c.pass, t))
+		return false
+	}
+
+	var edits []analysis.TextEdit
+	if c.hasV2MessageName() {
+		c.rw.require(c.pass, protoV2Path)
+		edits = append(edits, edit.ReplaceWithString(c.pass.Fset, c.sel, c.rw.qualifier(c.pass, protoV2Path)+".MessageName"))
+	} else {
+		x := report.Render(c.pass, m)
+		if !isOperand(m) {
+			x = "(" + x + ")"
+		}
+		edits = append(edits, edit.ReplaceWithString(c.pass.Fset, c.call, x+".ProtoReflect().Descriptor().FullName()"))
+	}
+	fix := "Use the v2 proto.MessageName"
+	full := msg
+	if c.messageNameNeedsString() {
+		full += ", so it is converted to the string required here"
+		fix += " converted to a string"
+		edits = append(edits, insert(c.call.Pos(), "string("), insert(c.call.End(), ")"))
+	}
+	report.Report(c.pass, c.call, full, report.Fixes(edit.Fix(fix, edits...)))
+	return true
+}
+
+// hasV2MessageName reports whether the v2 proto package the v1 one wraps has
+// MessageName.
+func (c *funcCall) hasV2MessageName() bool {
+	obj := c.pass.TypesInfo.Uses[c.sel.Sel]
+	if obj == nil || obj.Pkg() == nil {
+		return false
+	}
+	v2 := importedPackage(obj.Pkg(), protoV2Path)
+	if v2 == nil {
+		v2 = importedPackage(c.pass.Pkg, protoV2Path)
+	}
+	return v2 != nil && v2.Scope().Lookup("MessageName") != nil
+}
+
+// messageNameNeedsString reports whether the result of the call needs
+// converting to a string once it is a protoreflect.FullName.
+func (c *funcCall) messageNameNeedsString() bool {
+	switch parent := c.path[1].(type) {
+	case *ast.ExprStmt:
+		return false
+	case *ast.BinaryExpr:
+		other := parent.Y
+		if other == c.call {
+			other = parent.X
+		}
+		switch parent.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return !isUntypedConst(c.pass, other)
+		}
+		return true
+	case *ast.SwitchStmt:
+		for _, stmt := range parent.Body.List {
+			for _, x := range stmt.(*ast.CaseClause).List {
+				if !isUntypedConst(c.pass, x) {
+					return true
+				}
+			}
+		}
+		return false
+	case *ast.CallExpr:
+		if tv, ok := c.pass.TypesInfo.Types[parent.Fun]; ok && tv.IsType() {
+			// A conversion already.
+			return false
+		}
+		sig, ok := c.pass.TypesInfo.TypeOf(parent.Fun).Underlying().(*types.Signature)
+		if !ok {
+			return true
+		}
+		for i, arg := range parent.Args {
+			if arg != c.call {
+				continue
+			}
+			var t types.Type
+			switch {
+			case sig.Variadic() && i >= sig.Params().Len()-1:
+				t = sig.Params().At(sig.Params().Len() - 1).Type().(*types.Slice).Elem()
+			case i < sig.Params().Len():
+				t = sig.Params().At(i).Type()
+			default:
+				return true
+			}
+			return !types.IsInterface(t)
+		}
+		return true
+	}
+	return true
+}
+
+// isUntypedConst reports whether expr is a literal or a named untyped
+// constant, which converts to any string type.
+func isUntypedConst(pass *analysis.Pass, expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return isUntypedConst(pass, expr.X)
+	case *ast.Ident, *ast.SelectorExpr:
+		var id *ast.Ident
+		if sel, ok := expr.(*ast.SelectorExpr); ok {
+			id = sel.Sel
+		} else {
+			id = expr.(*ast.Ident)
+		}
+		obj, ok := pass.TypesInfo.Uses[id].(*types.Const)
+		if !ok {
+			return false
+		}
+		b, ok := obj.Type().(*types.Basic)
+		return ok && b.Info()&types.IsUntyped != 0
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package migrate runs the migration of protomigrate on packages loaded
+// with go/packages, and returns the edits of the files it fixes, for the
+// refactoring tools embedding it rather than running the protomigrate
+// command or an analysis driver.
+//
+//	pkgs, err := migrate.Load([]string{"./..."}, false)
+//	if err != nil {
+//		return err
+//	}
+//	res, err := new(migrate.Migrator).Migrate(pkgs)
+//	if err != nil {
+//		return err
+//	}
+//	for _, f := range res.Files {
+//		// Apply f.Edits, or write f.Content to f.Name.
+//	}
+package migrate
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
+)
+
+// A Migrator migrates packages with the analyzers of protomigrate.
+type Migrator struct {
+	// Analyzers are the analyzers run, protomigrate.Analyzer if empty, or
+	// some of those protomigrate.GroupAnalyzers returns.
+	Analyzers []*analysis.Analyzer
+
+	// Flags are set on the analyzers before they run, by name, like
+	// "fix-level" to "safe". The flags of protomigrate are global, so
+	// they stay set for the Migrators run after.
+	Flags map[string]string
+
+	// Severities maps the IDs of rules to the severity of their findings,
+	// warning for those it does not list.
+	Severities map[string]string
+}
+
+// An Edit replaces the bytes from offset Start to End of a file by Text.
+type Edit struct {
+	Start, End int
+	Text       string
+}
+
+// A File is a file changed by the fixes of a migration.
+type File struct {
+	Name string
+
+	// Edits are the edits of the fixes, sorted by offset, in terms of the
+	// contents of the file before the migration. They do not overlap.
+	Edits []Edit
+
+	// Content is the content of the file once migrated: the edits
+	// applied, then formatted with gofmt.
+	Content []byte
+}
+
+// A Result is the result of a migration.
+type Result struct {
+	// Report holds all the findings, sorted by position.
+	Report
+
+	// Files are the files changed by the fixes, sorted by name.
+	Files []*File
+
+	// Skipped counts the fixes left out because they conflict with
+	// others; migrating the files changed again applies them.
+	Skipped int
+}
+
+// Load loads the packages matching patterns as Migrate needs them: with
+// the syntax of all their dependencies and their modules. The test
+// variants of the packages are included if tests is set.
+func Load(patterns []string, tests bool) ([]*packages.Package, error) {
+	return checker.Load(patterns, tests)
+}
+
+// Migrate runs the analyzers of m on pkgs, which have to be loaded with
+// packages.LoadAllSyntax and share a file set, as Load loads them, and
+// returns the files their fixes change, without writing them. The go.mod
+// files of the modules of the packages are left to the caller, which
+// needs google.golang.org/protobuf v1.20.0 or later.
+func (m *Migrator) Migrate(pkgs []*packages.Package) (*Result, error) {
+	analyzers := m.Analyzers
+	if len(analyzers) == 0 {
+		analyzers = []*analysis.Analyzer{protomigrate.Analyzer}
+	}
+	for name, value := range m.Flags {
+		for _, a := range analyzers {
+			if a.Flags.Lookup(name) == nil {
+				return nil, fmt.Errorf("analyzer %s has no flag -%s", a.Name, name)
+			}
+			if err := a.Flags.Set(name, value); err != nil {
+				return nil, fmt.Errorf("invalid value %q for flag -%s: %v", value, name, err)
+			}
+		}
+	}
+	res := &Result{Report: Report{Findings: []Finding{}}}
+	if len(pkgs) == 0 {
+		return res, nil
+	}
+	diags, err := checker.Run(pkgs, analyzers, nil)
+	if err != nil {
+		return nil, err
+	}
+	fixes, err := checker.ApplyFixes(pkgs[0].Fset, diags)
+	if err != nil {
+		return nil, err
+	}
+	r := NewReporter(pkgs[0].Fset, m.Severities)
+	for _, d := range diags {
+		res.Findings = append(res.Findings, r.Finding(d.Diagnostic, d.Analyzer, d.Package))
+	}
+	for name, content := range fixes.Files {
+		f := &File{Name: name, Content: content}
+		for _, e := range fixes.Edits[name] {
+			f.Edits = append(f.Edits, Edit{e.Start, e.End, e.Text})
+		}
+		res.Files = append(res.Files, f)
+	}
+	sort.Slice(res.Files, func(i, j int) bool {
+		return res.Files[i].Name < res.Files[j].Name
+	})
+	res.Skipped = fixes.Skipped
+	return res, nil
+}
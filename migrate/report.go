@@ -0,0 +1,201 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package migrate
+
+import (
+	"fmt"
+	"go/token"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+)
+
+// A Report holds the findings of a migration. Its JSON form is the output of
+// protomigrate -format=json, whose fields are only ever added to.
+type Report struct {
+	Findings []Finding `json:"diagnostics"`
+}
+
+// A Finding is a finding of a migration.
+type Finding struct {
+	Position
+	End *Position `json:"end,omitempty"`
+
+	// Rule is the ID of the rule of the finding, like PM3001, or the name
+	// of the analyzer that reported it if it has none.
+	Rule string `json:"rule"`
+
+	// Severity is error, warning, the default, or note.
+	Severity string `json:"severity"`
+
+	Message string `json:"message"`
+
+	// Related holds the locations related to the finding, like the
+	// declaration of the deprecated symbol it reports on.
+	Related []Related `json:"related,omitempty"`
+
+	// Fixes are the fixes suggested, the first of which a migration
+	// applies; the findings without one call for a manual migration.
+	Fixes []Fix `json:"fixes,omitempty"`
+
+	// Package is the path of the package the finding is reported in.
+	Package string `json:"package,omitempty"`
+
+	// Generator is the generator of the file of the finding, if it was
+	// generated.
+	Generator *Generator `json:"generator,omitempty"`
+}
+
+// A Position is a position in a file. Lines and columns count from 1, and
+// columns count bytes; offsets count bytes from 0.
+type Position struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+// String returns p in the form file:line:column, as token.Position does.
+func (p Position) String() string {
+	s := p.File
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d", p.Line)
+		if p.Column != 0 {
+			s += fmt.Sprintf(":%d", p.Column)
+		}
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// A Related is a location related to a finding.
+type Related struct {
+	Position
+	Message string `json:"message"`
+}
+
+// A Fix is a fix of a finding.
+type Fix struct {
+	Message string     `json:"message"`
+	Edits   []TextEdit `json:"edits"`
+}
+
+// A TextEdit replaces the text from Start to End by NewText.
+type TextEdit struct {
+	Start   Position `json:"start"`
+	End     Position `json:"end"`
+	NewText string   `json:"new_text"`
+}
+
+// A Generator is the generator of a file, as the header of the file tells.
+type Generator struct {
+	// Name is the name of the generator, like protoc-gen-go.
+	Name string `json:"name"`
+
+	// Version is the version of the generator, like v1.25.0, if the
+	// header of the file gives it.
+	Version string `json:"version,omitempty"`
+
+	// API is the protobuf API the code of protoc-gen-go is generated for,
+	// v1 or v2, and "" for the other generators.
+	API string `json:"api,omitempty"`
+}
+
+// A Reporter turns the diagnostics of analyzers into findings.
+type Reporter struct {
+	fset       *token.FileSet
+	severities map[string]string
+
+	// generators caches the generators of the files, by name, nil for
+	// those not generated.
+	generators map[string]*Generator
+}
+
+// NewReporter returns a Reporter of the diagnostics of positions in fset.
+// severities maps the IDs of rules to the severity of their findings,
+// warning for those it does not list.
+func NewReporter(fset *token.FileSet, severities map[string]string) *Reporter {
+	return &Reporter{fset: fset, severities: severities, generators: map[string]*Generator{}}
+}
+
+// Finding returns the finding of d, reported by analyzer in the package of
+// path pkg.
+func (r *Reporter) Finding(d analysis.Diagnostic, analyzer *analysis.Analyzer, pkg string) Finding {
+	f := Finding{
+		Position: r.position(d.Pos),
+		Rule:     d.Category,
+		Message:  d.Message,
+		Package:  pkg,
+	}
+	if f.Rule == "" {
+		f.Rule = analyzer.Name
+	}
+	f.Severity = r.Severity(f.Rule)
+	if d.End.IsValid() {
+		end := r.position(d.End)
+		f.End = &end
+	}
+	for _, rel := range d.Related {
+		f.Related = append(f.Related, Related{r.position(rel.Pos), rel.Message})
+	}
+	for _, sf := range d.SuggestedFixes {
+		fix := Fix{Message: sf.Message, Edits: []TextEdit{}}
+		for _, e := range sf.TextEdits {
+			end := e.End
+			if !end.IsValid() {
+				end = e.Pos
+			}
+			fix.Edits = append(fix.Edits, TextEdit{
+				Start:   r.position(e.Pos),
+				End:     r.position(end),
+				NewText: string(e.NewText),
+			})
+		}
+		f.Fixes = append(f.Fixes, fix)
+	}
+	f.Generator = r.generator(f.File)
+	return f
+}
+
+// Severity returns the severity of the findings of the rule of ID id.
+func (r *Reporter) Severity(id string) string {
+	if s, ok := r.severities[id]; ok {
+		return s
+	}
+	return "warning"
+}
+
+func (r *Reporter) position(pos token.Pos) Position {
+	p := r.fset.Position(pos)
+	return Position{File: p.Filename, Line: p.Line, Column: p.Column, Offset: p.Offset}
+}
+
+// generator returns the generator of the named file, or nil if it was not
+// generated or by a generator unknown.
+func (r *Reporter) generator(name string) *Generator {
+	if name == "" {
+		return nil
+	}
+	if g, ok := r.generators[name]; ok {
+		return g
+	}
+	var g *Generator
+	if gen, ok := facts.ReadGenerated(name); ok && gen.Generator != facts.Unknown {
+		g = &Generator{Name: gen.Generator.String(), Version: gen.Version}
+		switch gen.Generator {
+		case facts.ProtocGenGo:
+			g.API = "v1"
+		case facts.ProtocGenGoV2:
+			g.API = "v2"
+		}
+	}
+	r.generators[name] = g
+	return g
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"runtime"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// forEachFile calls fn with each file of pass the checks do not skip, on up
+// to GOMAXPROCS goroutines, since the packages generated from large .proto
+// files hold tens of thousands of lines. fn is passed a copy of pass whose
+// findings are held back, then reported in the order of the files, so that
+// the output does not depend on the scheduling. The state fn shares
+// between the files must be read-only.
+func forEachFile(pass *analysis.Pass, fn func(pass *analysis.Pass, file *ast.File)) {
+	var files []*ast.File
+	for _, file := range pass.Files {
+		if !skipFile(pass, file) {
+			files = append(files, file)
+		}
+	}
+
+	diags := make([][]analysis.Diagnostic, len(files))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < runtime.GOMAXPROCS(0) && n < len(files); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				p := *pass
+				p.Report = func(d analysis.Diagnostic) { diags[i] = append(diags[i], d) }
+				fn(&p, files[i])
+			}
+		}()
+	}
+	for i := range files {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	for _, ds := range diags {
+		for _, d := range ds {
+			pass.Report(d)
+		}
+	}
+}
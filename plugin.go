@@ -0,0 +1,82 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// A Plugin is a check of rules of its own, like those of the wrappers of
+// protobuf a company's code uses, that Register adds to the checks of
+// Analyzer. It runs on the same packages, with the results and facts of
+// the analyzers Analyzer requires, and its findings are enabled,
+// suppressed, linked to their documentation and fixed as those of the
+// built-in rules are.
+type Plugin struct {
+	// Rules are the rules of the findings of the plugin. The first is the
+	// default one, the category of the findings reported without one;
+	// the IDs and names must differ from those of the other rules, and
+	// are best not prefixed with PM, which the built-in rules are.
+	Rules []Rule
+
+	// FixLevel is the safety of the fixes of the plugin, safe, the
+	// default, mostly-safe or unsafe, which -fix-level compares to that
+	// of the built-in checks.
+	FixLevel string
+
+	// Group is the name of the analyzer of the group, like pmimports,
+	// that runs the plugin along with Analyzer, or "" for none.
+	Group string
+
+	// Run runs the check on the package of pass, as the Run of an
+	// analyzer, and reports its findings with pass.Report.
+	Run func(*analysis.Pass) (interface{}, error)
+}
+
+// Register adds the check of p to those of Analyzer, after the built-in
+// ones, and its rules to those Rules returns. It has to be called before
+// the flags of Analyzer are parsed, which name the rules, as from an init
+// function of the driver, and returns an error if p is invalid.
+func Register(p Plugin) error {
+	if p.Run == nil {
+		return errors.New("plugin has no Run function")
+	}
+	if len(p.Rules) == 0 {
+		return errors.New("plugin has no rules")
+	}
+	level := fixSafe
+	if p.FixLevel != "" {
+		if err := level.Set(p.FixLevel); err != nil {
+			return err
+		}
+	}
+	if p.Group != "" && !isGroup(p.Group) {
+		return fmt.Errorf("unknown group %q", p.Group)
+	}
+	ids := make([]string, len(p.Rules))
+	for i, r := range p.Rules {
+		if r.ID == "" || r.Name == "" {
+			return fmt.Errorf("rule %q %q has no ID or name", r.ID, r.Name)
+		}
+		for _, other := range p.Rules[:i] {
+			if r.ID == other.ID || r.Name == other.Name || r.ID == other.Name || r.Name == other.ID {
+				return fmt.Errorf("rule %s %s is registered twice", r.ID, r.Name)
+			}
+		}
+		for _, name := range []string{r.ID, r.Name} {
+			if taken, ok := LookupRule(name); ok {
+				return fmt.Errorf("rule %s %s conflicts with rule %s %s", r.ID, r.Name, taken.ID, taken.Name)
+			}
+		}
+		ids[i] = r.ID
+	}
+	rules = append(rules, p.Rules...)
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	checks = append(checks, check{p.Group, ids, level, p.Run})
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+// checkPointerEqual reports the comparisons of messages with == and != where
+// the equality of their content is the likely intent: in tests, and in the
+// cases of switches on messages. The comparisons in tests are rewritten to
+// use proto.Equal; elsewhere a comparison of pointers is often what is
+// meant, as when looking for a message in a list, so they are left alone.
+func checkPointerEqual(pass *analysis.Pass) (interface{}, error) {
+	forEachFile(pass, func(pass *analysis.Pass, file *ast.File) {
+		test := strings.HasSuffix(pass.Fset.File(file.Pos()).Name(), "_test.go")
+		var fixable []*ast.BinaryExpr
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.BinaryExpr:
+				if !test || (node.Op != token.EQL && node.Op != token.NEQ) || !comparesMessages(pass, node.X, node.Y) {
+					return true
+				}
+				tx, ty := pass.TypesInfo.TypeOf(node.X), pass.TypesInfo.TypeOf(node.Y)
+				if !hasProtoReflect(tx) || !hasProtoReflect(ty) {
+					t := tx
+					if hasProtoReflect(t) {
+						t = ty
+					}
+					report.Report(pass, node, node.Op.String()+" compares the pointers to the messages, proto.Equal their content"+v1MessageNote(pass, t))
+					return true
+				}
+				fixable = append(fixable, node)
+			case *ast.SwitchStmt:
+				if node.Tag == nil {
+					return true
+				}
+				for _, stmt := range node.Body.List {
+					for _, x := range stmt.(*ast.CaseClause).List {
+						if comparesMessages(pass, node.Tag, x) {
+							report.Report(pass, x, "the case compares the pointers to the messages, proto.Equal their content")
+						}
+					}
+				}
+			}
+			return true
+		})
+		if len(fixable) == 0 {
+			return
+		}
+
+		// The fixes depend on each other like those of the other checks, so
+		// the first one makes the import edits they share.
+		rw := stdImportRewrite(pass, file, "", len(fixable), protoV2Path)
+		for i, expr := range fixable {
+			msg := expr.Op.String() + " compares the pointers to the messages, proto.Equal their content"
+			if rw == nil {
+				report.Report(pass, expr, msg)
+				continue
+			}
+			call := rw.qualifier(pass, protoV2Path) + ".Equal(" + report.Render(pass, expr.X) + ", " + report.Render(pass, expr.Y) + ")"
+			if expr.Op == token.NEQ {
+				call = "!" + call
+			}
+			edits := []analysis.TextEdit{edit.ReplaceWithString(pass.Fset, expr, call)}
+			if i == 0 {
+				edits = append(edits, rw.importEdits(pass)...)
+			}
+			report.Report(pass, expr, msg, report.Fixes(edit.Fix("Use proto.Equal", edits...)))
+		}
+	})
+	return nil, nil
+}
+
+// comparesMessages reports whether comparing x and y compares two messages,
+// neither of them nil.
+func comparesMessages(pass *analysis.Pass, x, y ast.Expr) bool {
+	for _, expr := range []ast.Expr{x, y} {
+		tv, ok := pass.TypesInfo.Types[astutil.Unparen(expr)]
+		if !ok || tv.IsNil() || !isMessage(tv.Type) {
+			return false
+		}
+	}
+	return true
+}
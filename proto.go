@@ -0,0 +1,202 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const (
+	protoV1Path = "github.com/golang/protobuf/proto"
+	protoV2Path = "google.golang.org/protobuf/proto"
+
+	protoreflectPath = "google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// protoFuncs maps the functions and types of the v1 proto package that
+// have v2 replacements to the functions rewriting the references to them,
+// or reporting them with guidance where no rewrite is mechanical.
+var protoFuncs = map[string]func(*funcCall) bool{
+	"Buffer":            rewriteBuffer,
+	"ClearExtension":    rewriteExtension,
+	"Clone":             rewriteClone,
+	"CompactText":       rewriteCompactText,
+	"CompactTextString": rewriteCompactTextString,
+	"DecodeVarint":      rewriteDecodeVarint,
+	"EncodeVarint":      rewriteEncodeVarint,
+	"EnumName":          rewriteEnumName,
+	"EnumValueMap":      rewriteEnumValueMap,
+	"FileDescriptor":    rewriteFileDescriptor,
+	"GetExtension":      rewriteGetExtension,
+	"HasExtension":      rewriteExtension,
+	"MarshalText":       rewriteMarshalText,
+	"MarshalTextString": rewriteMarshalTextString,
+	"MessageName":       rewriteMessageName,
+	"MessageType":       rewriteMessageType,
+	"MessageV1":         rewriteMessageV1,
+	"MessageV2":         rewriteMessageV2,
+	"NewBuffer":         rewriteBuffer,
+	"RegisterEnum":      rewriteRegisterEnum,
+	"RegisterExtension": rewriteRegisterExtension,
+	"RegisterMapType":   rewriteRegisterMapType,
+	"RegisterType":      rewriteRegisterType,
+	"SetDefaults":       rewriteSetDefaults,
+	"SetExtension":      rewriteSetExtension,
+	"SizeVarint":        rewriteSizeVarint,
+	"UnmarshalJSONEnum": rewriteUnmarshalJSONEnum,
+	"UnmarshalText":     rewriteUnmarshalText,
+}
+
+// checkProto rewrites calls of the v1 proto package's functions.
+func checkProto(pass *analysis.Pass) (interface{}, error) {
+	rewriteCalls(pass, protoV1Path, protoFuncs)
+	return nil, nil
+}
+
+// The families of the functions of the v1 proto package, whose calls the
+// analyzers of their groups rewrite; the others are rewritten by
+// ImportsAnalyzer. Analyzer rewrites them all at once, since the import
+// edits of a file are shared by the calls it rewrites.
+var (
+	textFuncs      = []string{"CompactText", "CompactTextString", "MarshalText", "MarshalTextString", "UnmarshalText"}
+	extensionFuncs = []string{"ClearExtension", "GetExtension", "HasExtension", "RegisterExtension", "SetExtension"}
+	registryFuncs  = []string{"EnumValueMap", "FileDescriptor", "MessageType", "RegisterEnum", "RegisterMapType", "RegisterType"}
+)
+
+// protoFamilyChecks splits checkProto by the families of the functions.
+var protoFamilyChecks = []check{
+	{"pmimports", []string{"PM3001"}, fixMostlySafe, checkProtoOthers},
+	{"pmtext", []string{"PM3001"}, fixMostlySafe, checkProtoText},
+	{"pmextensions", []string{"PM3001"}, fixMostlySafe, checkProtoExtensions},
+	{"pmregistry", []string{"PM3001"}, fixMostlySafe, checkProtoRegistry},
+}
+
+func checkProtoText(pass *analysis.Pass) (interface{}, error) {
+	rewriteCalls(pass, protoV1Path, protoFuncsOf(textFuncs, true))
+	return nil, nil
+}
+
+func checkProtoExtensions(pass *analysis.Pass) (interface{}, error) {
+	rewriteCalls(pass, protoV1Path, protoFuncsOf(extensionFuncs, true))
+	return nil, nil
+}
+
+func checkProtoRegistry(pass *analysis.Pass) (interface{}, error) {
+	rewriteCalls(pass, protoV1Path, protoFuncsOf(registryFuncs, true))
+	return nil, nil
+}
+
+// checkProtoOthers rewrites the calls of the functions of no family.
+func checkProtoOthers(pass *analysis.Pass) (interface{}, error) {
+	var families []string
+	families = append(families, textFuncs...)
+	families = append(families, extensionFuncs...)
+	families = append(families, registryFuncs...)
+	rewriteCalls(pass, protoV1Path, protoFuncsOf(families, false))
+	return nil, nil
+}
+
+// protoFuncsOf returns the entries of protoFuncs for the functions of
+// names, or for the others if in is false.
+func protoFuncsOf(names []string, in bool) map[string]func(*funcCall) bool {
+	listed := map[string]bool{}
+	for _, name := range names {
+		listed[name] = true
+	}
+	funcs := map[string]func(*funcCall) bool{}
+	for name, rewrite := range protoFuncs {
+		if listed[name] == in {
+			funcs[name] = rewrite
+		}
+	}
+	return funcs
+}
+
+func rewriteClone(c *funcCall) bool {
+	const msg = "proto.Clone should be replaced with the v2 proto.Clone, whose result needs a type assertion"
+	if len(c.call.Args) != 1 {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	t := c.pass.TypesInfo.TypeOf(c.call.Args[0])
+	if !hasProtoReflect(t) {
+		report.Report(c.pass, c.call, msg+v1MessageNote(c.pass, t))
+		return false
+	}
+
+	var edits []analysis.TextEdit
+	// The v2 proto.Clone returns a v2 proto.Message, which does not
+	// implement the v1 interface the result was used as, so it is asserted
+	// back to the type of the message unless that is done already.
+	if !c.cloneAsserted() && !isV2Message(t) {
+		typ, ok := typeString(c.pass, c.rw.file, t)
+		if !ok {
+			report.Report(c.pass, c.call, msg)
+			return false
+		}
+		edits = append(edits, insert(c.call.End(), ".("+typ+")"))
+	}
+	c.rw.require(c.pass, protoV2Path)
+	repl := c.rw.qualifier(c.pass, protoV2Path) + ".Clone"
+	edits = append(edits, edit.ReplaceWithString(c.pass.Fset, c.sel, repl))
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Use the v2 proto.Clone", edits...)))
+	return true
+}
+
+// cloneAsserted reports whether the result of the call is asserted to a
+// type, either directly or through the variable it is assigned to.
+func (c *funcCall) cloneAsserted() bool {
+	switch parent := c.path[1].(type) {
+	case *ast.TypeAssertExpr:
+		return true
+	case *ast.AssignStmt:
+		if len(parent.Lhs) != 1 || parent.Tok != token.DEFINE {
+			return false
+		}
+		id, ok := parent.Lhs[0].(*ast.Ident)
+		if !ok {
+			return false
+		}
+		obj := c.pass.TypesInfo.Defs[id]
+		if obj == nil {
+			return false
+		}
+		asserted := false
+		ast.Inspect(c.rw.file, func(node ast.Node) bool {
+			if assert, ok := node.(*ast.TypeAssertExpr); ok {
+				if x, ok := assert.X.(*ast.Ident); ok && c.pass.TypesInfo.Uses[x] == obj {
+					asserted = true
+				}
+			}
+			return !asserted
+		})
+		return asserted
+	default:
+		return false
+	}
+}
+
+// isV2Message reports whether t is the v2 proto.Message interface, which
+// is an alias of protoreflect.ProtoMessage.
+func isV2Message(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Name() == "ProtoMessage" && obj.Pkg() != nil && pkgPath(obj.Pkg()) == protoreflectPath
+}
+
+// rewriteSetDefaults reports the calls of proto.SetDefaults, which has no
+// v2 counterpart: the v2 API never populates fields with their defaults.
+func rewriteSetDefaults(c *funcCall) bool {
+	report.Report(c.pass, c.sel, "proto.SetDefaults has no v2 counterpart; the getters of unset proto2 fields return their defaults, and proto3 fields have none but the zero values, so the call can usually go. Code that needs the defaults set can range over the fields of protoreflect.Message and set those that are unset to FieldDescriptor.Default")
+	return false
+}
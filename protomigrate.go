@@ -10,11 +10,18 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/davecgh/go-spew/spew"
+	"golang.org/x/mod/modfile"
 	"golang.org/x/tools/go/analysis"
-	"golang.org/x/tools/go/analysis/passes/buildssa"
 	"golang.org/x/tools/go/analysis/passes/inspect"
 	"golang.org/x/tools/go/ast/inspector"
 	"honnef.co/go/tools/analysis/report"
@@ -23,110 +30,369 @@ import (
 	"github.com/protobuf-tools/protomigrate/facts"
 )
 
-var spewer = &spew.ConfigState{
-	Indent:                  "  ",
-	SortKeys:                true, // maps should be spewed in a deterministic order
-	DisablePointerAddresses: true, // don't spew the addresses of pointers
-	DisableCapacities:       true, // don't spew capacities of collections
-	ContinueOnMethod:        true, // recursion should continue once a custom error or Stringer interface is invoked
-	SpewKeys:                true, // if unable to sort map keys then spew keys to strings and sort those
-	MaxDepth:                2,    // maximum number of levels to descend into nested data structures.
-}
-
 const doc = "protomigrate migrate Go protobuf v1 usage to protobuf v2"
 
 // Analyzer describes protomigrate analysis function detector.
+//
+// It runs the checks of all the groups, which GroupAnalyzers returns the
+// analyzers of, in a single pass rather than requiring them: the fixes of
+// the calls of the v1 proto package share the import edits of their
+// files, which the analyzers of the groups would conflict over.
+//
+// Like the analyzers of the groups and those of package facts, it can run
+// within gopls and other long-lived drivers: it neither writes to the
+// standard output nor runs commands, and only reads the headers of the
+// files of the package and the .proto files of its generated code, up to
+// a bounded size, beyond what the driver loaded.
 var Analyzer = &analysis.Analyzer{
 	Name: "protomigrate",
 	Doc:  doc,
-	Run:  checkDeprecated,
+	Run:  migrate,
 	Requires: []*analysis.Analyzer{
-		buildssa.Analyzer,
 		inspect.Analyzer,
+		facts.API,
 		facts.Deprecated,
 		facts.Generated,
+		facts.Messages,
+		facts.Protoc,
+		facts.Registry,
 	},
 }
 
-var protoV1Packages = map[string]bool{
-	"github.com/golang/protobuf/descriptor":       true,
-	"github.com/golang/protobuf/jsonpb":           true,
-	"github.com/golang/protobuf/proto":            true,
-	"github.com/golang/protobuf/ptypes":           true,
-	"github.com/golang/protobuf/ptypes/any":       true,
-	"github.com/golang/protobuf/ptypes/duration":  true,
-	"github.com/golang/protobuf/ptypes/empty":     true,
-	"github.com/golang/protobuf/ptypes/struct":    true,
-	"github.com/golang/protobuf/ptypes/timestamp": true,
-	"github.com/golang/protobuf/ptypes/wrappers":  true,
+// Analyzers returns the full set of the analyzers of protomigrate: those of
+// package facts and the others Analyzer requires, directly or not, which
+// come first, then Analyzer. Drivers like multichecker, golangci-lint
+// plugins or vet tools can register them all without knowing which
+// Analyzer requires; the flags of protomigrate are those of Analyzer.
+func Analyzers() []*analysis.Analyzer {
+	var all []*analysis.Analyzer
+	seen := map[*analysis.Analyzer]bool{}
+	var visit func(a *analysis.Analyzer)
+	visit = func(a *analysis.Analyzer) {
+		if seen[a] {
+			return
+		}
+		seen[a] = true
+		for _, req := range a.Requires {
+			visit(req)
+		}
+		all = append(all, a)
+	}
+	visit(Analyzer)
+	return all
 }
 
-func run(pass *analysis.Pass) (interface{}, error) {
-	fmt.Printf("pass: %s\n", spewer.Sdump(pass))
+var (
+	// goVersion is the minor version of the Go release the migrated code
+	// targets.
+	goVersion = versionFlag(15)
 
-	for _, file := range pass.Files {
-		for _, imp := range file.Imports {
-			path, err := strconv.Unquote(imp.Path.Value)
-			if err != nil {
-				return nil, err
-			}
-			fmt.Printf("path: %s\n", path)
-			if protoV1Packages[path] {
-				fmt.Printf("hit: %s\n", path)
-			}
+	// migrateGenerated reports whether generated files are migrated too,
+	// rather than left for regenerating.
+	migrateGenerated bool
+
+	// enabledRules holds the IDs of the rules whose findings are reported,
+	// all if it is empty, and disabledRules those of the rules whose
+	// findings are not.
+	enabledRules  = ruleSet{}
+	disabledRules = ruleSet{}
+
+	// checkedRules holds the IDs of the heuristic rules whose findings
+	// are reported too, which optInRules lists.
+	checkedRules = ruleSet{}
+
+	// ownProtoDeprecations reports whether the uses of deprecated proto
+	// fields and enum values are reported in the package generated from
+	// the .proto file too.
+	ownProtoDeprecations bool
+
+	// extraDeprecated maps the paths of packages that are deprecated
+	// besides those documented so to the deprecation messages.
+	extraDeprecated = deprecatedFlag{}
+
+	// maxFixLevel is the least safe level of the fixes suggested.
+	maxFixLevel = fixUnsafe
+
+	// todoComments reports whether the findings left without a fix get one
+	// adding a TODO comment.
+	todoComments bool
+
+	// strictMode reports whether any use of the v1 API is reported, as in
+	// packages declared fully migrated to the v2 API.
+	strictMode bool
+
+	// docURL is the template of the URLs documenting the rules, with {id}
+	// and {name} standing for those of a rule; empty for the URLs of the
+	// rules, and none for no URL.
+	docURL string
+)
+
+func init() {
+	registerFlags(Analyzer, true)
+}
+
+// registerFlags registers the flags of protomigrate on a, those of the
+// deprecations too if deprecations is set. The analyzers of the groups
+// share them with Analyzer.
+func registerFlags(a *analysis.Analyzer, deprecations bool) {
+	a.Flags.Var(&goVersion, "go", "target Go version in the format '1.x'")
+	a.Flags.BoolVar(&migrateGenerated, "generated", false, "also migrate generated files")
+	a.Flags.Var(enabledRules, "enable", "comma-separated list of the only rules to report, by ID or name")
+	a.Flags.Var(disabledRules, "disable", "comma-separated list of the rules not to report, by ID or name")
+	a.Flags.Var(checkedRules, "check", "comma-separated list of the heuristic rules to report too, by ID or name, like aliasing")
+	im := facts.API.Flags.Lookup("import-map")
+	a.Flags.Var(im.Value, im.Name, im.Usage)
+	if deprecations {
+		db := facts.Deprecated.Flags.Lookup("deprecations")
+		a.Flags.Var(db.Value, db.Name, db.Usage)
+		a.Flags.BoolVar(&ownProtoDeprecations, "own-proto-deprecations", false, "also report the uses of deprecated proto fields and enum values in the package generated from their .proto file")
+		a.Flags.Var(extraDeprecated, "deprecated", "report the imports of a package as deprecated, in the format 'path: message'; may be repeated")
+		a.Flags.BoolVar(&strictMode, "strict", false, "report any use of the v1 API left, in packages declared fully migrated to the v2 API")
+	}
+	a.Flags.Var(&maxFixLevel, "fix-level", "only suggest the fixes that are safe, mostly-safe or below, or unsafe or below")
+	a.Flags.BoolVar(&todoComments, "todo", false, "suggest adding a TODO comment at the findings left without a fix")
+	a.Flags.Var(&debugLevel, "debug", "log the checks run on each package to the standard error, with =trace their findings too")
+	a.Flags.StringVar(&debugDir, "debug-dir", "", "with -debug, log to a file per package in the named `directory` instead")
+	a.Flags.StringVar(&docURL, "doc-url", "", "link the findings to the documentation of their rule at the URL `template`, where {id} and {name} stand for those of the rule, rather than to the protobuf and protomigrate documentation; none for no links")
+}
+
+// fixLevel is the safety of a fix, and a flag.Getter holding the least
+// safe level of the fixes suggested.
+type fixLevel int
+
+const (
+	// fixSafe is the level of the fixes that preserve the semantics of
+	// the code.
+	fixSafe fixLevel = iota
+
+	// fixMostlySafe is the level of the fixes that preserve them but for
+	// some details, like the exact output of a marshaler.
+	fixMostlySafe
+
+	// fixUnsafe is the level of the fixes that change them, which call for
+	// a review.
+	fixUnsafe
+)
+
+var fixLevelNames = []string{"safe", "mostly-safe", "unsafe"}
+
+func (l *fixLevel) String() string {
+	return fixLevelNames[*l]
+}
+
+func (l *fixLevel) Set(s string) error {
+	for i, name := range fixLevelNames {
+		if s == name {
+			*l = fixLevel(i)
+			return nil
 		}
 	}
+	return fmt.Errorf("invalid fix level: %q", s)
+}
 
-	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
-	nodeFilter := []ast.Node{
-		(*ast.BasicLit)(nil),
-		(*ast.BlockStmt)(nil),
-		(*ast.CallExpr)(nil),
-		(*ast.Comment)(nil),
-		(*ast.CommentGroup)(nil),
-		(*ast.DeclStmt)(nil),
-		(*ast.ExprStmt)(nil),
-		(*ast.FieldList)(nil),
-		(*ast.FuncDecl)(nil),
-		(*ast.GenDecl)(nil),
-		(*ast.GenDecl)(nil),
-		(*ast.GenDecl)(nil),
-		(*ast.Ident)(nil),
-		(*ast.ImportSpec)(nil),
-		(*ast.SelectorExpr)(nil),
-		(*ast.ValueSpec)(nil),
+func (l *fixLevel) Get() interface{} {
+	return l.String()
+}
+
+// versionFlag is a flag.Getter holding a Go version, which it gets as its
+// minor version number.
+type versionFlag int
+
+func (v *versionFlag) String() string {
+	return fmt.Sprintf("1.%d", *v)
+}
+
+func (v *versionFlag) Set(s string) error {
+	if !strings.HasPrefix(s, "1.") {
+		return fmt.Errorf("invalid Go version: %q", s)
 	}
-	ins.Preorder(nodeFilter, func(n ast.Node) {
-		switch n := n.(type) {
-		case *ast.Ident:
-			fmt.Printf("n: %T = %#v\n", n, n)
-			if n.Name == "gopher" {
-				pass.Reportf(n.Pos(), "identifier is gopher")
-			}
-		default:
-			fmt.Printf("n: %T = %#v\n", n, n)
-		}
-	})
+	minor, err := strconv.Atoi(s[len("1."):])
+	if err != nil || minor < 0 {
+		return fmt.Errorf("invalid Go version: %q", s)
+	}
+	*v = versionFlag(minor)
+	return nil
+}
 
-	s := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
-	for _, f := range s.SrcFuncs {
-		fmt.Println(f)
-		for _, b := range f.Blocks {
-			fmt.Printf("\tBlock %d\n", b.Index)
-			for _, instr := range b.Instrs {
-				fmt.Printf("\t\t%[1]T\t%[1]T = %[1]v(%[1]p)\n", instr)
-				for _, v := range instr.Operands(nil) {
-					if v != nil {
-						fmt.Printf("\t\t\t%[1]T\t%[1]v(%[1]p)\n", *v)
-					}
+func (v *versionFlag) Get() interface{} {
+	return int(*v)
+}
+
+// deprecatedFlag is a flag.Value mapping package paths to deprecation
+// messages, each set as 'path: message'.
+type deprecatedFlag map[string]string
+
+func (d deprecatedFlag) String() string {
+	paths := make([]string, 0, len(d))
+	for path := range d {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for i, path := range paths {
+		paths[i] = path + ": " + d[path]
+	}
+	return strings.Join(paths, "; ")
+}
+
+func (d deprecatedFlag) Set(v string) error {
+	i := strings.Index(v, ":")
+	if i < 0 {
+		return fmt.Errorf("invalid deprecated package, want 'path: message': %q", v)
+	}
+	d[strings.TrimSpace(v[:i])] = strings.TrimSpace(v[i+1:])
+	return nil
+}
+
+// A check is a check of the rules of IDs rules, whose fixes are of level
+// fix, run by Analyzer and by the analyzer of its group. The first rule is
+// the default one; the others are for findings without a fix, since the
+// fixes of a check depend on each other and are only left out together,
+// which makes the level that of the least safe of them.
+type check struct {
+	group string
+	rules []string
+	fix   fixLevel
+	run   func(*analysis.Pass) (interface{}, error)
+}
+
+// checks lists the checks run by Analyzer, in order. checkProto is run by
+// the analyzers of the families of the functions of the v1 proto package
+// as protoFamilyChecks splits it, and so has no group.
+var checks = []check{
+	{"pmimports", []string{"PM3002"}, fixSafe, checkAdapt},
+	{"pmmessages", []string{"PM8007"}, fixSafe, checkAliasing},
+	{"pmimports", []string{"PM3001"}, fixSafe, checkBuffer},
+	{"pmdeprecated", []string{"PM1002", "PM1001"}, fixSafe, checkDeprecated},
+	{"pmcodegen", []string{"PM7003"}, fixSafe, checkCodegen},
+	{"pmmessages", []string{"PM8003"}, fixSafe, checkCopy},
+	// proto.Equal ignores the internal state of messages DeepEqual sees.
+	{"pmmessages", []string{"PM8001"}, fixMostlySafe, checkDeepEqual},
+	{"pmimports", []string{"PM4001"}, fixMostlySafe, checkDescriptor},
+	// protojson names the fields in lowerCamelCase.
+	{"pmmessages", []string{"PM8002"}, fixUnsafe, checkEncodingJSON},
+	{"pmmessages", []string{"PM8009"}, fixSafe, checkEnumMaps},
+	{"pmcodegen", []string{"PM7002"}, fixUnsafe, checkGoGenerate},
+	{"pmcodegen", []string{"PM7001"}, fixSafe, checkGogo},
+	// The output of protojson is deliberately unstable.
+	{"pmjsonpb", []string{"PM2001", "PM2002", "PM2003"}, fixMostlySafe, checkJSONPB},
+	{"pmcodegen", []string{"PM7006"}, fixSafe, checkLegacyGRPC},
+	{"pmmessages", []string{"PM8005"}, fixSafe, checkMapKey},
+	{"pmmessages", []string{"PM8010"}, fixSafe, checkMarshalers},
+	// Distinct messages with equal contents become equal.
+	{"pmmessages", []string{"PM8004"}, fixUnsafe, checkPointerEqual},
+	// The output of prototext is deliberately unstable, and the errors of
+	// the v2 functions differ.
+	{"", []string{"PM3001"}, fixMostlySafe, checkProto},
+	// The conversions of the methods of the well-known types do not fail
+	// on invalid values.
+	{"pmptypes", []string{"PM5001"}, fixMostlySafe, checkPtypes},
+	{"pmregistry", []string{"PM8008"}, fixSafe, checkRegistry},
+	{"pmcodegen", []string{"PM7005"}, fixSafe, checkStale},
+	{"pmdeprecated", []string{"PM1004"}, fixSafe, checkStrict},
+	{"pmimports", []string{"PM6001"}, fixSafe, checkWKT},
+	{"pmmessages", []string{"PM8006"}, fixSafe, checkXXX},
+}
+
+func migrate(pass *analysis.Pass) (interface{}, error) {
+	return runChecks(pass, checks)
+}
+
+// runChecks runs cs on the package of pass, reporting the findings of the
+// rules enabled, with their categories and documentation, but those
+// suppressed, and leaving out the fixes above -fix-level.
+func runChecks(pass *analysis.Pass, cs []check) (interface{}, error) {
+	log, err := newLogger(pass)
+	if err != nil {
+		return nil, err
+	}
+	defer log.close()
+	log.log(logDebug, "package start", "files", len(pass.Files))
+	if !migrated(pass) {
+		log.log(logDebug, "package skipped", "reason", "imports no protobuf or deprecated package")
+		return nil, nil
+	}
+
+	dirs := directives(pass)
+	todos := map[token.Pos]bool{}
+	for _, c := range cs {
+		enabled := false
+		for _, id := range c.rules {
+			enabled = enabled || RuleEnabled(id)
+		}
+		if !enabled {
+			continue
+		}
+		rule := c.rules[0]
+		name := funcName(c.run)
+		start := time.Now()
+		suppressions := 0
+		p := *pass
+		// The fixes of a check depend on each other, so a suppressed fix
+		// leaves out the others of its file.
+		var diags []analysis.Diagnostic
+		unfixed := map[*token.File]bool{}
+		p.Report = func(d analysis.Diagnostic) {
+			if d.Category == "" {
+				d.Category = rule
+			}
+			if r, ok := LookupRule(d.Category); ok {
+				d.Message += r.docNote()
+			}
+			switch {
+			case !RuleEnabled(d.Category):
+			case suppressed(pass.Fset, dirs, d):
+				log.log(logTrace, "finding suppressed", "check", name, "pos", pass.Fset.Position(d.Pos), "rule", d.Category, "finding", d.Message)
+				suppressions++
+				if len(d.SuggestedFixes) > 0 {
+					unfixed[pass.Fset.File(d.Pos)] = true
 				}
+			default:
+				log.log(logTrace, "finding", "check", name, "pos", pass.Fset.Position(d.Pos), "rule", d.Category, "fixes", len(d.SuggestedFixes), "finding", d.Message)
+				diags = append(diags, d)
 			}
 		}
+		if _, err := c.run(&p); err != nil {
+			log.log(logDebug, "check failed", "check", name, "err", err)
+			return nil, err
+		}
+		log.log(logDebug, "check done", "check", name, "findings", len(diags), "suppressed", suppressions, "elapsed", time.Since(start))
+		for _, d := range diags {
+			if unfixed[pass.Fset.File(d.Pos)] || c.fix > maxFixLevel {
+				d.SuggestedFixes = nil
+			}
+			if len(d.SuggestedFixes) == 0 && todoComments {
+				d.SuggestedFixes = todoFix(pass, d, todos)
+			}
+			pass.Report(d)
+		}
 	}
-
 	return nil, nil
 }
 
+// migrated reports whether the package of pass has anything to migrate: it
+// imports a package of the protobuf runtimes, or one configured as
+// deprecated, directly or not. The checks skip the other packages, most of
+// a large repository, at no cost but walking their imports.
+func migrated(pass *analysis.Pass) bool {
+	configured := map[string]bool{}
+	for path := range extraDeprecated {
+		configured[path] = true
+	}
+	db := facts.Deprecated.Flags.Lookup("deprecations").Value.(flag.Getter).Get().(facts.DeprecationDatabase)
+	for _, d := range db.Deprecated {
+		configured[d.Package] = true
+	}
+	return facts.Imports(pass.Pkg, func(path string) bool {
+		return facts.IsProtoPackage(path) || configured[path]
+	})
+}
+
+// funcName returns the name of the function fn, without its package.
+func funcName(fn interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
 func checkDeprecated(pass *analysis.Pass) (interface{}, error) {
 	deprs := pass.ResultOf[facts.Deprecated].(facts.DeprecatedResult)
 
@@ -134,18 +400,80 @@ func checkDeprecated(pass *analysis.Pass) (interface{}, error) {
 	// declaring package level variables.
 
 	var tfn types.Object
-	stack := 0
+	var file *ast.File
 	fn := func(node ast.Node, push bool) bool {
 		if !push {
-			stack--
+			if _, ok := node.(*ast.FuncDecl); ok {
+				tfn = nil
+			}
 			return false
 		}
-		stack++
-		if stack == 1 {
+		if f, ok := node.(*ast.File); ok {
+			if isGogoFile(pass, f) {
+				// checkGogo reports on the file as a whole.
+				return false
+			}
+			file = f
 			tfn = nil
+			return true
 		}
 		if fn, ok := node.(*ast.FuncDecl); ok {
 			tfn = pass.TypesInfo.ObjectOf(fn.Name)
+			return true
+		}
+		if id, ok := node.(*ast.Ident); ok {
+			// Enum values are used unqualified in their own package.
+			obj, ok := pass.TypesInfo.Uses[id]
+			if !ok || obj.Pkg() != pass.Pkg || obj.Parent() != pass.Pkg.Scope() {
+				return true
+			}
+			depr, ok := deprs.Objects[obj]
+			if !ok || !depr.Proto {
+				return true
+			}
+			if _, ok := Generator(pass, file.Pos()); ok {
+				return true
+			}
+			if _, ok := deprs.Objects[tfn]; ok && tfn != nil {
+				return true
+			}
+			report.Report(pass, id, fmt.Sprintf("%s is deprecated: %s", id.Name, depr.Msg), deprecationRelated(pass, obj, id.Name, depr)...)
+			return true
+		}
+		if lit, ok := node.(*ast.CompositeLit); ok {
+			// The fields of messages are also set through the keys of
+			// composite literals, which are not selectors.
+			for _, elt := range lit.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				key, ok := kv.Key.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				field, ok := pass.TypesInfo.ObjectOf(key).(*types.Var)
+				if !ok || !field.IsField() || field.Pkg() == nil {
+					continue
+				}
+				depr, ok := deprs.Objects[field]
+				if !ok || !depr.Proto {
+					continue
+				}
+				if field.Pkg() == pass.Pkg {
+					if !ownProtoDeprecations {
+						continue
+					}
+					if _, ok := Generator(pass, file.Pos()); ok {
+						continue
+					}
+				}
+				if _, ok := deprs.Objects[tfn]; ok && tfn != nil {
+					continue
+				}
+				report.Report(pass, key, fmt.Sprintf("%s is deprecated: %s", key.Name, depr.Msg), deprecationRelated(pass, field, key.Name, depr)...)
+			}
+			return true
 		}
 		sel, ok := node.(*ast.SelectorExpr)
 		if !ok {
@@ -157,8 +485,16 @@ func checkDeprecated(pass *analysis.Pass) (interface{}, error) {
 			return true
 		}
 		if pass.Pkg == obj.Pkg() || obj.Pkg().Path()+"_test" == pass.Pkg.Path() {
-			// Don't flag stuff in our own package
-			return true
+			// Don't flag stuff in our own package, except for the
+			// deprecations of the .proto file the package is generated
+			// from when asked to, outside of the generated files.
+			depr, ok := deprs.Objects[obj]
+			if !ownProtoDeprecations || !ok || !depr.Proto {
+				return true
+			}
+			if _, ok := Generator(pass, file.Pos()); ok {
+				return true
+			}
 		}
 		if depr, ok := deprs.Objects[obj]; ok {
 			std, ok := knowledge.StdlibDeprecations[SelectorName(pass, sel)]
@@ -203,16 +539,18 @@ func checkDeprecated(pass *analysis.Pass) (interface{}, error) {
 				}
 			}
 
+			name := report.Render(pass, sel)
+			related := deprecationRelated(pass, obj, name, depr)
 			if ok {
 				if std.AlternativeAvailableSince == knowledge.DeprecatedNeverUse {
-					report.Report(pass, sel, fmt.Sprintf("%s has been deprecated since Go 1.%d because it shouldn't be used: %s", report.Render(pass, sel), std.DeprecatedSince, depr.Msg))
+					report.Report(pass, sel, fmt.Sprintf("%s has been deprecated since Go 1.%d because it shouldn't be used: %s", name, std.DeprecatedSince, depr.Msg), related...)
 				} else if std.AlternativeAvailableSince == std.DeprecatedSince || std.AlternativeAvailableSince == knowledge.DeprecatedUseNoLonger {
-					report.Report(pass, sel, fmt.Sprintf("%s has been deprecated since Go 1.%d: %s", report.Render(pass, sel), std.DeprecatedSince, depr.Msg))
+					report.Report(pass, sel, fmt.Sprintf("%s has been deprecated since Go 1.%d: %s", name, std.DeprecatedSince, depr.Msg), related...)
 				} else {
-					report.Report(pass, sel, fmt.Sprintf("%s has been deprecated since Go 1.%d and an alternative has been available since Go 1.%d: %s", report.Render(pass, sel), std.DeprecatedSince, std.AlternativeAvailableSince, depr.Msg))
+					report.Report(pass, sel, fmt.Sprintf("%s has been deprecated since Go 1.%d and an alternative has been available since Go 1.%d: %s", name, std.DeprecatedSince, std.AlternativeAvailableSince, depr.Msg), related...)
 				}
 			} else {
-				report.Report(pass, sel, fmt.Sprintf("%s is deprecated: %s", report.Render(pass, sel), depr.Msg))
+				report.Report(pass, sel, fmt.Sprintf("%s is deprecated: %s", name, depr.Msg), related...)
 			}
 			return true
 		}
@@ -228,34 +566,131 @@ func checkDeprecated(pass *analysis.Pass) (interface{}, error) {
 			imp = pass.TypesInfo.Implicits[spec].(*types.PkgName).Imported()
 		}
 
-		p := spec.Path.Value
-		path := p[1 : len(p)-1]
+		path := writtenPath(spec)
+		if gen, ok := Generator(pass, spec.Path.Pos()); ok && gen == facts.ProtocGenGogo {
+			return
+		}
 		if depr, ok := deprs.Packages[imp]; ok {
-			if path == "github.com/golang/protobuf/proto" {
+			if importPath(spec) == protoV1Path {
 				gen, ok := Generator(pass, spec.Path.Pos())
-				if ok && gen == facts.ProtocGenGo {
+				if ok && (gen == facts.ProtocGenGo || gen == facts.ProtocGenGoV2) {
 					return
 				}
 			}
-			report.Report(pass, spec, fmt.Sprintf("package %s is deprecated: %s", path, depr.Msg))
+			reportRule(pass, spec, "PM1001", fmt.Sprintf("package %s is deprecated: %s", path, depr.Msg))
+		} else if msg, ok := extraDeprecated[path]; ok {
+			reportRule(pass, spec, "PM1001", fmt.Sprintf("package %s is deprecated: %s", path, msg))
 		}
 	}
-	pass.ResultOf[inspect.Analyzer].(*inspector.Inspector).Nodes(nil, fn)
+	nodeFilter := []ast.Node{
+		(*ast.File)(nil),
+		(*ast.FuncDecl)(nil),
+		(*ast.CompositeLit)(nil),
+		(*ast.SelectorExpr)(nil),
+	}
+	if ownProtoDeprecations {
+		// Identifiers are the most common nodes by far, so they are only
+		// visited when they are reported.
+		nodeFilter = append(nodeFilter, (*ast.Ident)(nil))
+	}
+	pass.ResultOf[inspect.Analyzer].(*inspector.Inspector).Nodes(nodeFilter, fn)
 	Preorder(pass, fn2, (*ast.ImportSpec)(nil))
 	return nil, nil
 }
 
+// replacementPattern matches the replacement a deprecation message names,
+// like protoregistry.GlobalTypes.RegisterEnum in "Use
+// protoregistry.GlobalTypes.RegisterEnum instead."
+var replacementPattern = regexp.MustCompile(`\bUse ([A-Za-z_]\w*(?:\.[A-Za-z_]\w*)+) instead\b`)
+
+// deprecationRelated returns the related information of the finding of a
+// use of obj, named name, deprecated by depr: the declaration of obj, and
+// that of its replacement if the message names one that is found.
+func deprecationRelated(pass *analysis.Pass, obj types.Object, name string, depr *facts.IsDeprecated) []report.Option {
+	var related []report.Option
+	if obj.Pos().IsValid() {
+		related = append(related, report.Related(obj, name+" is declared here"))
+	}
+	m := replacementPattern.FindStringSubmatch(depr.Msg)
+	if m == nil {
+		return related
+	}
+	for _, pkg := range []*types.Package{obj.Pkg(), pass.Pkg} {
+		if repl := replacementObject(pkg, m[1]); repl != nil && repl.Pos().IsValid() {
+			return append(related, report.Related(repl, m[1]+" replaces it"))
+		}
+	}
+	return related
+}
+
+// replacementObject returns the object named by name, a qualified
+// identifier of a package pkg imports followed by the names of fields and
+// methods, or nil.
+func replacementObject(pkg *types.Package, name string) types.Object {
+	parts := strings.Split(name, ".")
+	var obj types.Object
+	for _, imp := range pkg.Imports() {
+		if imp.Name() == parts[0] {
+			obj = imp.Scope().Lookup(parts[1])
+			break
+		}
+	}
+	for _, member := range parts[2:] {
+		if obj == nil {
+			return nil
+		}
+		obj, _, _ = types.LookupFieldOrMethod(obj.Type(), true, obj.Pkg(), member)
+	}
+	return obj
+}
+
 func Generator(pass *analysis.Pass, pos token.Pos) (facts.Generator, bool) {
+	g, ok := GeneratedFile(pass, pos)
+	return g.Generator, ok
+}
+
+// GeneratedFile describes the generated file containing pos, if it is one.
+func GeneratedFile(pass *analysis.Pass, pos token.Pos) (facts.GeneratedFile, bool) {
 	file := pass.Fset.PositionFor(pos, false).Filename
-	m := pass.ResultOf[facts.Generated].(map[string]facts.Generator)
+	m := pass.ResultOf[facts.Generated].(map[string]facts.GeneratedFile)
 	g, ok := m[file]
 	return g, ok
 }
 
+// skipFile reports whether the checks leave file alone. Generated files are
+// only migrated with the -generated flag, since regenerating them with a
+// v2 code generator is the better migration, and the files generated by
+// protoc-gen-gogo never are, since it is the only one.
+func skipFile(pass *analysis.Pass, file *ast.File) bool {
+	gen, ok := Generator(pass, file.Pos())
+	if migrateGenerated {
+		return ok && gen == facts.ProtocGenGogo
+	}
+	return ok
+}
+
 func Preorder(pass *analysis.Pass, fn func(ast.Node), typs ...ast.Node) {
 	pass.ResultOf[inspect.Analyzer].(*inspector.Inspector).Preorder(typs, fn)
 }
 
+// preorderMigrated calls fn with the nodes of the types of nodeFilter in the
+// files the checks do not skip. The nodes are visited through the inspector
+// the checks share, which skips the subtrees holding none of them, rather
+// than by walking every file.
+func preorderMigrated(pass *analysis.Pass, nodeFilter []ast.Node, fn func(ast.Node)) {
+	nodeFilter = append([]ast.Node{(*ast.File)(nil)}, nodeFilter...)
+	pass.ResultOf[inspect.Analyzer].(*inspector.Inspector).Nodes(nodeFilter, func(node ast.Node, push bool) bool {
+		if !push {
+			return false
+		}
+		if file, ok := node.(*ast.File); ok {
+			return !skipFile(pass, file)
+		}
+		fn(node)
+		return true
+	})
+}
+
 func IsGoVersion(pass *analysis.Pass, minor int) bool {
 	f, ok := pass.Analyzer.Flags.Lookup("go").Value.(flag.Getter)
 	if !ok {
@@ -265,6 +700,34 @@ func IsGoVersion(pass *analysis.Pass, minor int) bool {
 	return version >= minor
 }
 
+// isModuleGoVersion reports whether the module file belongs to targets Go
+// 1.minor or later, by the go directive of its go.mod file, or by the go
+// flag outside of modules.
+func isModuleGoVersion(pass *analysis.Pass, file *ast.File, minor int) bool {
+	for d := filepath.Dir(pass.Fset.File(file.Pos()).Name()); ; {
+		data, err := ioutil.ReadFile(filepath.Join(d, "go.mod"))
+		if err == nil {
+			f, err := modfile.ParseLax(filepath.Join(d, "go.mod"), data, nil)
+			if err != nil || f.Go == nil {
+				break
+			}
+			// From Go 1.21 on, the directive may name a patch release.
+			fields := strings.SplitN(f.Go.Version, ".", 3)
+			var version versionFlag
+			if len(fields) < 2 || version.Set(fields[0]+"."+fields[1]) != nil {
+				break
+			}
+			return int(version) >= minor
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return IsGoVersion(pass, minor)
+}
+
 func SelectorName(pass *analysis.Pass, expr *ast.SelectorExpr) string {
 	info := pass.TypesInfo
 	sel := info.Selections[expr]
@@ -4,14 +4,26 @@
 package protomigrate_test
 
 import (
+	"bytes"
+	"encoding/gob"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/packages"
 
 	"github.com/protobuf-tools/protomigrate"
+	"github.com/protobuf-tools/protomigrate/internal/checker"
 )
 
 // TestAnalyzer is a test for Analyzer.
@@ -20,15 +32,152 @@ func TestAnalyzer(t *testing.T) {
 
 	testdata := analysistest.TestData()
 
+	// The heuristic checks are run too, which only report on the testdata
+	// made for them.
+	check := protomigrate.Analyzer.Flags.Lookup("check").Value.String()
+	if err := protomigrate.Analyzer.Flags.Set("check", "aliasing"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { protomigrate.Analyzer.Flags.Set("check", check) })
+
 	tests := map[string]struct {
 		name string
+		fix  bool
 	}{
 		"a": {
 			name: "a",
 		},
+		"Aliasing": {
+			name: "aliasing",
+		},
+		"Buffer": {
+			name: "buffer",
+			fix:  true,
+		},
 		"CheckDeprecated": {
 			name: "check_deprecated",
 		},
+		"Clone": {
+			name: "clone",
+			fix:  true,
+		},
+		"Copy": {
+			name: "copy",
+		},
+		"DeepEqual": {
+			name: "deepequal",
+			fix:  true,
+		},
+		"DeprecatedField": {
+			name: "deprecatedfield",
+		},
+		"Descriptor": {
+			name: "descriptor",
+			fix:  true,
+		},
+		"EncodingJSON": {
+			name: "encodingjson",
+			fix:  true,
+		},
+		"Enum": {
+			name: "enum",
+			fix:  true,
+		},
+		"Extension": {
+			name: "extension",
+			fix:  true,
+		},
+		"Gateway": {
+			name: "gateway",
+		},
+		"GoGenerate": {
+			name: "gogenerate",
+			fix:  true,
+		},
+		"Gogo": {
+			name: "gogo",
+		},
+		"JSONPB": {
+			name: "jsonpb",
+			fix:  true,
+		},
+		"JSONPBIO": {
+			name: "jsonpbio",
+			fix:  true,
+		},
+		"JSONPBKept": {
+			name: "jsonpbkept",
+			fix:  true,
+		},
+		"JSONPBV1": {
+			name: "jsonpbv1",
+			fix:  true,
+		},
+		"LegacyGRPC": {
+			name: "legacygrpc",
+		},
+		"Lookup": {
+			name: "lookup",
+			fix:  true,
+		},
+		"LookupReflect": {
+			name: "lookupreflect",
+			fix:  true,
+		},
+		"MapKey": {
+			name: "mapkey",
+		},
+		"Marshaler": {
+			name: "marshaler",
+		},
+		"MessageName": {
+			name: "messagename",
+			fix:  true,
+		},
+		"MessageNameV2": {
+			name: "messagenamev2",
+			fix:  true,
+		},
+		"PointerEqual": {
+			name: "pointerequal",
+			fix:  true,
+		},
+		"Protoadapt": {
+			name: "protoadapt",
+			fix:  true,
+		},
+		"Ptypes": {
+			name: "ptypes",
+			fix:  true,
+		},
+		"Register": {
+			name: "register",
+			fix:  true,
+		},
+		"Registry": {
+			name: "registry",
+		},
+		"SetDefaults": {
+			name: "setdefaults",
+		},
+		"Stale": {
+			name: "stale",
+		},
+		"Suppress": {
+			name: "suppress",
+			fix:  true,
+		},
+		"Text": {
+			name: "textformat",
+			fix:  true,
+		},
+		"WKT": {
+			name: "wkt",
+			fix:  true,
+		},
+		"XXX": {
+			name: "xxx",
+		},
 	}
 	for name, tt := range tests {
 		tt := tt
@@ -44,7 +193,266 @@ func TestAnalyzer(t *testing.T) {
 				os.RemoveAll(filepath.Join(testdata, "src", tt.name, "vendor"))
 			})
 
-			analysistest.Run(t, testdata, protomigrate.Analyzer, tt.name)
+			var results []*analysistest.Result
+			if tt.fix {
+				results = analysistest.RunWithSuggestedFixes(t, testdata, protomigrate.Analyzer, tt.name)
+				checkApplyFixes(t, filepath.Join(testdata, "src", tt.name))
+			} else {
+				results = analysistest.Run(t, testdata, protomigrate.Analyzer, tt.name)
+			}
+
+			// Every finding is categorized by the ID of its rule.
+			for _, r := range results {
+				for _, d := range r.Diagnostics {
+					if rule, ok := protomigrate.LookupRule(d.Category); !ok || rule.ID != d.Category {
+						t.Errorf("%v: diagnostic %q has category %q, want the ID of a rule", r.Pass.Fset.Position(d.Pos), d.Message, d.Category)
+					}
+				}
+			}
 		})
 	}
 }
+
+// checkApplyFixes checks that the fixes of the packages of dir, applied by
+// checker.ApplyFixes as the -fix flag of the command applies them, make
+// the .golden files of dir as they do through analysistest, and that the
+// fixed packages type-check.
+func checkApplyFixes(t *testing.T, dir string) {
+	t.Helper()
+	cfg := &packages.Config{Mode: packages.LoadAllSyntax | packages.NeedModule, Dir: dir, Tests: true}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags, err := checker.Run(pkgs, []*analysis.Analyzer{protomigrate.Analyzer}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixes, err := checker.ApplyFixes(pkgs[0].Fset, diags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goldens, err := filepath.Glob(filepath.Join(dir, "*.go.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, golden := range goldens {
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.TrimSuffix(golden, ".golden")
+		got, ok := fixes.Files[name]
+		if !ok {
+			// The file has no fixes, and its golden file is the same.
+			if got, err = ioutil.ReadFile(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("checker.ApplyFixes made %s:\n%s\nwant %s:\n%s", name, got, golden, want)
+		}
+	}
+
+	cfg.Overlay = fixes.Files
+	fixed, err := packages.Load(cfg, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range fixed {
+		for _, err := range pkg.Errors {
+			t.Errorf("fixed package %s does not type-check: %v", pkg.PkgPath, err)
+		}
+	}
+}
+
+// TestAnalyzers checks that Analyzers returns Analyzer last, after those
+// it requires.
+func TestAnalyzers(t *testing.T) {
+	all := protomigrate.Analyzers()
+	if err := analysis.Validate(all); err != nil {
+		t.Fatal(err)
+	}
+	if last := all[len(all)-1]; last != protomigrate.Analyzer {
+		t.Errorf("last analyzer is %s, not protomigrate", last.Name)
+	}
+	seen := map[*analysis.Analyzer]bool{}
+	for _, a := range all {
+		seen[a] = true
+	}
+	for _, req := range protomigrate.Analyzer.Requires {
+		if !seen[req] {
+			t.Errorf("analyzer %s required by protomigrate is missing", req.Name)
+		}
+	}
+}
+
+// TestGroupAnalyzers checks that the analyzers of the groups are valid,
+// and report the findings of their checks as Analyzer does.
+func TestGroupAnalyzers(t *testing.T) {
+	groups := protomigrate.GroupAnalyzers()
+	if err := analysis.Validate(groups); err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := analysistest.TestData()
+	dir := filepath.Join(testdata, "src", "gogo")
+	cmd := exec.Command("go", "mod", "vendor")
+	cmd.Dir = dir
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(filepath.Join(dir, "vendor"))
+	})
+	analysistest.Run(t, testdata, protomigrate.CodegenAnalyzer, "gogo")
+}
+
+// TestFacts checks that the facts of the analyzers encode with gob, as the
+// vet tool drivers write them for the packages depending on theirs.
+func TestFacts(t *testing.T) {
+	for _, a := range protomigrate.Analyzers() {
+		for _, f := range a.FactTypes {
+			gob.Register(f)
+			var buf bytes.Buffer
+			fact := reflect.New(reflect.TypeOf(f).Elem()).Interface().(analysis.Fact)
+			if err := gob.NewEncoder(&buf).Encode(&fact); err != nil {
+				t.Errorf("fact %T of %s: %v", f, a.Name, err)
+				continue
+			}
+			var got analysis.Fact
+			if err := gob.NewDecoder(&buf).Decode(&got); err != nil {
+				t.Errorf("fact %T of %s: %v", f, a.Name, err)
+			} else if reflect.TypeOf(got) != reflect.TypeOf(f) {
+				t.Errorf("fact %T of %s decodes as %T", f, a.Name, got)
+			}
+		}
+	}
+}
+
+// TestSideEffects checks that the packages of the analyzers neither write
+// to the standard output nor run commands, which would break the drivers
+// like gopls that run them in process.
+func TestSideEffects(t *testing.T) {
+	fset := token.NewFileSet()
+	for _, dir := range []string{".", "facts", filepath.Join("internal", "protosrc")} {
+		pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+			return !strings.HasSuffix(fi.Name(), "_test.go")
+		}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pkg := range pkgs {
+			for _, f := range pkg.Files {
+				for _, imp := range f.Imports {
+					if path, _ := strconv.Unquote(imp.Path.Value); path == "os/exec" || path == "syscall" {
+						t.Errorf("%v: package %s imported", fset.Position(imp.Pos()), path)
+					}
+				}
+				ast.Inspect(f, func(n ast.Node) bool {
+					var name string
+					switch n := n.(type) {
+					case *ast.SelectorExpr:
+						if x, ok := n.X.(*ast.Ident); ok {
+							name = x.Name + "." + n.Sel.Name
+						}
+					case *ast.CallExpr:
+						if id, ok := n.Fun.(*ast.Ident); ok {
+							name = id.Name
+						}
+					}
+					switch name {
+					case "os.Stdout", "fmt.Print", "fmt.Printf", "fmt.Println", "print", "println":
+						t.Errorf("%v: %s used", fset.Position(n.Pos()), name)
+					}
+					return true
+				})
+			}
+		}
+	}
+}
+
+// TestRegister checks that Register rejects the plugins it cannot run
+// along the built-in checks. Valid plugins are not registered, since the
+// rules of the other tests are those of Analyzer.
+func TestRegister(t *testing.T) {
+	run := func(*analysis.Pass) (interface{}, error) { return nil, nil }
+	rule := protomigrate.Rule{ID: "ACME1001", Name: "acme-wrapper", Doc: "Uses of the ACME protobuf wrappers"}
+	tests := map[string]protomigrate.Plugin{
+		"NoRun":        {Rules: []protomigrate.Rule{rule}},
+		"NoRules":      {Run: run},
+		"NoName":       {Rules: []protomigrate.Rule{{ID: "ACME1001"}}, Run: run},
+		"BuiltinID":    {Rules: []protomigrate.Rule{{ID: "PM3001", Name: "acme"}}, Run: run},
+		"BuiltinName":  {Rules: []protomigrate.Rule{{ID: "ACME1001", Name: "ptypes"}}, Run: run},
+		"Duplicate":    {Rules: []protomigrate.Rule{rule, rule}, Run: run},
+		"FixLevel":     {Rules: []protomigrate.Rule{rule}, FixLevel: "risky", Run: run},
+		"UnknownGroup": {Rules: []protomigrate.Rule{rule}, Group: "pmacme", Run: run},
+	}
+	for name, p := range tests {
+		if err := protomigrate.Register(p); err == nil {
+			t.Errorf("%s: Register succeeded, want an error", name)
+		}
+	}
+	if _, ok := protomigrate.LookupRule("ACME1001"); ok {
+		t.Error("rule ACME1001 of an invalid plugin is registered")
+	}
+}
+
+// TestRegisterRewrites checks that RegisterRewrites rejects the rewrites
+// it cannot apply, as TestRegister does the plugins.
+func TestRegisterRewrites(t *testing.T) {
+	rw := protomigrate.Rewrite{
+		ID:          "ACME2001",
+		Name:        "acme-marshal",
+		Package:     "example.com/acme/pbutil",
+		Symbol:      "MarshalText",
+		Replacement: "prototext.Format($1)",
+		Imports:     []string{"google.golang.org/protobuf/encoding/prototext"},
+	}
+	with := func(change func(rw *protomigrate.Rewrite)) protomigrate.Rewrite {
+		rw := rw
+		change(&rw)
+		return rw
+	}
+	tests := map[string][]protomigrate.Rewrite{
+		"NoSymbol":      {with(func(rw *protomigrate.Rewrite) { rw.Symbol = "" })},
+		"NoReplacement": {with(func(rw *protomigrate.Rewrite) { rw.Replacement = "" })},
+		"NotExpression": {with(func(rw *protomigrate.Rewrite) { rw.Replacement = "prototext.Format($1" })},
+		"Import":        {with(func(rw *protomigrate.Rewrite) { rw.Imports = []string{"gopkg.in/yaml.v2"} })},
+		"FixLevel":      {with(func(rw *protomigrate.Rewrite) { rw.FixLevel = "risky" })},
+		"BuiltinRule":   {with(func(rw *protomigrate.Rewrite) { rw.ID, rw.Name = "PM3001", "text" })},
+		"Duplicate":     {rw, with(func(rw *protomigrate.Rewrite) { rw.ID, rw.Name = "ACME2002", "acme-marshal-again" })},
+	}
+	for name, rws := range tests {
+		if err := protomigrate.RegisterRewrites(rws); err == nil {
+			t.Errorf("%s: RegisterRewrites succeeded, want an error", name)
+		}
+	}
+	if _, ok := protomigrate.LookupRule("ACME2001"); ok {
+		t.Error("rule ACME2001 of an invalid rewrite is registered")
+	}
+}
+
+// TestImportMap checks that the analyzer rejects invalid values of
+// -import-map; facts tests the mappings themselves.
+func TestImportMap(t *testing.T) {
+	for _, v := range []string{
+		"example.com/fork/proto",
+		"=github.com/golang/protobuf/proto",
+		"github.com/golang/protobuf/proto=github.com/golang/protobuf/proto",
+	} {
+		if err := protomigrate.Analyzer.Flags.Set("import-map", v); err == nil {
+			t.Errorf("-import-map=%s succeeded, want an error", v)
+		}
+	}
+}
+
+// TestExplain checks that every rule is explained.
+func TestExplain(t *testing.T) {
+	for _, r := range protomigrate.Rules() {
+		_, e, ok := protomigrate.Explain(r.Name)
+		if !ok || e.Description == "" || e.Before == "" || e.After == "" {
+			t.Errorf("rule %s %s has no explanation with an example", r.ID, r.Name)
+		}
+	}
+}
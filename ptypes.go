@@ -0,0 +1,404 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const ptypesPath = "github.com/golang/protobuf/ptypes"
+
+// ptypesFuncs maps the ptypes functions and types that can be rewritten to
+// the functions rewriting calls of, or references to, them.
+var ptypesFuncs = map[string]func(*funcCall) bool{
+	"Duration":       rewriteDuration,
+	"DurationProto":  rewriteDurationProto,
+	"DynamicAny":     rewriteDynamicAny,
+	"Is":             rewriteIs,
+	"MarshalAny":     rewriteMarshalAny,
+	"UnmarshalAny":   rewriteUnmarshalAny,
+	"Timestamp":      rewriteTimestamp,
+	"TimestampNow":   rewriteTimestampNow,
+	"TimestampProto": rewriteTimestampProto,
+}
+
+// checkPtypes rewrites calls of the ptypes helpers to the methods and
+// constructors of the well-known types.
+func checkPtypes(pass *analysis.Pass) (interface{}, error) {
+	rewriteCalls(pass, ptypesPath, ptypesFuncs)
+	return nil, nil
+}
+
+func rewriteMarshalAny(c *funcCall) bool {
+	const msg = "ptypes.MarshalAny should be replaced with anypb.New"
+	if t := c.pass.TypesInfo.TypeOf(c.call.Args[0]); !hasProtoReflect(t) {
+		report.Report(c.pass, c.call, msg+v1MessageNote(c.pass, t))
+		return false
+	}
+	c.rw.require(c.pass, anypbPath)
+	repl := c.rw.qualifier(c.pass, anypbPath) + ".New"
+	report.Report(c.pass, c.call, msg,
+		report.Fixes(edit.Fix("Use anypb.New", edit.ReplaceWithString(c.pass.Fset, c.sel, repl))))
+	return true
+}
+
+func rewriteUnmarshalAny(c *funcCall) bool {
+	if len(c.call.Args) == 2 && isDynamicAny(c.pass.TypesInfo.TypeOf(c.call.Args[1])) {
+		// Decoding into a DynamicAny is rewritten along with its
+		// declaration.
+		if u, ok := c.call.Args[1].(*ast.UnaryExpr); ok {
+			if id, ok := u.X.(*ast.Ident); ok {
+				if obj, ok := c.pass.TypesInfo.Uses[id].(*types.Var); ok {
+					if _, ok := findDynamicAny(c.pass, c.rw.file, obj); ok {
+						return true
+					}
+				}
+			}
+		}
+		report.Report(c.pass, c.call, dynamicAnyMsg)
+		return false
+	}
+	return c.rewriteMethod(2, "UnmarshalTo", "ptypes.UnmarshalAny should be replaced with the UnmarshalTo method of the Any")
+}
+
+func rewriteIs(c *funcCall) bool {
+	return c.rewriteMethod(2, "MessageIs", "ptypes.Is should be replaced with the MessageIs method of the Any")
+}
+
+// rewriteMethod rewrites the call, which must have nargs arguments, to a
+// call of the named method on its first argument. The last argument is the
+// message the method is passed.
+func (c *funcCall) rewriteMethod(nargs int, method, msg string) bool {
+	if len(c.call.Args) != nargs {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	if t := c.pass.TypesInfo.TypeOf(c.call.Args[nargs-1]); !hasProtoReflect(t) {
+		report.Report(c.pass, c.call, msg+v1MessageNote(c.pass, t))
+		return false
+	}
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Use the "+method+" method", methodCall(c.call, method)...)))
+	return true
+}
+
+func rewriteDurationProto(c *funcCall) bool {
+	c.rw.require(c.pass, durationpbPath)
+	repl := c.rw.qualifier(c.pass, durationpbPath) + ".New"
+	report.Report(c.pass, c.call, "ptypes.DurationProto should be replaced with durationpb.New",
+		report.Fixes(edit.Fix("Use durationpb.New", edit.ReplaceWithString(c.pass.Fset, c.sel, repl))))
+	return true
+}
+
+func rewriteDuration(c *funcCall) bool {
+	// Unlike ptypes.Duration, AsDuration cannot fail: durations that
+	// overflow a time.Duration are clamped to its range. CheckValid only
+	// reports durations outside the range of the well-known type, so the
+	// rewritten code silently accepts these.
+	const msg = "ptypes.Duration should be replaced with the AsDuration method, which saturates durations that overflow time.Duration instead of returning an error"
+	if len(c.call.Args) != 1 {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	return c.rewriteValidated(msg, "Use the AsDuration method", "", methodCall(c.call, "AsDuration"), c.call.Args[0], false)
+}
+
+func rewriteTimestampNow(c *funcCall) bool {
+	c.rw.require(c.pass, timestamppbPath)
+	repl := c.rw.qualifier(c.pass, timestamppbPath) + ".Now"
+	report.Report(c.pass, c.call, "ptypes.TimestampNow should be replaced with timestamppb.Now",
+		report.Fixes(edit.Fix("Use timestamppb.Now", edit.ReplaceWithString(c.pass.Fset, c.sel, repl))))
+	return true
+}
+
+func rewriteTimestampProto(c *funcCall) bool {
+	const msg = "ptypes.TimestampProto should be replaced with timestamppb.New, which does not validate the timestamp"
+	if len(c.call.Args) != 1 {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	convert := []analysis.TextEdit{
+		edit.ReplaceWithString(c.pass.Fset, c.sel, c.rw.qualifier(c.pass, timestamppbPath)+".New"),
+	}
+	// The current time is always in the range a Timestamp can represent.
+	valid := isCallTo(c.pass, c.call.Args[0], "time", "Now")
+	return c.rewriteValidated(msg, "Use timestamppb.New", timestamppbPath, convert, nil, valid)
+}
+
+func rewriteTimestamp(c *funcCall) bool {
+	const msg = "ptypes.Timestamp should be replaced with the AsTime method, which does not validate the timestamp"
+	if len(c.call.Args) != 1 {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	return c.rewriteValidated(msg, "Use the AsTime method", "", methodCall(c.call, "AsTime"), c.call.Args[0], false)
+}
+
+// isCallTo reports whether expr is a call of the named function of the
+// package with the given import path.
+func isCallTo(pass *analysis.Pass, expr ast.Expr, path, name string) bool {
+	call, ok := astutil.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	fn, ok := pass.TypesInfo.ObjectOf(sel.Sel).(*types.Func)
+	return ok && fn.Name() == name && fn.Pkg() != nil && pkgPath(fn.Pkg()) == path
+}
+
+// rewriteValidated rewrites the call, which returns a value and an error,
+// by applying convert, which turns it into a call returning only the value
+// and that refers to the package imported by newPath, if any. If the error
+// is used, it is recomputed by calling CheckValid on checked, or on the
+// value if checked is nil. If valid is set, the call is known to succeed.
+//
+// The call's results must be assigned by a statement of their own, or
+// returned; in all other cases only a diagnostic without a fix is reported.
+func (c *funcCall) rewriteValidated(msg, fixMsg, newPath string, convert []analysis.TextEdit, checked ast.Expr, valid bool) bool {
+	var edits []analysis.TextEdit
+	switch stmt := c.path[1].(type) {
+	case *ast.AssignStmt:
+		edits = c.assignValidated(stmt, convert, checked, valid)
+	case *ast.ReturnStmt:
+		edits = c.returnValidated(stmt, convert, checked, valid)
+	}
+	if edits == nil {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	if newPath != "" {
+		c.rw.require(c.pass, newPath)
+	}
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix(fixMsg, edits...)))
+	return true
+}
+
+// errorUse describes how the error result of a call is used after the
+// statement assigning it.
+type errorUse int
+
+const (
+	errorUnused errorUse = iota
+	// errorChecked means the error is only used by an immediately
+	// following if err != nil statement.
+	errorChecked
+	errorUsed
+)
+
+// assignValidated returns the edits for a call whose results are assigned
+// by stmt.
+func (c *funcCall) assignValidated(stmt *ast.AssignStmt, convert []analysis.TextEdit, checked ast.Expr, valid bool) []analysis.TextEdit {
+	if len(stmt.Lhs) != 2 || len(stmt.Rhs) != 1 {
+		return nil
+	}
+	list, next, ok := stmtList(c.path[2], stmt)
+	if !ok {
+		return nil
+	}
+	v := stmt.Lhs[0]
+	errVar, ok := stmt.Lhs[1].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+
+	use := errorUnused
+	var ifStmt *ast.IfStmt
+	if errVar.Name != "_" {
+		obj := c.pass.TypesInfo.ObjectOf(errVar)
+		if next < len(list) {
+			ifStmt, _ = list[next].(*ast.IfStmt)
+		}
+		switch {
+		case isResult(c.pass, c.path, obj):
+			// Bare returns read named results implicitly.
+			use = errorUsed
+		case !usedAfter(c.pass, list, stmt.End(), obj):
+		case ifStmt != nil && isNilCheck(c.pass, ifStmt, obj) && !usedAfter(c.pass, list, ifStmt.End(), obj):
+			use = errorChecked
+		default:
+			use = errorUsed
+		}
+	}
+
+	if id, ok := v.(*ast.Ident); ok && id.Name == "_" {
+		// Only the error is needed.
+		if use == errorUnused {
+			return nil
+		}
+		edits := []analysis.TextEdit{edit.Delete(edit.Range{v.Pos(), errVar.Pos()})}
+		if checked != nil {
+			if !isSimpleExpr(checked) {
+				return nil
+			}
+			edits = append(edits, edit.ReplaceWithString(c.pass.Fset, c.call, report.Render(c.pass, checked)+".CheckValid()"))
+		} else {
+			edits = append(edits, convert...)
+			edits = append(edits, insert(c.call.End(), ".CheckValid()"))
+		}
+		if stmt.Tok == token.DEFINE && !isDefined(c.pass, errVar) {
+			edits = append(edits, edit.ReplaceWithString(c.pass.Fset, edit.Range{stmt.TokPos, stmt.TokPos + 2}, "="))
+		}
+		return edits
+	}
+
+	if use != errorUnused {
+		if checked == nil {
+			checked = v
+		}
+		if !isSimpleExpr(checked) {
+			return nil
+		}
+	}
+
+	edits := []analysis.TextEdit{edit.Delete(edit.Range{v.End(), errVar.End()})}
+	edits = append(edits, convert...)
+	if stmt.Tok == token.DEFINE && !isDefined(c.pass, v) {
+		edits = append(edits, edit.ReplaceWithString(c.pass.Fset, edit.Range{stmt.TokPos, stmt.TokPos + 2}, "="))
+	}
+
+	switch {
+	case use == errorUnused:
+	case use == errorChecked && valid:
+		edits = append(edits, deleteLines(c.pass, ifStmt))
+	case use == errorChecked:
+		init := fmt.Sprintf("%s := %s.CheckValid(); ", errVar.Name, report.Render(c.pass, checked))
+		edits = append(edits, insert(ifStmt.Cond.Pos(), init))
+	default:
+		tok := "="
+		if stmt.Tok == token.DEFINE && isDefined(c.pass, errVar) {
+			tok = ":="
+		}
+		text := fmt.Sprintf("\n%s%s %s %s.CheckValid()", indentation(c.pass, stmt.Pos()), errVar.Name, tok, report.Render(c.pass, checked))
+		edits = append(edits, insert(lineEnd(c.pass, stmt.End()), text))
+	}
+	return edits
+}
+
+// returnValidated returns the edits for a call whose results are returned
+// by stmt.
+func (c *funcCall) returnValidated(stmt *ast.ReturnStmt, convert []analysis.TextEdit, checked ast.Expr, valid bool) []analysis.TextEdit {
+	if len(stmt.Results) != 1 {
+		return nil
+	}
+	if _, _, ok := stmtList(c.path[2], stmt); !ok {
+		return nil
+	}
+	edits := append([]analysis.TextEdit(nil), convert...)
+	switch {
+	case valid:
+		return append(edits, insert(c.call.End(), ", nil"))
+	case checked != nil:
+		if !isSimpleExpr(checked) {
+			return nil
+		}
+		return append(edits, insert(c.call.End(), fmt.Sprintf(", %s.CheckValid()", report.Render(c.pass, checked))))
+	}
+	// Declare the value, then return it along with its validity.
+	v := freshName(c.pass, stmt.Pos(), "v", c.call)
+	text := fmt.Sprintf("\n%sreturn %s, %s.CheckValid()", indentation(c.pass, stmt.Pos()), v, v)
+	return append(edits,
+		edit.ReplaceWithString(c.pass.Fset, edit.Range{stmt.Pos(), c.call.Pos()}, v+" := "),
+		insert(lineEnd(c.pass, stmt.End()), text))
+}
+
+// isDefined reports whether expr is an identifier declared at this point.
+func isDefined(pass *analysis.Pass, expr ast.Expr) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && pass.TypesInfo.Defs[id] != nil
+}
+
+// usedAfter reports whether obj, a variable declared in list, may be
+// referred to after pos: by the statements of list following pos, or by a
+// closure anywhere in list. Variables declared outside of list, which a
+// loop may refer to again, are always assumed to be.
+func usedAfter(pass *analysis.Pass, list []ast.Stmt, pos token.Pos, obj types.Object) bool {
+	if obj == nil {
+		return true
+	}
+	if len(list) == 0 || obj.Pos() < list[0].Pos() {
+		return true
+	}
+	found := false
+	for _, stmt := range list {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			if found {
+				return false
+			}
+			switch node := node.(type) {
+			case nil:
+			case *ast.FuncLit:
+				ast.Inspect(node.Body, func(node ast.Node) bool {
+					// Closures may run at any later point.
+					if id, ok := node.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == obj {
+						found = true
+					}
+					return !found
+				})
+				return false
+			case *ast.Ident:
+				if node.Pos() > pos && pass.TypesInfo.Uses[node] == obj {
+					found = true
+				}
+			}
+			return true
+		})
+	}
+	return found
+}
+
+// isResult reports whether obj is a named result of the function whose
+// body encloses path.
+func isResult(pass *analysis.Pass, path []ast.Node, obj types.Object) bool {
+	for _, node := range path {
+		var typ *ast.FuncType
+		switch node := node.(type) {
+		case *ast.FuncDecl:
+			typ = node.Type
+		case *ast.FuncLit:
+			typ = node.Type
+		default:
+			continue
+		}
+		if typ.Results == nil {
+			return false
+		}
+		for _, field := range typ.Results.List {
+			for _, name := range field.Names {
+				if pass.TypesInfo.Defs[name] == obj {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// isNilCheck reports whether stmt is an if statement without initializer
+// or else branch whose condition is obj != nil.
+func isNilCheck(pass *analysis.Pass, stmt *ast.IfStmt, obj types.Object) bool {
+	if stmt.Init != nil || stmt.Else != nil {
+		return false
+	}
+	cond, ok := stmt.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ {
+		return false
+	}
+	x, ok := cond.X.(*ast.Ident)
+	if !ok || pass.TypesInfo.Uses[x] != obj {
+		return false
+	}
+	y, ok := cond.Y.(*ast.Ident)
+	return ok && pass.TypesInfo.Uses[y] == types.Universe.Lookup("nil")
+}
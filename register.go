@@ -0,0 +1,122 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const protoimplPath = "google.golang.org/protobuf/runtime/protoimpl"
+
+// registeredNote tells that a registration is made by the generated code.
+const registeredNote = "; the code generated for the v2 API registers it already, and registering it again panics, so the call should go"
+
+// rewriteRegisterType rewrites the hand-written calls of proto.RegisterType,
+// which the code generated for the v2 API makes no more: its messages are
+// registered along with their file. Other messages need their
+// protoreflect.MessageType registered instead.
+func rewriteRegisterType(c *funcCall) bool {
+	const msg = "proto.RegisterType should be replaced with protoregistry.GlobalTypes.RegisterMessage"
+	if c.call == nil || len(c.call.Args) != 2 {
+		report.Report(c.pass, c.sel, msg)
+		return false
+	}
+	if !hasProtoReflect(c.pass.TypesInfo.TypeOf(c.call.Args[0])) {
+		report.Report(c.pass, c.call, msg+", which takes the protoreflect.MessageType of the message, like the one protoimpl.X.MessageTypeOf derives from the types generated for the v1 API")
+		return false
+	}
+	return c.removeRegistration(msg + registeredNote)
+}
+
+// rewriteRegisterEnum rewrites the hand-written calls of proto.RegisterEnum
+// for the enums generated for the v2 API. The v1 function only registered
+// the maps for proto.EnumValueMap, and the v2 registry needs a
+// protoreflect.EnumType rather than maps.
+func rewriteRegisterEnum(c *funcCall) bool {
+	const msg = "proto.RegisterEnum should be replaced with protoregistry.GlobalTypes.RegisterEnum, which takes the protoreflect.EnumType of the enum rather than its maps"
+	if c.call == nil || len(c.call.Args) != 3 {
+		report.Report(c.pass, c.sel, msg)
+		return false
+	}
+	enum, ok := enumOfMap(c.pass, c.call.Args[2], "_value")
+	if !ok || !hasMethod(enum.Type(), "Type") || !hasMethod(enum.Type(), "Descriptor") {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	return c.removeRegistration(msg + registeredNote)
+}
+
+// rewriteRegisterExtension rewrites the hand-written calls of
+// proto.RegisterExtension to protoregistry.GlobalTypes.RegisterExtension,
+// panicking on its errors as the v1 function does, or removes those of
+// the extensions generated for the v2 API, which registers them along with
+// their file.
+func rewriteRegisterExtension(c *funcCall) bool {
+	const msg = "proto.RegisterExtension should be replaced with protoregistry.GlobalTypes.RegisterExtension"
+	if c.call == nil || len(c.call.Args) != 1 || !isExtensionType(c.pass.TypesInfo.TypeOf(c.call.Args[0])) {
+		report.Report(c.pass, c.sel, msg)
+		return false
+	}
+	if generatedForV2(c.pass, c.call.Args[0]) {
+		return c.removeRegistration(msg + registeredNote)
+	}
+	stmt, ok := c.path[1].(*ast.ExprStmt)
+	if !ok {
+		report.Report(c.pass, c.call, msg+", which returns an error")
+		return false
+	}
+	c.rw.require(c.pass, protoregistryPath)
+	// The body follows any comment ending the line of the call.
+	indent := indentation(c.pass, stmt.Pos())
+	edits := []analysis.TextEdit{
+		edit.ReplaceWithString(c.pass.Fset, stmt, "if err := "+c.rw.qualifier(c.pass, protoregistryPath)+".GlobalTypes.RegisterExtension("+report.Render(c.pass, c.call.Args[0])+"); err != nil {"),
+		insert(lineEnd(c.pass, stmt.End()), "\n"+indent+"\tpanic(err)\n"+indent+"}"),
+	}
+	report.Report(c.pass, c.call, msg+", which returns an error", report.Fixes(edit.Fix("Use protoregistry.GlobalTypes.RegisterExtension", edits...)))
+	return true
+}
+
+// rewriteRegisterMapType removes the calls of proto.RegisterMapType, which
+// only made map types known to proto.MessageType.
+func rewriteRegisterMapType(c *funcCall) bool {
+	return c.removeRegistration("proto.RegisterMapType has no v2 counterpart; it only made the map type known to proto.MessageType, so the call should go")
+}
+
+// removeRegistration reports the registration call with msg, and with a
+// fix removing it if it is a statement of its own.
+func (c *funcCall) removeRegistration(msg string) bool {
+	stmt, ok := c.path[1].(*ast.ExprStmt)
+	if c.call == nil || !ok {
+		report.Report(c.pass, c.sel, msg)
+		return false
+	}
+	c.rw.removed = append(c.rw.removed, stmt)
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Remove the registration", deleteLines(c.pass, stmt))))
+	return true
+}
+
+// generatedForV2 reports whether x refers to a package-level variable of a
+// package generated for the v2 API, which imports protoimpl.
+func generatedForV2(pass *analysis.Pass, x ast.Expr) bool {
+	var id *ast.Ident
+	switch x := astutil.Unparen(x).(type) {
+	case *ast.Ident:
+		id = x
+	case *ast.SelectorExpr:
+		id = x.Sel
+	default:
+		return false
+	}
+	v, ok := pass.TypesInfo.Uses[id].(*types.Var)
+	if !ok || v.Pkg() == nil || v.Parent() != v.Pkg().Scope() {
+		return false
+	}
+	return importedPackage(v.Pkg(), protoimplPath) != nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/report"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+)
+
+// checkRegistry reports the packages that link in two packages registering
+// the same .proto file, or the same message or enum, in the global
+// registry, as a copy generated by protoc-gen-gogo and one generated by
+// protoc-gen-go do. The v2 runtime panics on such conflicts at init. A
+// conflict is reported by the first package linking in both, not by the
+// packages importing it, at the import linking in the second.
+func checkRegistry(pass *analysis.Pass) (interface{}, error) {
+	regs := pass.ResultOf[facts.Registry].(map[*types.Package]*facts.Registrations)
+	if len(regs) < 2 {
+		return nil, nil
+	}
+
+	// The packages linked in are visited breadth first, so that each is
+	// reached through one of its shortest import chains.
+	parent := map[*types.Package]*types.Package{pass.Pkg: nil}
+	order := []*types.Package{pass.Pkg}
+	for i := 0; i < len(order); i++ {
+		for _, imp := range order[i].Imports() {
+			if _, ok := parent[imp]; !ok {
+				parent[imp] = order[i]
+				order = append(order, imp)
+			}
+		}
+	}
+	registrants := map[string][]*types.Package{}
+	for _, pkg := range order {
+		r, ok := regs[pkg]
+		if !ok {
+			continue
+		}
+		for _, file := range r.Files {
+			registrants["the .proto file "+file] = append(registrants["the .proto file "+file], pkg)
+		}
+		for _, name := range r.Names {
+			registrants["the type "+name] = append(registrants["the type "+name], pkg)
+		}
+	}
+
+	// conflicts maps the pairs of registrants to what they both register.
+	type pair struct{ a, b *types.Package }
+	conflicts := map[pair][]string{}
+	var pairs []pair
+	reach := map[*types.Package]map[*types.Package]bool{}
+	for what, pkgs := range registrants {
+		if len(pkgs) < 2 {
+			continue
+		}
+		p := pair{pkgs[0], pkgs[1]}
+		if linkedByImport(pass.Pkg, p.a, p.b, reach) {
+			continue
+		}
+		if _, ok := conflicts[p]; !ok {
+			pairs = append(pairs, p)
+		}
+		conflicts[p] = append(conflicts[p], what)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].a != pairs[j].a {
+			return sourcePath(pairs[i].a) < sourcePath(pairs[j].a)
+		}
+		return sourcePath(pairs[i].b) < sourcePath(pairs[j].b)
+	})
+	for _, p := range pairs {
+		what := conflicts[p]
+		sort.Strings(what)
+		// The files come first, and then the names.
+		sort.SliceStable(what, func(i, j int) bool {
+			return strings.HasPrefix(what[i], "the .proto file ") && !strings.HasPrefix(what[j], "the .proto file ")
+		})
+		if len(what) > 3 {
+			what = append(what[:3:3], fmt.Sprintf("%d more", len(what)-3))
+		}
+		list := strings.Join(what[:len(what)-1], ", ")
+		if len(what) > 1 {
+			list += " and "
+		}
+		list += what[len(what)-1]
+		msg := fmt.Sprintf("%s and %s both register %s, which the v2 runtime panics on at init; they are linked in through %s and %s",
+			sourcePath(p.a), sourcePath(p.b), list, importChain(parent, p.a), importChain(parent, p.b))
+		spec := importOf(pass, importedThrough(parent, p.b))
+		if spec == nil {
+			spec = importOf(pass, importedThrough(parent, p.a))
+		}
+		if spec != nil {
+			report.Report(pass, spec, msg)
+		} else {
+			report.Report(pass, pass.Files[0].Name, msg)
+		}
+	}
+	return nil, nil
+}
+
+// linkedByImport reports whether a single import of pkg links in both a
+// and b, so that the package imported reports on them.
+func linkedByImport(pkg, a, b *types.Package, reach map[*types.Package]map[*types.Package]bool) bool {
+	for _, imp := range pkg.Imports() {
+		r, ok := reach[imp]
+		if !ok {
+			r = map[*types.Package]bool{imp: true}
+			stack := []*types.Package{imp}
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				for _, q := range p.Imports() {
+					if !r[q] {
+						r[q] = true
+						stack = append(stack, q)
+					}
+				}
+			}
+			reach[imp] = r
+		}
+		if r[a] && r[b] {
+			return true
+		}
+	}
+	return false
+}
+
+// importChain renders the chain of imports through which the package
+// visited first reaches pkg, like "a -> b -> c".
+func importChain(parent map[*types.Package]*types.Package, pkg *types.Package) string {
+	var chain []string
+	for p := pkg; p != nil; p = parent[p] {
+		chain = append(chain, sourcePath(p))
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return strings.Join(chain, " -> ")
+}
+
+// importedThrough returns the package that the package visited first
+// imports to reach pkg, or nil if pkg is that package.
+func importedThrough(parent map[*types.Package]*types.Package, pkg *types.Package) *types.Package {
+	for p := pkg; p != nil; p = parent[p] {
+		if parent[p] != nil && parent[parent[p]] == nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// importOf returns the first import of pkg in the files of pass, or nil.
+func importOf(pass *analysis.Pass, pkg *types.Package) *ast.ImportSpec {
+	if pkg == nil {
+		return nil
+	}
+	for _, file := range pass.Files {
+		for _, spec := range file.Imports {
+			if writtenPath(spec) == sourcePath(pkg) {
+				return spec
+			}
+		}
+	}
+	return nil
+}
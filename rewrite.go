@@ -0,0 +1,831 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+)
+
+// stdImportRewrite returns the rewrite of the imports of file shared by
+// the fixes of a check that rewrite fixed references through the import of
+// oldPath, a standard library package, to use the package imported by
+// newPath. The import of oldPath goes away if they leave it unused. It
+// returns nil if file has no import to add the one of newPath next to.
+func stdImportRewrite(pass *analysis.Pass, file *ast.File, oldPath string, fixed int, newPath string) *importRewrite {
+	if spec := findImport(file, oldPath); spec != nil && fixed > 0 {
+		if pkg := importedPkgName(pass, spec); pkg != nil {
+			rw := newImportRewrite(file, spec)
+			rw.fixed = fixed
+			rw.unfixed = len(qualifiedRefs(pass, file, pkg)) - fixed
+			rw.kept = rw.unfixed > 0
+			rw.after = lastNonStdImport(file)
+			rw.require(pass, newPath)
+			return rw
+		}
+	}
+	// The references stay, so the import of newPath goes after any
+	// import, preferably not of the standard library.
+	if len(file.Imports) == 0 {
+		return nil
+	}
+	rw := newImportRewrite(file, file.Imports[0])
+	rw.fixed, rw.unfixed, rw.kept = fixed, 1, true
+	rw.after = lastNonStdImport(file)
+	rw.require(pass, newPath)
+	return rw
+}
+
+// stdImport returns the rewrite of the imports of file adding the import
+// of newPath, a standard library package, after the last import of the
+// standard library, or the last import if there is none. The other imports
+// are left alone. It returns nil if file has no import to add it next to.
+func stdImport(pass *analysis.Pass, file *ast.File, newPath string) *importRewrite {
+	if len(file.Imports) == 0 {
+		return nil
+	}
+	spec := file.Imports[len(file.Imports)-1]
+	for _, s := range file.Imports {
+		if !strings.Contains(strings.SplitN(importPath(s), "/", 2)[0], ".") {
+			spec = s
+		}
+	}
+	rw := newImportRewrite(file, spec)
+	rw.unfixed, rw.kept = 1, true
+	rw.require(pass, newPath)
+	return rw
+}
+
+// lastNonStdImport returns the last import of file of a package outside of
+// the standard library, whose paths start with a domain name, or nil.
+func lastNonStdImport(file *ast.File) *ast.ImportSpec {
+	var last *ast.ImportSpec
+	for _, spec := range file.Imports {
+		if strings.Contains(strings.SplitN(importPath(spec), "/", 2)[0], ".") {
+			last = spec
+		}
+	}
+	return last
+}
+
+// importedPkgName returns the package name declared by spec, or nil for
+// blank and dot imports.
+func importedPkgName(pass *analysis.Pass, spec *ast.ImportSpec) *types.PkgName {
+	var obj types.Object
+	if spec.Name != nil {
+		if spec.Name.Name == "_" || spec.Name.Name == "." {
+			return nil
+		}
+		obj = pass.TypesInfo.ObjectOf(spec.Name)
+	} else {
+		obj = pass.TypesInfo.Implicits[spec]
+	}
+	pkg, _ := obj.(*types.PkgName)
+	return pkg
+}
+
+// importPath returns the import path of the package spec imports stands
+// for: its unquoted path, or the path -import-map maps it to.
+func importPath(spec *ast.ImportSpec) string {
+	return facts.CanonicalPath(writtenPath(spec))
+}
+
+// writtenPath returns the unquoted import path of spec.
+func writtenPath(spec *ast.ImportSpec) string {
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// pkgPath returns the import path of the package pkg stands for, without
+// any vendor prefix, as importPath does.
+func pkgPath(pkg *types.Package) string {
+	return facts.CanonicalPath(pkg.Path())
+}
+
+// sourcePath returns the import path of pkg, without any vendor prefix,
+// for the findings about packages rather than the APIs they stand for.
+func sourcePath(pkg *types.Package) string {
+	path := pkg.Path()
+	if i := strings.LastIndex(path, "/vendor/"); i >= 0 {
+		return path[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(path, "vendor/")
+}
+
+// findImport returns the import of path in file, or nil.
+func findImport(file *ast.File, path string) *ast.ImportSpec {
+	for _, spec := range file.Imports {
+		if importPath(spec) == path {
+			return spec
+		}
+	}
+	return nil
+}
+
+// qualifiedRefs returns every selector in file that refers to a member of
+// the package imported by pkg.
+func qualifiedRefs(pass *analysis.Pass, file *ast.File, pkg *types.PkgName) []*ast.SelectorExpr {
+	var refs []*ast.SelectorExpr
+	ast.Inspect(file, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok && pass.TypesInfo.Uses[x] == pkg {
+			refs = append(refs, sel)
+		}
+		return true
+	})
+	return refs
+}
+
+// funcCall is a call of a v1 package function being rewritten, or a
+// reference to a v1 package type, for which call is nil.
+type funcCall struct {
+	pass *analysis.Pass
+	rw   *importRewrite
+	sel  *ast.SelectorExpr
+	call *ast.CallExpr
+
+	// path holds the nodes enclosing sel, innermost first, starting with
+	// call if it is set.
+	path []ast.Node
+}
+
+// rewriteCalls rewrites the calls of functions of the package imported by
+// path, and the import itself, in every file that is not generated. funcs
+// maps the names of the functions and types that can be rewritten to the
+// functions rewriting calls of, or references to, them, which report
+// whether they could suggest a fix.
+func rewriteCalls(pass *analysis.Pass, path string, funcs map[string]func(*funcCall) bool) {
+	forEachFile(pass, func(pass *analysis.Pass, file *ast.File) {
+		spec := findImport(file, path)
+		if spec == nil {
+			return
+		}
+		pkg := importedPkgName(pass, spec)
+		if pkg == nil {
+			return
+		}
+
+		rw := newImportRewrite(file, spec)
+		var calls []*funcCall
+		for _, sel := range qualifiedRefs(pass, file, pkg) {
+			if _, ok := funcs[sel.Sel.Name]; !ok {
+				rw.unfixed++
+				rw.kept = true
+				continue
+			}
+			path, _ := astutil.PathEnclosingInterval(file, sel.Pos(), sel.End())
+			call, ok := path[1].(*ast.CallExpr)
+			if _, isType := pass.TypesInfo.Uses[sel.Sel].(*types.TypeName); isType {
+				call = nil
+			} else if !ok || call.Fun != sel {
+				// Function values cannot be rewritten.
+				rw.unfixed++
+				rw.kept = true
+				continue
+			}
+			calls = append(calls, &funcCall{
+				pass: pass,
+				rw:   rw,
+				sel:  sel,
+				call: call,
+				path: path[1:],
+			})
+		}
+
+		// The diagnostics are held back since a replacement may only reuse
+		// the name of the migrated import if every call can be rewritten,
+		// which is known only once they have been.
+		rewrite := func() []analysis.Diagnostic {
+			var diags []analysis.Diagnostic
+			p := *pass
+			p.Report = func(d analysis.Diagnostic) { diags = append(diags, d) }
+			for _, c := range calls {
+				c.pass = &p
+				if funcs[c.sel.Sel.Name](c) {
+					rw.fixed++
+				} else {
+					rw.unfixed++
+				}
+				c.pass = pass
+			}
+			return diags
+		}
+		diags := rewrite()
+		if rw.unfixed > 0 && !rw.kept && rw.reusesName(pass) {
+			rw = newImportRewrite(file, spec)
+			rw.kept = true
+			for _, c := range calls {
+				c.rw = rw
+			}
+			diags = rewrite()
+		}
+		for _, d := range diags {
+			pass.Report(d)
+		}
+		rw.report(pass)
+	})
+}
+
+// importRewrite describes the migration of one v1 import in a file to its
+// v2 replacements.
+type importRewrite struct {
+	file *ast.File
+	spec *ast.ImportSpec
+
+	// paths lists the import paths of the replacement packages in the order
+	// suggested fixes first required them, and names how rewritten
+	// references qualify each of them.
+	paths []string
+	names map[string]string
+
+	// fixed counts references through spec that have a suggested fix,
+	// unfixed those that do not.
+	fixed   int
+	unfixed int
+
+	// refs holds the edits of references that have no diagnostic of their
+	// own, and are fixed along with the import.
+	refs []analysis.TextEdit
+
+	// removed holds the statements the suggested fixes delete, whose
+	// imports go along with them if nothing else uses them.
+	removed []ast.Stmt
+
+	// kept reports whether some references through spec are known to be
+	// left alone, so that the replacements cannot reuse its name.
+	kept bool
+
+	// after, if set, is the import the replacements are added after,
+	// rather than next to spec or in place of it, for when spec is in the
+	// group of the standard library imports.
+	after *ast.ImportSpec
+}
+
+// newImportRewrite prepares the migration of spec.
+func newImportRewrite(file *ast.File, spec *ast.ImportSpec) *importRewrite {
+	return &importRewrite{
+		file:  file,
+		spec:  spec,
+		names: map[string]string{},
+	}
+}
+
+// qualifier returns the name rewritten references use to qualify members
+// of the package imported by newPath: the file's existing name for it, if
+// it is already imported, or the last element of newPath otherwise, with a
+// v2 suffix if the file already uses that name for something else.
+func (r *importRewrite) qualifier(pass *analysis.Pass, newPath string) string {
+	if name, ok := r.names[newPath]; ok {
+		return name
+	}
+	name := path.Base(newPath)
+	if imp := findImport(r.file, newPath); imp != nil {
+		if pkg := importedPkgName(pass, imp); pkg != nil {
+			name = pkg.Name()
+		}
+	} else if r.declares(pass, name) {
+		name += "v2"
+	}
+	r.names[newPath] = name
+	return name
+}
+
+// provided reports whether the file imports newPath, or imports a v1
+// well-known type package that checkWKT replaces with it.
+func (r *importRewrite) provided(newPath string) bool {
+	if findImport(r.file, newPath) != nil {
+		return true
+	}
+	for _, spec := range r.file.Imports {
+		if spec != r.spec && wktPaths[importPath(spec)] == newPath {
+			return true
+		}
+	}
+	return false
+}
+
+// declares reports whether the file declares name at package or file
+// scope, other than by the migrated import if it is going away.
+func (r *importRewrite) declares(pass *analysis.Pass, name string) bool {
+	if pass.Pkg.Scope().Lookup(name) != nil {
+		return true
+	}
+	for _, spec := range r.file.Imports {
+		if spec == r.spec && !r.kept {
+			continue
+		}
+		if pkg := importedPkgName(pass, spec); pkg != nil && pkg.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reusesName reports whether a replacement is qualified with the name of
+// the migrated import.
+func (r *importRewrite) reusesName(pass *analysis.Pass) bool {
+	pkg := importedPkgName(pass, r.spec)
+	for _, path := range r.paths {
+		if pkg != nil && r.names[path] == pkg.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// require records that a suggested fix refers to the package imported by
+// newPath.
+func (r *importRewrite) require(pass *analysis.Pass, newPath string) {
+	r.qualifier(pass, newPath)
+	for _, path := range r.paths {
+		if path == newPath {
+			return
+		}
+	}
+	r.paths = append(r.paths, newPath)
+}
+
+// report reports the import, with a fix that replaces it when every
+// reference through it has been fixed, or that adds the replacements next
+// to it otherwise. Nothing is reported if no reference was fixed.
+func (r *importRewrite) report(pass *analysis.Pass) {
+	if r.fixed == 0 {
+		return
+	}
+	msg := fmt.Sprintf("%s should be replaced with %s", writtenPath(r.spec), strings.Join(r.paths, " and "))
+	if len(r.paths) == 0 {
+		// The references were rewritten to methods of the messages.
+		msg = writtenPath(r.spec) + " should be removed"
+	}
+	if m, ok := facts.LookupImportPath(writtenPath(r.spec)); ok {
+		msg += ", since it stands for " + m.Path
+		if m.Note != "" {
+			msg += ": " + m.Note
+		}
+	}
+
+	edits := append(append([]analysis.TextEdit(nil), r.refs...), r.importEdits(pass)...)
+	edits = append(edits, r.orphanedImports(pass)...)
+	if len(edits) == 0 {
+		// References that cannot be rewritten keep using the old import,
+		// and the replacements are already available.
+		report.Report(pass, r.spec, msg)
+		return
+	}
+	name := "Import " + strings.Join(r.missing(), " and ")
+	switch {
+	case len(r.refs) > 0:
+		name = "Use " + strings.Join(r.paths, " and ")
+	case r.unfixed == 0 && len(r.missing()) == 0:
+		name = "Remove the import"
+	}
+	report.Report(pass, r.spec, msg, report.Fixes(edit.Fix(name, edits...)))
+}
+
+// missing returns the import paths of the replacements the file does not
+// provide, in order.
+func (r *importRewrite) missing() []string {
+	var missing []string
+	for _, path := range r.paths {
+		if !r.provided(path) {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
+
+// importEdits returns the edits of the import itself: removing it, or
+// replacing it with the first missing replacement, when every reference
+// through it has been fixed, and adding the missing replacements next to
+// it otherwise.
+func (r *importRewrite) importEdits(pass *analysis.Pass) []analysis.TextEdit {
+	missing := r.missing()
+	switch {
+	case r.unfixed == 0 && len(missing) == 0:
+		return []analysis.TextEdit{deleteLines(pass, r.spec)}
+	case r.unfixed == 0 && r.after != nil:
+		return []analysis.TextEdit{deleteLines(pass, r.spec), r.addImports(pass, missing)}
+	case r.unfixed == 0:
+		text := importSpecText(r.names[missing[0]], missing[0])
+		edits := []analysis.TextEdit{edit.ReplaceWithString(pass.Fset, r.spec, text)}
+		if len(missing) > 1 {
+			edits = append(edits, r.addImports(pass, missing[1:]))
+		}
+		return edits
+	case len(missing) == 0:
+		return nil
+	default:
+		return []analysis.TextEdit{r.addImports(pass, missing)}
+	}
+}
+
+// orphanedImports returns the edits deleting the other imports of the
+// file whose every reference is in a statement the fixes delete.
+func (r *importRewrite) orphanedImports(pass *analysis.Pass) []analysis.TextEdit {
+	if len(r.removed) == 0 {
+		return nil
+	}
+	var edits []analysis.TextEdit
+	for _, spec := range r.file.Imports {
+		pkg := importedPkgName(pass, spec)
+		if spec == r.spec || pkg == nil {
+			continue
+		}
+		refs := qualifiedRefs(pass, r.file, pkg)
+		orphaned := len(refs) > 0
+		for _, ref := range refs {
+			if !r.removes(ref) {
+				orphaned = false
+				break
+			}
+		}
+		if orphaned {
+			edits = append(edits, deleteLines(pass, spec))
+		}
+	}
+	return edits
+}
+
+// removes reports whether node is in a statement the fixes delete.
+func (r *importRewrite) removes(node ast.Node) bool {
+	for _, stmt := range r.removed {
+		if stmt.Pos() <= node.Pos() && node.End() <= stmt.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// addImports returns an edit adding imports of paths on the lines after
+// the migrated import, or after r.after if it is set.
+func (r *importRewrite) addImports(pass *analysis.Pass, paths []string) analysis.TextEdit {
+	anchor := r.spec
+	if r.after != nil {
+		anchor = r.after
+	}
+	prefix := "\nimport "
+	for _, decl := range r.file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for _, s := range gen.Specs {
+			if s == anchor && gen.Lparen.IsValid() {
+				prefix = "\n\t"
+			}
+		}
+	}
+	var text string
+	for _, path := range paths {
+		text += prefix + importSpecText(r.names[path], path)
+	}
+	return insert(lineEnd(pass, anchor.End()), text)
+}
+
+// importSpecText renders an import of path under name, omitting the name
+// when it matches the last element of path.
+func importSpecText(name, importPath string) string {
+	if name == path.Base(importPath) {
+		return strconv.Quote(importPath)
+	}
+	return name + " " + strconv.Quote(importPath)
+}
+
+// deleteLines returns an edit removing the lines node spans, including the
+// trailing newline.
+func deleteLines(pass *analysis.Pass, node ast.Node) analysis.TextEdit {
+	tf := pass.Fset.File(node.Pos())
+	start := tf.LineStart(tf.Line(node.Pos()))
+	return analysis.TextEdit{Pos: start, End: lineEnd(pass, node.End()) + 1}
+}
+
+// lineEnd returns the position of the newline ending the line pos is on,
+// so that text inserted there follows any trailing comment.
+func lineEnd(pass *analysis.Pass, pos token.Pos) token.Pos {
+	tf := pass.Fset.File(pos)
+	line := tf.Line(pos)
+	if line < tf.LineCount() {
+		return tf.LineStart(line+1) - 1
+	}
+	// gofmt terminates the last line with a newline too.
+	return token.Pos(tf.Base()+tf.Size()) - 1
+}
+
+// indentation returns the indentation of the line pos is on, assuming
+// gofmt'ed source.
+func indentation(pass *analysis.Pass, pos token.Pos) string {
+	return strings.Repeat("\t", pass.Fset.Position(pos).Column-1)
+}
+
+// freshName returns an identifier based on base that is neither declared
+// in the scope enclosing pos nor used in any of the nodes in avoid.
+func freshName(pass *analysis.Pass, pos token.Pos, base string, avoid ...ast.Node) string {
+	used := map[string]bool{}
+	for _, node := range avoid {
+		ast.Inspect(node, func(node ast.Node) bool {
+			if id, ok := node.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+			return true
+		})
+	}
+	scope := pass.Pkg.Scope().Innermost(pos)
+	for i := 0; ; i++ {
+		name := base
+		if i > 0 {
+			name = base + strconv.Itoa(i)
+		}
+		if used[name] {
+			continue
+		}
+		if scope != nil {
+			if _, obj := scope.LookupParent(name, pos); obj != nil {
+				continue
+			}
+		}
+		return name
+	}
+}
+
+// marshalAndWrite returns the edits expanding stmt, which consists of call,
+// a call writing the message it is passed as its second argument to the
+// io.Writer it is passed as its first, into the call without the writer,
+// returning the encoded message instead, followed by a write of the
+// result.
+func marshalAndWrite(pass *analysis.Pass, stmt ast.Stmt, call *ast.CallExpr) []analysis.TextEdit {
+	w := report.Render(pass, call.Args[0])
+	indent := indentation(pass, stmt.Pos())
+	b := freshName(pass, stmt.Pos(), "b", call)
+	dropWriter := edit.Delete(edit.Range{call.Args[0].Pos(), call.Args[1].Pos()})
+	end := lineEnd(pass, stmt.End())
+
+	var (
+		lhs ast.Expr
+		tok token.Token
+	)
+	if assign, ok := stmt.(*ast.AssignStmt); ok {
+		lhs, tok = assign.Lhs[0], assign.Tok
+		if id, ok := lhs.(*ast.Ident); ok && id.Name == "_" {
+			lhs = nil
+		}
+	}
+
+	if lhs == nil {
+		// The error was ignored, so only write the message if it could be
+		// marshaled.
+		err := freshName(pass, stmt.Pos(), "err", call)
+		edits := []analysis.TextEdit{
+			edit.ReplaceWithString(pass.Fset, edit.Range{stmt.Pos(), call.Pos()}, fmt.Sprintf("if %s, %s := ", b, err)),
+			dropWriter,
+		}
+		cond := fmt.Sprintf("; %s == nil {", err)
+		body := fmt.Sprintf("\n%s\t%s.Write(%s)\n%s}", indent, w, b, indent)
+		if end == stmt.End() {
+			// With no trailing comment in between, a single edit makes up
+			// the condition and the body.
+			return append(edits, insert(end, cond+body))
+		}
+		return append(edits, insert(stmt.End(), cond), insert(end, body))
+	}
+
+	err := report.Render(pass, lhs)
+	decl := b + ", "
+	if tok == token.ASSIGN {
+		decl = fmt.Sprintf("var %s []byte\n%s%s, ", b, indent, b)
+	}
+	return []analysis.TextEdit{
+		insert(lhs.Pos(), decl),
+		dropWriter,
+		insert(end, fmt.Sprintf("\n%sif %s == nil {\n%s\t_, %s = %s.Write(%s)\n%s}", indent, err, indent, err, w, b, indent)),
+	}
+}
+
+// readAndUnmarshal returns the edits expanding stmt, which consists of
+// call, a call unmarshaling the message it is passed as its second argument
+// from the io.Reader it is passed as its first, into a read of the whole
+// reader with the ReadAll function of pkg, the name of the io or io/ioutil
+// import, followed by the call unmarshaling the result instead if the read
+// succeeded.
+func readAndUnmarshal(pass *analysis.Pass, stmt ast.Stmt, call *ast.CallExpr, pkg string) []analysis.TextEdit {
+	read := fmt.Sprintf("%s.ReadAll(%s)", pkg, report.Render(pass, call.Args[0]))
+	indent := indentation(pass, stmt.Pos())
+	b := freshName(pass, stmt.Pos(), "b", call)
+	useBytes := edit.ReplaceWithString(pass.Fset, call.Args[0], b)
+	closeIf := insert(lineEnd(pass, stmt.End()), "\n"+indent+"}")
+
+	var (
+		lhs ast.Expr
+		tok token.Token
+	)
+	if assign, ok := stmt.(*ast.AssignStmt); ok {
+		lhs, tok = assign.Lhs[0], assign.Tok
+		if id, ok := lhs.(*ast.Ident); ok && id.Name == "_" {
+			lhs = nil
+		}
+	}
+
+	if lhs == nil {
+		// The error was ignored, so only unmarshal the message if it could
+		// be read.
+		err := freshName(pass, stmt.Pos(), "err", call)
+		text := fmt.Sprintf("if %s, %s := %s; %s == nil {\n%s\t", b, err, read, err, indent)
+		return []analysis.TextEdit{
+			edit.ReplaceWithString(pass.Fset, edit.Range{stmt.Pos(), call.Pos()}, text),
+			useBytes,
+			closeIf,
+		}
+	}
+
+	err := report.Render(pass, lhs)
+	decl := fmt.Sprintf("%s, %s := ", b, err)
+	if tok == token.ASSIGN {
+		decl = fmt.Sprintf("var %s []byte\n%s%s, %s = ", b, indent, b, err)
+	}
+	text := fmt.Sprintf("%s%s\n%sif %s == nil {\n%s\t%s = ", decl, read, indent, err, indent, err)
+	return []analysis.TextEdit{
+		edit.ReplaceWithString(pass.Fset, edit.Range{stmt.Pos(), call.Pos()}, text),
+		useBytes,
+		closeIf,
+	}
+}
+
+// writerStmt returns the statement a call writing a message to an
+// io.Writer makes up, given the nodes enclosing the call, if it can be
+// expanded by marshalAndWrite.
+func writerStmt(path []ast.Node) (ast.Stmt, bool) {
+	var stmt ast.Stmt
+	switch parent := path[1].(type) {
+	case *ast.ExprStmt:
+		stmt = parent
+	case *ast.AssignStmt:
+		if len(parent.Lhs) != 1 || len(parent.Rhs) != 1 {
+			return nil, false
+		}
+		stmt = parent
+	default:
+		return nil, false
+	}
+	_, _, ok := stmtList(path[2], stmt)
+	return stmt, ok
+}
+
+// stmtList returns the statement list of parent, which must contain stmt,
+// and the index of the statement following stmt.
+func stmtList(parent ast.Node, stmt ast.Stmt) ([]ast.Stmt, int, bool) {
+	var list []ast.Stmt
+	switch parent := parent.(type) {
+	case *ast.BlockStmt:
+		list = parent.List
+	case *ast.CaseClause:
+		list = parent.Body
+	case *ast.CommClause:
+		list = parent.Body
+	default:
+		return nil, 0, false
+	}
+	for i, s := range list {
+		if s == stmt {
+			return list, i + 1, true
+		}
+	}
+	return nil, 0, false
+}
+
+// isOperand reports whether expr can be used as the operand of a selector
+// expression without parentheses.
+func isOperand(expr ast.Expr) bool {
+	switch expr.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.CallExpr, *ast.IndexExpr, *ast.ParenExpr, *ast.CompositeLit:
+		return true
+	default:
+		return false
+	}
+}
+
+// methodCall returns the edits turning call, a call of a function f(x,
+// args...), into a call of the method x.method(args...).
+func methodCall(call *ast.CallExpr, method string) []analysis.TextEdit {
+	x := call.Args[0]
+	open, sel := "", "."+method+"("
+	if !isOperand(x) {
+		open, sel = "(", ")"+sel
+	}
+	end := call.Rparen
+	if len(call.Args) > 1 {
+		end = call.Args[1].Pos()
+	}
+	return []analysis.TextEdit{
+		{Pos: call.Pos(), End: x.Pos(), NewText: []byte(open)},
+		{Pos: x.End(), End: end, NewText: []byte(sel)},
+	}
+}
+
+// insert returns an edit inserting text at pos.
+func insert(pos token.Pos, text string) analysis.TextEdit {
+	return analysis.TextEdit{Pos: pos, End: pos, NewText: []byte(text)}
+}
+
+// isSimpleExpr reports whether expr is an identifier or a chain of field
+// selections, which can be evaluated again, or in a different order,
+// without changing the program's behavior.
+func isSimpleExpr(expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		return isSimpleExpr(expr.X)
+	case *ast.ParenExpr:
+		return isSimpleExpr(expr.X)
+	default:
+		return false
+	}
+}
+
+// v1MessageNote completes the diagnostics of calls that cannot be rewritten
+// because their message, of type t, does not implement the v2 API. Messages
+// generated by old versions of protoc-gen-go only implement the v1
+// interface; the note tells which one generated the file of t, if it is
+// known.
+func v1MessageNote(pass *analysis.Pass, t types.Type) string {
+	if t != nil && types.IsInterface(t) {
+		return "; the message is only known to implement the v1 API, so it needs converting with proto.MessageV2 first"
+	}
+	note := "; the message does not implement the v2 API, so regenerate it first"
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return note
+	}
+	files, ok := pass.ResultOf[facts.Protoc].(map[*types.Package]*facts.ProtoFiles)[named.Obj().Pkg()]
+	if !ok {
+		return note
+	}
+	name := filepath.Base(pass.Fset.PositionFor(named.Obj().Pos(), false).Filename)
+	if f, ok := files.File(name); ok {
+		note += fmt.Sprintf(": %s was generated by %s", name, f.Generator())
+	}
+	return note
+}
+
+// hasProtoReflect reports whether values of type t implement the v2
+// message API.
+func hasProtoReflect(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, false, nil, "ProtoReflect")
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// typeString renders t as the file refers to it, reporting false if it
+// involves a package the file does not import.
+func typeString(pass *analysis.Pass, file *ast.File, t types.Type) (string, bool) {
+	ok := true
+	s := types.TypeString(t, func(pkg *types.Package) string {
+		if pkg == pass.Pkg {
+			return ""
+		}
+		// The types of the v1 well-known type packages are aliases, which
+		// are written as checkWKT rewrites them.
+		p := pkgPath(pkg)
+		if newPath, ok := wktPaths[p]; ok {
+			p = newPath
+		}
+		for _, spec := range file.Imports {
+			if importPath(spec) == p {
+				if name := importedPkgName(pass, spec); name != nil {
+					return name.Name()
+				}
+			}
+			if wktPaths[importPath(spec)] == p {
+				return newImportRewrite(file, spec).qualifier(pass, p)
+			}
+		}
+		ok = false
+		return pkg.Name()
+	})
+	return s, ok
+}
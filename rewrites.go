@@ -0,0 +1,232 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+// A Rewrite is a declarative rule rewriting the references to a symbol of
+// a package, like a function of the protobuf wrappers of a company, as the
+// built-in checks rewrite those of the v1 API: the import of the package
+// is replaced by those of the replacement once no reference is left.
+type Rewrite struct {
+	// ID and Name are those of the rule of the findings, and Doc and URL
+	// its description and documentation, if any.
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+	Doc  string `yaml:"doc"`
+	URL  string `yaml:"url"`
+
+	// Package is the import path of the package of the symbol, and Symbol
+	// its name, like example.com/pbutil and MarshalText.
+	Package string `yaml:"package"`
+	Symbol  string `yaml:"symbol"`
+
+	// Replacement is the Go expression replacing the references: that
+	// of the symbol, like pbv2.MarshalText, or else that of the calls of
+	// the symbol, in which $1 to $9 stand for their arguments and $* for
+	// all of them, like pbv2.Format($1, pbv2.Options{}). The calls it
+	// does not have the arguments of, and the references other than calls
+	// if it has any, are reported without a fix.
+	Replacement string `yaml:"replacement"`
+
+	// Imports are the import paths of the packages the replacement refers
+	// to, by the last elements of the paths, which are renamed in the
+	// files that import them under other names.
+	Imports []string `yaml:"imports"`
+
+	// Message is the message of the findings, by default that the symbol
+	// should be replaced with the replacement.
+	Message string `yaml:"message"`
+
+	// FixLevel is the safety of the fixes, as that of a Plugin.
+	FixLevel string `yaml:"fix-level"`
+}
+
+// placeholder matches the placeholders of the arguments of a Replacement.
+var placeholder = regexp.MustCompile(`\$([1-9*])`)
+
+// RegisterRewrites registers rws as plugins, one per package, since the
+// import edits of a file are shared by the references through an import.
+// As Register, it has to be called before the flags naming their rules
+// are parsed.
+func RegisterRewrites(rws []Rewrite) error {
+	var pkgs []string
+	byPkg := map[string][]Rewrite{}
+	for _, rw := range rws {
+		if err := rw.validate(); err != nil {
+			return err
+		}
+		if _, ok := byPkg[rw.Package]; !ok {
+			pkgs = append(pkgs, rw.Package)
+		}
+		byPkg[rw.Package] = append(byPkg[rw.Package], rw)
+	}
+	for _, pkg := range pkgs {
+		var p Plugin
+		least := fixSafe
+		funcs := map[string]func(*funcCall) bool{}
+		for _, rw := range byPkg[pkg] {
+			rw := rw
+			if _, ok := funcs[rw.Symbol]; ok {
+				return fmt.Errorf("rewrite %s: %s.%s is rewritten twice", rw.ID, rw.Package, rw.Symbol)
+			}
+			funcs[rw.Symbol] = rw.rewrite
+			p.Rules = append(p.Rules, Rule{ID: rw.ID, Name: rw.Name, Doc: rw.Doc, URL: rw.URL})
+			level := fixSafe
+			if rw.FixLevel != "" {
+				level.Set(rw.FixLevel)
+			}
+			if level > least {
+				least = level
+			}
+		}
+		p.FixLevel = least.String()
+		pkg := pkg
+		p.Run = func(pass *analysis.Pass) (interface{}, error) {
+			rewriteCalls(pass, pkg, funcs)
+			return nil, nil
+		}
+		if err := Register(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate reports whether rw has the fields it needs, and a replacement
+// that parses once its placeholders are replaced.
+func (rw *Rewrite) validate() error {
+	if rw.ID == "" || rw.Name == "" {
+		return fmt.Errorf("rewrite %q %q has no ID or name", rw.ID, rw.Name)
+	}
+	if rw.Package == "" || rw.Symbol == "" || rw.Replacement == "" {
+		return fmt.Errorf("rewrite %s has no package, symbol or replacement", rw.ID)
+	}
+	if rw.FixLevel != "" {
+		var level fixLevel
+		if err := level.Set(rw.FixLevel); err != nil {
+			return fmt.Errorf("rewrite %s: %v", rw.ID, err)
+		}
+	}
+	expr := placeholder.ReplaceAllString(rw.Replacement, "_pm$1")
+	expr = strings.Replace(expr, "_pm*", "_pmargs", -1)
+	if _, err := parser.ParseExpr(expr); err != nil {
+		return fmt.Errorf("rewrite %s: replacement %q is not an expression: %v", rw.ID, rw.Replacement, err)
+	}
+	for _, imp := range rw.Imports {
+		// The replacement refers to the package by the last element of
+		// its path.
+		if !token.IsIdentifier(path.Base(imp)) {
+			return fmt.Errorf("rewrite %s: import %q does not end with an identifier", rw.ID, imp)
+		}
+	}
+	return nil
+}
+
+// rewrite rewrites the reference of c, reporting whether it could suggest
+// a fix.
+func (rw *Rewrite) rewrite(c *funcCall) bool {
+	msg := rw.Message
+	if msg == "" {
+		msg = fmt.Sprintf("%s.%s should be replaced with %s", path.Base(rw.Package), rw.Symbol, rw.Replacement)
+	}
+	var node ast.Node = c.sel
+	if c.call != nil && placeholder.MatchString(rw.Replacement) {
+		node = c.call
+	}
+	text, ok := rw.expand(c)
+	if !ok {
+		c.pass.Report(analysis.Diagnostic{Pos: node.Pos(), End: node.End(), Category: rw.ID, Message: msg})
+		return false
+	}
+	// The qualifiers of the imports are those of the file.
+	for _, imp := range rw.Imports {
+		c.rw.require(c.pass, imp)
+		if q, base := c.rw.qualifier(c.pass, imp), path.Base(imp); q != base {
+			text = regexp.MustCompile(`\b`+regexp.QuoteMeta(base)+`\.`).ReplaceAllString(text, q+".")
+		}
+	}
+	text = rw.substitute(c, text)
+	fix := edit.Fix("Use "+rw.Replacement, edit.ReplaceWithString(c.pass.Fset, node, text))
+	c.pass.Report(analysis.Diagnostic{
+		Pos:            node.Pos(),
+		End:            node.End(),
+		Category:       rw.ID,
+		Message:        msg,
+		SuggestedFixes: []analysis.SuggestedFix{fix},
+	})
+	return true
+}
+
+// expand returns the replacement of the reference of c, with its
+// placeholders left, and whether c has the arguments they stand for.
+func (rw *Rewrite) expand(c *funcCall) (string, bool) {
+	uses := placeholder.FindAllStringSubmatch(rw.Replacement, -1)
+	if len(uses) == 0 {
+		return rw.Replacement, true
+	}
+	if c.call == nil {
+		return "", false
+	}
+	for _, m := range uses {
+		if m[1] == "*" {
+			continue
+		}
+		if n, _ := strconv.Atoi(m[1]); n > len(c.call.Args) || c.call.Ellipsis.IsValid() {
+			// The arguments spread by ... are only passed on whole.
+			return "", false
+		}
+	}
+	return rw.Replacement, true
+}
+
+// substitute returns text with its placeholders replaced by the arguments
+// of the call of c, parenthesized unless they are operands or passed as
+// arguments again.
+func (rw *Rewrite) substitute(c *funcCall, text string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(text[last:loc[0]])
+		last = loc[1]
+		if text[loc[2]:loc[3]] == "*" {
+			args := make([]string, len(c.call.Args))
+			for i, arg := range c.call.Args {
+				args[i] = report.Render(c.pass, arg)
+			}
+			s := strings.Join(args, ", ")
+			if c.call.Ellipsis.IsValid() {
+				s += "..."
+			}
+			b.WriteString(s)
+			continue
+		}
+		n, _ := strconv.Atoi(text[loc[2]:loc[3]])
+		arg := c.call.Args[n-1]
+		s := report.Render(c.pass, arg)
+		before := strings.TrimRight(text[:loc[0]], " \t")
+		after := strings.TrimLeft(text[loc[1]:], " \t")
+		passed := (strings.HasSuffix(before, "(") || strings.HasSuffix(before, ",")) &&
+			(strings.HasPrefix(after, ")") || strings.HasPrefix(after, ","))
+		if !passed && !isOperand(arg) {
+			s = "(" + s + ")"
+		}
+		b.WriteString(s)
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
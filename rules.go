@@ -0,0 +1,172 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// A Rule is a kind of finding reported by Analyzer. The category of each
+// diagnostic is the ID of its rule.
+type Rule struct {
+	// ID is the stable identifier of the rule, like PM1001.
+	ID string
+
+	// Name is the mnemonic of the rule, like import-deprecated.
+	Name string
+
+	// Doc describes the findings of the rule.
+	Doc string
+
+	// URL locates the documentation of the migration of the findings: that
+	// of the v2 package replacing the v1 API, or the catalog of the rules.
+	URL string
+}
+
+// catalogURL locates the catalog of the rules, for those the protobuf
+// documentation does not cover.
+const catalogURL = "https://pkg.go.dev/github.com/protobuf-tools/protomigrate/cmd/protomigrate"
+
+// rules lists the rules by ID. The first digit of an ID groups the rules
+// about the same v1 package, or with 8, about the uses of messages the v2
+// runtime breaks; IDs are never reused.
+var rules = []Rule{
+	{"PM1001", "import-deprecated", "Imports of deprecated packages", "https://go.dev/blog/protobuf-apiv2"},
+	{"PM1002", "symbol-deprecated", "Uses of deprecated identifiers", "https://go.dev/blog/protobuf-apiv2"},
+	{"PM1003", "module-version", "Modules on a version of github.com/golang/protobuf calling for another migration, reported in go.mod", "https://go.dev/blog/protobuf-apiv2"},
+	{"PM1004", "strict", "Uses of the v1 API left in packages declared fully migrated, with -strict", catalogURL},
+	{"PM1005", "dependency-version", "Versions of genproto and grpc-go that conflict with the targeted version of google.golang.org/protobuf, reported in go.mod", "https://protobuf.dev/reference/go/faq/#namespace-conflict"},
+	{"PM1006", "module-replace", "Replacements of the protobuf modules with forks or directories, reported in go.mod", catalogURL},
+	{"PM1007", "codegen-version", "Versions of grpc-go and google.golang.org/protobuf older than the generated code needs, reported in go.mod", catalogURL},
+	{"PM2001", "jsonpb", "Uses of package jsonpb, which package protojson replaces", "https://pkg.go.dev/google.golang.org/protobuf/encoding/protojson"},
+	{"PM2002", "jsonpb-options", "Options of jsonpb.Marshaler and jsonpb.Unmarshaler with no protojson translation", "https://pkg.go.dev/google.golang.org/protobuf/encoding/protojson"},
+	{"PM2003", "gateway-jsonpb", "Marshalers of grpc-gateway v1 configured with the options of jsonpb, which those of protojson replace in grpc-gateway v2", "https://grpc-ecosystem.github.io/grpc-gateway/docs/development/grpc-gateway_v2_migration_guide/"},
+	{"PM3001", "proto", "Uses of the functions of package proto that have moved to other v2 packages", "https://pkg.go.dev/google.golang.org/protobuf/proto"},
+	{"PM3002", "protoadapt", "Type assertions between the v1 and v2 message interfaces, which protoadapt replaces", "https://pkg.go.dev/google.golang.org/protobuf/protoadapt"},
+	{"PM4001", "descriptor", "Uses of package descriptor, which package protodesc replaces", "https://pkg.go.dev/google.golang.org/protobuf/reflect/protodesc"},
+	{"PM5001", "ptypes", "Uses of the ptypes helpers, which the methods and constructors of the well-known types replace", "https://pkg.go.dev/google.golang.org/protobuf/types/known"},
+	{"PM6001", "wkt", "Imports of the v1 well-known type packages, which the types/known packages replace", "https://pkg.go.dev/google.golang.org/protobuf/types/known"},
+	{"PM7001", "gogo", "Files generated by protoc-gen-gogo, which have to be regenerated with protoc-gen-go", catalogURL},
+	{"PM7002", "go-generate", "go:generate directives running protoc with the options of the v1 or gogo plugins", catalogURL},
+	{"PM7003", "old-codegen", "Files generated by protoc-gen-go before v1.4, for the v1 API, which have to be regenerated", catalogURL},
+	{"PM7004", "buf-plugins", "Plugins of buf.gen.yaml files generating code for the v1 API, like those of gogo protobuf, reported in buf.gen.yaml", catalogURL},
+	{"PM7005", "stale-codegen", "Files generated by protoc-gen-go that differ from their .proto files", catalogURL},
+	{"PM7006", "legacy-grpc", "Files generated by protoc-gen-go with plugins=grpc, whose gRPC services protoc-gen-go-grpc has to generate", catalogURL},
+	{"PM8001", "deep-equal", "Comparisons of messages with reflect.DeepEqual, which compares the internal state of v2 messages", "https://pkg.go.dev/google.golang.org/protobuf/proto#Equal"},
+	{"PM8002", "encoding-json", "Messages encoded with encoding/json, which does not follow the JSON mapping of protobuf", catalogURL},
+	{"PM8003", "message-copy", "Copies of message structs, which the v2 runtime does not allow", catalogURL},
+	{"PM8004", "pointer-equal", "Comparisons of messages with == where proto.Equal is likely meant", "https://pkg.go.dev/google.golang.org/protobuf/proto#Equal"},
+	{"PM8005", "map-key", "Maps keyed by message structs, which are not comparable once generated for the v2 API", catalogURL},
+	{"PM8006", "xxx-members", "Uses of the XXX_ fields and methods of messages, which are not generated for the v2 API", catalogURL},
+	{"PM8007", "aliasing", "Mutations of the sub-messages of shared messages, like those received from channels", catalogURL},
+	{"PM8008", "registry-conflict", "Packages linking in two registrations of the same .proto file or type, which the v2 runtime panics on", "https://protobuf.dev/reference/go/faq/#namespace-conflict"},
+	{"PM8009", "enum-maps", "Changes of the maps generated for enums, which no longer name their values", catalogURL},
+	{"PM8010", "custom-marshaler", "Marshal, Unmarshal and Merge methods of messages, which the v2 runtime ignores for the messages generated for it", catalogURL},
+}
+
+// optInRules holds the IDs of the rules whose findings are heuristic, so
+// only reported when asked to with -check.
+var optInRules = map[string]bool{
+	"PM8007": true,
+}
+
+// Rules returns the rules of the findings of Analyzer, in order of ID.
+func Rules() []Rule {
+	return append([]Rule(nil), rules...)
+}
+
+// LookupRule returns the rule with the given ID or name.
+func LookupRule(idOrName string) (Rule, bool) {
+	for _, r := range rules {
+		if r.ID == idOrName || r.Name == idOrName {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// DocURL returns the URL of the documentation of r: that -doc-url forms
+// from its ID and name, or else r.URL, or "" if -doc-url is none.
+func (r Rule) DocURL() string {
+	switch docURL {
+	case "":
+		return r.URL
+	case "none":
+		return ""
+	}
+	return strings.NewReplacer("{id}", r.ID, "{name}", r.Name).Replace(docURL)
+}
+
+// docNote returns the note ending the messages of the findings of r, which
+// links to its documentation.
+func (r Rule) docNote() string {
+	if u := r.DocURL(); u != "" {
+		return " (see " + u + ")"
+	}
+	return ""
+}
+
+// AddDocNote returns msg, the message of a finding of r, ending with the
+// link to the documentation of r, as the messages of Analyzer do.
+func (r Rule) AddDocNote(msg string) string {
+	return msg + r.docNote()
+}
+
+// TrimDocNote returns msg, the message of a finding of r, without the link
+// to the documentation of r ending it, so that it does not change with
+// -doc-url.
+func (r Rule) TrimDocNote(msg string) string {
+	return strings.TrimSuffix(msg, r.docNote())
+}
+
+// ruleSet is a flag.Value holding a comma-separated set of rules, given by
+// ID or name.
+type ruleSet map[string]bool
+
+func (s ruleSet) String() string {
+	ids := make([]string, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func (s ruleSet) Set(v string) error {
+	for id := range s {
+		delete(s, id)
+	}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		r, ok := LookupRule(name)
+		if !ok {
+			return fmt.Errorf("unknown rule: %q", name)
+		}
+		s[r.ID] = true
+	}
+	return nil
+}
+
+// RuleEnabled reports whether the findings of the rule with the given ID
+// are reported, as -enable, -disable and -check say.
+func RuleEnabled(id string) bool {
+	if optInRules[id] && !checkedRules[id] {
+		return false
+	}
+	return (len(enabledRules) == 0 || enabledRules[id]) && !disabledRules[id]
+}
+
+// reportRule reports msg at node as a finding of the rule with the given
+// ID, rather than of the default rule of the check.
+func reportRule(pass *analysis.Pass, node ast.Node, id, msg string) {
+	pass.Report(analysis.Diagnostic{Pos: node.Pos(), End: node.End(), Category: id, Message: msg})
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ssa"
+)
+
+// srcFuncs builds the SSA form of the package of pass, as buildssa does,
+// and returns its source functions, including literals, in source order,
+// but for those of the files skipped.
+//
+// Analyzer does not require buildssa, whose Run every package would pay
+// for, as it doubles the time and memory of the analysis: the few checks
+// needing SSA call srcFuncs only once they are enabled.
+func srcFuncs(pass *analysis.Pass, skipped map[*ast.File]bool) []*ssa.Function {
+	var files []*ast.File
+	for _, file := range pass.Files {
+		if !skipped[file] {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	prog := ssa.NewProgram(pass.Fset, 0)
+	created := map[*types.Package]bool{}
+	var createAll func(pkgs []*types.Package)
+	createAll = func(pkgs []*types.Package) {
+		for _, p := range pkgs {
+			if !created[p] {
+				created[p] = true
+				prog.CreatePackage(p, nil, nil, true)
+				createAll(p.Imports())
+			}
+		}
+	}
+	createAll(pass.Pkg.Imports())
+	prog.CreatePackage(pass.Pkg, pass.Files, pass.TypesInfo, false).Build()
+
+	var funcs []*ssa.Function
+	var addAnons func(fn *ssa.Function)
+	addAnons = func(fn *ssa.Function) {
+		funcs = append(funcs, fn)
+		for _, anon := range fn.AnonFuncs {
+			addAnons(anon)
+		}
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			// SSA builds no function for a FuncDecl named blank.
+			if decl, ok := decl.(*ast.FuncDecl); ok && decl.Name.Name != "_" {
+				addAnons(prog.FuncValue(pass.TypesInfo.Defs[decl.Name].(*types.Func)))
+			}
+		}
+	}
+	return funcs
+}
@@ -0,0 +1,199 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+	"github.com/protobuf-tools/protomigrate/internal/protosrc"
+)
+
+// maxStaleDiffs is the number of the differences between a generated file
+// and its .proto file a finding lists.
+const maxStaleDiffs = 5
+
+// maxProtoSize is the size of the largest .proto file checkStale compares
+// generated files to, beyond which the comparison would cost more than the
+// whole analysis of the package.
+const maxProtoSize = 1 << 20
+
+// checkStale reports the files generated by protoc-gen-go that are stale:
+// the messages and fields of the .proto file their header names, when it is
+// found, differ from those they have code for, as the protobuf struct tags
+// of the code tell. Migrating the imports of stale code, or the code using
+// it, breaks in ways that have nothing to do with the migration, so they
+// are to be regenerated first.
+func checkStale(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		gen, ok := Generator(pass, file.Pos())
+		if !ok || gen != facts.ProtocGenGo && gen != facts.ProtocGenGoV2 {
+			continue
+		}
+		source := protoSource(file)
+		if source == "" {
+			continue
+		}
+		dir := filepath.Dir(pass.Fset.PositionFor(file.Pos(), false).Filename)
+		rel, ok := locateProto(dir, source)
+		if !ok {
+			continue
+		}
+		data, ok := readProto(filepath.Join(dir, filepath.FromSlash(rel)))
+		if !ok {
+			continue
+		}
+		diffs := staleDiffs(pass, file, protosrc.Parse(data))
+		if len(diffs) == 0 {
+			continue
+		}
+		if len(diffs) > maxStaleDiffs {
+			diffs = append(diffs[:maxStaleDiffs], fmt.Sprintf("%d more", len(diffs)-maxStaleDiffs))
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     file.Package,
+			End:     file.Name.End(),
+			Message: fmt.Sprintf("generated file is stale, since it differs from %s: %s; regenerate it before migrating", rel, strings.Join(diffs, ", ")),
+		})
+	}
+	return nil, nil
+}
+
+// readProto returns the content of the named .proto file, unless it cannot
+// be read or is larger than maxProtoSize.
+func readProto(name string) ([]byte, bool) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxProtoSize+1))
+	if err != nil || len(data) > maxProtoSize {
+		return nil, false
+	}
+	return data, true
+}
+
+// staleDiffs returns the differences between the messages and fields of src
+// and those file, generated from it, has code for.
+func staleDiffs(pass *analysis.Pass, file *ast.File, src *protosrc.File) []string {
+	generated := generatedFields(pass, file)
+	var diffs []string
+	for _, m := range src.Messages {
+		fields, ok := generated[m.GoName()]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("message %s is missing", m.Name))
+			continue
+		}
+		delete(generated, m.GoName())
+		for _, f := range m.Fields {
+			if !fields[strings.ToLower(f)] {
+				diffs = append(diffs, fmt.Sprintf("field %s.%s is missing", m.Name, f))
+			}
+			delete(fields, strings.ToLower(f))
+		}
+		var removed []string
+		for f := range fields {
+			removed = append(removed, f)
+		}
+		sort.Strings(removed)
+		for _, f := range removed {
+			diffs = append(diffs, fmt.Sprintf("field %s.%s is no longer declared", m.Name, f))
+		}
+	}
+	var removed []string
+	for name := range generated {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		diffs = append(diffs, fmt.Sprintf("message type %s is no longer declared", name))
+	}
+	return diffs
+}
+
+// generatedFields returns the message types declared in file, by name, with
+// the names of their fields, lowercased, as their protobuf struct tags give
+// them. The fields of oneofs are those of the wrapper types implementing
+// the interface of the oneof field.
+func generatedFields(pass *analysis.Pass, file *ast.File) map[string]map[string]bool {
+	messages := map[string]map[string]bool{}
+	// oneofs maps the methods of the interfaces of oneof fields to the
+	// fields of their messages.
+	oneofs := map[string]map[string]bool{}
+	var wrappers []*types.TypeName
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			tn, ok := pass.TypesInfo.Defs[spec.(*ast.TypeSpec).Name].(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			st, ok := tn.Type().Underlying().(*types.Struct)
+			if !ok {
+				continue
+			}
+			if _, ok := facts.ClassifyMessage(tn.Type()); !ok {
+				wrappers = append(wrappers, tn)
+				continue
+			}
+			fields := map[string]bool{}
+			messages[tn.Name()] = fields
+			for i := 0; i < st.NumFields(); i++ {
+				tag := reflect.StructTag(st.Tag(i))
+				if name := tagName(tag.Get("protobuf")); name != "" {
+					fields[name] = true
+				}
+				if tag.Get("protobuf_oneof") == "" {
+					continue
+				}
+				if iface, ok := st.Field(i).Type().Underlying().(*types.Interface); ok && iface.NumMethods() == 1 {
+					oneofs[iface.Method(0).Name()] = fields
+				}
+			}
+		}
+	}
+	for _, tn := range wrappers {
+		mset := types.NewMethodSet(types.NewPointer(tn.Type()))
+		for i := 0; i < mset.Len(); i++ {
+			fields, ok := oneofs[mset.At(i).Obj().Name()]
+			if !ok {
+				continue
+			}
+			st := tn.Type().Underlying().(*types.Struct)
+			for j := 0; j < st.NumFields(); j++ {
+				if name := tagName(reflect.StructTag(st.Tag(j)).Get("protobuf")); name != "" {
+					fields[name] = true
+				}
+			}
+		}
+	}
+	return messages
+}
+
+// tagName returns the name of the field of a protobuf struct tag,
+// lowercased, like foo for "bytes,1,opt,name=foo,proto3".
+func tagName(tag string) string {
+	for _, part := range strings.Split(tag, ",") {
+		if strings.HasPrefix(part, "name=") {
+			return strings.ToLower(part[len("name="):])
+		}
+	}
+	return ""
+}
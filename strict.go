@@ -0,0 +1,74 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/report"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+)
+
+const protoV1Module = "github.com/golang/protobuf"
+
+// checkStrict reports, with -strict, what is left of the v1 API in a
+// package declared fully migrated to the v2 API, so that it does not come
+// back: the imports of the v1 packages, including those of the well-known
+// types that only alias the v2 ones, the uses of protoadapt, which only
+// bridge code still on the v1 API, and the exported functions, methods and
+// fields whose types mention v1 types. Generated files are checked too,
+// since code generated for the v1 API is as much of a regression.
+func checkStrict(pass *analysis.Pass) (interface{}, error) {
+	if !strictMode {
+		return nil, nil
+	}
+	for _, file := range pass.Files {
+		for _, spec := range file.Imports {
+			if path := importPath(spec); path == protoV1Module || strings.HasPrefix(path, protoV1Module+"/") {
+				report.Report(pass, spec, fmt.Sprintf("%s is a package of the v1 API, which the package is declared migrated off", writtenPath(spec)))
+			}
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			sel, ok := node.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			obj := pass.TypesInfo.Uses[sel.Sel]
+			if obj != nil && obj.Pkg() != nil && pkgPath(obj.Pkg()) == protoadaptPath {
+				report.Report(pass, sel, fmt.Sprintf("protoadapt.%s bridges code on the v1 API, which the package is declared migrated off", obj.Name()))
+			}
+			return true
+		})
+	}
+
+	api, ok := pass.ResultOf[facts.API].(map[*types.Package]*facts.V1API)[pass.Pkg]
+	if !ok {
+		return nil, nil
+	}
+	for _, name := range api.Objects {
+		typeName, member := name, ""
+		if i := strings.Index(name, "."); i >= 0 {
+			typeName, member = name[:i], name[i+1:]
+		}
+		obj := pass.Pkg.Scope().Lookup(typeName)
+		if obj != nil && member != "" {
+			obj, _, _ = types.LookupFieldOrMethod(obj.Type(), true, pass.Pkg, member)
+		}
+		if obj == nil || !obj.Pos().IsValid() {
+			continue
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     obj.Pos(),
+			End:     obj.Pos() + token.Pos(len(obj.Name())),
+			Message: fmt.Sprintf("the type of exported %s mentions v1 types, which the package is declared migrated off", name),
+		})
+	}
+	return nil, nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// pragma starts the comments suppressing findings, all of them or those
+// of the rules listed after an equal sign:
+//
+//	//protomigrate:ignore=PM3001,jsonpb
+const pragma = "//protomigrate:ignore"
+
+// A directive suppresses the findings of some rules on a range of lines of
+// a file, as a pragma or the comments of golangci-lint and staticcheck do:
+//
+//	s := proto.CompactTextString(m) //nolint:protomigrate
+//
+//	//lint:ignore PM3001 the text is compared in tests
+//	s := proto.CompactTextString(m)
+//
+//	//lint:file-ignore PM8003 the messages are copied before their first use
+//
+// A directive at the end of a line applies to the line, and one on lines
+// of its own to the declaration or statement following it, or for a
+// pragma preceding the package clause, to the whole file.
+type directive struct {
+	file       *token.File
+	start, end int
+
+	// rules holds the IDs of the rules suppressed, all if it is nil.
+	rules map[string]bool
+}
+
+// directives returns the directives in the files of pass.
+func directives(pass *analysis.Pass) []directive {
+	var dirs []directive
+	for _, file := range pass.Files {
+		var lines *codeLines
+		for _, group := range file.Comments {
+			for _, c := range group.List {
+				rules, scope, ok := parseDirective(c.Text)
+				if !ok {
+					continue
+				}
+				tf := pass.Fset.File(c.Pos())
+				d := directive{file: tf, rules: rules}
+				if scope == "file" || scope == "pragma" && c.Pos() < file.Package {
+					d.start, d.end = 1, tf.LineCount()
+				} else {
+					if lines == nil {
+						lines = newCodeLines(pass.Fset, file)
+					}
+					d.start, d.end = lines.scope(pass.Fset, c, group)
+				}
+				dirs = append(dirs, d)
+			}
+		}
+	}
+	return dirs
+}
+
+// parseDirective parses the text of a comment, returning the rules of the
+// directive it holds, nil for all, and its scope: "file" for the whole
+// file, "pragma" for the whole file if it precedes the package clause, and
+// "" otherwise. ok is false if the comment is no directive of protomigrate.
+func parseDirective(text string) (rules map[string]bool, scope string, ok bool) {
+	switch {
+	case strings.HasPrefix(text, pragma):
+		rest := strings.TrimPrefix(text, pragma)
+		if rest == "" || rest[0] == ' ' || rest[0] == '\t' {
+			return nil, "pragma", true
+		}
+		if rest[0] != '=' {
+			return nil, "", false
+		}
+		list := rest[1:]
+		if i := strings.IndexAny(list, " \t"); i >= 0 {
+			list = list[:i]
+		}
+		rules = parseRules(list)
+		if len(rules) == 0 {
+			return nil, "", false
+		}
+		return rules, "pragma", true
+	case strings.HasPrefix(text, "//nolint"):
+		rest := strings.TrimPrefix(text, "//nolint")
+		if rest == "" || rest[0] == ' ' || rest[0] == '\t' {
+			return nil, "", true
+		}
+		if rest[0] != ':' {
+			return nil, "", false
+		}
+		linters := strings.Fields(rest[1:])
+		if len(linters) == 0 {
+			return nil, "", false
+		}
+		for _, name := range strings.Split(linters[0], ",") {
+			if strings.EqualFold(strings.TrimSpace(name), "protomigrate") {
+				return nil, "", true
+			}
+		}
+		return nil, "", false
+	case strings.HasPrefix(text, "//lint:ignore "), strings.HasPrefix(text, "//lint:file-ignore "):
+		fields := strings.Fields(text)
+		if len(fields) < 3 {
+			// staticcheck requires a reason.
+			return nil, "", false
+		}
+		rules = parseRules(fields[1])
+		if len(rules) == 0 {
+			return nil, "", false
+		}
+		if fields[0] == "//lint:file-ignore" {
+			scope = "file"
+		}
+		return rules, scope, true
+	}
+	return nil, "", false
+}
+
+// parseRules returns the IDs of the rules in list, a comma-separated list
+// of IDs and names, leaving out those unknown.
+func parseRules(list string) map[string]bool {
+	rules := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		if r, ok := LookupRule(strings.TrimSpace(name)); ok {
+			rules[r.ID] = true
+		}
+	}
+	return rules
+}
+
+// suppressed reports whether one of dirs suppresses d.
+func suppressed(fset *token.FileSet, dirs []directive, d analysis.Diagnostic) bool {
+	if len(dirs) == 0 {
+		return false
+	}
+	tf := fset.File(d.Pos)
+	if tf == nil {
+		return false
+	}
+	line := tf.Line(d.Pos)
+	for _, dir := range dirs {
+		if dir.file == tf && dir.start <= line && line <= dir.end && (dir.rules == nil || dir.rules[d.Category]) {
+			return true
+		}
+	}
+	return false
+}
+
+// codeLines indexes the nodes of a file by the lines they are on, to tell
+// which nodes a directive applies to.
+type codeLines struct {
+	// first maps lines to the position of the first token on them.
+	first map[int]token.Pos
+
+	// nodes maps lines to the outermost declaration, statement, spec or
+	// field starting on them.
+	nodes map[int]ast.Node
+}
+
+func newCodeLines(fset *token.FileSet, file *ast.File) *codeLines {
+	l := &codeLines{first: map[int]token.Pos{}, nodes: map[int]ast.Node{}}
+	mark := func(pos token.Pos) {
+		line := fset.Position(pos).Line
+		if p, ok := l.first[line]; !ok || pos < p {
+			l.first[line] = pos
+		}
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node.(type) {
+		case nil, *ast.Comment, *ast.CommentGroup:
+			return false
+		}
+		mark(node.Pos())
+		mark(node.End() - 1)
+		switch node.(type) {
+		case ast.Decl, ast.Stmt, ast.Spec, *ast.Field, *ast.KeyValueExpr:
+			line := fset.Position(node.Pos()).Line
+			if _, ok := l.nodes[line]; !ok {
+				l.nodes[line] = node
+			}
+		}
+		return true
+	})
+	return l
+}
+
+// scope returns the range of lines the directive c in group applies to:
+// its own line, and the node following it if it is on lines of its own.
+func (l *codeLines) scope(fset *token.FileSet, c *ast.Comment, group *ast.CommentGroup) (start, end int) {
+	line := fset.Position(c.Pos()).Line
+	if first, ok := l.first[line]; ok && first < c.Pos() {
+		return line, line
+	}
+	next := fset.Position(group.End()).Line + 1
+	node, ok := l.nodes[next]
+	if !ok {
+		return line, next
+	}
+	return line, fset.Position(node.End()).Line
+}
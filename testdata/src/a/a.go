@@ -4,12 +4,12 @@ import (
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
-	"github.com/golang/protobuf/ptypes/any"
-	"github.com/golang/protobuf/ptypes/duration"
-	"github.com/golang/protobuf/ptypes/empty"
-	structpb "github.com/golang/protobuf/ptypes/struct"
-	"github.com/golang/protobuf/ptypes/timestamp"
-	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/golang/protobuf/ptypes/any"             // want `github.com/golang/protobuf/ptypes/any should be replaced with google.golang.org/protobuf/types/known/anypb`
+	"github.com/golang/protobuf/ptypes/duration"        // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+	"github.com/golang/protobuf/ptypes/empty"           // want `github.com/golang/protobuf/ptypes/empty should be replaced with google.golang.org/protobuf/types/known/emptypb`
+	structpb "github.com/golang/protobuf/ptypes/struct" // want `github.com/golang/protobuf/ptypes/struct should be replaced with google.golang.org/protobuf/types/known/structpb`
+	"github.com/golang/protobuf/ptypes/timestamp"       // want `github.com/golang/protobuf/ptypes/timestamp should be replaced with google.golang.org/protobuf/types/known/timestamppb`
+	"github.com/golang/protobuf/ptypes/wrappers"        // want `github.com/golang/protobuf/ptypes/wrappers should be replaced with google.golang.org/protobuf/types/known/wrapperspb`
 )
 
 var _ = proto.Marshal
@@ -21,9 +21,3 @@ var _ empty.Empty
 var _ structpb.NullValue
 var _ timestamp.Timestamp
 var _ wrappers.StringValue
-
-func a() {
-	// The pattern can be written in regular expression.
-	var gopher int // want "pattern"
-	print(gopher)  // want "identifier is gopher"
-}
@@ -0,0 +1,47 @@
+package aliasing
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func received(c chan *descriptorpb.FileDescriptorProto) {
+	for f := range c {
+		f.GetOptions().GoPackage = nil // want `the message GetOptions returns is mutated, but is part of a message received from a channel, which its other users may read at the same time; clone it with proto.Clone first`
+	}
+	f := <-c
+	f.Name = nil
+	opts := f.GetOptions()
+	opts.Reset() // want `the message GetOptions returns is mutated, but is part of a message received from a channel`
+}
+
+var cache = map[string]*descriptorpb.FileDescriptorProto{}
+
+func cached(name string) {
+	if f, ok := cache[name]; ok {
+		proto.Reset(f.GetOptions()) // want `the message GetOptions returns is mutated, but is part of a message looked up in a map`
+	}
+	opts := proto.Clone(cache[name].GetOptions()).(*descriptorpb.FileOptions)
+	opts.GoPackage = nil
+}
+
+var files sync.Map
+
+func loaded(name string) {
+	v, _ := files.Load(name)
+	f := v.(*descriptorpb.FileDescriptorProto)
+	f.GetSourceCodeInfo().Location = nil // want `the message GetSourceCodeInfo returns is mutated, but is part of a message loaded from a sync.Map`
+}
+
+// update updates f, which the caller has shared with others.
+func update(f *descriptorpb.FileDescriptorProto, g *descriptorpb.FileDescriptorProto) {
+	f.GetOptions().JavaPackage = nil // want `the message GetOptions returns is mutated, but is part of a message passed as the shared parameter f`
+	g.GetOptions().JavaPackage = nil
+}
+
+// own updates f.
+func own(f *descriptorpb.FileDescriptorProto) {
+	f.GetOptions().JavaPackage = nil
+}
@@ -0,0 +1,5 @@
+module github.com/protobuf-tools/protomigrate/testdata/src/aliasing
+
+go 1.15
+
+require google.golang.org/protobuf v1.25.0
@@ -0,0 +1,37 @@
+package buffer
+
+import (
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/encoding/protowire`
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func marshal(m *durationpb.Duration) ([]byte, error) {
+	b := proto.NewBuffer(nil)                 // want `proto.NewBuffer has no v2 counterpart; use proto.MarshalOptions and proto.UnmarshalOptions, which append to and read from plain byte slices, or protowire for the low-level encoding instead`
+	b.SetDeterministic(true)                  // want `the method Buffer.SetDeterministic of the v1 proto package has no v2 counterpart; use the Deterministic field of proto.MarshalOptions instead`
+	if err := b.EncodeVarint(1); err != nil { // want `the method Buffer.EncodeVarint of the v1 proto package has no v2 counterpart; use protowire.AppendVarint instead`
+		return nil, err
+	}
+	if err := b.Marshal(m); err != nil { // want `use proto.MarshalOptions.MarshalAppend instead`
+		return nil, err
+	}
+	return b.Bytes(), nil // want `the method Buffer.Bytes`
+}
+
+func unmarshal(b *proto.Buffer, m *durationpb.Duration) error { // want `proto.Buffer has no v2 counterpart`
+	if _, err := b.DecodeVarint(); err != nil { // want `use protowire.ConsumeVarint instead`
+		return err
+	}
+	return b.Unmarshal(m) // want `use proto.UnmarshalOptions with Merge set instead`
+}
+
+func varint(v uint64) ([]byte, int) {
+	return proto.EncodeVarint(v), proto.SizeVarint(v) // want `proto.EncodeVarint should be replaced with protowire.AppendVarint` `proto.SizeVarint should be replaced with protowire.SizeVarint`
+}
+
+func decode(b []byte) uint64 {
+	v, n := proto.DecodeVarint(b) // want `proto.DecodeVarint should be replaced with protowire.ConsumeVarint, which returns a negative length rather than 0 on errors`
+	if n == 0 {
+		return 0
+	}
+	return v
+}
@@ -1,5 +1,5 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 
-package pkg
+package pkg // want `files generated by protoc-gen-go before v1.4, for the v1 API, have to be regenerated with protoc-gen-go of google.golang.org/protobuf: protobuf.go`
 
 import _ "github.com/golang/protobuf/proto"
@@ -0,0 +1,31 @@
+package clone
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/proto`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func reset(d *duration.Duration) string {
+	c := proto.Clone(d) // want `proto.Clone should be replaced with the v2 proto.Clone`
+	c.Reset()
+	return c.String()
+}
+
+func asserted(d *duration.Duration) *duration.Duration {
+	return proto.Clone(d).(*duration.Duration) // want `proto.Clone should be replaced with the v2 proto.Clone`
+}
+
+func switched(d *duration.Duration) *duration.Duration {
+	c := proto.Clone(d) // want `proto.Clone should be replaced with the v2 proto.Clone`
+	if d, ok := c.(*duration.Duration); ok {
+		return d
+	}
+	return nil
+}
+
+func stringer(d *duration.Duration) fmt.Stringer {
+	var m fmt.Stringer = proto.Clone(d) // want `proto.Clone should be replaced with the v2 proto.Clone`
+	return m
+}
@@ -0,0 +1,12 @@
+package clone
+
+import (
+	"io"
+
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/proto`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func write(w io.Writer, d *duration.Duration) error {
+	return proto.MarshalText(w, proto.Clone(d)) // want `proto.MarshalText writes to an io.Writer` `proto.Clone should be replaced with the v2 proto.Clone`
+}
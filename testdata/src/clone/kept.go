@@ -0,0 +1,14 @@
+package clone
+
+import (
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/proto`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func copyMessage(m proto.Message) proto.Message {
+	return proto.Clone(m) // want `proto.Clone should be replaced with the v2 proto.Clone, whose result needs a type assertion; the message is only known to implement the v1 API`
+}
+
+func copyZero() interface{} {
+	return proto.Clone(new(duration.Duration)) // want `proto.Clone should be replaced with the v2 proto.Clone`
+}
@@ -0,0 +1,50 @@
+package copy
+
+import (
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type wrapper struct {
+	name string
+	d    durationpb.Duration
+}
+
+type ptrWrapper struct {
+	d *durationpb.Duration
+}
+
+func assign(m *durationpb.Duration) durationpb.Duration {
+	v := *m               // want `assignment copies message value to v: durationpb.Duration`
+	var w = wrapper{d: v} // want `literal copies message value from v: durationpb.Duration`
+	w2 := w               // want `assignment copies message value to w2: wrapper contains durationpb.Duration`
+	use(w2)               // want `call of use copies message value: wrapper contains durationpb.Duration`
+	return v              // want `return copies message value: durationpb.Duration; the v2 runtime keeps internal state in messages, which must not be copied`
+}
+
+func use(w wrapper) {} // want `use passes message by value: wrapper contains durationpb.Duration`
+
+func (w wrapper) name2() string { return w.name } // want `name2 passes message by value: wrapper`
+
+func ranges(ms []durationpb.Duration, ps []*durationpb.Duration) {
+	for _, m := range ms { // want `range var m copies message: durationpb.Duration`
+		_ = m.Seconds
+	}
+	for i := range ms {
+		_ = ms[i].Seconds
+	}
+	for _, p := range ps {
+		_ = p
+	}
+	f := func(d durationpb.Duration) {} // want `func passes message by value`
+	_ = f
+}
+
+func fine(m *durationpb.Duration) *durationpb.Duration {
+	p := m
+	w := ptrWrapper{d: p}
+	var v durationpb.Duration
+	n := *new(durationpb.Duration)
+	_ = w
+	_ = n
+	return &v
+}
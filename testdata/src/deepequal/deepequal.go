@@ -0,0 +1,15 @@
+package deepequal
+
+import (
+	"reflect"
+
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func equal(a, b *duration.Duration) bool {
+	return reflect.DeepEqual(a, b) // want `reflect.DeepEqual compares the internal state of v2 messages, proto.Equal their content`
+}
+
+func changed(a, b *duration.Duration) bool {
+	return !reflect.DeepEqual(a, b) // want `reflect.DeepEqual compares the internal state of v2 messages, proto.Equal their content`
+}
@@ -0,0 +1,8 @@
+module github.com/protobuf-tools/protomigrate/testdata/src/deepequal
+
+go 1.15
+
+require (
+	github.com/golang/protobuf v1.4.3
+	github.com/stretchr/testify v1.6.1
+)
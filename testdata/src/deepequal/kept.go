@@ -0,0 +1,29 @@
+package deepequal
+
+import (
+	"reflect"
+
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+type V1 struct{}
+
+func (*V1) Reset()         {}
+func (*V1) String() string { return "" }
+func (*V1) ProtoMessage()  {}
+
+func same(a, b *duration.Duration) bool {
+	return reflect.DeepEqual(a, b) // want `reflect.DeepEqual compares the internal state of v2 messages, proto.Equal their content`
+}
+
+func sameAll(a, b []*duration.Duration) bool {
+	return reflect.DeepEqual(a, b) // want `reflect.DeepEqual compares the internal state of v2 messages, cmp.Diff with protocmp.Transform\(\) their content`
+}
+
+func sameV1(a, b *V1) bool {
+	return reflect.DeepEqual(a, b) // want `reflect.DeepEqual compares the internal state of v2 messages, proto.Equal their content; the message does not implement the v2 API, so regenerate it first`
+}
+
+func sameInts(a, b []int) bool {
+	return reflect.DeepEqual(a, b)
+}
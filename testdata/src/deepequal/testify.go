@@ -0,0 +1,17 @@
+package deepequal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func check(t *testing.T, got, want *duration.Duration) {
+	assert.Equal(t, want, got, "durations differ") // want `assert.Equal compares the internal state of v2 messages, proto.Equal their content`
+	require.NotEqual(t, want, got)                 // want `require.NotEqual compares the internal state of v2 messages, proto.Equal their content`
+	a := assert.New(t)
+	a.Equal(want, got) // want `assert.Equal compares the internal state of v2 messages, proto.Equal their content`
+	assert.Equal(t, 1, 1)
+}
@@ -0,0 +1,24 @@
+package deprecatedfield
+
+import (
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func options(opts *descriptorpb.FileOptions) bool {
+	t := true
+	opts.JavaGenerateEqualsAndHash = &t        // want `opts.JavaGenerateEqualsAndHash is deprecated: Do not use\.`
+	return opts.GetJavaGenerateEqualsAndHash() // want `opts.GetJavaGenerateEqualsAndHash is deprecated: Do not use\.`
+}
+
+func newOptions() *descriptorpb.FileOptions {
+	t := true
+	return &descriptorpb.FileOptions{
+		JavaMultipleFiles:         &t,
+		JavaGenerateEqualsAndHash: &t, // want `JavaGenerateEqualsAndHash is deprecated: Do not use\.`
+	}
+}
+
+// Deprecated: Use newOptions.
+func oldOptions() *descriptorpb.FileOptions {
+	return &descriptorpb.FileOptions{JavaGenerateEqualsAndHash: new(bool)}
+}
@@ -0,0 +1,27 @@
+package descriptor
+
+import (
+	"github.com/golang/protobuf/descriptor"      // want `package github.com/golang/protobuf/descriptor is deprecated` `github.com/golang/protobuf/descriptor should be replaced with google.golang.org/protobuf/reflect/protodesc`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func messageProto(m *duration.Duration) *descriptorpb.DescriptorProto {
+	_, md := descriptor.ForMessage(m) // want `descriptor.ForMessage is deprecated` `descriptor.ForMessage should be replaced with protodesc.ToFileDescriptorProto and protodesc.ToDescriptorProto`
+	return md
+}
+
+func fileProto(m struct{ d *duration.Duration }) *descriptorpb.FileDescriptorProto {
+	fd, _ := descriptor.MessageDescriptorProto(m.d) // want `descriptor.MessageDescriptorProto should be replaced with protodesc.ToFileDescriptorProto and protodesc.ToDescriptorProto`
+	return fd
+}
+
+func protos(m *duration.Duration) (*descriptorpb.FileDescriptorProto, *descriptorpb.DescriptorProto) {
+	fd, md := descriptor.ForMessage(m) // want `descriptor.ForMessage is deprecated` `descriptor.ForMessage should be replaced with protodesc.ToFileDescriptorProto and protodesc.ToDescriptorProto`
+	return fd, md
+}
+
+func v1(m descriptor.Message) *descriptorpb.DescriptorProto { // want `descriptor.Message is deprecated`
+	_, md := descriptor.ForMessage(m) // want `descriptor.ForMessage is deprecated` `the message is only known to implement the v1 API`
+	return md
+}
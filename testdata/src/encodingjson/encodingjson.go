@@ -0,0 +1,21 @@
+package encodingjson
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func encode(m *duration.Duration) ([]byte, error) {
+	return json.Marshal(m) // want `json.Marshal should be replaced with protojson.Marshal for messages, which encoding/json does not encode in the JSON mapping of protobuf`
+}
+
+func encodeIndent(m *duration.Duration) ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ") // want `json.MarshalIndent should be replaced with protojson.MarshalOptions.Marshal for messages`
+}
+
+func decode(b []byte) (*duration.Duration, error) {
+	m := new(duration.Duration)
+	err := json.Unmarshal(b, m) // want `json.Unmarshal should be replaced with protojson.Unmarshal for messages`
+	return m, err
+}
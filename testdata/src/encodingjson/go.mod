@@ -0,0 +1,5 @@
+module github.com/protobuf-tools/protomigrate/testdata/src/encodingjson
+
+go 1.15
+
+require github.com/golang/protobuf v1.4.3
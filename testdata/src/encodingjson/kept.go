@@ -0,0 +1,42 @@
+package encodingjson
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+type V1 struct{}
+
+func (*V1) Reset()         {}
+func (*V1) String() string { return "" }
+func (*V1) ProtoMessage()  {}
+
+type wrapper struct {
+	D *duration.Duration
+}
+
+func encodeKept(m *duration.Duration) ([]byte, error) {
+	return json.Marshal(m) // want `json.Marshal should be replaced with protojson.Marshal for messages`
+}
+
+func encodePrefix(m *duration.Duration) ([]byte, error) {
+	return json.MarshalIndent(m, "> ", "  ") // want `json.MarshalIndent should be replaced with protojson.MarshalOptions.Marshal for messages, which encoding/json does not encode in the JSON mapping of protobuf; protojson has no prefix option`
+}
+
+func encodeV1(m *V1) ([]byte, error) {
+	return json.Marshal(m) // want `json.Marshal should be replaced with protojson.Marshal for messages, which encoding/json does not encode in the JSON mapping of protobuf; the message does not implement the v2 API, so regenerate it first`
+}
+
+func encodeWrapper(w wrapper) ([]byte, error) {
+	return json.Marshal(w) // want `json.Marshal does not encode the messages its argument holds in the JSON mapping of protobuf, so they should be encoded with protojson`
+}
+
+func write(w io.Writer, m *duration.Duration) error {
+	return json.NewEncoder(w).Encode(m) // want `\(\*json.Encoder\).Encode does not encode messages in the JSON mapping of protobuf, so they should be encoded with protojson`
+}
+
+func encodeInts(v []int) ([]byte, error) {
+	return json.Marshal(v)
+}
@@ -0,0 +1,13 @@
+package enum
+
+import "github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be removed`
+
+func name(c Color, n int32) (string, string) {
+	return proto.EnumName(Color_name, int32(c)), proto.EnumName(Color_name, n) // want `proto.EnumName should be replaced with the String method of Color, which names its values alike` `proto.EnumName is deprecated` `proto.EnumName should be replaced with the String method of Color` `proto.EnumName is deprecated`
+}
+
+func rename() {
+	Color_name[2] = "BLUE"           // want `changes of Color_name no longer change the names of the values of Color, which the v2 runtime takes from the descriptor of the enum`
+	delete(Color_value, "RED")       // want `changes of Color_value no longer change the names of the values of Color`
+	Color_value = map[string]int32{} // want `changes of Color_value no longer change`
+}
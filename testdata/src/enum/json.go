@@ -0,0 +1,14 @@
+package enum
+
+import (
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated`
+)
+
+func names(m map[int32]string, n int32) string {
+	return proto.EnumName(m, n) // want `proto.EnumName has no v2 counterpart; the values of generated enums are named by their String method` `proto.EnumName is deprecated`
+}
+
+func parse(data []byte) (Color, error) {
+	n, err := proto.UnmarshalJSONEnum(Color_value, data, "Color") // want `proto.UnmarshalJSONEnum has no v2 counterpart; protojson unmarshals the enums of messages` `proto.UnmarshalJSONEnum is deprecated`
+	return Color(n), err
+}
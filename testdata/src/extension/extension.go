@@ -0,0 +1,36 @@
+package extension
+
+import (
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/proto`
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func owner(opts *descriptorpb.FileOptions) string {
+	v, err := proto.GetExtension(opts, E_Owner) // want `proto.GetExtension should be replaced with the v2 proto.GetExtension, which returns no error, but the default value of a missing extension`
+	if err != nil {
+		return ""
+	}
+	return *v.(*string)
+}
+
+func ownerOrNil(opts *descriptorpb.FileOptions) interface{} {
+	v, _ := proto.GetExtension(opts, E_Owner) // want `proto.GetExtension should be replaced with the v2 proto.GetExtension`
+	return v
+}
+
+func setOwner(opts *descriptorpb.FileOptions, owner string) {
+	if err := proto.SetExtension(opts, E_Owner, &owner); err != nil { // want `proto.SetExtension should be replaced with the v2 proto.SetExtension, which returns no error, but panics on values of the wrong type`
+		panic(err)
+	}
+	err := proto.SetExtension(opts, E_Owner, &owner) // want `proto.SetExtension should be replaced with the v2 proto.SetExtension`
+	if err != nil {
+		return
+	}
+	_ = proto.SetExtension(opts, E_Owner, &owner) // want `proto.SetExtension should be replaced with the v2 proto.SetExtension`
+	proto.SetExtension(opts, E_Owner, &owner)     // want `proto.SetExtension should be replaced with the v2 proto.SetExtension`
+}
+
+func clearOwner(opts *descriptorpb.FileOptions) bool {
+	proto.ClearExtension(opts, E_Owner)      // want `proto.ClearExtension should be replaced with the v2 proto.ClearExtension`
+	return proto.HasExtension(opts, E_Owner) // want `proto.HasExtension should be replaced with the v2 proto.HasExtension`
+}
@@ -0,0 +1,12 @@
+package gateway
+
+import "github.com/grpc-ecosystem/grpc-gateway/runtime"
+
+var marshaler = &runtime.JSONPb{OrigName: true, EmitDefaults: true} // want `runtime.JSONPb of grpc-gateway v1 is configured with the options of jsonpb; the runtime.JSONPb of grpc-gateway v2 takes those of protojson instead, as in runtime.JSONPb{MarshalOptions: protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}, UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true}} \(see https://grpc-ecosystem.github.io/grpc-gateway/docs/development/grpc-gateway_v2_migration_guide/\)$`
+
+var plain = runtime.JSONPb{} // want `as in runtime.JSONPb{UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true}} \(see https://grpc-ecosystem.github.io/grpc-gateway/docs/development/grpc-gateway_v2_migration_guide/\)$`
+
+func mux() *runtime.ServeMux {
+	runtime.DisallowUnknownFields() // want `runtime.DisallowUnknownFields of grpc-gateway v1 has no v2 counterpart; the runtime.JSONPb of grpc-gateway v2 disallows unknown fields unless its UnmarshalOptions set DiscardUnknown`
+	return runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{EnumsAsInts: true, Indent: "  "})) // want `protojson.MarshalOptions{UseEnumNumbers: true, Indent: "  "}`
+}
@@ -0,0 +1,5 @@
+module github.com/protobuf-tools/protomigrate/testdata/src/gateway
+
+go 1.15
+
+require github.com/grpc-ecosystem/grpc-gateway v1.16.0
@@ -0,0 +1,10 @@
+package gogenerate
+
+import "google.golang.org/protobuf/proto"
+
+//go:generate protoc --go_out=plugins=grpc:. api.proto // want `protoc invocation of go:generate directive is deprecated: plugins=grpc is no longer supported`
+//go:generate protoc --gogofaster_out=Mgoogle/protobuf/any.proto=github.com/gogo/protobuf/types:. api.proto // want `protoc-gen-gogofaster is replaced by protoc-gen-go; the mappings to the gogo well-known types`
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. --go-grpc_out=paths=source_relative:. api.proto // want `plugins=grpc is no longer supported`
+//go:generate protoc --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. api.proto
+
+var _ = proto.Marshal
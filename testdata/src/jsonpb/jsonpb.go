@@ -0,0 +1,72 @@
+package jsonpb
+
+import (
+	"io"
+
+	"github.com/golang/protobuf/jsonpb"          // want `package github.com/golang/protobuf/jsonpb is deprecated` `github.com/golang/protobuf/jsonpb should be replaced with google.golang.org/protobuf/encoding/protojson`
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+var marshaler = &jsonpb.Marshaler{} // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions`
+
+var unmarshaler = &jsonpb.Unmarshaler{} // want `jsonpb.Unmarshaler should be replaced with protojson.UnmarshalOptions`
+
+func unmarshal(s string, m *duration.Duration) error {
+	var u jsonpb.Unmarshaler // want `jsonpb.Unmarshaler should be replaced with protojson.UnmarshalOptions`
+	_ = u
+	return jsonpb.UnmarshalString(s, m) // want `jsonpb.UnmarshalString should be replaced with protojson.Unmarshal`
+}
+
+func define(w io.Writer, m *duration.Duration) error {
+	err := marshaler.Marshal(w, m) // want `\(\*jsonpb.Marshaler\).Marshal writes to an io.Writer`
+	return err
+}
+
+func assign(w io.Writer, m *duration.Duration) (err error) {
+	err = marshaler.Marshal(w, m) // want `\(\*jsonpb.Marshaler\).Marshal writes to an io.Writer`
+	return
+}
+
+func ignore(b io.Writer, m *duration.Duration) {
+	marshaler.Marshal(b, m) // want `\(\*jsonpb.Marshaler\).Marshal writes to an io.Writer`
+}
+
+// ignoreUncommented has no comment after the statement, so the fix inserts
+// its condition and body at the same offset.
+func ignoreUncommented(w io.Writer, m *duration.Duration) {
+	/* want `\(\*jsonpb.Marshaler\).Marshal writes to an io.Writer` */ marshaler.Marshal(w, m)
+}
+
+func v1(s string, m proto.Message) error {
+	return jsonpb.UnmarshalString(s, m) // want `jsonpb.UnmarshalString should be replaced with protojson.Unmarshal; the message is only known to implement the v1 API`
+}
+
+func read(r io.Reader, m *duration.Duration) error {
+	err := unmarshaler.Unmarshal(r, m) // want `\(\*jsonpb.Unmarshaler\).Unmarshal reads from an io.Reader`
+	return err
+}
+
+func reread(r io.Reader, m *duration.Duration) (err error) {
+	err = unmarshaler.Unmarshal(r, m) // want `\(\*jsonpb.Unmarshaler\).Unmarshal reads from an io.Reader`
+	return
+}
+
+func skip(r io.Reader, m *duration.Duration) {
+	unmarshaler.Unmarshal(r, m) // want `\(\*jsonpb.Unmarshaler\).Unmarshal reads from an io.Reader`
+}
+
+func skipUncommented(r io.Reader, m *duration.Duration) {
+	/* want `\(\*jsonpb.Unmarshaler\).Unmarshal reads from an io.Reader` */ unmarshaler.Unmarshal(r, m)
+}
+
+func format(m *duration.Duration) error {
+	s, err := marshaler.MarshalToString(m) // want `\(\*jsonpb.Marshaler\).MarshalToString should be replaced with protojson.MarshalOptions.Marshal`
+	println(s)
+	return err
+}
+
+func reformat(m *duration.Duration) (s string, err error) {
+	s, err = (&jsonpb.Marshaler{OrigName: true}).MarshalToString(m) // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions, with option OrigName as UseProtoNames` `\(\*jsonpb.Marshaler\).MarshalToString should be replaced with protojson.MarshalOptions.Marshal`
+	return
+}
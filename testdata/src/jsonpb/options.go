@@ -0,0 +1,35 @@
+package jsonpb
+
+import (
+	"github.com/golang/protobuf/jsonpb" // want `package github.com/golang/protobuf/jsonpb is deprecated` `github.com/golang/protobuf/jsonpb should be replaced with google.golang.org/protobuf/encoding/protojson`
+)
+
+var plain = jsonpb.Marshaler{} // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions`
+
+var options = jsonpb.Marshaler{ // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions, with option EnumsAsInts as UseEnumNumbers: unset, both emit the enum values by their names rather than their numbers, so dropping it changes the values`
+	EnumsAsInts: true,
+}
+
+var indented = jsonpb.Marshaler{ // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions, with option Indent: protojson indents the JSON with it too, but adds random spaces to its output, which is then not byte-stable across releases`
+	Indent: "\t",
+}
+
+var compact = jsonpb.Marshaler{Indent: ""} // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions \(see `
+
+var indent = "\t"
+
+var indentedVar = jsonpb.Marshaler{Indent: indent} // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions, with option Indent: protojson indents the JSON`
+
+func setIndent(m *jsonpb.Marshaler) { // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions \(see `
+	m.Indent = indent // want `jsonpb.Marshaler option Indent is one of protojson.MarshalOptions too: protojson indents the JSON`
+	m.Indent = ""
+	m.Indent = "> " // want `jsonpb.Marshaler option Indent has no automatic protojson translation: protojson.MarshalOptions only indents with spaces and tabs`
+}
+
+var names = &jsonpb.Marshaler{OrigName: true} // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions, with option OrigName as UseProtoNames: unset, both name the fields of the JSON by their lowerCamelCase JSON names rather than their names in the .proto file, so dropping it changes the field names`
+
+var lenient = jsonpb.Unmarshaler{AllowUnknownFields: true} // want `jsonpb.Unmarshaler should be replaced with protojson.UnmarshalOptions, with option AllowUnknownFields as DiscardUnknown: unset, both reject the JSON with unknown fields, so dropping it makes unmarshaling fail on them`
+
+var strict = &jsonpb.Unmarshaler{ // want `jsonpb.Unmarshaler should be replaced with protojson.UnmarshalOptions, with option AllowUnknownFields as DiscardUnknown`
+	AllowUnknownFields: false, // want `jsonpb.Unmarshaler option AllowUnknownFields is explicitly false, as protojson.UnmarshalOptions option DiscardUnknown is by default: both reject the JSON with unknown fields, but the errors of protojson are worded otherwise, and not stably, so code matching their text breaks`
+}
@@ -0,0 +1,19 @@
+package jsonpb
+
+import (
+	"github.com/golang/protobuf/jsonpb"          // want `package github.com/golang/protobuf/jsonpb is deprecated` `github.com/golang/protobuf/jsonpb should be replaced with google.golang.org/protobuf/encoding/protojson`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+// EmitDefaults emits the unset fields as EmitUnpopulated does, so its
+// rename has no note.
+var defaults = &jsonpb.Marshaler{EmitDefaults: true} // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions, with option EmitDefaults as EmitUnpopulated \(see `
+
+func marshalDuration(d *duration.Duration) (string, error) {
+	return defaults.MarshalToString(d) // want `\(\*jsonpb.Marshaler\).MarshalToString should be replaced with protojson.MarshalOptions.Marshal, whose result is converted to a string \(see `
+}
+
+func marshalCompact(d *duration.Duration) (string, error) {
+	m := jsonpb.Marshaler{EmitDefaults: false} // want `jsonpb.Marshaler should be replaced with protojson.MarshalOptions, with option EmitDefaults as EmitUnpopulated \(see `
+	return m.MarshalToString(d)                // want `\(\*jsonpb.Marshaler\).MarshalToString should be replaced with protojson.MarshalOptions.Marshal, whose result is converted to a string \(see `
+}
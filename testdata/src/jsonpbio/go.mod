@@ -0,0 +1,5 @@
+module github.com/protobuf-tools/protomigrate/testdata/src/jsonpbio
+
+go 1.16
+
+require github.com/golang/protobuf v1.4.3
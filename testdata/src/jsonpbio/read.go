@@ -0,0 +1,17 @@
+package jsonpbio
+
+import (
+	"io"
+
+	"github.com/golang/protobuf/jsonpb"          // want `package github.com/golang/protobuf/jsonpb is deprecated` `github.com/golang/protobuf/jsonpb should be replaced with google.golang.org/protobuf/encoding/protojson`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+// The module targets Go 1.16, so the reader is read with io.ReadAll rather
+// than ioutil.ReadAll.
+var unmarshaler = &jsonpb.Unmarshaler{} // want `jsonpb.Unmarshaler should be replaced with protojson.UnmarshalOptions`
+
+func read(r io.Reader, m *duration.Duration) error {
+	err := unmarshaler.Unmarshal(r, m) // want `\(\*jsonpb.Unmarshaler\).Unmarshal reads from an io.Reader`
+	return err
+}
@@ -0,0 +1,49 @@
+package jsonpbkept
+
+import (
+	"io"
+
+	"github.com/golang/protobuf/jsonpb"          // want `package github.com/golang/protobuf/jsonpb is deprecated` `github.com/golang/protobuf/jsonpb should be replaced with google.golang.org/protobuf/encoding/protojson`
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+// Some calls of the methods of both jsonpb types cannot be fixed, so the
+// types are kept, and so are the calls that could be.
+
+var marshaler = &jsonpb.Marshaler{OrigName: true} // want `jsonpb.Marshaler option OrigName is protojson.MarshalOptions option UseProtoNames: unset, both name the fields`
+
+var unmarshaler = &jsonpb.Unmarshaler{}
+
+func unmarshal(s string, m *duration.Duration) error {
+	return jsonpb.UnmarshalString(s, m) // want `jsonpb.UnmarshalString should be replaced with protojson.Unmarshal`
+}
+
+func define(w io.Writer, m *duration.Duration) error {
+	err := marshaler.Marshal(w, m) // want `\(\*jsonpb.Marshaler\).Marshal writes to an io.Writer, protojson.MarshalOptions.Marshal returns the encoded message instead; jsonpb.Marshaler is kept, since other uses of its values cannot be rewritten`
+	return err
+}
+
+func unsupported(w io.Writer, m *duration.Duration) error {
+	if err := marshaler.Marshal(w, m); err != nil { // want `\(\*jsonpb.Marshaler\).Marshal writes to an io.Writer, protojson.MarshalOptions.Marshal returns the encoded message instead \(see `
+		return err
+	}
+	return nil
+}
+
+func writeV1(w io.Writer, m proto.Message) {
+	marshaler.Marshal(w, m) // want `\(\*jsonpb.Marshaler\).Marshal writes to an io.Writer, protojson.MarshalOptions.Marshal returns the encoded message instead; the message is only known to implement the v1 API`
+}
+
+func formatV1(m proto.Message) (string, error) {
+	return marshaler.MarshalToString(m) // want `\(\*jsonpb.Marshaler\).MarshalToString should be replaced with protojson.MarshalOptions.Marshal, whose result is converted to a string; the message is only known to implement the v1 API`
+}
+
+func read(r io.Reader, m *duration.Duration) error {
+	err := unmarshaler.Unmarshal(r, m) // want `\(\*jsonpb.Unmarshaler\).Unmarshal reads from an io.Reader, protojson.UnmarshalOptions.Unmarshal takes the encoded message instead; jsonpb.Unmarshaler is kept, since other uses of its values cannot be rewritten`
+	return err
+}
+
+func readV1(r io.Reader, m proto.Message) {
+	unmarshaler.Unmarshal(r, m) // want `\(\*jsonpb.Unmarshaler\).Unmarshal reads from an io.Reader, protojson.UnmarshalOptions.Unmarshal takes the encoded message instead; the message is only known to implement the v1 API`
+}
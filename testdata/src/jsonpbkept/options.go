@@ -0,0 +1,19 @@
+package jsonpbkept
+
+import (
+	"github.com/golang/protobuf/jsonpb" // want `package github.com/golang/protobuf/jsonpb is deprecated`
+)
+
+var prefixed = jsonpb.Marshaler{
+	Indent: "> ", // want `jsonpb.Marshaler option Indent has no automatic protojson translation: protojson.MarshalOptions only indents with spaces and tabs, and fails to marshal with others`
+}
+
+var mixed = jsonpb.Marshaler{
+	OrigName:    true,           // want `jsonpb.Marshaler option OrigName is protojson.MarshalOptions option UseProtoNames: unset, both name the fields`
+	Indent:      "  ",           // want `jsonpb.Marshaler option Indent is one of protojson.MarshalOptions too: protojson indents the JSON`
+	AnyResolver: &nilResolver{}, // want `jsonpb.Marshaler option AnyResolver has no automatic protojson translation`
+}
+
+func setNames(m *jsonpb.Marshaler) {
+	m.OrigName = true
+}
@@ -0,0 +1,31 @@
+package jsonpbkept
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/jsonpb" // want `package github.com/golang/protobuf/jsonpb is deprecated`
+	"github.com/golang/protobuf/proto"  // want `package github.com/golang/protobuf/proto is deprecated`
+)
+
+type mapResolver map[string]func() proto.Message // want `mapResolver implements jsonpb.AnyResolver; protojson takes a protoregistry.MessageTypeResolver and ExtensionTypeResolver, like a \*protoregistry.Types, as the Resolver option instead, and defaults to protoregistry.GlobalTypes; register the types it resolves in a protoregistry.Types to pass instead; it resolves the types from a hand-maintained map`
+
+func (r mapResolver) Resolve(typeURL string) (proto.Message, error) {
+	if f, ok := r[typeURL]; ok {
+		return f(), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", typeURL)
+}
+
+type nilResolver struct{} // want `nilResolver implements jsonpb.AnyResolver; protojson takes a protoregistry.MessageTypeResolver and ExtensionTypeResolver, like a \*protoregistry.Types, as the Resolver option instead, and defaults to protoregistry.GlobalTypes; register the types it resolves in a protoregistry.Types to pass instead \(see https://pkg.go.dev/google.golang.org/protobuf/encoding/protojson\)$`
+
+func (*nilResolver) Resolve(typeURL string) (proto.Message, error) {
+	return nil, fmt.Errorf("unknown type %q", typeURL)
+}
+
+var mapped = jsonpb.Marshaler{
+	AnyResolver: mapResolver{}, // want `jsonpb.Marshaler option AnyResolver has no automatic protojson translation; protojson takes a protoregistry.MessageTypeResolver and ExtensionTypeResolver, like a \*protoregistry.Types, as the Resolver option instead, and defaults to protoregistry.GlobalTypes; it resolves the types from a hand-maintained map`
+}
+
+var resolved = jsonpb.Unmarshaler{
+	AnyResolver: &nilResolver{}, // want `jsonpb.Unmarshaler option AnyResolver has no automatic protojson translation; protojson takes a protoregistry.MessageTypeResolver and ExtensionTypeResolver, like a \*protoregistry.Types, as the Resolver option instead, and defaults to protoregistry.GlobalTypes \(see https://pkg.go.dev/google.golang.org/protobuf/encoding/protojson\)$`
+}
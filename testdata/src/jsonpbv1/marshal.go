@@ -0,0 +1,14 @@
+package jsonpbv1
+
+import (
+	"github.com/golang/protobuf/jsonpb" // want `package github.com/golang/protobuf/jsonpb is deprecated`
+	"github.com/golang/protobuf/proto"  // want `package github.com/golang/protobuf/proto is deprecated`
+)
+
+// The message of MarshalToString is only known to implement the v1 API,
+// so the marshaler is left a jsonpb.Marshaler.
+var marshaler = &jsonpb.Marshaler{EmitDefaults: true} // want `jsonpb.Marshaler option EmitDefaults is protojson.MarshalOptions option EmitUnpopulated \(see `
+
+func format(m proto.Message) (string, error) {
+	return marshaler.MarshalToString(m) // want `\(\*jsonpb.Marshaler\).MarshalToString should be replaced with protojson.MarshalOptions.Marshal, whose result is converted to a string; the message is only known to implement the v1 API`
+}
@@ -0,0 +1,17 @@
+package jsonpbv1
+
+import (
+	"io"
+
+	"github.com/golang/protobuf/jsonpb" // want `package github.com/golang/protobuf/jsonpb is deprecated`
+	"github.com/golang/protobuf/proto"  // want `package github.com/golang/protobuf/proto is deprecated`
+)
+
+// The message Unmarshal reads is only known to implement the v1 API, so
+// the unmarshaler is left a jsonpb.Unmarshaler.
+var unmarshaler = &jsonpb.Unmarshaler{}
+
+func read(r io.Reader, m proto.Message) error {
+	err := unmarshaler.Unmarshal(r, m) // want `\(\*jsonpb.Unmarshaler\).Unmarshal reads from an io.Reader, protojson.UnmarshalOptions.Unmarshal takes the encoded message instead; the message is only known to implement the v1 API`
+	return err
+}
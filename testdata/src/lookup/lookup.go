@@ -0,0 +1,33 @@
+package lookup
+
+import (
+	"reflect"
+
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/reflect/protoregistry and google.golang.org/protobuf/reflect/protoreflect`
+)
+
+func newMessage(name string) proto.Message {
+	t := proto.MessageType(name) // want `proto.MessageType should be replaced with protoregistry.GlobalTypes.FindMessageByName, which returns a protoreflect.MessageType rather than a reflect.Type; its New method makes new messages` `proto.MessageType is deprecated`
+	if t == nil {
+		return nil
+	}
+	return reflect.New(t.Elem()).Interface().(proto.Message)
+}
+
+func registered() bool {
+	t := proto.MessageType("google.protobuf.Duration") // want `proto.MessageType should be replaced` `proto.MessageType is deprecated`
+	return t != nil
+}
+
+func typeOf(name string) reflect.Type {
+	t := proto.MessageType(name) // want `proto.MessageType should be replaced` `proto.MessageType is deprecated`
+	return t
+}
+
+func descriptor(path string) []byte {
+	return proto.FileDescriptor(path) // want `proto.FileDescriptor should be replaced with protoregistry.GlobalFiles.FindFileByPath, which returns a protoreflect.FileDescriptor rather than the gzipped bytes of a FileDescriptorProto` `proto.FileDescriptor is deprecated`
+}
+
+func enumValue(enum, name string) int32 {
+	return proto.EnumValueMap(enum)[name] // want `proto.EnumValueMap should be replaced with protoregistry.GlobalTypes.FindEnumByName, which returns a protoreflect.EnumType rather than a map` `proto.EnumValueMap is deprecated`
+}
@@ -0,0 +1,15 @@
+package lookupreflect
+
+import (
+	"reflect"
+
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/reflect/protoregistry and google.golang.org/protobuf/reflect/protoreflect`
+)
+
+func newMessage(name string) interface{} {
+	t := proto.MessageType(name) // want `proto.MessageType should be replaced` `proto.MessageType is deprecated`
+	if t == nil {
+		return nil
+	}
+	return reflect.New(t.Elem()).Interface()
+}
@@ -0,0 +1,31 @@
+package mapkey
+
+type key struct {
+	id  int
+	old Old
+}
+
+type set map[Old]bool // want `the map is keyed by message structs: Old, which are not comparable once generated for the v2 API`
+
+func index(olds []*Old) map[key]int { // want `the map is keyed by message structs: key contains Old`
+	m := make(map[key]int) // want `the map is keyed by message structs: key contains Old`
+	for i, o := range olds {
+		m[key{i, *o}] = i // want `literal copies message value from \*o: Old`
+	}
+	return m
+}
+
+func byPointer(olds []*Old) map[*Old]bool {
+	m := map[*Old]bool{}
+	for _, o := range olds {
+		m[o] = true
+	}
+	return m
+}
+
+func same(a, b *Old, k1, k2 *key) bool {
+	if a == b {
+		return true
+	}
+	return *a == *b || *k1 != *k2 // want `== compares message structs: Old` `!= compares message structs: key contains Old`
+}
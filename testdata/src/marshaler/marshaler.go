@@ -0,0 +1,32 @@
+package marshaler
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated`
+)
+
+func (m *Point) Marshal() ([]byte, error) { // want `Point implements proto.Marshaler of the v1 API, which the v2 runtime ignores for the messages generated for the v2 API; drop the customization once Point is regenerated`
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, uint64(m.X))], nil
+}
+
+func (m *Point) Unmarshal(b []byte) error { // want `Point implements proto.Unmarshaler of the v1 API`
+	x, n := binary.Uvarint(b)
+	if n <= 0 {
+		return errors.New("bad point")
+	}
+	m.X = int32(x)
+	return nil
+}
+
+func (m *Point) Merge(src proto.Message) { // want `Point implements proto.Merger of the v1 API`
+	if p, ok := src.(*Point); ok {
+		m.X = p.X
+	}
+}
+
+type codec struct{}
+
+func (codec) Marshal() ([]byte, error) { return nil, nil }
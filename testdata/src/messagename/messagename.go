@@ -0,0 +1,27 @@
+package messagename
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be removed`
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const durationName = "google.protobuf.Duration"
+
+func names(m *durationpb.Duration, want string) (string, bool) {
+	name := proto.MessageName(m)              // want `proto.MessageName should be replaced with the v2 proto.MessageName, which returns a protoreflect.FullName, so it is converted to the string required here` `proto.MessageName is deprecated`
+	fmt.Println(proto.MessageName(m))         // want `proto.MessageName should be replaced with the v2 proto.MessageName, which returns a protoreflect.FullName` `proto.MessageName is deprecated`
+	if proto.MessageName(m) == durationName { // want `proto.MessageName should be replaced with the v2 proto.MessageName, which returns a protoreflect.FullName` `proto.MessageName is deprecated`
+		return name, true
+	}
+	if strings.HasPrefix(proto.MessageName(m), "google.") { // want `so it is converted to the string required here` `proto.MessageName is deprecated`
+		return name, true
+	}
+	switch proto.MessageName(m) { // want `proto.MessageName should be replaced with the v2 proto.MessageName` `proto.MessageName is deprecated`
+	case "google.protobuf.Timestamp", durationName:
+		return name, true
+	}
+	return proto.MessageName(m), proto.MessageName(m) == want // want `so it is converted to the string required here` `so it is converted to the string required here` `proto.MessageName is deprecated` `proto.MessageName is deprecated`
+}
@@ -0,0 +1,8 @@
+module github.com/protobuf-tools/protomigrate/testdata/src/messagenamev2
+
+go 1.15
+
+require (
+	github.com/golang/protobuf v1.5.4
+	google.golang.org/protobuf v1.33.0
+)
@@ -0,0 +1,10 @@
+package messagenamev2
+
+import (
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/proto`
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func name(m *durationpb.Duration) (string, bool) {
+	return proto.MessageName(m), proto.MessageName(m) == "google.protobuf.Duration" // want `so it is converted to the string required here` `which returns a protoreflect.FullName \(see https://pkg.go.dev/google.golang.org/protobuf/proto\)$` `proto.MessageName is deprecated` `proto.MessageName is deprecated`
+}
@@ -0,0 +1,27 @@
+package pointerequal
+
+import (
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// find compares pointers, as is meant outside of tests.
+func find(ms []*durationpb.Duration, m *durationpb.Duration) int {
+	for i, x := range ms {
+		if x == m {
+			return i
+		}
+	}
+	return -1
+}
+
+func kind(m, short, long *durationpb.Duration) string {
+	switch m {
+	case nil:
+		return "none"
+	case short: // want `the case compares the pointers to the messages, proto.Equal their content`
+		return "short"
+	case long: // want `the case compares the pointers to the messages`
+		return "long"
+	}
+	return "other"
+}
@@ -0,0 +1,25 @@
+package pointerequal
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestFind(t *testing.T) {
+	want := &durationpb.Duration{Seconds: 1}
+	got := find([]*durationpb.Duration{{Seconds: 1}}, want)
+	if got < 0 {
+		t.Fatal("not found")
+	}
+	m := durationpb.New(1)
+	if m == nil {
+		t.Fatal("nil")
+	}
+	if m != want { // want `!= compares the pointers to the messages, proto.Equal their content`
+		t.Errorf("got %v, want %v", m, want)
+	}
+	if ok := m == want; !ok { // want `== compares the pointers to the messages`
+		t.Errorf("got %v, want %v", m, want)
+	}
+}
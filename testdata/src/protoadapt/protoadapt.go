@@ -0,0 +1,32 @@
+package protoadapt
+
+import (
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/protoadapt`
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func size(m proto.Message) int {
+	return protov2.Size(proto.MessageV2(m)) // want `proto.MessageV2 should be replaced with protoadapt.MessageV2Of`
+}
+
+func v1(m protov2.Message) proto.Message {
+	return proto.MessageV1(m) // want `proto.MessageV1 should be replaced with protoadapt.MessageV1Of`
+}
+
+func any(m interface{}) protov2.Message {
+	return proto.MessageV2(m) // want `proto.MessageV2 should be replaced with protoadapt.MessageV2Of, which only takes messages of the API it adapts`
+}
+
+func assert(m proto.Message) int {
+	return protov2.Size(m.(protov2.Message)) // want `the type assertion fails for the messages not generated for the API it asserts, so it should be replaced with protoadapt.MessageV2Of`
+}
+
+func assertOK(m proto.Message) (protov2.Message, bool) {
+	v2, ok := m.(protov2.Message) // want `the type assertion fails for the messages not generated for the API it asserts; protoadapt.MessageV2Of adapts them`
+	return v2, ok
+}
+
+func message() *durationpb.Duration {
+	return durationpb.New(0)
+}
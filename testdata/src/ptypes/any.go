@@ -0,0 +1,35 @@
+package ptypes
+
+import (
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated`
+	"github.com/golang/protobuf/ptypes"          // want `github.com/golang/protobuf/ptypes should be replaced with google.golang.org/protobuf/types/known/anypb`
+	"github.com/golang/protobuf/ptypes/any"      // want `github.com/golang/protobuf/ptypes/any should be replaced with google.golang.org/protobuf/types/known/anypb`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func pack(m *duration.Duration) (*any.Any, error) {
+	return ptypes.MarshalAny(m) // want `ptypes.MarshalAny should be replaced with anypb.New`
+}
+
+func unpack(a *any.Any, m *duration.Duration) error {
+	if !ptypes.Is(a, m) { // want `ptypes.Is should be replaced with the MessageIs method of the Any`
+		return nil
+	}
+	return ptypes.UnmarshalAny(a, m) // want `ptypes.UnmarshalAny should be replaced with the UnmarshalTo method of the Any`
+}
+
+func unpackField(w struct{ any *any.Any }, m *duration.Duration) error {
+	return ptypes.UnmarshalAny(w.any, m) // want `ptypes.UnmarshalAny should be replaced with the UnmarshalTo method of the Any`
+}
+
+func packV1(m proto.Message) (*any.Any, error) {
+	return ptypes.MarshalAny(m) // want `ptypes.MarshalAny should be replaced with anypb.New; the message is only known to implement the v1 API`
+}
+
+func unpackV1(a *any.Any, m proto.Message) error {
+	return ptypes.UnmarshalAny(a, m) // want `ptypes.UnmarshalAny should be replaced with the UnmarshalTo method of the Any; the message is only known to implement the v1 API`
+}
+
+func packOld(m *Old) (*any.Any, error) {
+	return ptypes.MarshalAny(m) // want `ptypes.MarshalAny should be replaced with anypb.New; the message does not implement the v2 API, so regenerate it first: old.pb.go was generated by protoc-gen-go before v1.4`
+}
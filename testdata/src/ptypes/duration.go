@@ -0,0 +1,25 @@
+package ptypes
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/ptypes"          // want `github.com/golang/protobuf/ptypes should be replaced with google.golang.org/protobuf/types/known/durationpb`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func toProto(d time.Duration) *duration.Duration {
+	return ptypes.DurationProto(d) // want `ptypes.DurationProto should be replaced with durationpb.New`
+}
+
+func fromProto(pb *duration.Duration) (time.Duration, error) {
+	d, err := ptypes.Duration(pb) // want `ptypes.Duration should be replaced with the AsDuration method, which saturates durations that overflow time.Duration instead of returning an error`
+	if err != nil {
+		return 0, err
+	}
+	return d, nil
+}
+
+func timeout(pb *duration.Duration) time.Duration {
+	d, _ := ptypes.Duration(pb) // want `ptypes.Duration should be replaced with the AsDuration method`
+	return d
+}
@@ -0,0 +1,31 @@
+package ptypes
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/ptypes"     // want `github.com/golang/protobuf/ptypes should be replaced with google.golang.org/protobuf/proto`
+	"github.com/golang/protobuf/ptypes/any" // want `github.com/golang/protobuf/ptypes/any should be replaced with google.golang.org/protobuf/types/known/anypb`
+)
+
+func describe(a *any.Any) (string, error) {
+	var dyn ptypes.DynamicAny // want `ptypes.DynamicAny should be replaced with the UnmarshalNew method of the Any, which looks the message type up in protoregistry.GlobalTypes`
+	err := ptypes.UnmarshalAny(a, &dyn)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(dyn.Message), nil
+}
+
+func ignoreError(a *any.Any) interface{} {
+	var dyn ptypes.DynamicAny // want `ptypes.DynamicAny should be replaced with the UnmarshalNew method`
+	ptypes.UnmarshalAny(a, &dyn)
+	return dyn.Message
+}
+
+func pointer(a *any.Any) (fmt.Stringer, error) {
+	dyn := new(ptypes.DynamicAny)                       // want `ptypes.DynamicAny should be replaced with the UnmarshalNew method`
+	if err := ptypes.UnmarshalAny(a, dyn); err != nil { // want `ptypes.DynamicAny should be replaced with the UnmarshalNew method`
+		return nil, err
+	}
+	return dyn.Message, nil
+}
@@ -0,0 +1,74 @@
+package ptypes
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"                // want `github.com/golang/protobuf/ptypes should be replaced with google.golang.org/protobuf/types/known/timestamppb`
+	tspb "github.com/golang/protobuf/ptypes/timestamp" // want `github.com/golang/protobuf/ptypes/timestamp should be replaced with google.golang.org/protobuf/types/known/timestamppb`
+)
+
+func now() *tspb.Timestamp {
+	return ptypes.TimestampNow() // want `ptypes.TimestampNow should be replaced with timestamppb.Now`
+}
+
+func ignored(t time.Time) *tspb.Timestamp {
+	ts, _ := ptypes.TimestampProto(t) // want `ptypes.TimestampProto should be replaced with timestamppb.New`
+	return ts
+}
+
+func checked(t time.Time) (*tspb.Timestamp, error) {
+	ts, err := ptypes.TimestampProto(t) // want `ptypes.TimestampProto should be replaced with timestamppb.New`
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+func alwaysValid() *tspb.Timestamp {
+	ts, err := ptypes.TimestampProto(time.Now()) // want `ptypes.TimestampProto should be replaced with timestamppb.New`
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
+
+func consumed(t time.Time) error {
+	ts, err := ptypes.TimestampProto(t) // want `ptypes.TimestampProto should be replaced with timestamppb.New`
+	_ = ts
+	return err
+}
+
+func validate(t time.Time) error {
+	_, err := ptypes.TimestampProto(t) // want `ptypes.TimestampProto should be replaced with timestamppb.New`
+	return err
+}
+
+func returned(t time.Time) (*tspb.Timestamp, error) {
+	return ptypes.TimestampProto(t) // want `ptypes.TimestampProto should be replaced with timestamppb.New`
+}
+
+func toTime(ts *tspb.Timestamp) (time.Time, error) {
+	t, err := ptypes.Timestamp(ts) // want `ptypes.Timestamp should be replaced with the AsTime method`
+	if err != nil {
+		return time.Time{}, errors.New("bad timestamp")
+	}
+	return t, nil
+}
+
+func returnTime(ts *tspb.Timestamp) (time.Time, error) {
+	return ptypes.Timestamp(ts) // want `ptypes.Timestamp should be replaced with the AsTime method`
+}
+
+func named(t time.Time) (ts *tspb.Timestamp, err error) {
+	ts, err = ptypes.TimestampProto(t) // want `ptypes.TimestampProto should be replaced with timestamppb.New`
+	return
+}
+
+func nested(t time.Time) {
+	if ts, err := ptypes.TimestampProto(t); err == nil { // want `ptypes.TimestampProto should be replaced with timestamppb.New`
+		_ = ts
+	}
+}
+
+var fn = ptypes.TimestampProto
@@ -0,0 +1,15 @@
+package register
+
+import (
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/reflect/protoregistry`
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func init() {
+	proto.RegisterType((*Legacy)(nil), "register.Legacy")                                                                                                      // want `proto.RegisterType should be replaced with protoregistry.GlobalTypes.RegisterMessage, which takes the protoreflect.MessageType of the message` `proto.RegisterType is deprecated`
+	proto.RegisterType((*descriptorpb.FileOptions)(nil), "google.protobuf.FileOptions")                                                                        // want `proto.RegisterType should be replaced with protoregistry.GlobalTypes.RegisterMessage; the code generated for the v2 API registers it already, and registering it again panics, so the call should go` `proto.RegisterType is deprecated`
+	proto.RegisterEnum("register.Color", Color_name, Color_value)                                                                                              // want `proto.RegisterEnum should be replaced with protoregistry.GlobalTypes.RegisterEnum, which takes the protoreflect.EnumType of the enum rather than its maps \(see https://pkg.go.dev/google.golang.org/protobuf/proto\)$` `proto.RegisterEnum is deprecated`
+	proto.RegisterEnum("google.protobuf.FieldDescriptorProto.Type", descriptorpb.FieldDescriptorProto_Type_name, descriptorpb.FieldDescriptorProto_Type_value) // want `the code generated for the v2 API registers it already` `proto.RegisterEnum is deprecated`
+	proto.RegisterExtension(E_Owner)                                                                                                                           // want `proto.RegisterExtension should be replaced with protoregistry.GlobalTypes.RegisterExtension, which returns an error` `proto.RegisterExtension is deprecated`
+	proto.RegisterMapType((map[string]string)(nil), "register.Entry")                                                                                          // want `proto.RegisterMapType has no v2 counterpart; it only made the map type known to proto.MessageType, so the call should go` `proto.RegisterMapType is deprecated`
+}
@@ -0,0 +1,8 @@
+module github.com/protobuf-tools/protomigrate/testdata/src/registry
+
+go 1.15
+
+require (
+	github.com/golang/protobuf v1.4.3
+	google.golang.org/protobuf v1.23.0
+)
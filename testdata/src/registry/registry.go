@@ -0,0 +1,7 @@
+package registry
+
+import (
+	"google.golang.org/protobuf/types/known/durationpb" // want `registry and google.golang.org/protobuf/types/known/durationpb both register the .proto file google/protobuf/duration.proto and the type google.protobuf.Duration, which the v2 runtime panics on at init; they are linked in through registry and registry -> google.golang.org/protobuf/types/known/durationpb`
+)
+
+var _ = durationpb.Duration{}
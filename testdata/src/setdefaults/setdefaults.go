@@ -0,0 +1,10 @@
+package setdefaults
+
+import (
+	"github.com/golang/protobuf/proto" // want `package github.com/golang/protobuf/proto is deprecated`
+)
+
+func populate(m proto.Message) proto.Message {
+	proto.SetDefaults(m) // want `proto.SetDefaults has no v2 counterpart; the getters of unset proto2 fields return their defaults, and proto3 fields have none but the zero values`
+	return m
+}
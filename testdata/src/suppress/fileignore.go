@@ -0,0 +1,12 @@
+//lint:file-ignore PM1001,PM3001 the text is compared in tests
+
+package suppress
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func text(m *duration.Duration) string {
+	return proto.MarshalTextString(m)
+}
@@ -0,0 +1,10 @@
+package suppress
+
+import (
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/encoding/prototext`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func format(m *duration.Duration) string {
+	return proto.MarshalTextString(m) // want `proto.MarshalTextString should be replaced with prototext.Format`
+}
@@ -0,0 +1,28 @@
+package suppress
+
+import (
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/encoding/prototext`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+//lint:ignore PM3001 the text is compared in tests
+func golden(m *duration.Duration) string {
+	s := proto.MarshalTextString(m)
+	return s + proto.CompactTextString(m)
+}
+
+func statement(m *duration.Duration) string {
+	//lint:ignore proto the text is compared in tests
+	s := proto.MarshalTextString(m)
+	return s + proto.CompactTextString(m) // want `proto.CompactTextString should be replaced with prototext.MarshalOptions\{\}.Format`
+}
+
+func otherRule(m *duration.Duration) string {
+	//lint:ignore PM8001 the text is compared in tests
+	return proto.MarshalTextString(m) // want `proto.MarshalTextString should be replaced with prototext.Format`
+}
+
+func noReason(m *duration.Duration) string {
+	//lint:ignore PM3001
+	return proto.MarshalTextString(m) // want `proto.MarshalTextString should be replaced with prototext.Format`
+}
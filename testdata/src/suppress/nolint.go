@@ -0,0 +1,22 @@
+package suppress
+
+import (
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/encoding/prototext`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func compare(m *duration.Duration) string {
+	return proto.CompactTextString(m) //nolint:protomigrate
+}
+
+func compareAll(m *duration.Duration) string {
+	return proto.CompactTextString(m) //nolint
+}
+
+func compareOthers(m *duration.Duration) string {
+	return proto.CompactTextString(m) //nolint:errcheck,staticcheck // want `proto.CompactTextString should be replaced with prototext.MarshalOptions\{\}.Format`
+}
+
+func compareList(m *duration.Duration) string {
+	return proto.CompactTextString(m) //nolint:errcheck,protomigrate // the text is compared in tests
+}
@@ -0,0 +1,27 @@
+package suppress
+
+import (
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/encoding/prototext`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+//protomigrate:ignore=PM3001
+func frozen(m *duration.Duration) string {
+	s := proto.MarshalTextString(m)
+	return s + proto.CompactTextString(m)
+}
+
+func frozenStatement(m *duration.Duration) string {
+	//protomigrate:ignore=proto,deep-equal
+	s := proto.MarshalTextString(m)
+	return s + proto.CompactTextString(m) // want `proto.CompactTextString should be replaced with prototext.MarshalOptions\{\}.Format`
+}
+
+func frozenLine(m *duration.Duration) string {
+	return proto.CompactTextString(m) //protomigrate:ignore
+}
+
+func unknownRule(m *duration.Duration) string {
+	//protomigrate:ignore=PM9999
+	return proto.MarshalTextString(m) // want `proto.MarshalTextString should be replaced with prototext.Format`
+}
@@ -0,0 +1,12 @@
+//protomigrate:ignore=PM1001,PM3001
+
+package suppress
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func frozenFile(m *duration.Duration) string {
+	return proto.CompactTextString(m)
+}
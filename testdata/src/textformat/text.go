@@ -0,0 +1,46 @@
+package textformat
+
+import (
+	"io"
+
+	"github.com/golang/protobuf/proto"           // want `package github.com/golang/protobuf/proto is deprecated` `github.com/golang/protobuf/proto should be replaced with google.golang.org/protobuf/encoding/prototext`
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+func format(m *duration.Duration) string {
+	return proto.MarshalTextString(m) // want `proto.MarshalTextString should be replaced with prototext.Format`
+}
+
+func compact(m *duration.Duration) string {
+	return proto.CompactTextString(m) // want `proto.CompactTextString should be replaced with prototext.MarshalOptions\{\}.Format`
+}
+
+func parse(s string, m *duration.Duration) error {
+	return proto.UnmarshalText(s, m) // want `proto.UnmarshalText should be replaced with prototext.Unmarshal`
+}
+
+func write(w io.Writer, m *duration.Duration) error {
+	err := proto.MarshalText(w, m) // want `proto.MarshalText writes to an io.Writer`
+	return err
+}
+
+func writeCompact(w io.Writer, m *duration.Duration) {
+	proto.CompactText(w, m) // want `proto.CompactText writes to an io.Writer`
+}
+
+// writeUncommented has no comment after the statement, so the fix inserts
+// its condition and body at the same offset.
+func writeUncommented(w io.Writer, m *duration.Duration) {
+	/* want `proto.CompactText writes to an io.Writer` */ proto.CompactText(w, m)
+}
+
+func unsupported(w io.Writer, m *duration.Duration) error {
+	if err := proto.MarshalText(w, m); err != nil { // want `proto.MarshalText writes to an io.Writer`
+		return err
+	}
+	return nil
+}
+
+func v1(m proto.Message) string {
+	return proto.MarshalTextString(m) // want `proto.MarshalTextString should be replaced with prototext.Format; the message is only known to implement the v1 API`
+}
@@ -0,0 +1,11 @@
+package wkt
+
+import (
+	"github.com/golang/protobuf/ptypes/duration" // want `github.com/golang/protobuf/ptypes/duration should be replaced with google.golang.org/protobuf/types/known/durationpb`
+)
+
+type durationpb struct{}
+
+func zero() *duration.Duration {
+	return new(duration.Duration)
+}
@@ -0,0 +1,11 @@
+package wkt
+
+import (
+	"github.com/golang/protobuf/ptypes/timestamp" // want `github.com/golang/protobuf/ptypes/timestamp should be replaced with google.golang.org/protobuf/types/known/timestamppb`
+)
+
+var file = timestamp.File_github_com_golang_protobuf_ptypes_timestamp_timestamp_proto
+
+func epoch() *timestamp.Timestamp {
+	return &timestamp.Timestamp{}
+}
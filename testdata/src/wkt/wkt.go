@@ -0,0 +1,19 @@
+package wkt
+
+import (
+	"github.com/golang/protobuf/ptypes/empty"           // want `github.com/golang/protobuf/ptypes/empty should be replaced with google.golang.org/protobuf/types/known/emptypb`
+	structpb "github.com/golang/protobuf/ptypes/struct" // want `github.com/golang/protobuf/ptypes/struct should be replaced with google.golang.org/protobuf/types/known/structpb`
+	wpb "github.com/golang/protobuf/ptypes/wrappers"    // want `github.com/golang/protobuf/ptypes/wrappers should be replaced with google.golang.org/protobuf/types/known/wrapperspb`
+)
+
+func ping(*empty.Empty) (*empty.Empty, error) {
+	return &empty.Empty{}, nil
+}
+
+func null() *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_NullValue{NullValue: structpb.NullValue_NULL_VALUE}}
+}
+
+func wrap(s string) *wpb.StringValue {
+	return &wpb.StringValue{Value: s}
+}
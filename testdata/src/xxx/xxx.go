@@ -0,0 +1,13 @@
+package xxx
+
+type notMessage struct {
+	XXX_unrecognized []byte
+}
+
+func use(m *Old, n notMessage) int {
+	m.XXX_unrecognized = nil              // want `the field XXX_unrecognized of messages is not generated for the v2 API; use the GetUnknown and SetUnknown methods of protoreflect.Message instead`
+	m.XXX_DiscardUnknown()                // want `the method XXX_DiscardUnknown of messages is not generated for the v2 API; use proto.DiscardUnknown instead`
+	o := Old{Name: "o", XXX_sizecache: 1} // want `the field XXX_sizecache of messages is not generated for the v2 API; use proto.Size instead`
+	_ = n.XXX_unrecognized
+	return m.XXX_Size() + len(o.Name) // want `the method XXX_Size of messages`
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"honnef.co/go/tools/analysis/edit"
+	"honnef.co/go/tools/analysis/report"
+)
+
+const prototextPath = "google.golang.org/protobuf/encoding/prototext"
+
+func rewriteMarshalTextString(c *funcCall) bool {
+	return c.rewriteText("proto.MarshalTextString should be replaced with prototext.Format", "Format")
+}
+
+func rewriteCompactTextString(c *funcCall) bool {
+	return c.rewriteText("proto.CompactTextString should be replaced with prototext.MarshalOptions{}.Format", "MarshalOptions{}.Format")
+}
+
+func rewriteMarshalText(c *funcCall) bool {
+	return c.rewriteTextWriter("proto.MarshalText writes to an io.Writer, prototext.MarshalOptions.Marshal returns the encoded message instead", "MarshalOptions{Multiline: true}.Marshal")
+}
+
+func rewriteCompactText(c *funcCall) bool {
+	return c.rewriteTextWriter("proto.CompactText writes to an io.Writer, prototext.Marshal returns the encoded message instead", "Marshal")
+}
+
+func rewriteUnmarshalText(c *funcCall) bool {
+	const msg = "proto.UnmarshalText should be replaced with prototext.Unmarshal"
+	if len(c.call.Args) != 2 {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	if t := c.pass.TypesInfo.TypeOf(c.call.Args[1]); !hasProtoReflect(t) {
+		report.Report(c.pass, c.call, msg+v1MessageNote(c.pass, t))
+		return false
+	}
+	c.rw.require(c.pass, prototextPath)
+	// prototext.Unmarshal takes the encoded message as bytes rather than
+	// a string.
+	s := c.call.Args[0]
+	fix := edit.Fix("Use prototext.Unmarshal",
+		edit.ReplaceWithString(c.pass.Fset, c.sel, c.rw.qualifier(c.pass, prototextPath)+".Unmarshal"),
+		insert(s.Pos(), "[]byte("),
+		insert(s.End(), ")"))
+	report.Report(c.pass, c.call, msg, report.Fixes(fix))
+	return true
+}
+
+// rewriteText replaces the function called, which must take only the
+// message, with the prototext function fn.
+func (c *funcCall) rewriteText(msg, fn string) bool {
+	if len(c.call.Args) != 1 {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	if t := c.pass.TypesInfo.TypeOf(c.call.Args[0]); !hasProtoReflect(t) {
+		report.Report(c.pass, c.call, msg+v1MessageNote(c.pass, t))
+		return false
+	}
+	c.rw.require(c.pass, prototextPath)
+	repl := c.rw.qualifier(c.pass, prototextPath) + "." + fn
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Use prototext."+fn, edit.ReplaceWithString(c.pass.Fset, c.sel, repl))))
+	return true
+}
+
+// rewriteTextWriter replaces the function called, which must write the
+// message to an io.Writer, with the prototext function fn, which returns
+// the encoded message instead, followed by a write of the result.
+func (c *funcCall) rewriteTextWriter(msg, fn string) bool {
+	stmt, ok := writerStmt(c.path)
+	if !ok || len(c.call.Args) != 2 || !isSimpleExpr(c.call.Args[0]) {
+		report.Report(c.pass, c.call, msg)
+		return false
+	}
+	if t := c.pass.TypesInfo.TypeOf(c.call.Args[1]); !hasProtoReflect(t) {
+		report.Report(c.pass, c.call, msg+v1MessageNote(c.pass, t))
+		return false
+	}
+	c.rw.require(c.pass, prototextPath)
+	repl := c.rw.qualifier(c.pass, prototextPath) + "." + fn
+	edits := append(marshalAndWrite(c.pass, stmt, c.call), edit.ReplaceWithString(c.pass.Fset, c.sel, repl))
+	report.Report(c.pass, c.call, msg, report.Fixes(edit.Fix("Marshal the message and write it", edits...)))
+	return true
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// todoFix returns the fix inserting a TODO comment with the message of d
+// on the line before the statement, declaration or field d reports on, so
+// that the migration left to do by hand is tracked in the code:
+//
+//	// TODO(protomigrate PM3001): proto.NewBuffer has no v2 counterpart; ...
+//	b := proto.NewBuffer(nil)
+//
+// It returns nil if the line holds a TODO of the rule already, or if done
+// holds its position, which the returned fix adds to, since distinct
+// insertions at the same position conflict.
+func todoFix(pass *analysis.Pass, d analysis.Diagnostic, done map[token.Pos]bool) []analysis.SuggestedFix {
+	file := fileOf(pass, d.Pos)
+	if file == nil {
+		return nil
+	}
+	path, _ := astutil.PathEnclosingInterval(file, d.Pos, d.Pos)
+	var node ast.Node
+	for _, n := range path {
+		switch n.(type) {
+		case *ast.BlockStmt:
+		case ast.Stmt, ast.Decl, ast.Spec, *ast.Field:
+			node = n
+		}
+		if node != nil {
+			break
+		}
+	}
+	if node == nil {
+		return nil
+	}
+	tf := pass.Fset.File(node.Pos())
+	line := tf.Line(node.Pos())
+	pos := tf.LineStart(line)
+	if done[pos] {
+		return nil
+	}
+	tag := "// TODO(protomigrate " + d.Category + "):"
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			if tf.Line(c.End()) == line-1 && strings.HasPrefix(c.Text, tag) {
+				return nil
+			}
+		}
+	}
+	done[pos] = true
+	text := indentation(pass, node.Pos()) + tag + " " + d.Message + "\n"
+	return []analysis.SuggestedFix{{
+		Message:   "Add a TODO comment",
+		TextEdits: []analysis.TextEdit{insert(pos, text)},
+	}}
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/edit"
+)
+
+const (
+	anypbPath       = "google.golang.org/protobuf/types/known/anypb"
+	durationpbPath  = "google.golang.org/protobuf/types/known/durationpb"
+	emptypbPath     = "google.golang.org/protobuf/types/known/emptypb"
+	structpbPath    = "google.golang.org/protobuf/types/known/structpb"
+	timestamppbPath = "google.golang.org/protobuf/types/known/timestamppb"
+	wrapperspbPath  = "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// wktPaths maps the v1 packages of the well-known types to the v2 packages
+// their declarations are aliases of.
+var wktPaths = map[string]string{
+	"github.com/golang/protobuf/ptypes/any":       anypbPath,
+	"github.com/golang/protobuf/ptypes/duration":  durationpbPath,
+	"github.com/golang/protobuf/ptypes/empty":     emptypbPath,
+	"github.com/golang/protobuf/ptypes/struct":    structpbPath,
+	"github.com/golang/protobuf/ptypes/timestamp": timestamppbPath,
+	"github.com/golang/protobuf/ptypes/wrappers":  wrapperspbPath,
+}
+
+// checkWKT rewrites the imports of the v1 well-known type packages, and
+// every reference through them, to the v2 packages.
+func checkWKT(pass *analysis.Pass) (interface{}, error) {
+	forEachFile(pass, func(pass *analysis.Pass, file *ast.File) {
+		for _, spec := range file.Imports {
+			if newPath, ok := wktPaths[importPath(spec)]; ok {
+				checkWKTImport(pass, file, spec, newPath)
+			}
+		}
+	})
+	return nil, nil
+}
+
+// checkWKTImport rewrites the references through spec, an import of a v1
+// well-known type package, to newPath, and the import itself. Since the
+// declarations of the v1 packages are aliases, the references are only
+// fixed along with the import rather than reported one by one.
+func checkWKTImport(pass *analysis.Pass, file *ast.File, spec *ast.ImportSpec, newPath string) {
+	pkg := importedPkgName(pass, spec)
+	if pkg == nil {
+		return
+	}
+	v2 := importedPackage(pkg.Imported(), newPath)
+
+	rw := newImportRewrite(file, spec)
+	refs := qualifiedRefs(pass, file, pkg)
+	for _, sel := range refs {
+		// The v1 packages also declare the descriptors of their own
+		// .proto files, which have no v2 counterpart.
+		if v2 == nil || v2.Scope().Lookup(sel.Sel.Name) == nil {
+			rw.unfixed++
+			rw.kept = true
+		}
+	}
+	for _, sel := range refs {
+		if v2 == nil || v2.Scope().Lookup(sel.Sel.Name) == nil {
+			continue
+		}
+		rw.fixed++
+		rw.require(pass, newPath)
+		rw.refs = append(rw.refs, edit.ReplaceWithString(pass.Fset, sel.X, rw.qualifier(pass, newPath)))
+	}
+	rw.report(pass)
+}
+
+// importedPackage returns the package imported by pkg whose path is path,
+// or nil.
+func importedPackage(pkg *types.Package, path string) *types.Package {
+	for _, imp := range pkg.Imports() {
+		if pkgPath(imp) == path {
+			return imp
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 The protobuf-tools Authors.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package protomigrate
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"honnef.co/go/tools/analysis/report"
+
+	"github.com/protobuf-tools/protomigrate/facts"
+)
+
+// xxxReplacements maps the XXX_ fields and methods of the messages
+// generated for the v1 API to what replaces them.
+var xxxReplacements = map[string]string{
+	"XXX_unrecognized":       "the GetUnknown and SetUnknown methods of protoreflect.Message",
+	"XXX_sizecache":          "proto.Size",
+	"XXX_NoUnkeyedLiteral":   "keyed composite literals",
+	"XXX_InternalExtensions": "proto.GetExtension, proto.SetExtension, proto.HasExtension and proto.ClearExtension",
+	"XXX_extensions":         "proto.GetExtension, proto.SetExtension, proto.HasExtension and proto.ClearExtension",
+	"XXX_Marshal":            "proto.Marshal or proto.MarshalOptions",
+	"XXX_Unmarshal":          "proto.Unmarshal or proto.UnmarshalOptions",
+	"XXX_Size":               "proto.Size",
+	"XXX_Merge":              "proto.Merge",
+	"XXX_DiscardUnknown":     "proto.DiscardUnknown",
+	"XXX_OneofWrappers":      "the oneof descriptors of protoreflect",
+	"XXX_OneofFuncs":         "the oneof descriptors of protoreflect",
+	"XXX_WellKnownType":      "the full name of the message descriptor of protoreflect",
+}
+
+// checkXXX reports the uses of the XXX_ fields and methods of messages,
+// which the code generated for the v2 API has none of, in selectors and in
+// the keys of composite literals.
+func checkXXX(pass *analysis.Pass) (interface{}, error) {
+	nodeFilter := []ast.Node{
+		(*ast.SelectorExpr)(nil),
+		(*ast.CompositeLit)(nil),
+	}
+	preorderMigrated(pass, nodeFilter, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			sel, ok := pass.TypesInfo.Selections[node]
+			if !ok || !strings.HasPrefix(node.Sel.Name, "XXX_") || !isMessageMember(sel.Recv()) {
+				return
+			}
+			reportXXX(pass, node.Sel, sel.Obj())
+		case *ast.CompositeLit:
+			t := pass.TypesInfo.TypeOf(node)
+			if t == nil || !isMessageMember(t) {
+				return
+			}
+			for _, elt := range node.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if key, ok := kv.Key.(*ast.Ident); ok && strings.HasPrefix(key.Name, "XXX_") {
+					reportXXX(pass, key, pass.TypesInfo.ObjectOf(key))
+				}
+			}
+		}
+	})
+	return nil, nil
+}
+
+func reportXXX(pass *analysis.Pass, id *ast.Ident, obj types.Object) {
+	kind := "field"
+	if _, ok := obj.(*types.Func); ok {
+		kind = "method"
+	}
+	msg := "the " + kind + " " + id.Name + " of messages is not generated for the v2 API"
+	if repl, ok := xxxReplacements[id.Name]; ok {
+		msg += "; use " + repl + " instead"
+	} else {
+		msg += "; use the protoreflect API of proto.Message instead"
+	}
+	report.Report(pass, id, msg)
+}
+
+// isMessageMember reports whether t, the type a member is selected from, is
+// a message struct or a pointer to one.
+func isMessageMember(t types.Type) bool {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	_, ok := facts.ClassifyMessage(t)
+	return ok
+}